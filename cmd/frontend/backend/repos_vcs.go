@@ -64,7 +64,13 @@ func GitRepo(ctx context.Context, repo *types.Repo) (gitserver.Repo, error) {
 	if result.Repo == nil {
 		return gitserver.Repo{Name: repo.Name}, repoupdater.ErrNotFound
 	}
-	return gitserver.Repo{Name: result.Repo.Name, URL: result.Repo.VCS.URL}, nil
+	return gitserver.Repo{
+		Name:               result.Repo.Name,
+		URL:                result.Repo.VCS.URL,
+		PartialCloneFilter: result.Repo.VCS.PartialCloneFilter,
+		MaxRepoSize:        result.Repo.VCS.MaxRepoSize,
+		LFSMaxFileSize:     result.Repo.VCS.LFSMaxFileSize,
+	}, nil
 }
 
 func quickGitserverRepo(ctx context.Context, repo api.RepoName, serviceType string) (*gitserver.Repo, error) {
@@ -73,28 +79,31 @@ func quickGitserverRepo(ctx context.Context, repo api.RepoName, serviceType stri
 	// (especially on Sourcegraph.com), which reduces rate limit pressure significantly.
 	//
 	// This fails for private repositories, which require authentication in the URL userinfo.
+	// It also fails for repositories whose connection sets clone options (partial clones, a
+	// maximum repo size or LFS), which only RepoLookup resolves.
 
 	lowerRepo := strings.ToLower(string(repo))
-	var hasToken func(context.Context) (bool, error)
+	var inspectConns func(context.Context) (hasToken, hasCloneOptions bool, err error)
 	switch {
 	case serviceType == github.ServiceType && strings.HasPrefix(lowerRepo, "github.com/"):
-		hasToken = hasGitHubDotComToken
+		inspectConns = inspectGitHubDotComConnections
 	case serviceType == gitlab.ServiceType && strings.HasPrefix(lowerRepo, "gitlab.com/"):
-		hasToken = hasGitLabDotComToken
+		inspectConns = inspectGitLabDotComConnections
 	default:
 		return nil, nil
 	}
 
-	r := &gitserver.Repo{Name: repo, URL: "https://" + string(repo) + ".git"}
-	if envvar.SourcegraphDotComMode() {
-		return r, nil
-	}
-
-	ok, err := hasToken(ctx)
+	hasToken, hasCloneOptions, err := inspectConns(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
+	if hasCloneOptions {
+		// Fall back to performing full RepoLookup, which resolves the clone options.
+		return nil, nil
+	}
+
+	r := &gitserver.Repo{Name: repo, URL: "https://" + string(repo) + ".git"}
+	if envvar.SourcegraphDotComMode() || !hasToken {
 		return r, nil
 	}
 
@@ -102,12 +111,12 @@ func quickGitserverRepo(ctx context.Context, repo api.RepoName, serviceType stri
 	return nil, nil
 }
 
-// hasGitHubDotComToken reports whether there are any personal access tokens configured for
-// github.com.
-func hasGitHubDotComToken(ctx context.Context) (bool, error) {
+// inspectGitHubDotComConnections reports whether there are any personal access tokens
+// configured for github.com, and whether any github.com connection sets clone options.
+func inspectGitHubDotComConnections(ctx context.Context) (hasToken, hasCloneOptions bool, err error) {
 	conns, err := db.ExternalServices.ListGitHubConnections(ctx)
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
 	for _, c := range conns {
 		u, err := url.Parse(c.Url)
@@ -115,31 +124,42 @@ func hasGitHubDotComToken(ctx context.Context) (bool, error) {
 			continue
 		}
 		hostname := strings.ToLower(u.Hostname())
-		if (hostname == "github.com" || hostname == "api.github.com") && c.Token != "" {
-			return true, nil
+		if hostname != "github.com" && hostname != "api.github.com" {
+			continue
+		}
+		if c.Token != "" {
+			hasToken = true
+		}
+		if c.PartialCloneFilter != "" || c.MaxRepoSizeMB > 0 || c.FetchLFS {
+			hasCloneOptions = true
 		}
 	}
-	return false, nil
+	return hasToken, hasCloneOptions, nil
 }
 
-// hasGitLabDotComToken reports whether there are any personal access tokens configured for
-// github.com.
-func hasGitLabDotComToken(ctx context.Context) (bool, error) {
+// inspectGitLabDotComConnections reports whether there are any personal access tokens
+// configured for gitlab.com, and whether any gitlab.com connection sets clone options.
+func inspectGitLabDotComConnections(ctx context.Context) (hasToken, hasCloneOptions bool, err error) {
 	conns, err := db.ExternalServices.ListGitLabConnections(ctx)
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
 	for _, c := range conns {
 		u, err := url.Parse(c.Url)
 		if err != nil {
 			continue
 		}
-		hostname := strings.ToLower(u.Hostname())
-		if hostname == "gitlab.com" && c.Token != "" {
-			return true, nil
+		if strings.ToLower(u.Hostname()) != "gitlab.com" {
+			continue
+		}
+		if c.Token != "" {
+			hasToken = true
+		}
+		if c.PartialCloneFilter != "" || c.MaxRepoSizeMB > 0 || c.FetchLFS {
+			hasCloneOptions = true
 		}
 	}
-	return false, nil
+	return hasToken, hasCloneOptions, nil
 }
 
 // ResolveRev will return the absolute commit for a commit-ish spec in a repo.
@@ -6,8 +6,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
 	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
@@ -203,3 +206,48 @@ func TestRepos_GetCommit_repoupdaterError(t *testing.T) {
 		t.Errorf("got commit %q, want %q", commit.ID, want)
 	}
 }
+
+func TestQuickGitserverRepo(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		want   *gitserver.Repo
+	}{
+		"no connection": {
+			want: &gitserver.Repo{Name: "github.com/foo/bar", URL: "https://github.com/foo/bar.git"},
+		},
+		"token": {
+			config: `{"url": "https://github.com", "token": "t"}`,
+		},
+		"partial clone filter": {
+			config: `{"url": "https://github.com", "partialCloneFilter": "blob:none"}`,
+		},
+		"max repo size": {
+			config: `{"url": "https://github.com", "maxRepoSizeMB": 100}`,
+		},
+		"fetch LFS": {
+			config: `{"url": "https://github.com", "fetchLFS": true}`,
+		},
+		"other host": {
+			config: `{"url": "https://github.example.com", "token": "t", "partialCloneFilter": "blob:none"}`,
+			want:   &gitserver.Repo{Name: "github.com/foo/bar", URL: "https://github.com/foo/bar.git"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := testContext()
+			db.Mocks.ExternalServices.List = func(opt db.ExternalServicesListOptions) ([]*types.ExternalService, error) {
+				if test.config == "" {
+					return nil, nil
+				}
+				return []*types.ExternalService{{Kind: "GITHUB", Config: test.config}}, nil
+			}
+			got, err := quickGitserverRepo(ctx, "github.com/foo/bar", github.ServiceType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -20,3 +20,15 @@ func (symbols) ListTags(ctx context.Context, args protocol.SearchArgs) ([]protoc
 	}
 	return result.Symbols, err
 }
+
+// Definitions returns the candidate definitions of the identifier at a
+// position, based on the symbols in the repository.
+func (symbols) Definitions(ctx context.Context, args protocol.PositionArgs) (*protocol.DefinitionsResult, error) {
+	return symbolsclient.DefaultClient.Definitions(ctx, args)
+}
+
+// References returns the candidate references to the identifier at a
+// position, based on a text search of the repository.
+func (symbols) References(ctx context.Context, args protocol.PositionArgs) (*protocol.ReferencesResult, error) {
+	return symbolsclient.DefaultClient.References(ctx, args)
+}
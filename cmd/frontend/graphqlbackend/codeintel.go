@@ -0,0 +1,79 @@
+package graphqlbackend
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/go-langserver/pkg/lsp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gituri"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+type positionArgs struct {
+	Line      int32
+	Character int32
+	First     *int32
+}
+
+func (r *gitTreeEntryResolver) positionArgs(args *positionArgs) protocol.PositionArgs {
+	return protocol.PositionArgs{
+		Repo:      r.commit.repo.repo.Name,
+		CommitID:  api.CommitID(r.commit.oid),
+		Path:      r.path,
+		Line:      int(args.Line),
+		Character: int(args.Character),
+		First:     limitOrDefault(args.First),
+	}
+}
+
+// Definitions returns the candidate definitions of the identifier at a
+// position in the blob, using the symbols service.
+func (r *gitTreeEntryResolver) Definitions(ctx context.Context, args *positionArgs) ([]*locationResolver, error) {
+	ctx, done := context.WithTimeout(ctx, 5*time.Second)
+	defer done()
+
+	result, err := backend.Symbols.Definitions(ctx, r.positionArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	baseURI, err := gituri.Parse("git://" + string(r.commit.repo.repo.Name) + "?" + string(r.commit.oid))
+	if err != nil {
+		return nil, err
+	}
+	locations := make([]*locationResolver, 0, len(result.Symbols))
+	for _, symbol := range result.Symbols {
+		locations = append(locations, toSymbolResolver(symbol, baseURI, strings.ToLower(symbol.Language), r.commit).location)
+	}
+	return locations, nil
+}
+
+// References returns the candidate references to the identifier at a
+// position in the blob, using the symbols service.
+func (r *gitTreeEntryResolver) References(ctx context.Context, args *positionArgs) ([]*locationResolver, error) {
+	ctx, done := context.WithTimeout(ctx, 10*time.Second)
+	defer done()
+
+	result, err := backend.Symbols.References(ctx, r.positionArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	length := len([]rune(result.Identifier))
+	locations := make([]*locationResolver, 0, len(result.References))
+	for _, ref := range result.References {
+		locations = append(locations, &locationResolver{
+			resource: &gitTreeEntryResolver{
+				commit: r.commit,
+				path:   ref.Path,
+				stat:   createFileInfo(ref.Path, false),
+			},
+			lspRange: &lsp.Range{
+				Start: lsp.Position{Line: ref.Line, Character: ref.Character},
+				End:   lsp.Position{Line: ref.Line, Character: ref.Character + length},
+			},
+		})
+	}
+	return locations, nil
+}
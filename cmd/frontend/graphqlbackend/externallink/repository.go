@@ -15,6 +15,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/mercurial"
 	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
 	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
@@ -41,9 +42,19 @@ func Repository(ctx context.Context, repo *types.Repo) (links []*Resolver, err e
 
 // FileOrDir returns the external links for a file or directory in a repository.
 func FileOrDir(ctx context.Context, repo *types.Repo, rev, path string, isDir bool) (links []*Resolver, err error) {
-	rev = url.PathEscape(rev)
-
 	phabRepo, link, serviceType := linksForRepository(ctx, repo)
+
+	linkRev := rev
+	if link != nil && serviceType == mercurial.ServiceType {
+		// hgweb only knows the Mercurial changesets, not the commits they
+		// were converted to.
+		linkRev = hgChangeset(ctx, repo, rev)
+		if linkRev == "" {
+			link = nil
+		}
+	}
+	rev, linkRev = url.PathEscape(rev), url.PathEscape(linkRev)
+
 	if phabRepo != nil {
 		// We need a branch name to construct the Phabricator URL.
 		cachedRepo, err := backend.CachedGitRepo(ctx, repo)
@@ -68,7 +79,7 @@ func FileOrDir(ctx context.Context, repo *types.Repo, rev, path string, isDir bo
 			url = link.Blob
 		}
 		if url != "" {
-			url = strings.NewReplacer("{rev}", rev, "{path}", path).Replace(url)
+			url = strings.NewReplacer("{rev}", linkRev, "{path}", path).Replace(url)
 			links = append(links, &Resolver{url: url, serviceType: serviceType})
 		}
 	}
@@ -88,6 +99,13 @@ func Commit(ctx context.Context, repo *types.Repo, commitID api.CommitID) (links
 		})
 	}
 
+	if link != nil && serviceType == mercurial.ServiceType {
+		commitStr = url.PathEscape(hgChangeset(ctx, repo, string(commitID)))
+		if commitStr == "" {
+			link = nil
+		}
+	}
+
 	if link != nil && link.Commit != "" {
 		links = append(links, &Resolver{
 			url:         strings.Replace(link.Commit, "{commit}", commitStr, -1),
@@ -139,6 +157,27 @@ func linksForRepository(ctx context.Context, repo *types.Repo) (phabRepo *types.
 	return phabRepo, link, serviceType
 }
 
+// hgChangeset returns the Mercurial changeset that the revision of a repository
+// mirrored from Mercurial was converted from, or "" if it has none or if it
+// can't be determined.
+func hgChangeset(ctx context.Context, repo *types.Repo, rev string) string {
+	cachedRepo, err := backend.CachedGitRepo(ctx, repo)
+	if err != nil {
+		log15.Warn("hgChangeset failed to get repo", "repo", repo.Name, "error", err)
+		return ""
+	}
+	commitID, err := git.ResolveRevision(ctx, *cachedRepo, nil, rev, nil)
+	if err != nil {
+		return ""
+	}
+	changeset, err := git.HgChangeset(ctx, *cachedRepo, commitID)
+	if err != nil {
+		log15.Warn("hgChangeset failed to look up changeset", "repo", repo.Name, "commit", commitID, "error", err)
+		return ""
+	}
+	return changeset
+}
+
 var linksForRepositoryFailed = prometheus.NewCounter(prometheus.CounterOpts{
 	Namespace: "src",
 	Subsystem: "graphql",
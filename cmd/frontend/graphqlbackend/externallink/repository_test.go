@@ -243,6 +243,53 @@ func TestFileOrDir(t *testing.T) {
 		}
 	})
 
+	t.Run("mercurial", func(t *testing.T) {
+		resetMocks()
+		externalRepoSpec := api.ExternalRepoSpec{
+			ID:          "myrepo",
+			ServiceType: "mercurial",
+		}
+		repoupdater.MockRepoLookup = func(args protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error) {
+			return &protocol.RepoLookupResult{
+				Repo: &protocol.RepoInfo{
+					Links:        &protocol.RepoLinks{Blob: "http://hg.example.com/myrepo/file/{rev}/{path}"},
+					ExternalRepo: args.ExternalRepo,
+				},
+			}, nil
+		}
+		db.Mocks.Phabricator.GetByName = func(repo api.RepoName) (*types.PhabricatorRepo, error) {
+			return nil, errors.New("x")
+		}
+		git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+			if spec != rev {
+				t.Errorf("got rev %q, want %q", spec, rev)
+			}
+			return "mycommit", nil
+		}
+		git.Mocks.HgChangeset = func(commit api.CommitID) (string, error) {
+			if commit != "mycommit" {
+				return "", nil
+			}
+			return "mychangeset", nil
+		}
+		defer git.ResetMocks()
+		links, err := FileOrDir(context.Background(), &types.Repo{
+			Name:         "myrepo",
+			ExternalRepo: &externalRepoSpec,
+		}, rev, path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []*Resolver{
+			{
+				url:         "http://hg.example.com/myrepo/file/mychangeset/mydir/myfile",
+				serviceType: "mercurial",
+			},
+		}; !reflect.DeepEqual(links, want) {
+			t.Errorf("got %+v, want %+v", links, want)
+		}
+	})
+
 	t.Run("errors", func(t *testing.T) {
 		resetMocks()
 		repoupdater.MockRepoLookup = func(protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error) {
@@ -356,6 +403,56 @@ func TestCommit(t *testing.T) {
 		}
 	})
 
+	t.Run("mercurial", func(t *testing.T) {
+		externalRepoSpec := api.ExternalRepoSpec{
+			ID:          "myrepo",
+			ServiceType: "mercurial",
+		}
+		for changeset, want := range map[string][]*Resolver{
+			"mychangeset": {
+				{
+					url:         "http://hg.example.com/myrepo/rev/mychangeset",
+					serviceType: "mercurial",
+				},
+			},
+			// Commits without a changeset are not linked.
+			"": nil,
+		} {
+			resetMocks()
+			repoupdater.MockRepoLookup = func(args protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error) {
+				return &protocol.RepoLookupResult{
+					Repo: &protocol.RepoInfo{
+						Links:        &protocol.RepoLinks{Commit: "http://hg.example.com/myrepo/rev/{commit}"},
+						ExternalRepo: args.ExternalRepo,
+					},
+				}, nil
+			}
+			db.Mocks.Phabricator.GetByName = func(repo api.RepoName) (*types.PhabricatorRepo, error) {
+				return nil, errors.New("x")
+			}
+			git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+				return api.CommitID(spec), nil
+			}
+			git.Mocks.HgChangeset = func(id api.CommitID) (string, error) {
+				if id != commit {
+					t.Errorf("got commit %q, want %q", id, commit)
+				}
+				return changeset, nil
+			}
+			links, err := Commit(context.Background(), &types.Repo{
+				Name:         "myrepo",
+				ExternalRepo: &externalRepoSpec,
+			}, commit)
+			git.ResetMocks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(links, want) {
+				t.Errorf("changeset %q: got %+v, want %+v", changeset, links, want)
+			}
+		}
+	})
+
 	t.Run("errors", func(t *testing.T) {
 		resetMocks()
 		repoupdater.MockRepoLookup = func(protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error) {
@@ -321,6 +321,48 @@ func (r *gitCommitResolver) BehindAhead(ctx context.Context, args *struct {
 	}, nil
 }
 
+func (r *gitCommitResolver) ContainingRefs(ctx context.Context) ([]*gitRefResolver, error) {
+	cachedRepo, err := backend.CachedGitRepo(ctx, r.repo.repo)
+	if err != nil {
+		return nil, err
+	}
+	oid, err := r.OID()
+	if err != nil {
+		return nil, err
+	}
+	refNames, err := git.ContainingRefs(ctx, *cachedRepo, api.CommitID(oid))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]*gitRefResolver, len(refNames))
+	for i, name := range refNames {
+		refs[i] = &gitRefResolver{repo: r.repo, name: name}
+	}
+	return refs, nil
+}
+
+func (r *gitCommitResolver) Ancestry(ctx context.Context, args *struct {
+	Revspec string
+}) (string, error) {
+	cachedRepo, err := backend.CachedGitRepo(ctx, r.repo.repo)
+	if err != nil {
+		return "", err
+	}
+	oid, err := r.OID()
+	if err != nil {
+		return "", err
+	}
+	other, err := git.ResolveRevision(ctx, *cachedRepo, nil, args.Revspec, nil)
+	if err != nil {
+		return "", err
+	}
+	rel, err := git.CommitRelation(ctx, *cachedRepo, api.CommitID(oid), other)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(string(rel)), nil
+}
+
 type behindAheadCountsResolver struct{ behind, ahead int32 }
 
 func (r *behindAheadCountsResolver) Behind() int32 { return r.behind }
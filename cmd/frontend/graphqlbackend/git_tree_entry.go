@@ -150,6 +150,22 @@ func reposourceCloneURLToRepoName(ctx context.Context, cloneURL string) (repoNam
 		repoSources = append(repoSources, reposource.BitbucketServer{BitbucketServerConnection: c})
 	}
 
+	bitbucketClouds, err := db.ExternalServices.ListBitbucketCloudConnections(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range bitbucketClouds {
+		repoSources = append(repoSources, reposource.BitbucketCloud{BitbucketCloudConnection: c})
+	}
+
+	giteas, err := db.ExternalServices.ListGiteaConnections(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range giteas {
+		repoSources = append(repoSources, reposource.Gitea{GiteaConnection: c})
+	}
+
 	awscodecommits, err := db.ExternalServices.ListAWSCodeCommitConnections(ctx)
 	if err != nil {
 		return "", err
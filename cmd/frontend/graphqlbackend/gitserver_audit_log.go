@@ -0,0 +1,139 @@
+package graphqlbackend
+
+import (
+	"context"
+	"math"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+func (r *schemaResolver) GitserverAuditLog(ctx context.Context, args *struct {
+	First      *int32
+	Repository *string
+	User       *graphql.ID
+	Before     *string
+}) ([]*gitserverAuditLogEntryResolver, error) {
+	// 🚨 SECURITY: The audit log can only be viewed by site admins.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	req := protocol.AuditLogRequest{Limit: 100}
+	if args.First != nil {
+		req.Limit = int(*args.First)
+	}
+	if args.Repository != nil {
+		req.Repo = api.RepoName(*args.Repository)
+	}
+	if args.User != nil {
+		uid, err := UnmarshalUserID(*args.User)
+		if err != nil {
+			return nil, err
+		}
+		req.ActorUID = uid
+	}
+	if args.Before != nil {
+		before, err := time.Parse(time.RFC3339, *args.Before)
+		if err != nil {
+			return nil, err
+		}
+		req.Before = before
+	}
+
+	entries, err := gitserver.DefaultClient.AuditLog(ctx, req)
+	if err != nil {
+		// Show the entries of the gitservers that could be queried.
+		if len(entries) == 0 {
+			return nil, err
+		}
+		log15.Warn("failed to query the audit logs of some gitservers", "error", err)
+	}
+
+	resolvers := make([]*gitserverAuditLogEntryResolver, len(entries))
+	for i, e := range entries {
+		resolvers[i] = &gitserverAuditLogEntryResolver{entry: e}
+	}
+	return resolvers, nil
+}
+
+type gitserverAuditLogEntryResolver struct {
+	entry *protocol.AuditLogEntry
+}
+
+func (r *gitserverAuditLogEntryResolver) Time() string {
+	return r.entry.Time.Format(time.RFC3339)
+}
+
+func (r *gitserverAuditLogEntryResolver) Repository() string {
+	return string(r.entry.Repo)
+}
+
+func (r *gitserverAuditLogEntryResolver) Arguments() []string {
+	return r.entry.Args
+}
+
+func (r *gitserverAuditLogEntryResolver) User(ctx context.Context) (*UserResolver, error) {
+	if r.entry.ActorUID == 0 {
+		return nil, nil
+	}
+	user, err := UserByIDInt32(ctx, r.entry.ActorUID)
+	if errcode.IsNotFound(err) {
+		// The user was deleted since.
+		return nil, nil
+	}
+	return user, err
+}
+
+func (r *gitserverAuditLogEntryResolver) Internal() bool {
+	return r.entry.ActorInternal
+}
+
+func (r *gitserverAuditLogEntryResolver) Client() string {
+	return r.entry.Client
+}
+
+func (r *gitserverAuditLogEntryResolver) DurationMilliseconds() int32 {
+	return int32(r.entry.Duration / time.Millisecond)
+}
+
+func (r *gitserverAuditLogEntryResolver) StdoutBytes() int32 {
+	return clampInt32(r.entry.StdoutBytes)
+}
+
+func (r *gitserverAuditLogEntryResolver) StderrBytes() int32 {
+	return clampInt32(r.entry.StderrBytes)
+}
+
+func (r *gitserverAuditLogEntryResolver) ExitStatus() *int32 {
+	if r.entry.ExitStatus < 0 {
+		return nil
+	}
+	n := int32(r.entry.ExitStatus)
+	return &n
+}
+
+func (r *gitserverAuditLogEntryResolver) Status() string {
+	return r.entry.Status
+}
+
+func (r *gitserverAuditLogEntryResolver) Error() *string {
+	if r.entry.Error == "" {
+		return nil
+	}
+	return &r.entry.Error
+}
+
+// clampInt32 returns n, or math.MaxInt32 if n doesn't fit in a GraphQL Int.
+func clampInt32(n int64) int32 {
+	if n > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(n)
+}
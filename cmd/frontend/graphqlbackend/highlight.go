@@ -6,12 +6,32 @@ type highlightedRange struct {
 	line      int32
 	character int32
 	length    int32
+
+	// endLine and endCharacter are where the highlight ends if it spans
+	// multiple lines. If endLine is 0, the highlight ends on line, length
+	// characters after character.
+	endLine      int32
+	endCharacter int32
 }
 
 func (h *highlightedRange) Line() int32      { return h.line }
 func (h *highlightedRange) Character() int32 { return h.character }
 func (h *highlightedRange) Length() int32    { return h.length }
 
+func (h *highlightedRange) EndLine() int32 {
+	if h.endLine == 0 {
+		return h.line
+	}
+	return h.endLine
+}
+
+func (h *highlightedRange) EndCharacter() int32 {
+	if h.endLine == 0 {
+		return h.character + h.length
+	}
+	return h.endCharacter
+}
+
 type highlightedString struct {
 	value      string
 	highlights []*highlightedRange
@@ -0,0 +1,360 @@
+package graphqlbackend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	replacerprotocol "github.com/sourcegraph/sourcegraph/cmd/replacer/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/endpoint"
+	"github.com/sourcegraph/sourcegraph/pkg/env"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var (
+	replacerURL = env.Get("REPLACER_URL", "k8s+http://replacer:3185", "replacer server URL")
+
+	replacerURLsOnce sync.Once
+	replacerURLs     *endpoint.Map
+)
+
+// replaceConcurrency is the number of repositories the replaceAll mutation
+// rewrites concurrently.
+const replaceConcurrency = 8
+
+// maxReplaceSearchResults is the maximum number of file matches the replaceAll
+// mutation searches for to find the repositories to rewrite.
+const maxReplaceSearchResults = 10000
+
+// replaceBranchRx matches the branch names accepted by the replaceAll
+// mutation. It is stricter than `git check-ref-format` so that branch names
+// are safe to use in refs and URLs without escaping.
+var replaceBranchRx = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_./-]*$`)
+
+type rewriteSpecificationInput struct {
+	MatchTemplate   string
+	RewriteTemplate string
+	FileExtension   string
+}
+
+// ReplaceAll rewrites every repository matched by a search query with the
+// replacer service and commits the result on a new branch in each repository
+// with gitserver.
+func (r *schemaResolver) ReplaceAll(ctx context.Context, args *struct {
+	Query         string
+	Rewrite       rewriteSpecificationInput
+	Branch        string
+	CommitMessage *string
+}) ([]*repositoryReplaceResultResolver, error) {
+	// 🚨 SECURITY: Only site admins may rewrite code across repositories.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if !replaceBranchRx.MatchString(args.Branch) || strings.Contains(args.Branch, "..") || strings.HasSuffix(args.Branch, "/") || strings.HasSuffix(args.Branch, ".lock") {
+		return nil, fmt.Errorf("invalid branch name %q", args.Branch)
+	}
+	if args.Rewrite.MatchTemplate == "" {
+		return nil, errors.New("rewrite match template must be non-empty")
+	}
+
+	q, err := query.ParseAndCheck(args.Query)
+	if err != nil {
+		return nil, err
+	}
+	repoRevs, err := replaceRepositories(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	info := protocol.PatchCommitInfo{
+		Message: fmt.Sprintf("Replace %q with %q", args.Rewrite.MatchTemplate, args.Rewrite.RewriteTemplate),
+		Date:    time.Now(),
+	}
+	if args.CommitMessage != nil && *args.CommitMessage != "" {
+		info.Message = *args.CommitMessage
+	}
+	if user, err := db.Users.GetByCurrentAuthUser(ctx); err == nil {
+		info.AuthorName = user.Username
+		if user.DisplayName != "" {
+			info.AuthorName = user.DisplayName
+		}
+		if email, _, err := db.UserEmails.GetPrimaryEmail(ctx, user.ID); err == nil {
+			info.AuthorEmail = email
+		}
+	}
+
+	spec := replacerprotocol.RewriteSpecification{
+		MatchTemplate:   args.Rewrite.MatchTemplate,
+		RewriteTemplate: args.Rewrite.RewriteTemplate,
+		FileExtension:   args.Rewrite.FileExtension,
+	}
+	targetRef := "refs/heads/" + args.Branch
+
+	results := make([]*repositoryReplaceResultResolver, len(repoRevs))
+	var (
+		wg  sync.WaitGroup
+		sem = make(semaphore, replaceConcurrency)
+	)
+	for i, repoRev := range repoRevs {
+		wg.Add(1)
+		go func(i int, repoRev *search.RepositoryRevisions) {
+			defer wg.Done()
+			if err := sem.Acquire(ctx); err != nil {
+				results[i] = &repositoryReplaceResultResolver{repo: repoRev.Repo, state: "FAILED", err: err}
+				return
+			}
+			defer sem.Release()
+			results[i] = replaceInRepo(ctx, repoRev, spec, targetRef, info)
+		}(i, repoRev)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// replaceRepositories returns the repositories (and revisions) to rewrite for
+// the search query q. These are the repositories with at least one file match,
+// or all repositories q resolves to if it has no pattern.
+func replaceRepositories(ctx context.Context, q *query.Query) ([]*search.RepositoryRevisions, error) {
+	sr := &searchResolver{query: q, forceMaxResults: maxReplaceSearchResults}
+	repoRevs, _, _, _, err := sr.resolveRepositories(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Like file searches, the rewrite is based on the refs a ref glob matches.
+	if repoRevs, _, err = expandRepoRefGlobs(ctx, repoRevs); err != nil {
+		return nil, err
+	}
+	if len(q.Values(query.FieldDefault)) == 0 && q.Pattern == nil {
+		return repoRevs, nil
+	}
+
+	results, err := sr.doResults(ctx, "file")
+	if err != nil {
+		return nil, err
+	}
+	if results.alert != nil {
+		return nil, errors.New(results.alert.title)
+	}
+	// Rewriting only some of the repositories with matches would be
+	// surprising, so the search must be exhaustive.
+	if results.LimitHit() || len(results.cloning) > 0 || len(results.timedout) > 0 {
+		return nil, errors.New("not all repositories matching the query could be searched (narrow the query with repo: filters)")
+	}
+
+	matched := make(map[api.RepoName]struct{})
+	for _, result := range results.results {
+		if result.fileMatch != nil {
+			matched[result.fileMatch.repo.Name] = struct{}{}
+		}
+	}
+	matchedRepoRevs := make([]*search.RepositoryRevisions, 0, len(matched))
+	for _, repoRev := range repoRevs {
+		if _, ok := matched[repoRev.Repo.Name]; ok {
+			matchedRepoRevs = append(matchedRepoRevs, repoRev)
+		}
+	}
+	return matchedRepoRevs, nil
+}
+
+// replaceInRepo rewrites a single repository and commits the result on the
+// branch targetRef, which must not exist yet. Failures are reported in the result rather than returned so that
+// one repository does not fail the whole mutation.
+func replaceInRepo(ctx context.Context, repoRev *search.RepositoryRevisions, spec replacerprotocol.RewriteSpecification, targetRef string, info protocol.PatchCommitInfo) *repositoryReplaceResultResolver {
+	res := &repositoryReplaceResultResolver{repo: repoRev.Repo, state: "FAILED"}
+	fail := func(err error) *repositoryReplaceResultResolver {
+		log15.Warn("replaceAll failed", "repo", repoRev.Repo.Name, "error", err)
+		res.err = err
+		return res
+	}
+
+	if len(repoRev.Revs) >= 2 {
+		return fail(errMultipleRevsNotSupported)
+	}
+	var rev string
+	if len(repoRev.Revs) == 1 {
+		rev = repoRev.RevSpecs()[0]
+	}
+	gitserverRepo := repoRev.GitserverRepo()
+	commit, err := git.ResolveRevision(ctx, gitserverRepo, nil, rev, &git.ResolveRevisionOptions{NoEnsureRevision: true})
+	if err != nil {
+		return fail(err)
+	}
+
+	// Never overwrite an existing branch, which may have commits of its own.
+	if _, err := git.ResolveRevision(ctx, gitserverRepo, nil, targetRef, &git.ResolveRevisionOptions{NoEnsureRevision: true}); err == nil {
+		return fail(fmt.Errorf("branch %s already exists", strings.TrimPrefix(targetRef, "refs/heads/")))
+	} else if !git.IsRevisionNotFound(err) {
+		return fail(err)
+	}
+
+	fileMatches, err := replacerRewrite(ctx, gitserverRepo, commit, spec)
+	if err != nil {
+		return fail(err)
+	}
+	res.fileCount = int32(len(fileMatches))
+	if len(fileMatches) == 0 {
+		res.state = "UNCHANGED"
+		return res
+	}
+
+	_, err = gitserver.DefaultClient.CreateCommitFromPatch(ctx, protocol.CreateCommitFromPatchRequest{
+		Repo:       repoRev.Repo.Name,
+		BaseCommit: commit,
+		TargetRef:  targetRef,
+		Patch:      replacerPatch(fileMatches),
+		CommitInfo: info,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	// Resolve the ref with NoEnsureRevision, otherwise
+	// repositoryResolver.Commit would try to fetch it from the remote.
+	if _, err := git.ResolveRevision(ctx, gitserverRepo, nil, targetRef, &git.ResolveRevisionOptions{NoEnsureRevision: true}); err != nil {
+		return fail(err)
+	}
+	res.commit, err = (&repositoryResolver{repo: repoRev.Repo}).Commit(ctx, &repositoryCommitArgs{Rev: targetRef})
+	if err != nil {
+		return fail(err)
+	}
+	res.state = "COMMITTED"
+	return res
+}
+
+// replacerRewrite asks the replacer service to rewrite repo at commit and
+// returns the files that the rewrite changed.
+func replacerRewrite(ctx context.Context, repo gitserver.Repo, commit api.CommitID, spec replacerprotocol.RewriteSpecification) ([]replacerprotocol.FileMatch, error) {
+	replacerURLsOnce.Do(func() {
+		if len(strings.Fields(replacerURL)) == 0 {
+			replacerURLs = endpoint.Empty(errors.New("a replacer service has not been configured"))
+		} else {
+			replacerURLs = endpoint.New(replacerURL)
+		}
+	})
+
+	// Like searcher, replacer caches the archive for repo@commit, so we use
+	// consistent hashing to increase cache hits.
+	u, err := replacerURLs.Get(string(repo.Name)+"@"+string(commit), nil)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{
+		"Repo":            []string{string(repo.Name)},
+		"URL":             []string{repo.URL},
+		"Commit":          []string{string(commit)},
+		"FetchTimeout":    []string{time.Minute.String()},
+		"MatchTemplate":   []string{spec.MatchTemplate},
+		"RewriteTemplate": []string{spec.RewriteTemplate},
+		"FileExtension":   []string{spec.FileExtension},
+	}
+	req, err := http.NewRequest("GET", u+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := searchHTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "replacer request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("replacer request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var fileMatches []replacerprotocol.FileMatch
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 100*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var fm replacerprotocol.FileMatch
+		if err := json.Unmarshal(line, &fm); err != nil {
+			return nil, errors.Wrap(err, "replacer response invalid")
+		}
+		fileMatches = append(fileMatches, fm)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "replacer response invalid")
+	}
+	return fileMatches, nil
+}
+
+// replacerPatch combines the per-file diffs returned by replacer into a
+// single patch that `git apply` accepts. Replacer uses the plain file path in
+// the ---/+++ header lines, but git expects them to be prefixed with a/ and
+// b/.
+func replacerPatch(fileMatches []replacerprotocol.FileMatch) string {
+	var b strings.Builder
+	for _, fm := range fileMatches {
+		path := strings.TrimPrefix(fm.URI, "/")
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n", path, path)
+		lines := strings.SplitAfter(fm.Diff, "\n")
+		for i, line := range lines {
+			switch {
+			case i < 2 && strings.HasPrefix(line, "--- "):
+				fmt.Fprintf(&b, "--- a/%s\n", path)
+			case i < 2 && strings.HasPrefix(line, "+++ "):
+				fmt.Fprintf(&b, "+++ b/%s\n", path)
+			default:
+				b.WriteString(line)
+			}
+		}
+		if !strings.HasSuffix(fm.Diff, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// repositoryReplaceResultResolver resolves the GraphQL type
+// RepositoryReplaceResult.
+type repositoryReplaceResultResolver struct {
+	repo      *types.Repo
+	state     string
+	commit    *gitCommitResolver
+	fileCount int32
+	err       error
+}
+
+func (r *repositoryReplaceResultResolver) Repository() *repositoryResolver {
+	return &repositoryResolver{repo: r.repo}
+}
+
+func (r *repositoryReplaceResultResolver) State() string { return r.state }
+
+func (r *repositoryReplaceResultResolver) Commit() *gitCommitResolver { return r.commit }
+
+func (r *repositoryReplaceResultResolver) FileCount() int32 { return r.fileCount }
+
+func (r *repositoryReplaceResultResolver) Error() *string {
+	if r.err == nil {
+		return nil
+	}
+	s := r.err.Error()
+	return &s
+}
@@ -0,0 +1,173 @@
+package graphqlbackend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	replacerprotocol "github.com/sourcegraph/sourcegraph/cmd/replacer/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/endpoint"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestReplacerPatch(t *testing.T) {
+	got := replacerPatch([]replacerprotocol.FileMatch{
+		{
+			URI:  "main.go",
+			Diff: "--- main.go\n+++ main.go\n@@ -1,1 +1,1 @@\n-foo(x)\n+bar(x)\n",
+		},
+		{
+			URI:  "/cmd/a.go",
+			Diff: "--- cmd/a.go\n+++ cmd/a.go\n@@ -2,1 +2,1 @@\n-\tfoo()\n+\tbar()",
+		},
+	})
+	want := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,1 +1,1 @@
+-foo(x)
++bar(x)
+diff --git a/cmd/a.go b/cmd/a.go
+--- a/cmd/a.go
++++ b/cmd/a.go
+@@ -2,1 +2,1 @@
+-	foo()
++	bar()
+`
+	if got != want {
+		t.Errorf("got patch:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReplaceBranchRx(t *testing.T) {
+	for branch, want := range map[string]bool{
+		"replace-foo":     true,
+		"sqs/replace_foo": true,
+		"v1.2":            true,
+		"":                false,
+		"-foo":            false,
+		"/foo":            false,
+		"foo bar":         false,
+		"foo~1":           false,
+	} {
+		if got := replaceBranchRx.MatchString(branch); got != want {
+			t.Errorf("%q: got %v, want %v", branch, got, want)
+		}
+	}
+}
+
+func TestReplaceInRepo(t *testing.T) {
+	resetMocks()
+	defer git.ResetMocks()
+
+	const (
+		baseCommit = api.CommitID("1111111111111111111111111111111111111111")
+		newCommit  = api.CommitID("2222222222222222222222222222222222222222")
+		targetRef  = "refs/heads/replace-foo"
+	)
+
+	// The mocked gitserver records the refs it creates, and the mocked
+	// replacer only changes the repository "changed".
+	var (
+		mu   sync.Mutex
+		refs = map[string]api.CommitID{}
+	)
+	gs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req protocol.CreateCommitFromPatchRequest
+		if r.URL.Path != "/create-commit-from-patch" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.BaseCommit != baseCommit {
+			t.Errorf("got base commit %s, want %s", req.BaseCommit, baseCommit)
+		}
+		mu.Lock()
+		refs[req.TargetRef] = newCommit
+		mu.Unlock()
+		json.NewEncoder(w).Encode(protocol.CreatePatchFromPatchResponse{Rev: req.TargetRef})
+	}))
+	defer gs.Close()
+	oldAddrs := gitserver.DefaultClient.Addrs
+	gitserver.DefaultClient.Addrs = func(context.Context) []string { return []string{strings.TrimPrefix(gs.URL, "http://")} }
+	defer func() { gitserver.DefaultClient.Addrs = oldAddrs }()
+
+	replacer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("Repo") == "changed" {
+			json.NewEncoder(w).Encode(replacerprotocol.FileMatch{URI: "main.go", Diff: "--- main.go\n+++ main.go\n@@ -1,1 +1,1 @@\n-foo(x)\n+bar(x)\n"})
+		}
+	}))
+	defer replacer.Close()
+	replacerURLsOnce.Do(func() {})
+	replacerURLs = endpoint.New(replacer.URL)
+	defer func() {
+		replacerURLsOnce = sync.Once{}
+		replacerURLs = nil
+	}()
+
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		if spec == "" {
+			return baseCommit, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if commit, ok := refs[spec]; ok {
+			return commit, nil
+		}
+		return "", &git.RevisionNotFoundError{Spec: spec}
+	}
+	backend.Mocks.Repos.ResolveRev = func(ctx context.Context, repo *types.Repo, rev string) (api.CommitID, error) {
+		if rev != targetRef {
+			t.Errorf("got rev %q, want %q", rev, targetRef)
+		}
+		return newCommit, nil
+	}
+	backend.Mocks.Repos.MockGetCommit_Return_NoCheck(t, &git.Commit{ID: newCommit})
+
+	replace := func(repo api.RepoName) *repositoryReplaceResultResolver {
+		repoRev := &search.RepositoryRevisions{
+			Repo: &types.Repo{Name: repo, Enabled: true},
+			Revs: []search.RevisionSpecifier{{RevSpec: ""}},
+		}
+		spec := replacerprotocol.RewriteSpecification{MatchTemplate: "foo(:[x])", RewriteTemplate: "bar(:[x])"}
+		return replaceInRepo(context.Background(), repoRev, spec, targetRef, protocol.PatchCommitInfo{Message: "m"})
+	}
+
+	res := replace("unchanged")
+	if res.State() != "UNCHANGED" || res.FileCount() != 0 || res.Error() != nil {
+		t.Errorf("unchanged repo: got state %s, %d files, error %v", res.State(), res.FileCount(), res.Error())
+	}
+	mu.Lock()
+	if len(refs) != 0 {
+		t.Errorf("unchanged repo: got refs %v, want none", refs)
+	}
+	mu.Unlock()
+
+	res = replace("changed")
+	if res.State() != "COMMITTED" || res.FileCount() != 1 || res.Error() != nil {
+		t.Errorf("changed repo: got state %s, %d files, error %v", res.State(), res.FileCount(), res.Error())
+	}
+	mu.Lock()
+	if _, ok := refs[targetRef]; !ok || len(refs) != 1 {
+		t.Errorf("changed repo: got refs %v, want %s", refs, targetRef)
+	}
+	mu.Unlock()
+	if res.Commit() == nil || res.Commit().oid != gitObjectID(newCommit) {
+		t.Errorf("changed repo: got commit %v, want %s", res.Commit(), newCommit)
+	}
+
+	// The branch exists now, so it must not be overwritten.
+	res = replace("changed")
+	if res.State() != "FAILED" || res.Error() == nil || !strings.Contains(*res.Error(), "already exists") {
+		t.Errorf("existing branch: got state %s, error %v", res.State(), res.Error())
+	}
+}
@@ -120,6 +120,18 @@ func (r *repositoryMirrorInfoResolver) UpdatedAt(ctx context.Context) (*string,
 	return &s, nil
 }
 
+func (r *repositoryMirrorInfoResolver) DiskUsageKB(ctx context.Context) (*int32, error) {
+	info, err := r.gitserverRepoInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size == 0 {
+		return nil, nil
+	}
+	kb := int32(info.Size / 1024)
+	return &kb, nil
+}
+
 func (r *repositoryMirrorInfoResolver) UpdateSchedule(ctx context.Context) (*updateScheduleResolver, error) {
 	info, err := r.repoUpdateSchedulerInfo(ctx)
 	if err != nil {
@@ -25,6 +25,40 @@ interface Node {
 # A valid JSON value.
 scalar JSONValue
 
+# A specification of how to rewrite code with the replacer service.
+input RewriteSpecificationInput {
+    # A template pattern that expresses what to match (e.g., "foo(:[args])").
+    matchTemplate: String!
+    # A template pattern that expresses how matches should be rewritten (e.g., "bar(:[args])").
+    rewriteTemplate: String!
+    # A file extension suffix filtering which files to process (e.g., ".go").
+    fileExtension: String!
+}
+
+# The result of rewriting a single repository with the replaceAll mutation.
+type RepositoryReplaceResult {
+    # The repository.
+    repository: Repository!
+    # Whether a commit was created in the repository.
+    state: RepositoryReplaceState!
+    # The commit created by the rewrite, if any.
+    commit: GitCommit
+    # The number of files changed by the rewrite.
+    fileCount: Int!
+    # The error encountered when rewriting the repository, if any.
+    error: String
+}
+
+# The state of a repository rewritten with the replaceAll mutation.
+enum RepositoryReplaceState {
+    # A commit containing the rewrite was created.
+    COMMITTED
+    # The rewrite made no changes, so no commit was created.
+    UNCHANGED
+    # The rewrite or commit failed. See the error field for details.
+    FAILED
+}
+
 # A mutation.
 type Mutation {
     # Updates the user profile information for the user with the given ID.
@@ -244,6 +278,26 @@ type Mutation {
         # When the diff was created.
         date: String
     ): GitCommit
+    # Rewrites the code in every repository matched by a search query and commits the result of the
+    # rewrite in each repository. The rewrite is performed by the replacer service.
+    #
+    # The commit in each repository is based on the revision of the repository that the query
+    # searches, and is stored on a new branch. Repositories that already have the branch are not
+    # rewritten.
+    #
+    # Only site admins may perform this mutation.
+    replaceAll(
+        # The search query whose repositories will be rewritten (e.g., "repo:^github.com/foo/ foo(").
+        # If it has a pattern, only the repositories with file matches are rewritten. Otherwise, all
+        # repositories (and revisions) it matches are rewritten.
+        query: String!
+        # How to rewrite the contents of files.
+        rewrite: RewriteSpecificationInput!
+        # The name of the branch to create in each repository. It must not exist yet.
+        branch: String!
+        # The commit message. If not given, a message describing the rewrite is used.
+        commitMessage: String
+    ): [RepositoryReplaceResult!]!
     # Logs a user event.
     logUserEvent(event: UserEvent!, userCookieID: String!): EmptyResponse
     # Sends a test notification for the saved search. Be careful: this will send a notifcation (email and other
@@ -774,6 +828,18 @@ type Query {
     repoGroups: [RepoGroup!]!
     # The current site.
     site: Site!
+    # Lists the git commands that gitserver executed, newest first. Only site admins may view the
+    # audit log.
+    gitserverAuditLog(
+        # Returns the first n entries from the list.
+        first: Int
+        # When present, lists only the commands that ran in the repository with this name.
+        repository: String
+        # When present, lists only the commands executed on behalf of this user.
+        user: ID
+        # When present, lists only the commands received before this time (in RFC 3339 format).
+        before: String
+    ): [GitserverAuditLogEntry!]!
     # Retrieve responses to surveys.
     surveyResponses(
         # Returns the first n survey responses from the list.
@@ -825,6 +891,61 @@ type Search {
     # cached and thus quicker to query. Useful for e.g. querying sparkline
     # data.
     stats: SearchResultsStats!
+    # Match counts of the search results grouped by a property of each result (such as its repository).
+    # Unlike the results field (and its dynamicFilters), the counts are computed without a result limit or a
+    # limit on the matches per file, using the whole timeout of the search, so this is slower. Only the counts
+    # are sent back by the search backends, not the matches. If the search is incomplete, the counts are lower
+    # bounds (see SearchAggregations.limitHit). The counts are cached for 10 minutes. Results which don't
+    # have the property (such as repository results when grouping by language) are not counted.
+    aggregations(
+        # The property to group results by.
+        groupBy: SearchAggregationGroupBy!
+        # Returns the first n groups (with the highest match counts).
+        first: Int
+        # The number of leading path components of the directory prefixes to group file matches
+        # by, with groupBy: DIRECTORY. For example, with a depth of 2, a match in cmd/foo/bar/main.go
+        # is in the cmd/foo/ group.
+        directoryDepth: Int = 1
+    ): SearchAggregations!
+}
+
+# The properties search results can be grouped by for aggregations.
+enum SearchAggregationGroupBy {
+    # The repository of the result.
+    REPOSITORY
+    # The directory prefix of file matches (see the directoryDepth argument). Files in the root directory are in the
+    # "/" group.
+    DIRECTORY
+    # The file extension of file matches, such as ".go". Files without an extension are in the "" group.
+    EXTENSION
+    # The language of file matches, such as "Go".
+    LANGUAGE
+    # The author of commit and diff matches, in the form "Full Name <user@example.com>".
+    AUTHOR
+}
+
+# Match counts of search results grouped by a property.
+type SearchAggregations {
+    # The groups, ordered by decreasing match count.
+    groups: [SearchAggregationGroup!]!
+    # The total number of groups, including those not returned because of the first argument.
+    totalCount: Int!
+    # Whether some repositories were not (fully) searched because they are cloning or the search timed out. If
+    # true, the counts are lower bounds.
+    limitHit: Boolean!
+    # An alert about the search, such as why it could not be run. The groups are empty if the search was not run.
+    alert: SearchAlert
+}
+
+# A group of search results with the same value of the property they are grouped by.
+type SearchAggregationGroup {
+    # The value of the property, such as the repository name.
+    label: String!
+    # The number of matches in the group. Like SearchResults.resultCount, each line match of a file match counts as
+    # a match.
+    matchCount: Int!
+    # The number of results (such as file matches or commits) in the group.
+    resultCount: Int!
 }
 
 # A search result.
@@ -1044,8 +1165,14 @@ type Highlight {
     line: Int!
     # The 1-indexed character on the line.
     character: Int!
-    # The length of the highlight, in characters (on the same line).
+    # The length of the highlight, in characters (on the same line). If the highlight spans multiple lines,
+    # this is the length of its part on the first line.
     length: Int!
+    # The 1-indexed line number on which the highlight ends. It is the same as line unless the highlight spans
+    # multiple lines.
+    endLine: Int!
+    # The character on endLine at which the highlight ends (exclusive).
+    endCharacter: Int!
 }
 
 # A list of external services.
@@ -1063,11 +1190,15 @@ type ExternalServiceConnection {
 # A specific kind of external service.
 enum ExternalServiceKind {
     AWSCODECOMMIT
+    BITBUCKETCLOUD
     BITBUCKETSERVER
+    GITEA
     GITHUB
     GITLAB
     GITOLITE
+    MERCURIAL
     PHABRICATOR
+    SVN
     OTHER
 }
 
@@ -1253,6 +1384,8 @@ type MirrorRepositoryInfo {
     cloned: Boolean!
     # When the repository was last successfully updated from the remote source repository..
     updatedAt: String
+    # The size of the repository on disk in KiB as of its last clone or update, or null if it is not known.
+    diskUsageKB: Int
     # The state of this repository in the update schedule.
     updateSchedule: UpdateSchedule
     # The state of this repository in the update queue.
@@ -1741,6 +1874,10 @@ type GitCommit implements Node {
     ): GitCommitConnection!
     # Returns the number of commits that this commit is behind and ahead of revspec.
     behindAhead(revspec: String!): BehindAheadCounts!
+    # The branches and tags whose history contains this commit.
+    containingRefs: [GitRef!]!
+    # Returns how this commit relates to the commit of revspec in the commit graph.
+    ancestry(revspec: String!): GitCommitAncestry!
     # Symbols defined as of this commit. (All symbols, not just symbols that were newly defined in this commit.)
     symbols(
         # Returns the first n symbols from the list.
@@ -1753,6 +1890,18 @@ type GitCommit implements Node {
     ): SymbolConnection!
 }
 
+# How a Git commit relates to another commit in the commit graph.
+enum GitCommitAncestry {
+    # The commits are the same.
+    EQUAL
+    # The commit is an ancestor of the other commit (the other commit's history contains it).
+    ANCESTOR
+    # The commit is a descendant of the other commit (its history contains the other commit).
+    DESCENDANT
+    # Neither commit's history contains the other commit.
+    DIVERGED
+}
+
 # A set of Git behind/ahead counts for one commit relative to another.
 type BehindAheadCounts {
     # The number of commits behind the other commit.
@@ -1985,6 +2134,28 @@ type GitBlob implements TreeEntry & File2 {
         # Return symbols matching the query.
         query: String
     ): SymbolConnection!
+    # EXPERIMENTAL: Candidate definitions of the identifier at the given position in this blob. The
+    # candidates are symbols in the repository with the same name as the identifier, so they are not
+    # precise: they are ranked by the likelihood of being the definition (symbols in this blob first).
+    definitions(
+        # The zero-based line number of the position.
+        line: Int!
+        # The zero-based character offset (in Unicode code points) of the position on the line.
+        character: Int!
+        # Returns the first n definitions.
+        first: Int
+    ): [Location!]!
+    # EXPERIMENTAL: Candidate references to the identifier at the given position in this blob. The
+    # candidates are whole-word, case-sensitive text matches of the identifier in the repository, so
+    # they are not precise.
+    references(
+        # The zero-based line number of the position.
+        line: Int!
+        # The zero-based character offset (in Unicode code points) of the position on the line.
+        character: Int!
+        # Returns the first n references.
+        first: Int
+    ): [Location!]!
     # Always false, since a blob is a file, not directory.
     isSingleChild(
         # Returns the first n files in the tree.
@@ -2020,6 +2191,11 @@ type FileMatch {
     symbols: [Symbol!]!
     # The line matches.
     lineMatches: [LineMatch!]!
+    # The revisions of the repository (such as branch names) in which the file has these matches. It is empty
+    # if the repository's default branch was searched. When multiple revisions are searched (for example, all
+    # branches with repo:foo@*refs/heads/), identical matches in several revisions are returned as a single
+    # FileMatch with all of these revisions.
+    revisions: [String!]!
     # Whether or not the limit was hit.
     limitHit: Boolean!
 }
@@ -2034,6 +2210,10 @@ type LineMatch {
     offsetAndLengths: [[Int!]!]!
     # Whether or not the limit was hit.
     limitHit: Boolean!
+    # The ranges of the matches that start on the line, in the same order as offsetAndLengths. Unlike
+    # offsetAndLengths, which are clipped to the end of the line, a range may end on a later line (for example,
+    # with multiline:yes).
+    ranges: [Range!]!
 }
 
 # A hunk.
@@ -2987,6 +3167,35 @@ type DeploymentConfiguration {
     siteID: String
 }
 
+# A git command that gitserver executed.
+type GitserverAuditLogEntry {
+    # The time when gitserver received the request for the command (in RFC 3339 format).
+    time: String!
+    # The name of the repository that the command ran in.
+    repository: String!
+    # The arguments of the git command.
+    arguments: [String!]!
+    # The user that the command was executed on behalf of, if any.
+    user: User
+    # Whether the command was executed on behalf of an internal Sourcegraph service.
+    internal: Boolean!
+    # The user agent of the client that requested the command.
+    client: String!
+    # How long the request took in milliseconds, including any fetch of the repository.
+    durationMilliseconds: Int!
+    # The size of the standard output of the command in bytes.
+    stdoutBytes: Int!
+    # The size of the standard error of the command in bytes.
+    stderrBytes: Int!
+    # The exit status of the command, or null if the command didn't run.
+    exitStatus: Int
+    # The outcome of the request: the exit status of the command if it ran, or why it didn't run (such as
+    # "repo-not-found").
+    status: String!
+    # The error that occurred when running the command, if any.
+    error: String
+}
+
 # A list of survey responses
 type SurveyResponseConnection {
     # A list of survey responses.
@@ -25,6 +25,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/trace"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
@@ -59,6 +60,8 @@ func (r *schemaResolver) Search(args *struct {
 	Suggestions(context.Context, *searchSuggestionsArgs) ([]*searchSuggestionResolver, error)
 	//lint:ignore U1000 is used by graphql via reflection
 	Stats(context.Context) (*searchResultsStats, error)
+	//lint:ignore U1000 is used by graphql via reflection
+	Aggregations(context.Context, *searchAggregationsArgs) (*searchAggregationsResolver, error)
 }, error) {
 	tr, _ := trace.New(context.Background(), "graphql.schemaResolver", "Search")
 	defer tr.Finish()
@@ -106,6 +109,15 @@ func asString(v *searchquerytypes.Value) string {
 type searchResolver struct {
 	query *query.Query // the parsed search query
 
+	// forceMaxResults, if non-zero, overrides the result limit of the query
+	// (see maxResults).
+	forceMaxResults int32
+
+	// countOnly is whether only the match counts of the results are needed
+	// (see search.PatternInfo.CountOnly). The search then gets the full
+	// deadline.
+	countOnly bool
+
 	// Cached resolveRepositories results.
 	reposMu                   sync.Mutex
 	repoRevs, missingRepoRevs []*search.RepositoryRevisions
@@ -120,6 +132,9 @@ func (r *searchResolver) rawQuery() string {
 }
 
 func (r *searchResolver) countIsSet() bool {
+	if r.forceMaxResults > 0 {
+		return true
+	}
 	count, _ := r.query.StringValues(query.FieldCount)
 	max, _ := r.query.StringValues(query.FieldMax)
 	return len(count) > 0 || len(max) > 0
@@ -128,6 +143,9 @@ func (r *searchResolver) countIsSet() bool {
 const defaultMaxSearchResults = 30
 
 func (r *searchResolver) maxResults() int32 {
+	if r.forceMaxResults > 0 {
+		return r.forceMaxResults
+	}
 	count, _ := r.query.StringValues(query.FieldCount)
 	if len(count) > 0 {
 		n, _ := strconv.Atoi(count[0])
@@ -484,7 +502,6 @@ func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions,
 			}
 			repoRev.Revs = append(repoRev.Revs, rev)
 		}
-
 		repoResolvers = append(repoResolvers, newSearchResultResolver(
 			repoResolver,
 			math.MaxInt32,
@@ -496,6 +513,125 @@ func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions,
 	return repoRevisions, missingRepoRevisions, repoResolvers, overLimit, nil
 }
 
+// maxRefGlobRevs is the maximum number of refs that the ref globs of a
+// repository are expanded to for file, path and symbol searches. Each ref is
+// searched separately, so a broad ref glob such as "*refs/" would otherwise
+// start a search per ref in the repository.
+const maxRefGlobRevs = 50
+
+// expandRepoRefGlobs returns repoRevs with the ref globs of each repository
+// expanded into the refs they match (see expandRefGlobs). It is used by file,
+// path and symbol searches, which search each revision separately. Commit and
+// diff searches pass the ref globs to git log instead, so they search all refs
+// that match.
+//
+// Repositories whose refs can't be listed are returned in missing (with their
+// ref globs as the user wrote them), and repositories where no refs match are
+// omitted. repoRevs is not modified.
+func expandRepoRefGlobs(ctx context.Context, repoRevs []*search.RepositoryRevisions) (expanded, missing []*search.RepositoryRevisions, err error) {
+	expanded = make([]*search.RepositoryRevisions, 0, len(repoRevs))
+	for _, repoRev := range repoRevs {
+		var revs, refGlobs []search.RevisionSpecifier
+		hasIncludeGlob := false
+		for _, rev := range repoRev.Revs {
+			if rev.RefGlob != "" || rev.ExcludeRefGlob != "" {
+				refGlobs = append(refGlobs, rev)
+				hasIncludeGlob = hasIncludeGlob || rev.RefGlob != ""
+			} else {
+				revs = append(revs, rev)
+			}
+		}
+		if len(refGlobs) == 0 {
+			expanded = append(expanded, repoRev)
+			continue
+		}
+		if !hasIncludeGlob {
+			// Exclude ref globs only have an effect on ref globs, so search the
+			// other revisions (or the default branch).
+			if len(revs) == 0 {
+				revs = []search.RevisionSpecifier{{RevSpec: ""}}
+			}
+			expanded = append(expanded, &search.RepositoryRevisions{Repo: repoRev.Repo, Revs: revs})
+			continue
+		}
+
+		refRevs, limitHit, err := expandRefGlobs(ctx, repoRev.GitserverRepo(), refGlobs)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			// The refs could not be listed (e.g., because the repository is not cloned yet),
+			// so report the ref globs (as the user wrote them) as missing.
+			log15.Warn("Listing refs to expand ref globs failed", "repo", repoRev.Repo.Name, "error", err)
+			m := &search.RepositoryRevisions{Repo: repoRev.Repo}
+			for _, rev := range refGlobs {
+				m.Revs = append(m.Revs, search.RevisionSpecifier{RevSpec: rev.String()})
+			}
+			missing = append(missing, m)
+		}
+		for _, rev := range refRevs {
+			if !containsRevisionSpecifier(revs, rev) {
+				revs = append(revs, rev)
+			}
+		}
+		if len(revs) == 0 {
+			// No refs match, so there is nothing to search in this repository. (Searching
+			// it with no revisions would search its default branch instead.)
+			continue
+		}
+		expanded = append(expanded, &search.RepositoryRevisions{Repo: repoRev.Repo, Revs: revs, RefGlobLimitHit: limitHit})
+	}
+	return expanded, missing, nil
+}
+
+// expandRefGlobs returns a revision specifier for each ref in repo that
+// matches the ref globs in revs (and none of their exclude ref globs), so
+// that each of these refs is searched. Exclude ref globs only have an effect
+// on ref globs, so if revs has no ref globs they are returned unchanged.
+//
+// Refs that point to the same commit have the same contents, so only the
+// first of them (by name) is returned. At most maxRefGlobRevs refs are
+// returned, and limitHit reports whether more refs matched.
+func expandRefGlobs(ctx context.Context, repo gitserver.Repo, revs []search.RevisionSpecifier) (expanded []search.RevisionSpecifier, limitHit bool, err error) {
+	var opt git.RefsOptions
+	for _, rev := range revs {
+		if rev.RefGlob != "" {
+			opt.Include = append(opt.Include, rev.RefGlob)
+		} else if rev.ExcludeRefGlob != "" {
+			opt.Exclude = append(opt.Exclude, rev.ExcludeRefGlob)
+		}
+	}
+	if len(opt.Include) == 0 {
+		return revs, false, nil
+	}
+
+	refs, err := git.ListRefs(ctx, repo, opt)
+	if err != nil {
+		return nil, false, err
+	}
+	seen := make(map[api.CommitID]struct{}, len(refs))
+	for _, ref := range refs {
+		if _, ok := seen[ref.CommitID]; ok {
+			continue
+		}
+		if len(expanded) == maxRefGlobRevs {
+			return expanded, true, nil
+		}
+		seen[ref.CommitID] = struct{}{}
+		expanded = append(expanded, search.RevisionSpecifier{RevSpec: ref.Name})
+	}
+	return expanded, false, nil
+}
+
+func containsRevisionSpecifier(revs []search.RevisionSpecifier, rev search.RevisionSpecifier) bool {
+	for _, r := range revs {
+		if r == rev {
+			return true
+		}
+	}
+	return false
+}
+
 func optimizeRepoPatternWithHeuristics(repoPattern string) string {
 	// Optimization: make the "." in "github.com" a literal dot
 	// so that the regexp can be optimized more effectively.
@@ -517,6 +653,9 @@ func (r *searchResolver) suggestFilePaths(ctx context.Context, limit int) ([]*se
 		// about, so don't bother searching filenames at all.
 		return nil, nil
 	}
+	if repos, _, err = expandRepoRefGlobs(ctx, repos); err != nil {
+		return nil, err
+	}
 
 	p, err := r.getPatternInfo(&getPatternInfoOptions{forceFileSearch: true})
 	if err != nil {
@@ -661,24 +800,9 @@ func sortSearchSuggestions(s []*searchSuggestionResolver) {
 // and -lang: filter values in a search query. For example, a query containing "lang:go" should
 // include files whose paths match /\.go$/.
 func langIncludeExcludePatterns(values, negatedValues []string) (includePatterns, excludePatterns []string, err error) {
-	lookup := func(value string) *filelang.Language {
-		value = strings.ToLower(value)
-		for _, lang := range filelang.Langs {
-			if strings.ToLower(lang.Name) == value {
-				return lang
-			}
-			for _, alias := range lang.Aliases {
-				if alias == value {
-					return lang
-				}
-			}
-		}
-		return nil
-	}
-
 	do := func(values []string, patterns *[]string) error {
 		for _, value := range values {
-			lang := lookup(value)
+			lang := lookupLanguage(value)
 			if lang == nil {
 				return fmt.Errorf("unknown language: %q", value)
 			}
@@ -701,6 +825,23 @@ func langIncludeExcludePatterns(values, negatedValues []string) (includePatterns
 	return includePatterns, excludePatterns, nil
 }
 
+// lookupLanguage returns the language with the given name or alias (ignoring
+// case), or nil if there is no such language.
+func lookupLanguage(value string) *filelang.Language {
+	value = strings.ToLower(value)
+	for _, lang := range filelang.Langs {
+		if strings.ToLower(lang.Name) == value {
+			return lang
+		}
+		for _, alias := range lang.Aliases {
+			if alias == value {
+				return lang
+			}
+		}
+	}
+	return nil
+}
+
 // handleRepoSearchResult handles the limitHit and searchErr returned by a search function,
 // updating common as to reflect that new information. If searchErr is a fatal error,
 // it returns a non-nil error; otherwise, if searchErr == nil or a non-fatal error, it returns a
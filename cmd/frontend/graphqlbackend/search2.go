@@ -119,6 +119,10 @@ func (r *searcherResolver) Stats(ctx context.Context) (stats *searchResultsStats
 	return nil, errors.New("search stats not implemented")
 }
 
+func (r *searcherResolver) Aggregations(ctx context.Context, args *searchAggregationsArgs) (*searchAggregationsResolver, error) {
+	return nil, errors.New("search aggregations not implemented")
+}
+
 func toSearchResultResolvers(ctx context.Context, sCtx *searchContext, r *search.Result) ([]*searchResultResolver, error) {
 	results := make([]*searchResultResolver, 0, len(r.Files))
 
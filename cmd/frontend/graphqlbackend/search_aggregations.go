@@ -0,0 +1,210 @@
+package graphqlbackend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/inventory/filelang"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/rcache"
+)
+
+// searchAggregationsCache caches the groups of searches, since the searches
+// are slow.
+var searchAggregationsCache = rcache.NewWithTTL("search_aggregations", 600) // 10m
+
+type searchAggregationsArgs struct {
+	GroupBy        string
+	First          *int32
+	DirectoryDepth int32
+}
+
+// Aggregations runs the search without a result limit, only counting the
+// matches of file results, and returns the match counts of its results
+// grouped by args.GroupBy. If the search times out in some repositories, the
+// counts are lower bounds. The groups are cached per user, query and
+// grouping.
+func (r *searchResolver) Aggregations(ctx context.Context, args *searchAggregationsArgs) (*searchAggregationsResolver, error) {
+	if args.DirectoryDepth < 1 {
+		return nil, errors.New("directoryDepth must be at least 1")
+	}
+	groupKey, err := searchAggregationGroupKey(args.GroupBy, int(args.DirectoryDepth))
+	if err != nil {
+		return nil, err
+	}
+
+	// The results depend on the repositories the user can see, so they are
+	// cached per user.
+	cacheKey := fmt.Sprintf("%d:%s:%d:%s", actor.FromContext(ctx).UID, args.GroupBy, args.DirectoryDepth, r.rawQuery())
+	var res *searchAggregationsResolver
+	if b, ok := searchAggregationsCache.Get(cacheKey); ok {
+		if err := json.Unmarshal(b, &res); err != nil {
+			return nil, err
+		}
+	} else {
+		// Use a separate resolver, so that the result limit doesn't affect
+		// other fields of the search.
+		all := &searchResolver{query: r.query, forceMaxResults: math.MaxInt32, countOnly: true}
+		results, err := all.doResults(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		res = &searchAggregationsResolver{
+			JGroups:   aggregateSearchResults(results.results, groupKey),
+			JLimitHit: results.LimitHit() || len(results.cloning) > 0 || len(results.timedout) > 0,
+			alert:     results.alert,
+		}
+
+		// Don't cache results that are likely to change soon (because
+		// repositories are still cloning or timed out), or that come with an
+		// alert (which is not cached).
+		if results.alert == nil && len(results.cloning) == 0 && len(results.timedout) == 0 {
+			b, err := json.Marshal(res)
+			if err != nil {
+				return nil, err
+			}
+			searchAggregationsCache.Set(cacheKey, b)
+		}
+	}
+
+	res.totalCount = int32(len(res.JGroups))
+	if args.First != nil && int(*args.First) >= 0 && int(*args.First) < len(res.JGroups) {
+		res.JGroups = res.JGroups[:*args.First]
+	}
+	return res, nil
+}
+
+// languagesByFilename returns the languages of a file name, in order of
+// preference.
+var languagesByFilename = filelang.Langs.CompileByFilename()
+
+// searchAggregationGroupKey returns a function that returns the label of the
+// group of a search result when grouping by groupBy (a
+// SearchAggregationGroupBy GraphQL enum value), or false if the result has no
+// group.
+func searchAggregationGroupKey(groupBy string, directoryDepth int) (func(*searchResultResolver) (string, bool), error) {
+	switch groupBy {
+	case "REPOSITORY":
+		return func(result *searchResultResolver) (string, bool) {
+			switch {
+			case result.fileMatch != nil:
+				return string(result.fileMatch.repo.Name), true
+			case result.diff != nil:
+				return string(result.diff.commit.repo.repo.Name), true
+			case result.repo != nil:
+				return string(result.repo.repo.Name), true
+			}
+			return "", false
+		}, nil
+
+	case "DIRECTORY":
+		return func(result *searchResultResolver) (string, bool) {
+			if result.fileMatch == nil {
+				return "", false
+			}
+			return directoryPrefix(result.fileMatch.JPath, directoryDepth), true
+		}, nil
+
+	case "EXTENSION":
+		return func(result *searchResultResolver) (string, bool) {
+			if result.fileMatch == nil {
+				return "", false
+			}
+			return path.Ext(result.fileMatch.JPath), true
+		}, nil
+
+	case "LANGUAGE":
+		return func(result *searchResultResolver) (string, bool) {
+			if result.fileMatch == nil {
+				return "", false
+			}
+			langs := languagesByFilename(path.Base(result.fileMatch.JPath))
+			if len(langs) == 0 {
+				return "", false
+			}
+			return langs[0].Name, true
+		}, nil
+
+	case "AUTHOR":
+		return func(result *searchResultResolver) (string, bool) {
+			if result.diff == nil {
+				return "", false
+			}
+			person := result.diff.commit.author.person
+			return fmt.Sprintf("%s <%s>", person.name, person.email), true
+		}, nil
+	}
+	return nil, errors.Errorf("invalid groupBy %q", groupBy)
+}
+
+// directoryPrefix returns the first depth components of the directory of the
+// file at name, with a trailing slash. It returns "/" for files in the root
+// directory.
+func directoryPrefix(name string, depth int) string {
+	dir := path.Dir(name)
+	if dir == "." || dir == "/" {
+		return "/"
+	}
+	components := strings.Split(strings.TrimPrefix(dir, "/"), "/")
+	if len(components) > depth {
+		components = components[:depth]
+	}
+	return strings.Join(components, "/") + "/"
+}
+
+// aggregateSearchResults groups results by groupKey. The groups are ordered
+// by decreasing match count.
+func aggregateSearchResults(results []*searchResultResolver, groupKey func(*searchResultResolver) (string, bool)) []*searchAggregationGroupResolver {
+	groupsByLabel := map[string]*searchAggregationGroupResolver{}
+	var groups []*searchAggregationGroupResolver
+	for _, result := range results {
+		label, ok := groupKey(result)
+		if !ok {
+			continue
+		}
+		group, ok := groupsByLabel[label]
+		if !ok {
+			group = &searchAggregationGroupResolver{JLabel: label}
+			groupsByLabel[label] = group
+			groups = append(groups, group)
+		}
+		group.JMatchCount += result.resultCount()
+		group.JResultCount++
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].JMatchCount != groups[j].JMatchCount {
+			return groups[i].JMatchCount > groups[j].JMatchCount
+		}
+		return groups[i].JLabel < groups[j].JLabel
+	})
+	return groups
+}
+
+type searchAggregationsResolver struct {
+	JGroups    []*searchAggregationGroupResolver
+	JLimitHit  bool
+	totalCount int32
+	alert      *searchAlert
+}
+
+func (r *searchAggregationsResolver) Groups() []*searchAggregationGroupResolver { return r.JGroups }
+func (r *searchAggregationsResolver) TotalCount() int32                         { return r.totalCount }
+func (r *searchAggregationsResolver) LimitHit() bool                            { return r.JLimitHit }
+func (r *searchAggregationsResolver) Alert() *searchAlert                       { return r.alert }
+
+type searchAggregationGroupResolver struct {
+	JLabel       string
+	JMatchCount  int32
+	JResultCount int32
+}
+
+func (r *searchAggregationGroupResolver) Label() string      { return r.JLabel }
+func (r *searchAggregationGroupResolver) MatchCount() int32  { return r.JMatchCount }
+func (r *searchAggregationGroupResolver) ResultCount() int32 { return r.JResultCount }
@@ -0,0 +1,133 @@
+package graphqlbackend
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+func TestAggregateSearchResults(t *testing.T) {
+	fileMatch := func(repo api.RepoName, path string, lineMatches int) *searchResultResolver {
+		return &searchResultResolver{fileMatch: &fileMatchResolver{
+			repo:         &types.Repo{Name: repo},
+			JPath:        path,
+			JLineMatches: make([]*lineMatch, lineMatches),
+		}}
+	}
+	commit := func(repo api.RepoName, name, email string) *searchResultResolver {
+		return &searchResultResolver{diff: &commitSearchResultResolver{commit: &gitCommitResolver{
+			repo:   &repositoryResolver{repo: &types.Repo{Name: repo}},
+			author: signatureResolver{person: &personResolver{name: name, email: email}},
+		}}}
+	}
+	results := []*searchResultResolver{
+		fileMatch("a", "cmd/foo/main.go", 3),
+		fileMatch("a", "cmd/bar/main.go", 1),
+		fileMatch("b", "README.md", 2),
+		fileMatch("b", "Makefile", 0),
+		commit("b", "Alice", "alice@example.com"),
+		commit("c", "Alice", "alice@example.com"),
+		commit("c", "Bob", "bob@example.com"),
+		{repo: &repositoryResolver{repo: &types.Repo{Name: "d"}}},
+	}
+
+	type group struct {
+		Label       string
+		MatchCount  int32
+		ResultCount int32
+	}
+	tests := []struct {
+		groupBy string
+		depth   int
+		want    []group
+	}{
+		{"REPOSITORY", 1, []group{{"a", 4, 2}, {"b", 4, 3}, {"c", 2, 2}, {"d", 1, 1}}},
+		{"DIRECTORY", 1, []group{{"cmd/", 4, 2}, {"/", 3, 2}}},
+		{"DIRECTORY", 2, []group{{"/", 3, 2}, {"cmd/foo/", 3, 1}, {"cmd/bar/", 1, 1}}},
+		{"EXTENSION", 1, []group{{".go", 4, 2}, {".md", 2, 1}, {"", 1, 1}}},
+		{"LANGUAGE", 1, []group{{"Go", 4, 2}, {"Markdown", 2, 1}, {"Makefile", 1, 1}}},
+		{"AUTHOR", 1, []group{{"Alice <alice@example.com>", 2, 2}, {"Bob <bob@example.com>", 1, 1}}},
+	}
+	for _, test := range tests {
+		groupKey, err := searchAggregationGroupKey(test.groupBy, test.depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []group
+		for _, g := range aggregateSearchResults(results, groupKey) {
+			got = append(got, group{g.Label(), g.MatchCount(), g.ResultCount()})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s (depth %d): got %+v, want %+v", test.groupBy, test.depth, got, test.want)
+		}
+	}
+
+	if _, err := searchAggregationGroupKey("COLOR", 1); err == nil {
+		t.Error("expected error for invalid groupBy")
+	}
+}
+
+func TestAggregateSearchResults_countOnly(t *testing.T) {
+	// Count-only searches return match counts instead of line matches.
+	var results []*searchResultResolver
+	for _, fm := range []*fileMatchResolver{
+		{JPath: "a.go", JMatchCount: 500},
+		{JPath: "b.go", JMatchCount: 1},
+		{JPath: "c.md", JMatchCount: 200},
+	} {
+		results = append(results, &searchResultResolver{fileMatch: fm})
+	}
+	groupKey, err := searchAggregationGroupKey("EXTENSION", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := aggregateSearchResults(results, groupKey)
+	want := []*searchAggregationGroupResolver{
+		{JLabel: ".go", JMatchCount: 501, JResultCount: 2},
+		{JLabel: ".md", JMatchCount: 200, JResultCount: 1},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("got %+v, want %+v", groups, want)
+	}
+}
+
+func TestSearchAggregationsResolverJSON(t *testing.T) {
+	// The resolver is cached as JSON, without its alert.
+	want := &searchAggregationsResolver{
+		JGroups: []*searchAggregationGroupResolver{
+			{JLabel: "a", JMatchCount: 4, JResultCount: 2},
+			{JLabel: "b", JMatchCount: 1, JResultCount: 1},
+		},
+		JLimitHit: true,
+	}
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *searchAggregationsResolver
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDirectoryPrefix(t *testing.T) {
+	tests := map[string]string{
+		"main.go":         "/",
+		"/main.go":        "/",
+		"cmd/main.go":     "cmd/",
+		"cmd/foo/main.go": "cmd/foo/",
+		"a/b/c/d/main.go": "a/b/",
+		"/a/b/c/main.go":  "a/b/",
+	}
+	for name, want := range tests {
+		if got := directoryPrefix(name, 2); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
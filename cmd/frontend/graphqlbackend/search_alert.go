@@ -297,7 +297,7 @@ outer:
 		newExpr := addQueryRegexpField(r.query, query.FieldRepo, repoParentPattern)
 		alert.proposedQueries = append(alert.proposedQueries, &searchQueryDescription{
 			description: "in repositories under " + repoParent + more,
-			query:       syntax.ExprString(newExpr),
+			query:       queryString(r.query, newExpr),
 		})
 	}
 	if len(alert.proposedQueries) == 0 || ctx.Err() == context.DeadlineExceeded {
@@ -315,7 +315,7 @@ outer:
 			newExpr := addQueryRegexpField(r.query, query.FieldRepo, "^"+regexp.QuoteMeta(pathToPropose)+"$")
 			alert.proposedQueries = append(alert.proposedQueries, &searchQueryDescription{
 				description: "in the repository " + strings.TrimPrefix(pathToPropose, "github.com/"),
-				query:       syntax.ExprString(newExpr),
+				query:       queryString(r.query, newExpr),
 			})
 		}
 	}
@@ -344,8 +344,64 @@ func (r *searchResolver) alertForMissingRepoRevs(missingRepoRevs []*search.Repos
 	}
 }
 
+// alertForRefGlobLimit returns an alert if the ref globs of any of the
+// repositories matched more refs than are searched. It returns nil otherwise.
+func alertForRefGlobLimit(repoRevs []*search.RepositoryRevisions) *searchAlert {
+	var repos []string
+	for _, r := range repoRevs {
+		if r.RefGlobLimitHit {
+			repos = append(repos, string(r.Repo.Name))
+		}
+	}
+	if len(repos) == 0 {
+		return nil
+	}
+	return &searchAlert{
+		title:       "Some refs were not searched",
+		description: fmt.Sprintf("Your ref globs matched more than %d refs with distinct commits in %s, so only the first %d refs (sorted by name) were searched. Use a more specific ref glob to search the others.", maxRefGlobRevs, strings.Join(repos, ", "), maxRefGlobRevs),
+	}
+}
+
+// alertForBooleanPattern returns an alert if the query combines its patterns
+// with boolean operators (or negates them) and resultTypes includes a type
+// whose search only matches a single pattern. It returns nil otherwise.
+func alertForBooleanPattern(q *query.Query, resultTypes []string) *searchAlert {
+	if q.Pattern == nil {
+		return nil
+	}
+	for _, resultType := range resultTypes {
+		switch resultType {
+		case "commit", "diff", "symbol":
+			return &searchAlert{
+				title:       fmt.Sprintf("Boolean operators are not supported with type:%s", resultType),
+				description: "Patterns combined with AND, OR or NOT (or negated with -) can only be used to search file contents and paths. Use a single pattern to search commits, diffs or symbols.",
+			}
+		}
+	}
+	return nil
+}
+
+// alertForStructuralPattern returns an alert if the query's patterns are
+// structural match templates (patterntype:structural) and any of the result
+// types can't be searched with them.
+func alertForStructuralPattern(q *query.Query, resultTypes []string) *searchAlert {
+	if !q.IsStructural() {
+		return nil
+	}
+	for _, resultType := range resultTypes {
+		switch resultType {
+		case "commit", "diff", "symbol":
+			return &searchAlert{
+				title:       fmt.Sprintf("Structural search is not supported with type:%s", resultType),
+				description: "Structural match templates (patterntype:structural) can only be used to search file contents. Remove patterntype:structural to search commits, diffs or symbols.",
+			}
+		}
+	}
+	return nil
+}
+
 func omitQueryFields(r *searchResolver, field string) string {
-	return syntax.ExprString(omitQueryExprWithField(r.query, field))
+	return queryString(r.query, omitQueryExprWithField(r.query, field))
 }
 
 func omitQueryExprWithField(query *query.Query, field string) []*syntax.Expr {
@@ -414,3 +470,34 @@ func addQueryRegexpField(query *query.Query, field, pattern string) []*syntax.Ex
 	}
 	return expr
 }
+
+// queryString returns the query string for expr, which are the expressions of
+// query with some of them changed (keeping their position) or omitted, or
+// with expressions appended. It preserves the boolean structure of query.
+func queryString(query *query.Query, expr []*syntax.Expr) string {
+	if query.Syntax.Tree == nil {
+		return syntax.ExprString(expr)
+	}
+
+	n := len(expr)
+	if len(query.Syntax.Expr) < n {
+		n = len(query.Syntax.Expr)
+	}
+	byPos := make(map[int]*syntax.Expr, n)
+	for _, e := range expr[:n] {
+		byPos[e.Pos] = e
+	}
+	tree := query.Syntax.Tree.Map(func(e *syntax.Expr) *syntax.Expr { return byPos[e.Pos] })
+	for _, e := range expr[n:] {
+		leaf := &syntax.Node{Expr: e}
+		if tree == nil {
+			tree = leaf
+		} else {
+			tree = &syntax.Node{Op: syntax.OpAnd, Operands: []*syntax.Node{tree, leaf}}
+		}
+	}
+	if tree == nil {
+		return ""
+	}
+	return tree.String()
+}
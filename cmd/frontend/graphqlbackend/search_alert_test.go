@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
-	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 )
 
 func TestAddQueryRegexpField(t *testing.T) {
@@ -81,6 +82,18 @@ func TestAddQueryRegexpField(t *testing.T) {
 			addPattern: "pq",
 			want:       "foo repo:p|q repo:pq",
 		},
+		{
+			query:      "(foo OR bar) repo:p",
+			addField:   "repo",
+			addPattern: "pq",
+			want:       "(foo OR bar) AND repo:pq",
+		},
+		{
+			query:      "foo OR bar",
+			addField:   "repo",
+			addPattern: "p",
+			want:       "(foo OR bar) AND repo:p",
+		},
 	}
 	for _, test := range tests {
 		t.Run(fmt.Sprintf("%s, add %s:%s", test.query, test.addField, test.addPattern), func(t *testing.T) {
@@ -89,9 +102,73 @@ func TestAddQueryRegexpField(t *testing.T) {
 				t.Fatal(err)
 			}
 			got := addQueryRegexpField(query, test.addField, test.addPattern)
-			if got := syntax.ExprString(got); got != test.want {
+			if got := queryString(query, got); got != test.want {
 				t.Errorf("got %q, want %q", got, test.want)
 			}
 		})
 	}
 }
+
+func TestAlertForBooleanPattern(t *testing.T) {
+	tests := map[string]bool{
+		"type:diff foo AND bar":    true,
+		"type:commit NOT foo":      true,
+		"type:symbol -foo":         true,
+		"type:symbol (foo OR bar)": true,
+		"type:file foo AND bar":    false,
+		"type:path -foo":           false,
+		"type:diff foo bar":        false,
+		"type:commit foo":          false,
+		"type:symbol foo":          false,
+	}
+	for queryString, wantAlert := range tests {
+		q, err := query.ParseAndCheck(queryString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resultTypes, _ := q.StringValues(query.FieldType)
+		if alert := alertForBooleanPattern(q, resultTypes); (alert != nil) != wantAlert {
+			t.Errorf("%q: got alert %v, want alert %v", queryString, alert, wantAlert)
+		}
+	}
+}
+
+func TestAlertForStructuralPattern(t *testing.T) {
+	tests := map[string]bool{
+		"patterntype:structural type:commit foo(:[x])":   true,
+		"patterntype:structural type:diff foo(:[x])":     true,
+		`patterntype:"structural" type:symbol foo(:[x])`: true,
+		"patterntype:structural type:file foo(:[x])":     false,
+		"patterntype:structural foo(:[x])":               false,
+		"patterntype:regexp type:commit foo":             false,
+	}
+	for queryString, wantAlert := range tests {
+		q, err := query.ParseAndCheck(queryString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resultTypes, _ := q.StringValues(query.FieldType)
+		if alert := alertForStructuralPattern(q, resultTypes); (alert != nil) != wantAlert {
+			t.Errorf("%q: got alert %v, want alert %v", queryString, alert, wantAlert)
+		}
+	}
+}
+
+func TestAlertForRefGlobLimit(t *testing.T) {
+	repoRevs := []*search.RepositoryRevisions{
+		{Repo: &types.Repo{Name: "a"}},
+		{Repo: &types.Repo{Name: "b"}},
+	}
+	if alert := alertForRefGlobLimit(repoRevs); alert != nil {
+		t.Errorf("got alert %v, want nil", alert)
+	}
+
+	repoRevs[1].RefGlobLimitHit = true
+	alert := alertForRefGlobLimit(repoRevs)
+	if alert == nil {
+		t.Fatal("got nil alert")
+	}
+	if want := fmt.Sprintf("Your ref globs matched more than %d refs with distinct commits in b, so only the first %d refs (sorted by name) were searched. Use a more specific ref glob to search the others.", maxRefGlobRevs, maxRefGlobRevs); alert.description != want {
+		t.Errorf("got description %q, want %q", alert.description, want)
+	}
+}
@@ -275,7 +275,7 @@ func searchCommitsInRepo(ctx context.Context, op commitSearchOp) (results []*com
 				}
 				pat, err := regexp.Compile(patString)
 				if err == nil {
-					results[i].messagePreview = highlightMatches(pat, []byte(commit.Message))
+					results[i].messagePreview = highlightMatches(pat, []byte(commit.Message), op.query.IsMultiline())
 					matchHighlights = results[i].messagePreview.highlights
 				}
 			} else {
@@ -396,10 +396,22 @@ func displayRepoName(repoPath string) string {
 	return strings.Join(parts, "/")
 }
 
-func highlightMatches(pattern *regexp.Regexp, data []byte) *highlightedString {
+// highlightMatches highlights the matches of pattern in data. If multiline is
+// true, pattern is matched against all of data, so a highlight may span
+// multiple lines.
+func highlightMatches(pattern *regexp.Regexp, data []byte, multiline bool) *highlightedString {
 	const maxMatchesPerLine = 25 // arbitrary
 
 	var highlights []*highlightedRange
+	if multiline {
+		for _, match := range pattern.FindAllIndex(bytes.ToLower(data), maxMatchesPerLine) {
+			highlights = append(highlights, highlightedRangeOf(data, match[0], match[1]))
+		}
+		return &highlightedString{
+			value:      string(data),
+			highlights: highlights,
+		}
+	}
 	for i, line := range bytes.Split(data, []byte("\n")) {
 		for _, match := range pattern.FindAllIndex(bytes.ToLower(line), maxMatchesPerLine) {
 			highlights = append(highlights, &highlightedRange{
@@ -415,6 +427,23 @@ func highlightMatches(pattern *regexp.Regexp, data []byte) *highlightedString {
 	}
 }
 
+// highlightedRangeOf returns the highlight of data[start:end], which may span
+// multiple lines.
+func highlightedRangeOf(data []byte, start, end int) *highlightedRange {
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	h := &highlightedRange{
+		line:      int32(bytes.Count(data[:start], []byte("\n")) + 1),
+		character: int32(start - lineStart),
+		length:    int32(end - start),
+	}
+	if i := bytes.IndexByte(data[start:end], '\n'); i != -1 {
+		h.length = int32(i)
+		h.endLine = h.line + int32(bytes.Count(data[start:end], []byte("\n")))
+		h.endCharacter = int32(end - (bytes.LastIndexByte(data[:end], '\n') + 1))
+	}
+	return h
+}
+
 var mockSearchCommitDiffsInRepos func(args *search.Args) ([]*searchResultResolver, *searchResultsCommon, error)
 
 // searchCommitDiffsInRepos searches a set of repos for matching commit diffs.
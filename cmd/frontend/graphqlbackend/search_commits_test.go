@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 	"testing"
 	"time"
@@ -125,3 +126,30 @@ func TestExpandUsernamesToEmails(t *testing.T) {
 		t.Errorf("got %q, want %q", x, want)
 	}
 }
+
+func TestHighlightMatches(t *testing.T) {
+	pat := regexp.MustCompile(`b\nc|d`)
+	data := []byte("ab\ncd")
+
+	// Line by line, only the match on a single line is found.
+	got := highlightMatches(pat, data, false).highlights
+	want := []*highlightedRange{{line: 2, character: 1, length: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", pretty.Sprint(got), pretty.Sprint(want))
+	}
+
+	got = highlightMatches(pat, data, true).highlights
+	want = []*highlightedRange{
+		{line: 1, character: 1, length: 1, endLine: 2, endCharacter: 1},
+		{line: 2, character: 1, length: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", pretty.Sprint(got), pretty.Sprint(want))
+	}
+	if got[0].EndLine() != 2 || got[0].EndCharacter() != 1 {
+		t.Errorf("got end %d:%d, want 2:1", got[0].EndLine(), got[0].EndCharacter())
+	}
+	if got[1].EndLine() != 2 || got[1].EndCharacter() != 2 {
+		t.Errorf("got end %d:%d, want 2:2", got[1].EndLine(), got[1].EndCharacter())
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/inventory/filelang"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
+	searchquerytypes "github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/types"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
@@ -624,18 +626,82 @@ func (r *searchResolver) getPatternInfo(opts *getPatternInfoOptions) (*search.Pa
 	patternInfo := &search.PatternInfo{
 		IsRegExp:                     true,
 		IsCaseSensitive:              r.query.IsCaseSensitive(),
+		IsMultiline:                  r.query.IsMultiline(),
+		CountOnly:                    r.countOnly,
 		FileMatchLimit:               r.maxResults(),
 		Pattern:                      regexpPatternMatchingExprsInOrder(patternsToCombine),
 		IncludePatterns:              includePatterns,
 		PathPatternsAreRegExps:       true,
 		PathPatternsAreCaseSensitive: r.query.IsCaseSensitive(),
 	}
+	if r.query.IsStructural() && (opts == nil || !opts.forceFileSearch) {
+		// The patterns are structural match templates, not regexps. Multiple
+		// patterns are joined with whitespace, which in a template matches
+		// any whitespace.
+		var templates []string
+		for _, v := range r.query.Values(query.FieldDefault) {
+			if s := asString(v); s != "" {
+				templates = append(templates, s)
+			}
+		}
+		patternInfo.Pattern = strings.Join(templates, " ")
+		patternInfo.IsRegExp = false
+		patternInfo.IsStructuralPat = true
+		patternInfo.IsCaseSensitive = true
+	}
+	if r.query.Pattern != nil && !patternInfo.IsStructuralPat && (opts == nil || !opts.forceFileSearch) {
+		// The query combines its patterns with boolean operators (or negates
+		// them), so they can't be matched in order as a single regexp.
+		patternInfo.PatternExpr = patternExpr(r.query.Pattern)
+		patternInfo.Pattern = unionRegExps(patternInfo.PatternExpr.Patterns(false))
+	}
 	if len(excludePatterns) > 0 {
 		patternInfo.ExcludePattern = unionRegExps(excludePatterns)
 	}
 	return patternInfo, nil
 }
 
+// patternExpr returns the regexp pattern expression for the boolean
+// combination of the query's patterns.
+func patternExpr(node *searchquerytypes.PatternNode) *search.PatternExpr {
+	if v := node.Value; v != nil {
+		// Treat quoted strings as literal strings to match, not regexps.
+		var pattern string
+		switch {
+		case v.String != nil:
+			pattern = regexp.QuoteMeta(*v.String)
+		case v.Regexp != nil:
+			pattern = v.Regexp.String()
+		}
+		expr := &search.PatternExpr{Pattern: pattern}
+		if v.Not() {
+			expr = &search.PatternExpr{Op: search.PatternOpNot, Operands: []*search.PatternExpr{expr}}
+		}
+		return expr
+	}
+	if node.InOrder {
+		// The values match in order on a single line, as if the query had no
+		// pattern tree.
+		patterns := make([]string, len(node.Operands))
+		for i, operand := range node.Operands {
+			patterns[i] = patternExpr(operand).Pattern
+		}
+		return &search.PatternExpr{Pattern: regexpPatternMatchingExprsInOrder(patterns)}
+	}
+
+	expr := &search.PatternExpr{Op: patternOps[node.Op]}
+	for _, operand := range node.Operands {
+		expr.Operands = append(expr.Operands, patternExpr(operand))
+	}
+	return expr
+}
+
+var patternOps = map[syntax.Operator]search.PatternOp{
+	syntax.OpAnd: search.PatternOpAnd,
+	syntax.OpOr:  search.PatternOpOr,
+	syntax.OpNot: search.PatternOpNot,
+}
+
 var (
 	// The default timeout to use for queries.
 	defaultTimeout = 10 * time.Second
@@ -712,7 +778,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		Pattern:         p,
 		Repos:           repos,
 		Query:           r.query,
-		UseFullDeadline: r.searchTimeoutFieldSet(),
+		UseFullDeadline: r.searchTimeoutFieldSet() || r.countOnly,
 	}
 	if err := args.Pattern.Validate(); err != nil {
 		return nil, &badRequestError{err}
@@ -725,7 +791,35 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 	} else {
 		resultTypes, _ = r.query.StringValues(query.FieldType)
 		if len(resultTypes) == 0 {
-			resultTypes = []string{"file", "path", "repo", "ref"}
+			if kinds, containers, _ := symbolFilters(r.query); len(kinds) > 0 || len(containers) > 0 {
+				// Only symbols have a kind or container.
+				resultTypes = []string{"symbol"}
+			} else {
+				resultTypes = []string{"file", "path", "repo", "ref"}
+			}
+		}
+	}
+	if alert := alertForBooleanPattern(r.query, resultTypes); alert != nil {
+		return &searchResultsResolver{alert: alert, start: start}, nil
+	}
+	if alert := alertForStructuralPattern(r.query, resultTypes); alert != nil {
+		return &searchResultsResolver{alert: alert, start: start}, nil
+	}
+
+	// File, path and symbol searches search each ref matched by a ref glob
+	// separately, so they search the repositories with their ref globs
+	// expanded.
+	fileArgs := args
+	for _, resultType := range resultTypes {
+		if resultType == "file" || resultType == "path" || resultType == "symbol" {
+			expanded, missing, err := expandRepoRefGlobs(ctx, repos)
+			if err != nil {
+				return nil, err
+			}
+			fileArgs.Repos = expanded
+			// Don't append to the slice cached by resolveRepositories.
+			missingRepoRevs = append(missingRepoRevs[:len(missingRepoRevs):len(missingRepoRevs)], missing...)
+			break
 		}
 	}
 	seenResultTypes := make(map[string]struct{}, len(resultTypes))
@@ -803,7 +897,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 			goroutine.Go(func() {
 				defer wg.Done()
 
-				symbolFileMatches, symbolsCommon, err := searchSymbols(ctx, &args, int(r.maxResults()))
+				symbolFileMatches, symbolsCommon, err := searchSymbols(ctx, &fileArgs, int(r.maxResults()))
 				// Timeouts are reported through searchResultsCommon so don't report an error for them
 				if err != nil && !isContextError(ctx, err) {
 					multiErrMu.Lock()
@@ -840,7 +934,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 			goroutine.Go(func() {
 				defer wg.Done()
 
-				fileResults, fileCommon, err := searchFilesInRepos(ctx, &args)
+				fileResults, fileCommon, err := searchFilesInRepos(ctx, &fileArgs)
 				// Timeouts are reported through searchResultsCommon so don't report an error for them
 				if err != nil && !(err == context.DeadlineExceeded || err == context.Canceled) {
 					multiErrMu.Lock()
@@ -940,6 +1034,8 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 
 	if len(missingRepoRevs) > 0 {
 		alert = r.alertForMissingRepoRevs(missingRepoRevs)
+	} else {
+		alert = alertForRefGlobLimit(fileArgs.Repos)
 	}
 
 	// If we have some results, only log the error instead of returning it,
@@ -1020,6 +1116,9 @@ func (g *searchResultResolver) ToCommitSearchResult() (*commitSearchResultResolv
 func (g *searchResultResolver) resultCount() int32 {
 	switch {
 	case g.fileMatch != nil:
+		if g.fileMatch.JMatchCount > 0 {
+			return g.fileMatch.JMatchCount
+		}
 		if l := len(g.fileMatch.LineMatches()); l > 0 {
 			return int32(l)
 		}
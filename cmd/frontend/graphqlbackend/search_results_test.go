@@ -2,6 +2,7 @@ package graphqlbackend
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -11,6 +12,8 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 )
 
 func TestSearchResults(t *testing.T) {
@@ -164,6 +167,18 @@ func TestSearchResolver_getPatternInfo(t *testing.T) {
 			IsRegExp:               true,
 			PathPatternsAreRegExps: true,
 		},
+		"p1 p2 -p3": {
+			Pattern:  "(p1).*?(p2)",
+			IsRegExp: true,
+			PatternExpr: &search.PatternExpr{
+				Op: search.PatternOpAnd,
+				Operands: []*search.PatternExpr{
+					{Pattern: "(p1).*?(p2)"},
+					{Op: search.PatternOpNot, Operands: []*search.PatternExpr{{Pattern: "p3"}}},
+				},
+			},
+			PathPatternsAreRegExps: true,
+		},
 		"p case:yes": {
 			Pattern:                      "p",
 			IsRegExp:                     true,
@@ -480,6 +495,110 @@ func TestSearchRevspecs(t *testing.T) {
 	}
 }
 
+func TestExpandRefGlobs(t *testing.T) {
+	git.Mocks.ListRefs = func(opt git.RefsOptions) ([]*git.Ref, error) {
+		if want := (git.RefsOptions{Include: []string{"refs/heads/"}, Exclude: []string{"refs/heads/old/"}}); !reflect.DeepEqual(opt, want) {
+			t.Errorf("got %+v, want %+v", opt, want)
+		}
+		return []*git.Ref{
+			{Name: "refs/heads/feature", CommitID: "a"},
+			{Name: "refs/heads/master", CommitID: "b"},
+			{Name: "refs/heads/release", CommitID: "a"}, // same commit as feature
+		}, nil
+	}
+	defer git.ResetMocks()
+
+	revs, limitHit, err := expandRefGlobs(context.Background(), gitserver.Repo{Name: "foo"}, []search.RevisionSpecifier{
+		{RefGlob: "refs/heads/"},
+		{ExcludeRefGlob: "refs/heads/old/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []search.RevisionSpecifier{{RevSpec: "refs/heads/feature"}, {RevSpec: "refs/heads/master"}}; !reflect.DeepEqual(revs, want) {
+		t.Errorf("got %+v, want %+v", revs, want)
+	}
+	if limitHit {
+		t.Error("got limitHit, want false")
+	}
+
+	// At most maxRefGlobRevs refs are returned.
+	git.Mocks.ListRefs = func(opt git.RefsOptions) ([]*git.Ref, error) {
+		refs := make([]*git.Ref, maxRefGlobRevs+1)
+		for i := range refs {
+			refs[i] = &git.Ref{Name: fmt.Sprintf("refs/heads/b%03d", i), CommitID: api.CommitID(fmt.Sprint(i))}
+		}
+		return refs, nil
+	}
+	revs, limitHit, err = expandRefGlobs(context.Background(), gitserver.Repo{Name: "foo"}, []search.RevisionSpecifier{{RefGlob: "refs/heads/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != maxRefGlobRevs {
+		t.Errorf("got %d revs, want %d", len(revs), maxRefGlobRevs)
+	}
+	if !limitHit {
+		t.Error("got !limitHit, want true")
+	}
+
+	// Revisions without an include glob are returned unchanged, without listing refs.
+	git.Mocks.ListRefs = func(opt git.RefsOptions) ([]*git.Ref, error) {
+		t.Fatal("unexpected call to ListRefs")
+		return nil, nil
+	}
+	in := []search.RevisionSpecifier{{RevSpec: "v1"}}
+	revs, _, err = expandRefGlobs(context.Background(), gitserver.Repo{Name: "foo"}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(revs, in) {
+		t.Errorf("got %+v, want %+v", revs, in)
+	}
+}
+
+func TestExpandRepoRefGlobs(t *testing.T) {
+	git.Mocks.ListRefs = func(opt git.RefsOptions) ([]*git.Ref, error) {
+		switch opt.Include[0] {
+		case "refs/heads/":
+			return []*git.Ref{{Name: "refs/heads/master", CommitID: "a"}}, nil
+		case "refs/tags/":
+			return nil, nil
+		}
+		return nil, errors.New("not cloned")
+	}
+	defer git.ResetMocks()
+
+	plain := &search.RepositoryRevisions{Repo: &types.Repo{Name: "plain"}, Revs: []search.RevisionSpecifier{{RevSpec: "v1"}}}
+	globbed := &search.RepositoryRevisions{Repo: &types.Repo{Name: "globbed"}, Revs: []search.RevisionSpecifier{{RevSpec: "v1"}, {RefGlob: "refs/heads/"}}}
+	noMatch := &search.RepositoryRevisions{Repo: &types.Repo{Name: "nomatch"}, Revs: []search.RevisionSpecifier{{RefGlob: "refs/tags/"}}}
+	excludeOnly := &search.RepositoryRevisions{Repo: &types.Repo{Name: "excludeonly"}, Revs: []search.RevisionSpecifier{{ExcludeRefGlob: "refs/heads/old/"}}}
+	failed := &search.RepositoryRevisions{Repo: &types.Repo{Name: "failed"}, Revs: []search.RevisionSpecifier{{RefGlob: "refs/pull/"}}}
+
+	expanded, missing, err := expandRepoRefGlobs(context.Background(), []*search.RepositoryRevisions{plain, globbed, noMatch, excludeOnly, failed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantExpanded := []*search.RepositoryRevisions{
+		plain,
+		{Repo: globbed.Repo, Revs: []search.RevisionSpecifier{{RevSpec: "v1"}, {RevSpec: "refs/heads/master"}}},
+		{Repo: excludeOnly.Repo, Revs: []search.RevisionSpecifier{{RevSpec: ""}}},
+	}
+	if !reflect.DeepEqual(expanded, wantExpanded) {
+		t.Errorf("got expanded %+v, want %+v", expanded, wantExpanded)
+	}
+	wantMissing := []*search.RepositoryRevisions{
+		{Repo: failed.Repo, Revs: []search.RevisionSpecifier{{RevSpec: "*refs/pull/"}}},
+	}
+	if !reflect.DeepEqual(missing, wantMissing) {
+		t.Errorf("got missing %+v, want %+v", missing, wantMissing)
+	}
+
+	// The input is not modified.
+	if want := []search.RevisionSpecifier{{RevSpec: "v1"}, {RefGlob: "refs/heads/"}}; !reflect.DeepEqual(globbed.Revs, want) {
+		t.Errorf("got %+v, want %+v", globbed.Revs, want)
+	}
+}
+
 func TestCompareSearchResults(t *testing.T) {
 	type testCase struct {
 		a       *searchResultResolver
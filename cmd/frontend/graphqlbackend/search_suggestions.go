@@ -100,11 +100,18 @@ func (r *searchResolver) Suggestions(ctx context.Context, args *searchSuggestion
 		if err != nil {
 			return nil, err
 		}
+		if repoRevs, _, err = expandRepoRefGlobs(ctx, repoRevs); err != nil {
+			return nil, err
+		}
 
 		p, err := r.getPatternInfo(nil)
 		if err != nil {
 			return nil, err
 		}
+		if p.PatternExpr != nil || p.IsStructuralPat {
+			// Symbol search only matches a single regexp pattern.
+			return nil, nil
+		}
 
 		ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 		defer cancel()
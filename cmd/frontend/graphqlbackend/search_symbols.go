@@ -99,7 +99,32 @@ func searchSymbols(ctx context.Context, args *search.Args, limit int) (res []*fi
 	return res, common, err
 }
 
+// searchSymbolsInRepo searches the symbols of each revision of repoRevs, one
+// revision at a time, until limit symbols are found.
 func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisions, patternInfo *search.PatternInfo, query *query.Query, limit int) (res []*fileMatchResolver, err error) {
+	var symbols int
+	for _, rev := range repoRevs.RevSpecs() {
+		// Revspecs such as ^refs/heads/master exclude the commits reachable
+		// from them. They only have a meaning for commit searches.
+		if strings.HasPrefix(rev, "^") {
+			continue
+		}
+		revRes, err := searchSymbolsInRepoRev(ctx, repoRevs, rev, patternInfo, query, limit-symbols)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, revRes...)
+		for _, fm := range revRes {
+			symbols += len(fm.symbols)
+		}
+		if symbols >= limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+func searchSymbolsInRepoRev(ctx context.Context, repoRevs *search.RepositoryRevisions, inputRev string, patternInfo *search.PatternInfo, query *query.Query, limit int) (res []*fileMatchResolver, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Search symbols in repo")
 	defer func() {
 		if err != nil {
@@ -109,8 +134,6 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 		span.Finish()
 	}()
 	span.SetTag("repo", string(repoRevs.Repo.Name))
-
-	inputRev := repoRevs.RevSpecs()[0]
 	span.SetTag("rev", inputRev)
 	// Do not trigger a repo-updater lookup (e.g.,
 	// backend.{GitRepo,Repos.ResolveRev}) because that would slow this operation
@@ -126,6 +149,7 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 		return nil, err
 	}
 
+	kinds, containers, languages := symbolFilters(query)
 	symbols, err := backend.Symbols.ListTags(ctx, protocol.SearchArgs{
 		Repo:            repoRevs.Repo.Name,
 		CommitID:        commitID,
@@ -134,6 +158,9 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 		IsRegExp:        patternInfo.IsRegExp,
 		IncludePatterns: patternInfo.IncludePatterns,
 		ExcludePattern:  patternInfo.ExcludePattern,
+		Kinds:           kinds,
+		Containers:      containers,
+		Languages:       languages,
 		First:           limit,
 	})
 	fileMatchesByURI := make(map[string]*fileMatchResolver)
@@ -168,6 +195,48 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 	return fileMatches, err
 }
 
+// symbolFilters returns the values of the kind:, container: and lang: fields
+// of the query, which restrict which symbols are returned. Languages are
+// normalized to the name ctags uses for them (e.g., lang:golang is "Go" and
+// lang:bash is "Sh"), since that is the language of the symbols.
+func symbolFilters(q *query.Query) (kinds, containers, languages []string) {
+	if q == nil {
+		return nil, nil, nil
+	}
+	kinds, _ = q.StringValues(query.FieldKind)
+	containers, _ = q.StringValues(query.FieldContainer)
+	langs, _ := q.StringValues(query.FieldLang)
+	for _, value := range langs {
+		if lang := lookupLanguage(value); lang != nil {
+			if name, ok := ctagsLanguages[lang.Name]; ok {
+				languages = append(languages, name)
+			} else {
+				languages = append(languages, lang.Name)
+			}
+		} else {
+			languages = append(languages, value)
+		}
+	}
+	return kinds, containers, languages
+}
+
+// ctagsLanguages maps the names of languages (see filelang.Langs) to the
+// names ctags uses for them, where they differ other than in case. See the
+// --languages flag in cmd/symbols/internal/pkg/ctags.
+var ctagsLanguages = map[string]string{
+	"Common Lisp":     "Lisp",
+	"Emacs Lisp":      "Lisp",
+	"Less":            "CSS",
+	"Objective-C":     "ObjectiveC",
+	"Perl 6":          "Perl6",
+	"Protocol Buffer": "Protobuf",
+	"Sass":            "CSS",
+	"SCSS":            "CSS",
+	"Shell":           "Sh",
+	"Vim script":      "Vim",
+	"Visual Basic":    "Basic",
+}
+
 // makeFileMatchURIFromSymbol makes a git://repo?rev#path URI from a symbolResolver to use in a fileMatchResolver
 func makeFileMatchURIFromSymbol(symbolResolver *symbolResolver, inputRev string) string {
 	uri := "git:/" + string(symbolResolver.location.resource.commit.repo.URL())
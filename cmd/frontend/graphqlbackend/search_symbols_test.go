@@ -0,0 +1,48 @@
+package graphqlbackend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+)
+
+func TestSymbolFilters(t *testing.T) {
+	tests := []struct {
+		query                                string
+		wantKinds, wantContainers, wantLangs []string
+	}{
+		{query: "foo"},
+		{
+			query:          "type:symbol kind:function kind:method container:Foo foo",
+			wantKinds:      []string{"function", "method"},
+			wantContainers: []string{"Foo"},
+		},
+		{
+			// Languages are normalized to their canonical names.
+			query:     "type:symbol lang:golang foo",
+			wantLangs: []string{"Go"},
+		},
+		{
+			// Languages are named like ctags names them.
+			query:     "type:symbol lang:shell lang:objective-c foo",
+			wantLangs: []string{"Sh", "ObjectiveC"},
+		},
+	}
+	for _, test := range tests {
+		q, err := query.ParseAndCheck(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds, containers, langs := symbolFilters(q)
+		if !reflect.DeepEqual(kinds, test.wantKinds) {
+			t.Errorf("%q: got kinds %v, want %v", test.query, kinds, test.wantKinds)
+		}
+		if !reflect.DeepEqual(containers, test.wantContainers) {
+			t.Errorf("%q: got containers %v, want %v", test.query, containers, test.wantContainers)
+		}
+		if !reflect.DeepEqual(langs, test.wantLangs) {
+			t.Errorf("%q: got languages %v, want %v", test.query, langs, test.wantLangs)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"regexp/syntax"
@@ -23,9 +25,11 @@ import (
 
 	"github.com/google/zoekt"
 	zoektquery "github.com/google/zoekt/query"
+	"github.com/sourcegraph/go-langserver/pkg/lsp"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/errcode"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
@@ -57,6 +61,7 @@ type fileMatchResolver struct {
 	JPath        string       `json:"Path"`
 	JLineMatches []*lineMatch `json:"LineMatches"`
 	JLimitHit    bool         `json:"LimitHit"`
+	JMatchCount  int32        `json:"MatchCount"` // the number of line matches, if the search was count-only (JLineMatches is empty then)
 	symbols      []*symbolResolver
 	uri          string
 	repo         *types.Repo
@@ -65,6 +70,10 @@ type fileMatchResolver struct {
 	// preserve the original revision specifier from the user instead of navigating them to the
 	// absolute commit ID when they select a result.
 	inputRev *string
+	// revs are the revisions in which the file has identical matches, if
+	// multiple revisions of the repository were searched (see
+	// searchFilesInRepoRevs).
+	revs []string
 }
 
 func (fm *fileMatchResolver) Key() string {
@@ -102,16 +111,36 @@ func (fm *fileMatchResolver) LineMatches() []*lineMatch {
 	return fm.JLineMatches
 }
 
+// Revisions returns the revisions of the repository in which the file has
+// these matches. It is empty if the default branch was searched.
+func (fm *fileMatchResolver) Revisions() []string {
+	if len(fm.revs) > 0 {
+		return fm.revs
+	}
+	if fm.inputRev != nil && *fm.inputRev != "" {
+		return []string{*fm.inputRev}
+	}
+	return []string{}
+}
+
 func (fm *fileMatchResolver) LimitHit() bool {
 	return fm.JLimitHit
 }
 
 // LineMatch is the struct used by vscode to receive search results for a line
 type lineMatch struct {
-	JPreview          string     `json:"Preview"`
-	JOffsetAndLengths [][2]int32 `json:"OffsetAndLengths"`
-	JLineNumber       int32      `json:"LineNumber"`
-	JLimitHit         bool       `json:"LimitHit"`
+	JPreview          string           `json:"Preview"`
+	JOffsetAndLengths [][2]int32       `json:"OffsetAndLengths"`
+	JLineNumber       int32            `json:"LineNumber"`
+	JLimitHit         bool             `json:"LimitHit"`
+	JRanges           []lineMatchRange `json:"Ranges"`
+}
+
+// lineMatchRange is a range of a match returned by searcher (see
+// cmd/searcher/protocol.Range). Lines and columns are 0-based, and columns
+// are measured in characters.
+type lineMatchRange struct {
+	Start, End struct{ Line, Column int }
 }
 
 func (lm *lineMatch) Preview() string {
@@ -134,9 +163,36 @@ func (lm *lineMatch) LimitHit() bool {
 	return lm.JLimitHit
 }
 
-// textSearch searches repo@commit with p.
+// Ranges returns the range of each match, which may span multiple lines.
+// Matches without a range (such as those found by indexed search) are on a
+// single line, so their range is derived from their offset and length.
+func (lm *lineMatch) Ranges() []*rangeResolver {
+	if len(lm.JRanges) > 0 {
+		r := make([]*rangeResolver, len(lm.JRanges))
+		for i, rng := range lm.JRanges {
+			r[i] = &rangeResolver{lsp.Range{
+				Start: lsp.Position{Line: rng.Start.Line, Character: rng.Start.Column},
+				End:   lsp.Position{Line: rng.End.Line, Character: rng.End.Column},
+			}}
+		}
+		return r
+	}
+	line := int(lm.JLineNumber)
+	r := make([]*rangeResolver, len(lm.JOffsetAndLengths))
+	for i, ol := range lm.JOffsetAndLengths {
+		offset, length := int(ol[0]), int(ol[1])
+		r[i] = &rangeResolver{lsp.Range{
+			Start: lsp.Position{Line: line, Character: offset},
+			End:   lsp.Position{Line: line, Character: offset + length},
+		}}
+	}
+	return r
+}
+
+// textSearch searches repo@commit with p. If onMatch is non-nil, it is called
+// with each match as it arrives from searcher (before it is returned).
 // Note: the returned matches do not set fileMatch.uri
-func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (matches []*fileMatchResolver, limitHit bool, err error) {
 	tr, ctx := trace.New(ctx, "searcher.client", fmt.Sprintf("%s@%s", repo.Name, commit))
 	defer func() {
 		tr.SetError(err)
@@ -176,9 +232,25 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	if p.IsRegExp {
 		q.Set("IsRegExp", "true")
 	}
+	if p.PatternExpr != nil {
+		b, err := json.Marshal(p.PatternExpr)
+		if err != nil {
+			return nil, false, err
+		}
+		q.Set("PatternExpr", string(b))
+	}
+	if p.IsStructuralPat {
+		q.Set("IsStructuralPat", "true")
+	}
 	if p.IsWordMatch {
 		q.Set("IsWordMatch", "true")
 	}
+	if p.IsMultiline {
+		q.Set("IsMultiline", "true")
+	}
+	if p.CountOnly {
+		q.Set("CountOnly", "true")
+	}
 	if p.IsCaseSensitive {
 		q.Set("IsCaseSensitive", "true")
 	}
@@ -192,6 +264,9 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	// these fields from old frontends that do not (and provide a default in the latter case).
 	q.Set("PatternMatchesContent", strconv.FormatBool(p.PatternMatchesContent))
 	q.Set("PatternMatchesPath", strconv.FormatBool(p.PatternMatchesPath))
+	// Ask searcher to stream matches so that we keep the matches found so
+	// far if our deadline is hit mid-response. Older searchers ignore this.
+	q.Set("Stream", "true")
 	rawQuery := q.Encode()
 
 	// Searcher caches the file contents for repo@commit since it is
@@ -206,6 +281,11 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 		attempt            = 0
 		maxAttempts        = 2
 	)
+	if onMatch != nil {
+		// An attempt that fails with a temporary error may have streamed
+		// some matches already, which the retry returns again.
+		onMatch = onNewMatch(onMatch)
+	}
 	for {
 		attempt++
 
@@ -225,7 +305,7 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 
 		url := searcherURL + "?" + rawQuery
 		tr.LazyPrintf("attempt %d: %s", attempt, url)
-		matches, limitHit, err = textSearchURL(ctx, url)
+		matches, limitHit, err = textSearchURL(ctx, url, onMatch)
 		// Useful trace for debugging:
 		//
 		// tr.LazyPrintf("%d matches, limitHit=%v, err=%v, ctx.Err()=%v", len(matches), limitHit, err, ctx.Err())
@@ -233,9 +313,10 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 			return matches, limitHit, err
 		}
 
-		// If we are canceled, return that error.
+		// If we are canceled, return that error along with the matches
+		// streamed so far.
 		if err := ctx.Err(); err != nil {
-			return nil, false, err
+			return matches, false, err
 		}
 
 		// If not temporary or our last attempt then don't try again.
@@ -249,12 +330,28 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	}
 }
 
-func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool, error) {
+// onNewMatch returns a function that calls onMatch with each match that is
+// not of the same path as a match it was called with before, so that matches
+// are passed to onMatch only once when a search is retried. It is not safe
+// for concurrent use.
+func onNewMatch(onMatch func(*fileMatchResolver)) func(*fileMatchResolver) {
+	seen := map[string]bool{}
+	return func(fm *fileMatchResolver) {
+		if seen[fm.JPath] {
+			return
+		}
+		seen[fm.JPath] = true
+		onMatch(fm)
+	}
+}
+
+func textSearchURL(ctx context.Context, url string, onMatch func(*fileMatchResolver)) ([]*fileMatchResolver, bool, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, false, err
 	}
 	req = req.WithContext(ctx)
+	actor.SetHTTPHeader(req.Header, actor.FromContext(ctx))
 
 	req, ht := nethttp.TraceRequest(opentracing.GlobalTracer(), req,
 		nethttp.OperationName("Searcher Client"),
@@ -288,6 +385,10 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 		return nil, false, errors.WithStack(&searcherError{StatusCode: resp.StatusCode, Message: string(body)})
 	}
 
+	if resp.Header.Get("Content-Type") == searcherStreamContentType {
+		return decodeSearcherStream(ctx, resp.Body, onMatch)
+	}
+
 	r := struct {
 		Matches     []*fileMatchResolver
 		LimitHit    bool
@@ -300,9 +401,65 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 	if r.DeadlineHit {
 		err = context.DeadlineExceeded
 	}
+	if onMatch != nil {
+		for _, m := range r.Matches {
+			onMatch(m)
+		}
+	}
 	return r.Matches, r.LimitHit, err
 }
 
+// searcherStreamContentType is the Content-Type searcher responds with when
+// it streams matches. It must be kept in sync with
+// cmd/searcher/protocol.StreamContentType.
+const searcherStreamContentType = "application/x-ndjson"
+
+// decodeSearcherStream reads the newline-delimited events of a streamed
+// searcher response (see cmd/searcher/protocol.StreamEvent) as they arrive,
+// and passes each match to onMatch (if non-nil) as soon as it is read.
+//
+// Unlike a buffered response, if the stream is cut short because ctx is done
+// the matches read so far are returned along with ctx.Err().
+func decodeSearcherStream(ctx context.Context, body io.Reader, onMatch func(*fileMatchResolver)) (matches []*fileMatchResolver, limitHit bool, err error) {
+	dec := json.NewDecoder(body)
+	for {
+		var ev struct {
+			Match *fileMatchResolver
+			Done  *struct {
+				LimitHit    bool
+				DeadlineHit bool
+				Error       string
+			}
+		}
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil {
+				return matches, false, ctx.Err()
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return matches, false, errors.Wrap(err, "searcher response invalid")
+		}
+
+		switch {
+		case ev.Match != nil:
+			matches = append(matches, ev.Match)
+			if onMatch != nil {
+				onMatch(ev.Match)
+			}
+
+		case ev.Done != nil:
+			if ev.Done.Error != "" {
+				return matches, false, errors.Errorf("searcher failed: %s", ev.Done.Error)
+			}
+			if ev.Done.DeadlineHit {
+				err = context.DeadlineExceeded
+			}
+			return matches, ev.Done.LimitHit, err
+		}
+	}
+}
+
 type searcherError struct {
 	StatusCode int
 	Message    string
@@ -320,11 +477,14 @@ func (e *searcherError) Error() string {
 	return e.Message
 }
 
-var mockSearchFilesInRepo func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error)
+var mockSearchFilesInRepo func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (matches []*fileMatchResolver, limitHit bool, err error)
 
-func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+// searchFilesInRepo searches repo at rev. If onMatch is non-nil, it is called
+// with each match as it arrives (see textSearch). The matches passed to it
+// don't have their URI, repo and commit set yet.
+func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (matches []*fileMatchResolver, limitHit bool, err error) {
 	if mockSearchFilesInRepo != nil {
-		return mockSearchFilesInRepo(ctx, repo, gitserverRepo, rev, info, fetchTimeout)
+		return mockSearchFilesInRepo(ctx, repo, gitserverRepo, rev, info, fetchTimeout, onMatch)
 	}
 
 	// Do not trigger a repo-updater lookup (e.g.,
@@ -336,7 +496,7 @@ func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gits
 		return nil, false, err
 	}
 
-	matches, limitHit, err = textSearch(ctx, gitserverRepo, commit, info, fetchTimeout)
+	matches, limitHit, err = textSearch(ctx, gitserverRepo, commit, info, fetchTimeout, onMatch)
 
 	workspace := fileMatchURI(repo.Name, rev, "")
 	for _, fm := range matches {
@@ -349,6 +509,89 @@ func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gits
 	return matches, limitHit, err
 }
 
+// maxRevSearchConcurrency is the maximum number of revisions of a repository
+// that searchFilesInRepoRevs searches concurrently.
+const maxRevSearchConcurrency = 8
+
+// searchFilesInRepoRevs searches each revision of repoRev with
+// searchFilesInRepo, at most maxRevSearchConcurrency at a time. Identical
+// matches in a file (the same path and line matches) in several revisions are
+// returned once, with all of these revisions.
+//
+// If onMatch is non-nil and a single revision is searched, it is called with
+// each match as it arrives. Matches in several revisions are only known to be
+// identical once all revisions are searched, so it is not called for them.
+func searchFilesInRepoRevs(ctx context.Context, repoRev *search.RepositoryRevisions, info *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (matches []*fileMatchResolver, limitHit bool, err error) {
+	var revs []string
+	for _, rev := range repoRev.RevSpecs() {
+		// Revspecs such as ^refs/heads/master exclude the commits reachable
+		// from them. They only have a meaning for commit searches.
+		if !strings.HasPrefix(rev, "^") {
+			revs = append(revs, rev)
+		}
+	}
+	if len(revs) == 1 {
+		return searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo(), revs[0], info, fetchTimeout, onMatch)
+	}
+
+	var (
+		wg          sync.WaitGroup
+		sem         = make(semaphore, maxRevSearchConcurrency)
+		revMatches  = make([][]*fileMatchResolver, len(revs))
+		revLimitHit = make([]bool, len(revs))
+		revErr      = make([]error, len(revs))
+	)
+	for i, rev := range revs {
+		if err := sem.Acquire(ctx); err != nil {
+			revErr[i] = err
+			break
+		}
+		wg.Add(1)
+		go func(i int, rev string) {
+			defer wg.Done()
+			defer sem.Release()
+			revMatches[i], revLimitHit[i], revErr[i] = searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo(), rev, info, fetchTimeout, nil)
+		}(i, rev)
+	}
+	wg.Wait()
+
+	seen := map[string]*fileMatchResolver{}
+	for i, rev := range revs {
+		limitHit = limitHit || revLimitHit[i]
+		if err == nil {
+			err = revErr[i]
+		}
+		if rev == "" {
+			rev = "HEAD" // the default branch
+		}
+		for _, fm := range revMatches[i] {
+			key, keyErr := fileMatchContentKey(fm)
+			if keyErr != nil {
+				return nil, false, keyErr
+			}
+			if first, ok := seen[key]; ok {
+				first.revs = append(first.revs, rev)
+				continue
+			}
+			fm.revs = []string{rev}
+			seen[key] = fm
+			matches = append(matches, fm)
+		}
+	}
+	return matches, limitHit, err
+}
+
+// fileMatchContentKey returns a key that is equal for file matches of the
+// same path with the same line matches. Count-only file matches have no line
+// matches, so their match counts are compared instead.
+func fileMatchContentKey(fm *fileMatchResolver) (string, error) {
+	lineMatches, err := json.Marshal(fm.JLineMatches)
+	if err != nil {
+		return "", err
+	}
+	return fm.JPath + "\x00" + strconv.Itoa(int(fm.JMatchCount)) + "\x00" + string(lineMatches), nil
+}
+
 func fileMatchURI(name api.RepoName, ref, path string) string {
 	var b strings.Builder
 	ref = url.QueryEscape(ref)
@@ -382,7 +625,7 @@ func zoektResultCountFactor(numRepos int, query *search.PatternInfo) int {
 	case numRepos <= 5:
 		k = 100
 	}
-	if query.FileMatchLimit > defaultMaxSearchResults {
+	if query.FileMatchLimit > defaultMaxSearchResults && !query.CountOnly {
 		k = int(float64(k) * 3 * float64(query.FileMatchLimit) / float64(defaultMaxSearchResults))
 	}
 	return k
@@ -408,6 +651,17 @@ func zoektSearchOpts(k int, query *search.PatternInfo) zoekt.SearchOptions {
 		searchOpts.MaxDocDisplayCount = 2000
 	}
 
+	if query.CountOnly {
+		// Count all matches. The search is only limited by its deadline
+		// (count-only searches use the full deadline).
+		searchOpts.ShardMaxMatchCount = math.MaxInt32
+		searchOpts.TotalMaxMatchCount = math.MaxInt32
+		searchOpts.ShardMaxImportantMatch = math.MaxInt32
+		searchOpts.TotalMaxImportantMatch = math.MaxInt32
+		searchOpts.MaxDocDisplayCount = math.MaxInt32
+		return searchOpts
+	}
+
 	if userProbablyWantsToWaitLonger := query.FileMatchLimit > defaultMaxSearchResults; userProbablyWantsToWaitLonger {
 		searchOpts.MaxWallTime *= time.Duration(3 * float64(query.FileMatchLimit) / float64(defaultMaxSearchResults))
 	}
@@ -523,7 +777,7 @@ func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*se
 	matches := make([]*fileMatchResolver, len(resp.Files))
 	for i, file := range resp.Files {
 		fileLimitHit := false
-		if len(file.LineMatches) > maxLineMatches {
+		if len(file.LineMatches) > maxLineMatches && !query.CountOnly {
 			file.LineMatches = file.LineMatches[:maxLineMatches]
 			fileLimitHit = true
 			limitHit = true
@@ -601,7 +855,15 @@ func queryToZoektQuery(query *search.PatternInfo) (zoektquery.Q, error) {
 		return parseRe(pattern, true)
 	}
 
-	if query.IsRegExp {
+	if query.PatternExpr != nil {
+		q, err := patternExprToZoektQuery(query.PatternExpr, func(pattern string) (zoektquery.Q, error) {
+			return parseRe(pattern, false)
+		})
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, q)
+	} else if query.IsRegExp {
 		q, err := parseRe(query.Pattern, false)
 		if err != nil {
 			return nil, err
@@ -641,6 +903,32 @@ func queryToZoektQuery(query *search.PatternInfo) (zoektquery.Q, error) {
 	return zoektquery.Simplify(zoektquery.NewAnd(and...)), nil
 }
 
+// patternExprToZoektQuery returns the zoekt query for expr, using parseRe to
+// build the query of each pattern.
+func patternExprToZoektQuery(expr *search.PatternExpr, parseRe func(pattern string) (zoektquery.Q, error)) (zoektquery.Q, error) {
+	if expr.Op == "" {
+		return parseRe(expr.Pattern)
+	}
+	operands := make([]zoektquery.Q, len(expr.Operands))
+	for i, operand := range expr.Operands {
+		q, err := patternExprToZoektQuery(operand, parseRe)
+		if err != nil {
+			return nil, err
+		}
+		operands[i] = q
+	}
+	switch expr.Op {
+	case search.PatternOpAnd:
+		return zoektquery.NewAnd(operands...), nil
+	case search.PatternOpOr:
+		return zoektquery.NewOr(operands...), nil
+	case search.PatternOpNot:
+		return &zoektquery.Not{Child: operands[0]}, nil
+	default:
+		return nil, fmt.Errorf("unknown pattern operator %q", expr.Op)
+	}
+}
+
 func zoektIndexedRepos(ctx context.Context, repos []*search.RepositoryRevisions) (indexed, unindexed []*search.RepositoryRevisions, err error) {
 	if !Search().Index.Enabled() {
 		return nil, repos, nil
@@ -648,8 +936,7 @@ func zoektIndexedRepos(ctx context.Context, repos []*search.RepositoryRevisions)
 	for _, repoRev := range repos {
 		// We search HEAD using zoekt
 		if revspecs := repoRev.RevSpecs(); len(revspecs) > 0 {
-			// TODO(sqs): search all revspecs
-			if revspecs[0] == "" {
+			if len(revspecs) == 1 && revspecs[0] == "" {
 				indexed = append(indexed, repoRev)
 			} else {
 				unindexed = append(unindexed, repoRev)
@@ -755,15 +1042,47 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		}
 	}
 
+	if args.Pattern.IsStructuralPat && len(zoektRepos) > 0 {
+		// Indexed search does not support structural patterns, so search
+		// every repository with searcher.
+		tr.LazyPrintf("structural search, bypassing zoekt (using searcher) for %d indexed repos", len(zoektRepos))
+		searcherRepos = append(searcherRepos, zoektRepos...)
+		zoektRepos = nil
+	}
+	if args.Pattern.IsMultiline && len(zoektRepos) > 0 {
+		// Indexed search reports matches line by line, so it can't describe
+		// matches spanning multiple lines. Search every repository with
+		// searcher.
+		tr.LazyPrintf("multiline search, bypassing zoekt (using searcher) for %d indexed repos", len(zoektRepos))
+		searcherRepos = append(searcherRepos, zoektRepos...)
+		zoektRepos = nil
+	}
+
 	var (
 		// TODO: convert wg to an errgroup
 		wg                sync.WaitGroup
 		mu                sync.Mutex
 		unflattened       [][]*fileMatchResolver
 		flattenedSize     int
+		arrivedSize       int  // matches that arrived from searcher, including those of repos still being searched
 		overLimitCanceled bool // canceled because we were over the limit
 	)
 
+	// onMatch is called with each match of searcher as it arrives, so that we
+	// stop searching as soon as enough matches have arrived instead of when
+	// the repos they are in have been searched.
+	onMatch := func(*fileMatchResolver) {
+		mu.Lock()
+		defer mu.Unlock()
+		arrivedSize++
+		if arrivedSize > int(args.Pattern.FileMatchLimit) && !overLimitCanceled {
+			tr.LazyPrintf("cancel due to arrived result size: %d > %d", arrivedSize, args.Pattern.FileMatchLimit)
+			overLimitCanceled = true
+			common.limitHit = true
+			cancel()
+		}
+	}
+
 	// addMatches assumes the caller holds mu.
 	addMatches := func(matches []*fileMatchResolver) {
 		if len(matches) > 0 {
@@ -807,15 +1126,11 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		if len(repoRev.Revs) == 0 {
 			continue
 		}
-		if len(repoRev.Revs) >= 2 {
-			return nil, common, errMultipleRevsNotSupported
-		}
 
 		wg.Add(1)
 		go func(repoRev search.RepositoryRevisions) {
 			defer wg.Done()
-			rev := repoRev.RevSpecs()[0] // TODO(sqs): search multiple revs
-			matches, repoLimitHit, searchErr := searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo(), rev, args.Pattern, fetchTimeout)
+			matches, repoLimitHit, searchErr := searchFilesInRepoRevs(ctx, &repoRev, args.Pattern, fetchTimeout, onMatch)
 			if searchErr != nil {
 				tr.LogFields(otlog.String("repo", string(repoRev.Repo.Name)), otlog.String("searchErr", searchErr.Error()), otlog.Bool("timeout", errcode.IsTimeout(searchErr)), otlog.Bool("temporary", errcode.IsTemporary(searchErr)))
 				log15.Warn("searchFilesInRepo failed", "error", searchErr, "repo", repoRev.Repo.Name)
@@ -833,10 +1148,12 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 			if fatalErr := handleRepoSearchResult(common, repoRev, repoLimitHit, false, searchErr); fatalErr != nil {
 				if ctx.Err() == context.Canceled {
 					// Our request has been canceled (either because another one of searcherRepos
-					// had a fatal error, or otherwise), so we can just ignore these results. We
+					// had a fatal error, or otherwise), so we can just ignore the error. We
 					// handle this here, not in handleRepoSearchResult, because different callers of
 					// handleRepoSearchResult (for different result types) currently all need to
-					// handle cancellations differently.
+					// handle cancellations differently. The matches that arrived before the
+					// cancellation are kept.
+					addMatches(matches)
 					return
 				}
 				err = errors.Wrapf(searchErr, "failed to search %s", repoRev.String())
@@ -2,14 +2,19 @@ package graphqlbackend
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/zoekt"
 	zoektquery "github.com/google/zoekt/query"
 	"github.com/pkg/errors"
+	"github.com/sourcegraph/go-langserver/pkg/lsp"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
@@ -91,6 +96,25 @@ func TestQueryToZoektQuery(t *testing.T) {
 			},
 			Query: `foo case:yes f:\.go$ f:\.yaml$ -f:\bvendor\b`,
 		},
+		{
+			Name: "boolean",
+			Pattern: &search.PatternInfo{
+				IsRegExp:        true,
+				IsCaseSensitive: false,
+				Pattern:         "foo|bar",
+				PatternExpr: &search.PatternExpr{
+					Op: search.PatternOpAnd,
+					Operands: []*search.PatternExpr{
+						{Op: search.PatternOpOr, Operands: []*search.PatternExpr{{Pattern: "foo"}, {Pattern: "bar"}}},
+						{Op: search.PatternOpNot, Operands: []*search.PatternExpr{{Pattern: "baz"}}},
+					},
+				},
+				IncludePatterns:              []string{`\.go$`},
+				PathPatternsAreRegExps:       true,
+				PathPatternsAreCaseSensitive: false,
+			},
+			Query: `(foo or bar) -baz case:no f:\.go$`,
+		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.Name, func(t *testing.T) {
@@ -127,7 +151,7 @@ func queryEqual(a zoektquery.Q, b zoektquery.Q) bool {
 }
 
 func TestSearchFilesInRepos(t *testing.T) {
-	mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+	mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (matches []*fileMatchResolver, limitHit bool, err error) {
 		repoName := repo.Name
 		switch repoName {
 		case "foo/one":
@@ -154,6 +178,31 @@ func TestSearchFilesInRepos(t *testing.T) {
 			return nil, false, context.DeadlineExceeded
 		case "foo/no-rev":
 			return nil, false, &git.RevisionNotFoundError{Repo: repoName, Spec: "missing"}
+		case "foo/multi":
+			preview := "foo"
+			if rev == "c" {
+				preview = "foo bar"
+			}
+			return []*fileMatchResolver{
+				{
+					uri:          "git://" + string(repoName) + "?" + rev + "#" + "main.go",
+					JPath:        "main.go",
+					JLineMatches: []*lineMatch{{JPreview: preview, JOffsetAndLengths: [][2]int32{{0, 3}}}},
+				},
+			}, false, nil
+		case "foo/stream":
+			// Stream more matches than the limit, then wait for the search
+			// to be canceled because of them.
+			for i := 0; i <= int(info.FileMatchLimit); i++ {
+				fm := &fileMatchResolver{
+					uri:   "git://" + string(repoName) + "?" + rev + "#" + strconv.Itoa(i) + ".go",
+					JPath: strconv.Itoa(i) + ".go",
+				}
+				matches = append(matches, fm)
+				onMatch(fm)
+			}
+			<-ctx.Done()
+			return matches, false, ctx.Err()
 		default:
 			return nil, false, errors.New("Unexpected repo")
 		}
@@ -204,6 +253,64 @@ func TestSearchFilesInRepos(t *testing.T) {
 	if !git.IsRevisionNotFound(errors.Cause(err)) {
 		t.Fatalf("searching non-existent rev expected to fail with RevisionNotFoundError got: %v", err)
 	}
+
+	// Identical matches in multiple revisions are merged into a single
+	// result annotated with all of the revisions.
+	args = &search.Args{
+		Pattern: &search.PatternInfo{
+			FileMatchLimit: defaultMaxSearchResults,
+			Pattern:        "foo",
+		},
+		Repos: makeRepositoryRevisions("foo/multi@a:b:c"),
+		Query: q,
+	}
+	results, _, err = searchFilesInRepos(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revs [][]string
+	for _, r := range results {
+		revs = append(revs, r.Revisions())
+	}
+	if want := [][]string{{"a", "b"}, {"c"}}; !reflect.DeepEqual(revs, want) {
+		t.Errorf("got revisions %v, want %v", revs, want)
+	}
+
+	// The search is canceled as soon as more matches than the limit have
+	// arrived, before the repo they are in has been searched.
+	args = &search.Args{
+		Pattern: &search.PatternInfo{
+			FileMatchLimit: 2,
+			Pattern:        "foo",
+		},
+		Repos: makeRepositoryRevisions("foo/stream"),
+		Query: q,
+	}
+	results, common, err = searchFilesInRepos(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected two results, got %d", len(results))
+	}
+	if !common.limitHit {
+		t.Error("expected limitHit")
+	}
+}
+
+func TestOnNewMatch(t *testing.T) {
+	var paths []string
+	onMatch := onNewMatch(func(fm *fileMatchResolver) {
+		paths = append(paths, fm.JPath)
+	})
+	// A retried search returns the matches streamed by the failed attempt
+	// again.
+	for _, path := range []string{"a.go", "b.go", "a.go", "b.go", "c.go"} {
+		onMatch(&fileMatchResolver{JPath: path})
+	}
+	if want := []string{"a.go", "b.go", "c.go"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
 }
 
 func makeRepositoryRevisions(repos ...string) []*search.RepositoryRevisions {
@@ -354,3 +461,104 @@ func Test_zoektSearchHEAD(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeSearcherStream(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantPaths    []string
+		wantLimitHit bool
+		wantErr      error
+	}{
+		{
+			name:      "empty",
+			body:      `{"Done":{}}` + "\n",
+			wantPaths: nil,
+		},
+		{
+			name: "matches",
+			body: `{"Match":{"Path":"a.go"}}` + "\n" +
+				`{"Match":{"Path":"b.go"}}` + "\n" +
+				`{"Done":{"LimitHit":true}}` + "\n",
+			wantPaths:    []string{"a.go", "b.go"},
+			wantLimitHit: true,
+		},
+		{
+			name: "deadline",
+			body: `{"Match":{"Path":"a.go"}}` + "\n" +
+				`{"Done":{"DeadlineHit":true}}` + "\n",
+			wantPaths: []string{"a.go"},
+			wantErr:   context.DeadlineExceeded,
+		},
+		{
+			name:      "truncated",
+			body:      `{"Match":{"Path":"a.go"}}` + "\n",
+			wantPaths: []string{"a.go"},
+			wantErr:   io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var arrivedPaths []string
+			onMatch := func(m *fileMatchResolver) {
+				arrivedPaths = append(arrivedPaths, m.JPath)
+			}
+			matches, limitHit, err := decodeSearcherStream(context.Background(), strings.NewReader(tt.body), onMatch)
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("got err %v, want %v", err, tt.wantErr)
+			}
+			var paths []string
+			for _, m := range matches {
+				paths = append(paths, m.JPath)
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("got paths %v, want %v", paths, tt.wantPaths)
+			}
+			if !reflect.DeepEqual(arrivedPaths, tt.wantPaths) {
+				t.Errorf("got arrived paths %v, want %v", arrivedPaths, tt.wantPaths)
+			}
+			if limitHit != tt.wantLimitHit {
+				t.Errorf("got limitHit %v, want %v", limitHit, tt.wantLimitHit)
+			}
+		})
+	}
+}
+
+func TestLineMatchRanges(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []lsp.Range
+	}{
+		{
+			name: "offsets",
+			body: `{"LineNumber":2,"OffsetAndLengths":[[1,3],[6,2]]}`,
+			want: []lsp.Range{
+				{Start: lsp.Position{Line: 2, Character: 1}, End: lsp.Position{Line: 2, Character: 4}},
+				{Start: lsp.Position{Line: 2, Character: 6}, End: lsp.Position{Line: 2, Character: 8}},
+			},
+		},
+		{
+			name: "multiline",
+			body: `{"LineNumber":2,"OffsetAndLengths":[[1,3]],"Ranges":[{"Start":{"Line":2,"Column":1},"End":{"Line":4,"Column":1}}]}`,
+			want: []lsp.Range{
+				{Start: lsp.Position{Line: 2, Character: 1}, End: lsp.Position{Line: 4, Character: 1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lm lineMatch
+			if err := json.Unmarshal([]byte(tt.body), &lm); err != nil {
+				t.Fatal(err)
+			}
+			var got []lsp.Range
+			for _, r := range lm.Ranges() {
+				got = append(got, r.lspRange)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
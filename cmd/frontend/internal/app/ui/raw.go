@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"html"
 	"io"
+	"io/ioutil"
 	"mime"
 	"net/http"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 
 	"github.com/golang/gddo/httputil"
 	"github.com/gorilla/mux"
@@ -231,6 +233,27 @@ func serveRaw(w http.ResponseWriter, r *http.Request) error {
 			return err
 		}
 		defer f.Close()
+
+		// Serve the contents of files stored with Git LFS instead of their
+		// pointer, if gitserver fetched them.
+		if fi.Size() <= git.MaxLFSPointerSize {
+			b, err := ioutil.ReadAll(f)
+			if err != nil {
+				return err
+			}
+			if git.IsLFSPointer(b) {
+				content, ok, err := git.ReadLFSObject(r.Context(), gitserver.Repo{Name: common.Repo.Name}, common.CommitID, requestedPath)
+				if err != nil {
+					return err
+				}
+				if ok {
+					b = content
+				}
+			}
+			_, err = w.Write(b)
+			return err
+		}
+
 		_, err = io.Copy(w, f)
 		return err
 	}
@@ -16,12 +16,14 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/app/assetsutil"
 	internalauth "github.com/sourcegraph/sourcegraph/cmd/frontend/internal/auth"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/cli/middleware"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/githttp"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/httpapi"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/httpapi/router"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/handlerutil"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/session"
 	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	tracepkg "github.com/sourcegraph/sourcegraph/pkg/trace"
 	"github.com/sourcegraph/sourcegraph/pkg/version"
 )
@@ -49,10 +51,24 @@ func newExternalHTTPHandler(ctx context.Context) (http.Handler, error) {
 	appHandler = session.CookieMiddleware(appHandler)                                          // app accepts cookies
 	appHandler = httpapi.AccessTokenAuthMiddleware(appHandler)                                 // app accepts access tokens
 
+	// Git smart HTTP handler (read-only clones of repositories).
+	gitHandler := githttp.NewHandler(gitserver.DefaultClient)
+	gitHandler = authMiddlewares.API(gitHandler)               // 🚨 SECURITY: auth middleware
+	gitHandler = httpapi.AccessTokenAuthMiddleware(gitHandler) // git clients authenticate with access tokens
+	gitHandler = githttp.BasicAuthChallengeMiddleware(gitHandler)
+
 	// Mount handlers and assets.
 	sm := http.NewServeMux()
 	sm.Handle("/.api/", apiHandler)
-	sm.Handle("/", appHandler)
+	sm.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Git smart HTTP requests share their paths with app pages (the
+		// repository name), so we dispatch them here.
+		if githttp.IsGitRequest(r) {
+			gitHandler.ServeHTTP(w, r)
+			return
+		}
+		appHandler.ServeHTTP(w, r)
+	}))
 	assetsutil.Mount(sm)
 
 	var h http.Handler = sm
@@ -0,0 +1,145 @@
+// Package githttp serves the read-only git smart HTTP protocol for
+// repositories on Sourcegraph by proxying it to the gitserver that stores
+// them. This lets users clone repositories from Sourcegraph's mirrors, e.g.
+// with `git clone https://sourcegraph.example.com/github.com/foo/bar.git`.
+package githttp
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// IsGitRequest reports whether r is a git smart HTTP request, which should be
+// served by the handler returned by NewHandler instead of the app.
+func IsGitRequest(r *http.Request) bool {
+	_, _, ok := splitPath(r)
+	return ok
+}
+
+// splitPath splits the path of a git smart HTTP request into the repo name
+// and the path of the git service endpoint (such as "/info/refs").
+func splitPath(r *http.Request) (repo api.RepoName, service string, ok bool) {
+	p := r.URL.Path
+	switch {
+	case strings.HasSuffix(p, "/info/refs") && strings.HasPrefix(r.URL.Query().Get("service"), "git-"):
+		service = "/info/refs"
+	case strings.HasSuffix(p, "/git-upload-pack") && r.Method == "POST":
+		service = "/git-upload-pack"
+	case strings.HasSuffix(p, "/git-receive-pack") && r.Method == "POST":
+		service = "/git-receive-pack"
+	default:
+		return "", "", false
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSuffix(p, service), "/"), ".git")
+	if name == "" {
+		return "", "", false
+	}
+	return api.RepoName(name), service, true
+}
+
+// NewHandler returns a handler that serves git smart HTTP requests by
+// proxying them to gitserver. Pushes are rejected.
+//
+// 🚨 SECURITY: The caller MUST wrap the returned handler in middleware that checks authentication
+// and sets the actor in the request context. Authorization is enforced by looking up the repository,
+// which only returns repositories the actor can read.
+func NewHandler(client *gitserver.Client) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			// The gitserver URL is set by the handler below. The
+			// ReverseProxy already copied the headers, so we can modify
+			// them.
+			//
+			// 🚨 SECURITY: Don't leak the user's credentials to gitserver.
+			r.Header.Del("Authorization")
+			r.Header.Del("Cookie")
+
+			// Tell gitserver who the request is made on behalf of, for its
+			// audit log.
+			actor.SetHTTPHeader(r.Header, actor.FromContext(r.Context()))
+		},
+		// Flush regularly so git clients can report progress while a large
+		// pack is being sent.
+		FlushInterval: 100 * time.Millisecond,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, service, ok := splitPath(r)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if service == "/git-receive-pack" || r.URL.Query().Get("service") == "git-receive-pack" {
+			http.Error(w, "Pushing to Sourcegraph is not supported. Repositories are read-only mirrors of their code host.", http.StatusForbidden)
+			return
+		}
+
+		// 🚨 SECURITY: db.Repos.GetByName only returns the repository if the
+		// actor is authorized to read it.
+		repo, err := db.Repos.GetByName(r.Context(), name)
+		if errcode.IsNotFound(err) {
+			if !actor.FromContext(r.Context()).IsAuthenticated() {
+				// The repository may only be hidden from anonymous users.
+				// Ask the git client for credentials, like code hosts do.
+				http.Error(w, "Authentication required.", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "Repository not found.", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log15.Error("git smart HTTP: failed to look up repository", "repo", name, "error", err)
+			http.Error(w, "Failed to look up repository.", http.StatusInternalServerError)
+			return
+		}
+
+		r2 := r.WithContext(r.Context())
+		r2.URL = &url.URL{
+			Scheme:   "http",
+			Host:     client.AddrForRepo(r.Context(), repo.Name),
+			Path:     "/git/" + string(repo.Name) + service,
+			RawQuery: r.URL.RawQuery,
+		}
+		proxy.ServeHTTP(w, r2)
+	})
+}
+
+// BasicAuthChallengeMiddleware adds a Basic authentication challenge to the
+// 401 Unauthorized responses of next. Git clients only send credentials (such
+// as an access token as the username of https://TOKEN@sourcegraph.example.com)
+// after they receive the challenge.
+func BasicAuthChallengeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&challengeResponseWriter{ResponseWriter: w}, r)
+	})
+}
+
+type challengeResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *challengeResponseWriter) WriteHeader(code int) {
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Sourcegraph"`)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher, so that the proxy can flush progress to git
+// clients.
+func (w *challengeResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,147 @@
+package githttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+func TestIsGitRequest(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/github.com/foo/bar.git/info/refs?service=git-upload-pack", true},
+		{"GET", "/github.com/foo/bar/info/refs?service=git-upload-pack", true},
+		{"GET", "/github.com/foo/bar/info/refs?service=git-receive-pack", true},
+		{"POST", "/github.com/foo/bar.git/git-upload-pack", true},
+		{"POST", "/github.com/foo/bar.git/git-receive-pack", true},
+		{"GET", "/github.com/foo/bar/info/refs", false},
+		{"GET", "/github.com/foo/bar/-/blob/info/refs", false},
+		{"GET", "/github.com/foo/bar/git-upload-pack", false},
+		{"GET", "/info/refs?service=git-upload-pack", false},
+		{"GET", "/search?q=foo", false},
+	} {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if got := IsGitRequest(r); got != tc.want {
+			t.Errorf("%s %s: got %v, want %v", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var gotPath, gotQuery, gotAuth, gotProtocol, gotActor string
+	gs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+		gotAuth, gotProtocol = r.Header.Get("Authorization"), r.Header.Get("Git-Protocol")
+		gotActor = actor.FromHTTPHeader(r.Header).UIDString()
+		_, _ = w.Write([]byte("refs"))
+	}))
+	defer gs.Close()
+
+	client := &gitserver.Client{
+		Addrs: func(context.Context) []string {
+			return []string{strings.TrimPrefix(gs.URL, "http://")}
+		},
+		HTTPClient: http.DefaultClient,
+	}
+
+	db.Mocks.Repos.GetByName = func(ctx context.Context, name api.RepoName) (*types.Repo, error) {
+		if name != "github.com/foo/bar" {
+			return nil, notFoundErr{}
+		}
+		return &types.Repo{ID: 1, Name: name}, nil
+	}
+	defer func() { db.Mocks = db.MockStores{} }()
+
+	h := NewHandler(client)
+
+	t.Run("info/refs", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/github.com/foo/bar.git/info/refs?service=git-upload-pack", nil)
+		r = r.WithContext(actor.WithActor(r.Context(), actor.FromUser(2)))
+		r.Header.Set("Authorization", "token secret")
+		r.Header.Set("Git-Protocol", "version=2")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+		}
+		if body, _ := ioutil.ReadAll(w.Body); string(body) != "refs" {
+			t.Errorf("got body %q", body)
+		}
+		if want := "/git/github.com/foo/bar/info/refs"; gotPath != want {
+			t.Errorf("got gitserver path %q, want %q", gotPath, want)
+		}
+		if want := "service=git-upload-pack"; gotQuery != want {
+			t.Errorf("got gitserver query %q, want %q", gotQuery, want)
+		}
+		if gotAuth != "" {
+			t.Errorf("Authorization header was passed to gitserver: %q", gotAuth)
+		}
+		if gotProtocol != "version=2" {
+			t.Errorf("got Git-Protocol %q, want %q", gotProtocol, "version=2")
+		}
+		if gotActor != "2" {
+			t.Errorf("got actor %q passed to gitserver, want %q", gotActor, "2")
+		}
+	})
+
+	for _, tc := range []struct {
+		name, method, path string
+		anonymous          bool
+		wantStatus         int
+	}{
+		{"not found", "GET", "/github.com/foo/secret.git/info/refs?service=git-upload-pack", false, http.StatusNotFound},
+		{"not found anonymous", "GET", "/github.com/foo/secret.git/info/refs?service=git-upload-pack", true, http.StatusUnauthorized},
+		{"push refs", "GET", "/github.com/foo/bar.git/info/refs?service=git-receive-pack", false, http.StatusForbidden},
+		{"push", "POST", "/github.com/foo/bar.git/git-receive-pack", false, http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gotPath = ""
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			if !tc.anonymous {
+				r = r.WithContext(actor.WithActor(r.Context(), actor.FromUser(2)))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tc.wantStatus)
+			}
+			if gotPath != "" {
+				t.Errorf("request was proxied to gitserver: %s", gotPath)
+			}
+		})
+	}
+}
+
+func TestBasicAuthChallengeMiddleware(t *testing.T) {
+	for _, code := range []int{http.StatusOK, http.StatusUnauthorized, http.StatusNotFound} {
+		h := BasicAuthChallengeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/github.com/foo/bar.git/info/refs?service=git-upload-pack", nil))
+		got := w.Header().Get("WWW-Authenticate")
+		if want := code == http.StatusUnauthorized; (got != "") != want {
+			t.Errorf("status %d: got WWW-Authenticate %q", code, got)
+		}
+		if code == http.StatusUnauthorized && !strings.HasPrefix(got, "Basic ") {
+			t.Errorf("got WWW-Authenticate %q, want Basic challenge", got)
+		}
+	}
+}
+
+type notFoundErr struct{}
+
+func (notFoundErr) Error() string  { return "not found" }
+func (notFoundErr) NotFound() bool { return true }
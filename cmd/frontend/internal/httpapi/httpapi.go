@@ -39,6 +39,10 @@ func NewHandler(m *mux.Router) http.Handler {
 
 	m.Get(apirouter.Telemetry).Handler(trace.TraceRoute(telemetryHandler))
 
+	m.Get(apirouter.GitHubWebhook).Handler(trace.TraceRoute(handler(serveGitHubWebhook)))
+	m.Get(apirouter.GitLabWebhook).Handler(trace.TraceRoute(handler(serveGitLabWebhook)))
+	m.Get(apirouter.BitbucketServerWebhook).Handler(trace.TraceRoute(handler(serveBitbucketServerWebhook)))
+
 	if envvar.SourcegraphDotComMode() {
 		m.Path("/updates").Methods("GET").Name("updatecheck").Handler(trace.TraceRoute(http.HandlerFunc(updatecheck.Handler)))
 	}
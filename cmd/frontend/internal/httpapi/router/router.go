@@ -15,6 +15,10 @@ const (
 	RepoRefresh = "repo.refresh"
 	Telemetry   = "telemetry"
 
+	GitHubWebhook          = "webhooks.github"
+	GitLabWebhook          = "webhooks.gitlab"
+	BitbucketServerWebhook = "webhooks.bitbucket-server"
+
 	SavedQueriesListAll    = "internal.saved-queries.list-all"
 	SavedQueriesGetInfo    = "internal.saved-queries.get-info"
 	SavedQueriesSetInfo    = "internal.saved-queries.set-info"
@@ -58,6 +62,10 @@ func New(base *mux.Router) *mux.Router {
 	addGraphQLRoute(base)
 	addTelemetryRoute(base)
 
+	base.Path("/webhooks/github").Methods("POST").Name(GitHubWebhook)
+	base.Path("/webhooks/gitlab").Methods("POST").Name(GitLabWebhook)
+	base.Path("/webhooks/bitbucket-server").Methods("POST").Name(BitbucketServerWebhook)
+
 	// repo contains routes that are NOT specific to a revision. In these routes, the URL may not contain a revspec after the repo (that is, no "github.com/foo/bar@myrevspec").
 	repoPath := `/repos/` + routevar.Repo
 
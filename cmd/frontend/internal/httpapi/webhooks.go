@@ -0,0 +1,278 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+)
+
+// maxWebhookPayloadSize is the largest webhook payload we accept. It's the
+// limit GitHub applies to the payloads it sends.
+const maxWebhookPayloadSize = 25 << 20
+
+var errInvalidWebhookSignature = &errcode.HTTPErr{
+	Status: http.StatusUnauthorized,
+	Err:    errors.New("webhook signature does not match the webhookSecret of any external service"),
+}
+
+// serveGitHubWebhook handles push webhooks from GitHub by enqueuing an update
+// of the pushed repository.
+//
+// 🚨 SECURITY: This endpoint is accessible to anonymous users. The request is
+// authenticated by its signature, which must match the "webhookSecret" of a
+// GitHub external service.
+func serveGitHubWebhook(w http.ResponseWriter, r *http.Request) error {
+	payload, err := readWebhookPayload(r)
+	if err != nil {
+		return err
+	}
+
+	conns, err := db.ExternalServices.ListGitHubConnections(r.Context())
+	if err != nil {
+		return err
+	}
+
+	var p struct {
+		Repository struct {
+			CloneURL string `json:"clone_url"`
+		} `json:"repository"`
+	}
+	push := r.Header.Get("X-GitHub-Event") == "push"
+	if push {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: err}
+		}
+	}
+
+	var authenticated bool
+	var names []api.RepoName
+	signature := r.Header.Get("X-Hub-Signature")
+	for _, c := range conns {
+		if !validHMACSignature(signature, "sha1=", sha1.New, c.WebhookSecret, payload) {
+			continue
+		}
+		authenticated = true
+
+		if push {
+			name, err := reposource.GitHub{GitHubConnection: c}.CloneURLToRepoName(p.Repository.CloneURL)
+			if err != nil {
+				return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: err}
+			}
+			names = append(names, name)
+		}
+	}
+
+	if !authenticated {
+		return errInvalidWebhookSignature
+	}
+	if !push {
+		// Other events (such as "ping") are acknowledged, but ignored.
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return enqueueWebhookRepoUpdate(r.Context(), w, names)
+}
+
+// serveGitLabWebhook handles push webhooks from GitLab by enqueuing an update
+// of the pushed project.
+//
+// 🚨 SECURITY: This endpoint is accessible to anonymous users. The request is
+// authenticated by its secret token, which must match the "webhookSecret" of a
+// GitLab external service.
+func serveGitLabWebhook(w http.ResponseWriter, r *http.Request) error {
+	payload, err := readWebhookPayload(r)
+	if err != nil {
+		return err
+	}
+
+	conns, err := db.ExternalServices.ListGitLabConnections(r.Context())
+	if err != nil {
+		return err
+	}
+
+	var p struct {
+		Project struct {
+			GitHTTPURL string `json:"git_http_url"`
+		} `json:"project"`
+	}
+	event := r.Header.Get("X-Gitlab-Event")
+	push := event == "Push Hook" || event == "Tag Push Hook"
+	if push {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: err}
+		}
+	}
+
+	var authenticated bool
+	var names []api.RepoName
+	token := r.Header.Get("X-Gitlab-Token")
+	for _, c := range conns {
+		if c.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.WebhookSecret)) != 1 {
+			continue
+		}
+		authenticated = true
+
+		if push {
+			name, err := reposource.GitLab{GitLabConnection: c}.CloneURLToRepoName(p.Project.GitHTTPURL)
+			if err != nil {
+				return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: err}
+			}
+			names = append(names, name)
+		}
+	}
+
+	if !authenticated {
+		return errInvalidWebhookSignature
+	}
+	if !push {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return enqueueWebhookRepoUpdate(r.Context(), w, names)
+}
+
+// serveBitbucketServerWebhook handles repo:refs_changed webhooks from
+// Bitbucket Server by enqueuing an update of the changed repository.
+//
+// 🚨 SECURITY: This endpoint is accessible to anonymous users. The request is
+// authenticated by its signature, which must match the "webhookSecret" of a
+// Bitbucket Server external service.
+func serveBitbucketServerWebhook(w http.ResponseWriter, r *http.Request) error {
+	payload, err := readWebhookPayload(r)
+	if err != nil {
+		return err
+	}
+
+	conns, err := db.ExternalServices.ListBitbucketServerConnections(r.Context())
+	if err != nil {
+		return err
+	}
+
+	var p struct {
+		Repository struct {
+			Slug    string `json:"slug"`
+			Project struct {
+				Key string `json:"key"`
+			} `json:"project"`
+		} `json:"repository"`
+	}
+	push := r.Header.Get("X-Event-Key") == "repo:refs_changed"
+	if push {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: err}
+		}
+	}
+
+	var authenticated bool
+	var names []api.RepoName
+	signature := r.Header.Get("X-Hub-Signature")
+	for _, c := range conns {
+		if !validHMACSignature(signature, "sha256=", sha256.New, c.WebhookSecret, payload) {
+			continue
+		}
+		authenticated = true
+
+		if push {
+			// The payload doesn't contain any URLs of the repository, so each
+			// connection with a matching secret is a candidate.
+			baseURL, err := url.Parse(c.Url)
+			if err != nil {
+				return err
+			}
+			names = append(names, reposource.BitbucketServerRepoName(c.RepositoryPathPattern, baseURL.Hostname(), p.Repository.Project.Key, p.Repository.Slug))
+		}
+	}
+
+	if !authenticated {
+		return errInvalidWebhookSignature
+	}
+	if !push {
+		// Other events (such as "diagnostics:ping") are acknowledged, but ignored.
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return enqueueWebhookRepoUpdate(r.Context(), w, names)
+}
+
+func readWebhookPayload(r *http.Request) ([]byte, error) {
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, maxWebhookPayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxWebhookPayloadSize {
+		return nil, &errcode.HTTPErr{Status: http.StatusRequestEntityTooLarge}
+	}
+	return payload, nil
+}
+
+// validHMACSignature reports whether signature (such as "sha1=0a1b...") is
+// the HMAC of payload with the given secret. An empty secret never matches.
+func validHMACSignature(signature, prefix string, h func() hash.Hash, secret string, payload []byte) bool {
+	if secret == "" || !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// enqueueWebhookRepoUpdate enqueues a high priority update of the first of
+// the candidate repositories that exists on Sourcegraph.
+func enqueueWebhookRepoUpdate(ctx context.Context, w http.ResponseWriter, names []api.RepoName) error {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+
+		repo, err := db.Repos.GetByName(ctx, name)
+		if errcode.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		repoMeta, err := repoupdater.DefaultClient.RepoLookup(ctx, protocol.RepoLookupArgs{
+			Repo:         repo.Name,
+			ExternalRepo: repo.ExternalRepo,
+		})
+		if err != nil {
+			return err
+		}
+		resp, err := repoupdater.DefaultClient.EnqueueRepoUpdate(ctx, gitserver.Repo{
+			Name: repo.Name,
+			URL:  repoMeta.Repo.VCS.URL,
+		})
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, resp)
+	}
+
+	return &errcode.HTTPErr{
+		Status: http.StatusNotFound,
+		Err:    errors.New("repository of webhook not found"),
+	}
+}
@@ -0,0 +1,183 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+)
+
+func TestWebhooks(t *testing.T) {
+	c := newTest()
+
+	db.Mocks.ExternalServices.List = func(opt db.ExternalServicesListOptions) ([]*types.ExternalService, error) {
+		switch opt.Kinds[0] {
+		case "GITHUB":
+			return []*types.ExternalService{
+				{Kind: "GITHUB", Config: `{"url": "https://github.com", "token": "t"}`},
+				{Kind: "GITHUB", Config: `{"url": "https://github.com", "token": "t", "webhookSecret": "s3cr3t"}`},
+			}, nil
+		case "GITLAB":
+			return []*types.ExternalService{
+				{Kind: "GITLAB", Config: `{"url": "https://gitlab.com", "token": "t", "webhookSecret": "s3cr3t"}`},
+			}, nil
+		case "BITBUCKETSERVER":
+			return []*types.ExternalService{
+				{Kind: "BITBUCKETSERVER", Config: `{"url": "https://bitbucket.example.com", "token": "t", "webhookSecret": "s3cr3t"}`},
+			}, nil
+		}
+		return nil, nil
+	}
+	defer func() { db.Mocks = db.MockStores{} }()
+
+	known := map[api.RepoName]bool{
+		"github.com/foo/bar":                 true,
+		"gitlab.com/foo/bar":                 true,
+		"bitbucket.example.com/FOO/bar":      true,
+		"bitbucket.example.com/FOO/notfound": false,
+	}
+	db.Mocks.Repos.GetByName = func(ctx context.Context, name api.RepoName) (*types.Repo, error) {
+		if !known[name] {
+			return nil, notFoundErr{}
+		}
+		return &types.Repo{ID: 1, Name: name}, nil
+	}
+
+	var updated []api.RepoName
+	repoupdater.MockRepoLookup = func(args protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error) {
+		return &protocol.RepoLookupResult{
+			Repo: &protocol.RepoInfo{Name: args.Repo, VCS: protocol.VCSInfo{URL: "https://" + string(args.Repo)}},
+		}, nil
+	}
+	repoupdater.MockEnqueueRepoUpdate = func(ctx context.Context, repo gitserver.Repo) (*protocol.RepoUpdateResponse, error) {
+		updated = append(updated, repo.Name)
+		return &protocol.RepoUpdateResponse{Name: string(repo.Name), URL: repo.URL}, nil
+	}
+	defer func() {
+		repoupdater.MockRepoLookup = nil
+		repoupdater.MockEnqueueRepoUpdate = nil
+	}()
+
+	sign := func(h func() hash.Hash, prefix, secret, payload string) string {
+		mac := hmac.New(h, []byte(secret))
+		mac.Write([]byte(payload))
+		return prefix + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	const (
+		githubPush          = `{"repository": {"clone_url": "https://github.com/foo/bar.git"}}`
+		gitlabPush          = `{"project": {"git_http_url": "https://gitlab.com/foo/bar.git"}}`
+		bitbucketServerPush = `{"repository": {"slug": "bar", "project": {"key": "FOO"}}}`
+		bitbucketServerMiss = `{"repository": {"slug": "notfound", "project": {"key": "FOO"}}}`
+	)
+
+	for _, tc := range []struct {
+		name       string
+		path       string
+		payload    string
+		header     map[string]string
+		wantStatus int
+		wantRepo   api.RepoName
+	}{
+		{
+			name:       "github push",
+			path:       "/webhooks/github",
+			payload:    githubPush,
+			header:     map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature": sign(sha1.New, "sha1=", "s3cr3t", githubPush)},
+			wantStatus: http.StatusOK,
+			wantRepo:   "github.com/foo/bar",
+		},
+		{
+			name:       "github ping",
+			path:       "/webhooks/github",
+			payload:    `{}`,
+			header:     map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature": sign(sha1.New, "sha1=", "s3cr3t", `{}`)},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "github bad signature",
+			path:       "/webhooks/github",
+			payload:    githubPush,
+			header:     map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature": sign(sha1.New, "sha1=", "wrong", githubPush)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "github missing signature",
+			path:       "/webhooks/github",
+			payload:    githubPush,
+			header:     map[string]string{"X-GitHub-Event": "push"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "gitlab push",
+			path:       "/webhooks/gitlab",
+			payload:    gitlabPush,
+			header:     map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "s3cr3t"},
+			wantStatus: http.StatusOK,
+			wantRepo:   "gitlab.com/foo/bar",
+		},
+		{
+			name:       "gitlab bad token",
+			path:       "/webhooks/gitlab",
+			payload:    gitlabPush,
+			header:     map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "wrong"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "bitbucket server refs changed",
+			path:       "/webhooks/bitbucket-server",
+			payload:    bitbucketServerPush,
+			header:     map[string]string{"X-Event-Key": "repo:refs_changed", "X-Hub-Signature": sign(sha256.New, "sha256=", "s3cr3t", bitbucketServerPush)},
+			wantStatus: http.StatusOK,
+			wantRepo:   "bitbucket.example.com/FOO/bar",
+		},
+		{
+			name:       "bitbucket server unknown repo",
+			path:       "/webhooks/bitbucket-server",
+			payload:    bitbucketServerMiss,
+			header:     map[string]string{"X-Event-Key": "repo:refs_changed", "X-Hub-Signature": sign(sha256.New, "sha256=", "s3cr3t", bitbucketServerMiss)},
+			wantStatus: http.StatusNotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			updated = nil
+
+			req, _ := http.NewRequest("POST", tc.path, strings.NewReader(tc.payload))
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+
+			var wantUpdated []api.RepoName
+			if tc.wantRepo != "" {
+				wantUpdated = []api.RepoName{tc.wantRepo}
+			}
+			if len(updated) != len(wantUpdated) || (len(updated) > 0 && updated[0] != wantUpdated[0]) {
+				t.Errorf("got updated repos %v, want %v", updated, wantUpdated)
+			}
+		})
+	}
+}
+
+type notFoundErr struct{}
+
+func (notFoundErr) Error() string  { return "not found" }
+func (notFoundErr) NotFound() bool { return true }
@@ -3,21 +3,25 @@
 package query
 
 import (
+	"errors"
+
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/types"
 )
 
 // All field names.
 const (
-	FieldDefault   = ""
-	FieldCase      = "case"
-	FieldRepo      = "repo"
-	FieldRepoGroup = "repogroup"
-	FieldFile      = "file"
-	FieldFork      = "fork"
-	FieldArchived  = "archived"
-	FieldLang      = "lang"
-	FieldType      = "type"
+	FieldDefault     = ""
+	FieldCase        = "case"
+	FieldRepo        = "repo"
+	FieldRepoGroup   = "repogroup"
+	FieldFile        = "file"
+	FieldFork        = "fork"
+	FieldArchived    = "archived"
+	FieldLang        = "lang"
+	FieldType        = "type"
+	FieldPatternType = "patterntype"
+	FieldMultiline   = "multiline"
 
 	// For diff and commit search only:
 	FieldBefore    = "before"
@@ -26,6 +30,10 @@ const (
 	FieldCommitter = "committer"
 	FieldMessage   = "message"
 
+	// For symbol search only:
+	FieldKind      = "kind"
+	FieldContainer = "container"
+
 	// Temporary experimental fields:
 	FieldIndex   = "index"
 	FieldCount   = "count" // Searches that specify `count:` will fetch at least that number of results, or the full result set
@@ -39,15 +47,17 @@ var (
 
 	conf = types.Config{
 		FieldTypes: map[string]types.FieldType{
-			FieldDefault:   {Literal: types.RegexpType, Quoted: types.StringType},
-			FieldCase:      {Literal: types.BoolType, Quoted: types.BoolType, Singular: true},
-			FieldRepo:      regexpNegatableFieldType,
-			FieldRepoGroup: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldFile:      regexpNegatableFieldType,
-			FieldFork:      {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldArchived:  {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldLang:      {Literal: types.StringType, Quoted: types.StringType, Negatable: true},
-			FieldType:      stringFieldType,
+			FieldDefault:     {Literal: types.RegexpType, Quoted: types.StringType, Negatable: true},
+			FieldCase:        {Literal: types.BoolType, Quoted: types.BoolType, Singular: true},
+			FieldRepo:        regexpNegatableFieldType,
+			FieldRepoGroup:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldFile:        regexpNegatableFieldType,
+			FieldFork:        {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldArchived:    {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldLang:        {Literal: types.StringType, Quoted: types.StringType, Negatable: true},
+			FieldType:        stringFieldType,
+			FieldPatternType: {Literal: types.StringType, Quoted: types.StringType, Singular: true, Values: []string{PatternTypeRegexp, PatternTypeStructural}},
+			FieldMultiline:   {Literal: types.BoolType, Quoted: types.BoolType, Singular: true},
 
 			FieldBefore:    stringFieldType,
 			FieldAfter:     stringFieldType,
@@ -55,6 +65,9 @@ var (
 			FieldCommitter: regexpNegatableFieldType,
 			FieldMessage:   regexpNegatableFieldType,
 
+			FieldKind:      stringFieldType,
+			FieldContainer: stringFieldType,
+
 			// Experimental fields:
 			FieldIndex:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
 			FieldCount:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
@@ -75,6 +88,27 @@ var (
 	}
 )
 
+// All pattern types (values of the patterntype: field).
+const (
+	PatternTypeRegexp     = "regexp"
+	PatternTypeStructural = "structural"
+)
+
+// structuralConfig returns a copy of conf for queries with
+// patterntype:structural. Unquoted patterns are structural match templates
+// such as foo(:[args]), not regexps, so they are typed as strings.
+func structuralConfig(conf *types.Config) *types.Config {
+	c := &types.Config{
+		FieldTypes:   make(map[string]types.FieldType, len(conf.FieldTypes)),
+		FieldAliases: conf.FieldAliases,
+	}
+	for field, typ := range conf.FieldTypes {
+		c.FieldTypes[field] = typ
+	}
+	c.FieldTypes[FieldDefault] = stringFieldType
+	return c
+}
+
 // A Query is the parsed representation of a search query.
 type Query struct {
 	conf *types.Config // the typechecker config used to produce this query
@@ -93,6 +127,16 @@ func parseAndCheck(conf *types.Config, input string) (*Query, error) {
 	if err != nil {
 		return nil, err
 	}
+	structural, err := isStructural(conf, syntaxQuery)
+	if err != nil {
+		return nil, err
+	}
+	if structural {
+		if syntaxQuery.Tree != nil {
+			return nil, &types.TypeError{Pos: syntaxQuery.Tree.Pos, Err: errors.New("boolean operators and parentheses are not supported with patterntype:structural")}
+		}
+		conf = structuralConfig(conf)
+	}
 	checkedQuery, err := conf.Check(syntaxQuery)
 	if err != nil {
 		return nil, err
@@ -118,6 +162,43 @@ func (q *Query) IsCaseSensitive() bool {
 	return q.BoolValue(FieldCase)
 }
 
+// IsMultiline reports whether the query's patterns are matched against whole
+// file contents, so that matches may span multiple lines (multiline:yes).
+func (q *Query) IsMultiline() bool {
+	return q.BoolValue(FieldMultiline)
+}
+
+// IsStructural reports whether the query's patterns are structural match
+// templates (patterntype:structural).
+func (q *Query) IsStructural() bool {
+	patternType, _ := q.StringValue(FieldPatternType)
+	return patternType == PatternTypeStructural
+}
+
+// isStructural reports whether the syntax query sets patterntype:structural.
+// It is used before typechecking to decide how to type the query's patterns,
+// so it only typechecks the query's patterntype: expressions.
+func isStructural(conf *types.Config, q *syntax.Query) (bool, error) {
+	var exprs []*syntax.Expr
+	for _, expr := range q.Expr {
+		field := expr.Field
+		if resolvedField, ok := conf.FieldAliases[field]; ok {
+			field = resolvedField
+		}
+		if field == FieldPatternType {
+			exprs = append(exprs, expr)
+		}
+	}
+	if len(exprs) == 0 {
+		return false, nil
+	}
+	checkedQuery, err := conf.Check(&syntax.Query{Input: q.Input, Expr: exprs})
+	if err != nil {
+		return false, err
+	}
+	return (&Query{conf: conf, Query: checkedQuery}).IsStructural(), nil
+}
+
 // Values returns the values for the given field.
 func (q *Query) Values(field string) []*types.Value {
 	if _, ok := q.conf.FieldTypes[field]; !ok {
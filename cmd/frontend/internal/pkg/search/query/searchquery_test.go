@@ -45,6 +45,22 @@ func TestQuery_IsCaseSensitive(t *testing.T) {
 	})
 }
 
+func TestQuery_IsMultiline(t *testing.T) {
+	for input, want := range map[string]bool{
+		`multiline:yes func \w+\(\)\s*\{`: true,
+		`multiline:no func`:               false,
+		`func`:                            false,
+	} {
+		query, err := ParseAndCheck(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := query.IsMultiline(); got != want {
+			t.Errorf("%s: got IsMultiline() == %v, want %v", input, got, want)
+		}
+	}
+}
+
 func TestQuery_RegexpPatterns(t *testing.T) {
 	conf := types.Config{
 		FieldTypes: map[string]types.FieldType{
@@ -142,3 +158,64 @@ func checkPanic(t *testing.T, msg string, f func()) {
 	}()
 	f()
 }
+
+func TestQuery_IsStructural(t *testing.T) {
+	t.Run("structural", func(t *testing.T) {
+		query, err := ParseAndCheck("patterntype:structural foo(:[args]")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !query.IsStructural() {
+			t.Error("IsStructural() == false, want true")
+		}
+		values := query.Values(FieldDefault)
+		if len(values) != 1 || values[0].String == nil || *values[0].String != "foo(:[args]" {
+			t.Errorf("got default values %v, want the string foo(:[args]", values)
+		}
+	})
+
+	t.Run("quoted", func(t *testing.T) {
+		query, err := ParseAndCheck(`patterntype:"structural" foo(:[args]`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !query.IsStructural() {
+			t.Error("IsStructural() == false, want true")
+		}
+	})
+
+	t.Run("regexp", func(t *testing.T) {
+		query, err := ParseAndCheck("patterntype:regexp foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query.IsStructural() {
+			t.Error("IsStructural() == true, want false")
+		}
+		if _, err := ParseAndCheck("foo(:[args]"); err == nil {
+			t.Error("expected invalid regexp to fail typechecking without patterntype:structural")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := ParseAndCheck("patterntype:literal foo"); err == nil {
+			t.Error("expected unknown pattern type to fail typechecking")
+		}
+	})
+}
+
+func TestParseAndCheck_boolean(t *testing.T) {
+	for _, input := range []string{"foo OR bar", "(foo OR bar) AND NOT baz", "repo:x -foo"} {
+		query, err := ParseAndCheck(input)
+		if err != nil {
+			t.Fatalf("%s: %s", input, err)
+		}
+		if query.Pattern == nil {
+			t.Errorf("%s: got no boolean pattern", input)
+		}
+	}
+
+	if _, err := ParseAndCheck("patterntype:structural (foo(:[x]) OR bar)"); err == nil {
+		t.Error("expected boolean operators to fail typechecking with patterntype:structural")
+	}
+}
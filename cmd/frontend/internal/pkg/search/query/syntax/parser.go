@@ -15,6 +15,9 @@ func (e *ParseError) Error() string {
 type parser struct {
 	tokens []Token
 	pos    int
+
+	exprs   []*Expr // all expressions parsed so far, in order
+	boolean bool    // whether boolean operators or parentheses were parsed
 }
 
 // context holds settings active within a given scope during parsing.
@@ -22,25 +25,42 @@ type context struct {
 	field string // name of the field currently in scope (or "")
 }
 
+// Keywords for boolean operators. They are case sensitive so that searches
+// for "and", "or" and "not" still work.
+const (
+	keywordAnd = "AND"
+	keywordOr  = "OR"
+	keywordNot = "NOT"
+)
+
 // Parse parses the query and returns its parse tree. Returned errors are of
 // type *ParseError, which includes the error position and message.
 //
 // BNF-ish query syntax:
 //
-//   exprList  := {exprSign} | exprSign (sep exprSign)*
-//   exprSign  := {"-"} expr
+//   query     := {sep} [orExpr] {sep}
+//   orExpr    := andExpr {"OR" andExpr}
+//   andExpr   := notExpr {["AND"] notExpr}
+//   notExpr   := "NOT" notExpr | exprSign
+//   exprSign  := {"-"} (expr | "(" orExpr ")")
 //   expr      := fieldExpr | lit | quoted | pattern
 //   fieldExpr := lit ":" value
 //   value     := lit | quoted
+//
+// Terms are separated by sep. Precedence is NOT, then AND, then OR.
 func Parse(input string) (*Query, error) {
 	tokens := Scan(input)
 	p := parser{tokens: tokens}
 	ctx := context{field: ""}
-	exprs, err := p.parseExprList(ctx)
+	tree, err := p.parseQuery(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &Query{Expr: exprs, Input: input}, nil
+	query := &Query{Expr: p.exprs, Input: input}
+	if p.boolean {
+		query.Tree = tree
+	}
+	return query, nil
 }
 
 // peek returns the next token without consuming it. Peeking beyond the end of
@@ -68,34 +88,99 @@ func (p *parser) next() Token {
 	return Token{Type: TokenEOF}
 }
 
-// exprList := {exprSign} | exprSign (sep exprSign)*
-func (p *parser) parseExprList(ctx context) (exprList []*Expr, err error) {
+// skipSep consumes separators.
+func (p *parser) skipSep() {
+	for p.peek().Type == TokenSep {
+		p.next()
+	}
+}
+
+// peekKeyword reports whether the next token (after separators) is the
+// keyword, without consuming it.
+func (p *parser) peekKeyword(keyword string) bool {
+	p.skipSep()
+	tok := p.peek()
+	return tok.Type == TokenLiteral && tok.Value == keyword
+}
+
+// query := {sep} [orExpr] {sep}
+func (p *parser) parseQuery(ctx context) (*Node, error) {
+	p.skipSep()
 	if p.peek().Type == TokenEOF {
 		return nil, nil
 	}
+	node, err := p.parseOr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSep()
+	if tok := p.next(); tok.Type != TokenEOF {
+		return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want EOF", tok.Type)}
+	}
+	return node, nil
+}
 
+// orExpr := andExpr {"OR" andExpr}
+func (p *parser) parseOr(ctx context) (*Node, error) {
+	node, err := p.parseAnd(ctx)
+	if err != nil {
+		return nil, err
+	}
+	operands := []*Node{node}
+	for p.peekKeyword(keywordOr) {
+		p.next()
+		p.boolean = true
+		node, err := p.parseAnd(ctx)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, node)
+	}
+	return newOperatorNode(OpOr, operands), nil
+}
+
+// andExpr := notExpr {["AND"] notExpr}
+func (p *parser) parseAnd(ctx context) (*Node, error) {
+	node, err := p.parseNot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	operands := []*Node{node}
 	for {
-		tok := p.peek()
-		if tok.Type == TokenEOF {
+		p.skipSep()
+		if tok := p.peek(); tok.Type == TokenEOF || tok.Type == TokenRParen || p.peekKeyword(keywordOr) {
 			break
 		}
-		if tok.Type == TokenSep {
+		if p.peekKeyword(keywordAnd) {
 			p.next()
-			continue
+			p.boolean = true
 		}
-
-		expr, err := p.parseExprSign(ctx)
+		node, err := p.parseNot(ctx)
 		if err != nil {
 			return nil, err
 		}
-		exprList = append(exprList, expr)
+		operands = append(operands, node)
 	}
+	return newOperatorNode(OpAnd, operands), nil
+}
 
-	return exprList, nil
+// notExpr := "NOT" notExpr | exprSign
+func (p *parser) parseNot(ctx context) (*Node, error) {
+	if !p.peekKeyword(keywordNot) {
+		return p.parseExprSign(ctx)
+	}
+	tok := p.next()
+	p.boolean = true
+	node, err := p.parseNot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return negate(tok.Pos, node), nil
 }
 
-// exprSign := {"-"} expr
-func (p *parser) parseExprSign(ctx context) (*Expr, error) {
+// exprSign := {"-"} (expr | "(" orExpr ")")
+func (p *parser) parseExprSign(ctx context) (*Node, error) {
+	p.skipSep()
 	tok := p.next()
 	switch tok.Type {
 	case TokenMinus:
@@ -105,17 +190,37 @@ func (p *parser) parseExprSign(ctx context) (*Expr, error) {
 		p.backup()
 	}
 
-	expr, err := p.parseExpr(ctx)
-	if err != nil {
-		return nil, err
+	var node *Node
+	if lparen := p.peek(); lparen.Type == TokenLParen {
+		p.next()
+		p.boolean = true
+		var err error
+		node, err = p.parseOr(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSep()
+		if rparen := p.next(); rparen.Type != TokenRParen {
+			return nil, &ParseError{Pos: lparen.Pos, Msg: "unclosed parenthesis"}
+		}
+	} else {
+		if next := p.peek(); next.Type == TokenLiteral && (next.Value == keywordAnd || next.Value == keywordOr) {
+			return nil, &ParseError{Pos: next.Pos, Msg: fmt.Sprintf("got %s, want expr", next.Value)}
+		}
+		expr, err := p.parseExpr(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.exprs = append(p.exprs, expr)
+		node = &Node{Pos: expr.Pos, Expr: expr}
 	}
 
 	switch tok.Type {
 	case TokenMinus:
-		expr.Not = true
+		node = negate(tok.Pos, node)
 	}
 
-	return expr, nil
+	return node, nil
 }
 
 // expr := exprField | lit | quoted | pattern
@@ -129,29 +234,39 @@ func (p *parser) parseExpr(ctx context) (*Expr, error) {
 			valueTok := p.next()
 			switch valueTok.Type {
 			case TokenLiteral, TokenQuoted:
-				if tok3 := p.next(); tok3.Type != TokenSep && tok3.Type != TokenEOF {
-					return nil, &ParseError{Pos: tok3.Pos, Msg: fmt.Sprintf("got %s, want separator or EOF", tok3.Type)}
+				if err := p.expectEndOfExpr(); err != nil {
+					return nil, err
 				}
 				return &Expr{Pos: tok.Pos, Field: tok.Value, Value: valueTok.Value, ValueType: valueTok.Type}, nil
-			case TokenSep, TokenEOF:
+			case TokenSep, TokenEOF, TokenRParen:
+				p.backup()
 				return &Expr{Pos: tok.Pos, Field: tok.Value, Value: "", ValueType: TokenLiteral}, nil
 			default:
 				return nil, &ParseError{Pos: valueTok.Pos, Msg: fmt.Sprintf("got %s, want value", valueTok.Type)}
 			}
-		case TokenSep, TokenEOF:
+		case TokenSep, TokenEOF, TokenRParen:
+			p.backup()
 			return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
 		default:
 			panic("unreachable")
 		}
 	case TokenQuoted, TokenPattern:
-		tok2 := p.next()
-		switch tok2.Type {
-		case TokenSep, TokenEOF:
-			return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
-		default:
-			return nil, &ParseError{Pos: tok2.Pos, Msg: fmt.Sprintf("got %s, want separator or EOF", tok2.Type)}
+		if err := p.expectEndOfExpr(); err != nil {
+			return nil, err
 		}
+		return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
 	}
 
 	return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want expr", tok.Type)}
 }
+
+// expectEndOfExpr returns an error if the next token can't follow an expr.
+// It doesn't consume the token.
+func (p *parser) expectEndOfExpr() error {
+	switch tok := p.peek(); tok.Type {
+	case TokenSep, TokenEOF, TokenRParen:
+		return nil
+	default:
+		return &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want separator or EOF", tok.Type)}
+	}
+}
@@ -111,3 +111,62 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_tree(t *testing.T) {
+	tests := map[string]struct {
+		wantTree string // empty for no tree
+		wantExpr int    // number of expressions
+		wantErr  *ParseError
+	}{
+		"a b":                   {wantExpr: 2},
+		"(a|b) f()":             {wantExpr: 2},
+		"a AND b":               {wantTree: "a AND b", wantExpr: 2},
+		"a OR b":                {wantTree: "a OR b", wantExpr: 2},
+		"NOT a":                 {wantTree: "-a", wantExpr: 1},
+		"NOT -a":                {wantTree: "a", wantExpr: 1},
+		"( a )":                 {wantTree: "a", wantExpr: 1},
+		"a b OR c":              {wantTree: "a AND b OR c", wantExpr: 3},
+		"a OR b c":              {wantTree: "a OR b AND c", wantExpr: 3},
+		"a OR b OR c":           {wantTree: "a OR b OR c", wantExpr: 3},
+		"(a OR b) AND NOT c":    {wantTree: "(a OR b) AND -c", wantExpr: 3},
+		"(a OR b) c":            {wantTree: "(a OR b) AND c", wantExpr: 3},
+		"x:y (a OR /b c/)":      {wantTree: "x:y AND (a OR /b c/)", wantExpr: 3},
+		"NOT (a OR b)":          {wantTree: "NOT (a OR b)", wantExpr: 2},
+		"-(a b)":                {wantTree: "NOT (a AND b)", wantExpr: 2},
+		"NOT NOT (a b)":         {wantTree: "a AND b", wantExpr: 2},
+		"((a OR b) (c OR d)) e": {wantTree: "(a OR b) AND (c OR d) AND e", wantExpr: 5},
+		`("a" OR 'b') c:`:       {wantTree: `("a" OR 'b') AND c:`, wantExpr: 3},
+		"and or not":            {wantExpr: 3},
+		"OR a":                  {wantErr: &ParseError{Pos: 0, Msg: "got OR, want expr"}},
+		"a AND":                 {wantErr: &ParseError{Pos: 5, Msg: "got TokenEOF, want expr"}},
+		"a OR AND b":            {wantErr: &ParseError{Pos: 5, Msg: "got AND, want expr"}},
+		"(a OR b":               {wantErr: &ParseError{Pos: 0, Msg: "unclosed parenthesis"}},
+		"( )":                   {wantErr: &ParseError{Pos: 2, Msg: "got TokenRParen, want expr"}},
+		`("a"b OR c)`:           {wantErr: &ParseError{Pos: 4, Msg: "got TokenLiteral, want separator or EOF"}},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			query, err := Parse(input)
+			if err != nil && test.wantErr == nil {
+				t.Fatal(err)
+			} else if err == nil && test.wantErr != nil {
+				t.Fatalf("got err == nil, want %q", test.wantErr)
+			} else if test.wantErr != nil && !reflect.DeepEqual(err, test.wantErr) {
+				t.Fatalf("got err == %q, want %q", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var tree string
+			if query.Tree != nil {
+				tree = query.Tree.String()
+			}
+			if tree != test.wantTree {
+				t.Errorf("tree: got %q, want %q", tree, test.wantTree)
+			}
+			if len(query.Expr) != test.wantExpr {
+				t.Errorf("got %d exprs, want %d", len(query.Expr), test.wantExpr)
+			}
+		})
+	}
+}
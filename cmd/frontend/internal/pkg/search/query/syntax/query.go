@@ -9,6 +9,122 @@ import (
 type Query struct {
 	Input string  // the original input query string
 	Expr  []*Expr // expressions in this query
+
+	// Tree is the boolean expression tree of the query. Its leaves are the
+	// expressions of Expr. It is nil if the query uses no boolean operators
+	// or parentheses, in which case the expressions are implicitly ANDed.
+	Tree *Node
+}
+
+// An Operator is a boolean operator in a query.
+type Operator int
+
+// All Operator values.
+const (
+	OpAnd Operator = iota + 1
+	OpOr
+	OpNot
+)
+
+// A Node is a node in the boolean expression tree of a query. It is either a
+// leaf holding an expression or an operator applied to its operands.
+type Node struct {
+	Pos      int      // the starting character position of the node
+	Expr     *Expr    // if a leaf, the expression
+	Op       Operator // if not a leaf, the operator
+	Operands []*Node  // if not a leaf, the operands (exactly 1 for OpNot)
+}
+
+// newOperatorNode returns a node applying op (OpAnd or OpOr) to operands.
+// Nested operands with the same operator are flattened, and a single operand
+// is returned as is.
+func newOperatorNode(op Operator, operands []*Node) *Node {
+	if len(operands) == 1 {
+		return operands[0]
+	}
+	node := &Node{Pos: operands[0].Pos, Op: op}
+	for _, operand := range operands {
+		if operand.Expr == nil && operand.Op == op {
+			node.Operands = append(node.Operands, operand.Operands...)
+		} else {
+			node.Operands = append(node.Operands, operand)
+		}
+	}
+	return node
+}
+
+// negate returns the negation of node. Leaves are negated in place, like a
+// term prefixed with "-".
+func negate(pos int, node *Node) *Node {
+	switch {
+	case node.Expr != nil:
+		node.Expr.Not = !node.Expr.Not
+		return node
+	case node.Op == OpNot:
+		return node.Operands[0]
+	default:
+		return &Node{Pos: pos, Op: OpNot, Operands: []*Node{node}}
+	}
+}
+
+// Map returns a copy of the tree with each leaf's expression replaced by
+// f(expr). Leaves for which f returns nil are removed, as are operators left
+// without operands. It returns nil if no leaves remain.
+func (n *Node) Map(f func(*Expr) *Expr) *Node {
+	if n.Expr != nil {
+		expr := f(n.Expr)
+		if expr == nil {
+			return nil
+		}
+		return &Node{Pos: n.Pos, Expr: expr}
+	}
+	node := &Node{Pos: n.Pos, Op: n.Op}
+	for _, operand := range n.Operands {
+		if operand := operand.Map(f); operand != nil {
+			node.Operands = append(node.Operands, operand)
+		}
+	}
+	switch {
+	case len(node.Operands) == 0:
+		return nil
+	case len(node.Operands) == 1 && node.Op != OpNot:
+		return node.Operands[0]
+	}
+	return node
+}
+
+// precedence returns the binding strength of op. Operators with higher
+// precedence bind more tightly.
+func (op Operator) precedence() int {
+	switch op {
+	case OpNot:
+		return 3
+	case OpAnd:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func (n *Node) String() string {
+	if n.Expr != nil {
+		return n.Expr.String()
+	}
+	s := make([]string, len(n.Operands))
+	for i, operand := range n.Operands {
+		s[i] = operand.String()
+		if operand.Expr == nil && operand.Op.precedence() < n.Op.precedence() {
+			s[i] = "(" + s[i] + ")"
+		}
+	}
+	switch n.Op {
+	case OpNot:
+		return "NOT " + s[0]
+	case OpAnd:
+		return strings.Join(s, " AND ")
+	default:
+		return strings.Join(s, " OR ")
+	}
 }
 
 // An Expr describes an expression in a query.
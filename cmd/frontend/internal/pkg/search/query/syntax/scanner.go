@@ -22,6 +22,8 @@ const (
 	TokenColon
 	TokenMinus
 	TokenSep // separator (like a semicolon)
+	TokenLParen
+	TokenRParen
 )
 
 var singleCharTokens = map[rune]TokenType{
@@ -54,6 +56,7 @@ type scanner struct {
 	pos     int
 	prevPos int
 	start   int
+	depth   int // number of groups opened by TokenLParen that are not yet closed
 }
 
 func (s *scanner) next() rune {
@@ -111,6 +114,18 @@ func scanDefault(s *scanner) stateFn {
 			s.emit(typ)
 			return scanDefault
 		}
+		if r == '(' && opensGroup(s.input[s.pos:]) {
+			s.next()
+			s.depth++
+			s.emit(TokenLParen)
+			return scanDefault
+		}
+		if r == ')' && s.depth > 0 {
+			s.next()
+			s.depth--
+			s.emit(TokenRParen)
+			return scanDefault
+		}
 
 		if r == '"' || r == '\'' {
 			return scanQuoted
@@ -180,10 +195,46 @@ func scanLiteral(s *scanner) stateFn {
 		}
 	}
 
+	// Trailing parentheses close open groups, unless they are balanced by
+	// opening parentheses in the literal (as in "foo()").
+	n := closingParens(s.input[s.start:s.pos], s.depth)
+	s.pos -= n
 	s.emit(TokenLiteral)
+	for i := 0; i < n; i++ {
+		s.next()
+		s.depth--
+		s.emit(TokenRParen)
+	}
 	return scanDefault
 }
 
+// opensGroup reports whether the '(' at the start of input opens a group. It
+// does if the parentheses in the term (up to the next space) are unbalanced,
+// so that a term like "(a|b)" remains a regexp.
+func opensGroup(input string) bool {
+	term := input
+	if i := strings.IndexFunc(input, unicode.IsSpace); i >= 0 {
+		term = input[:i]
+	}
+	return strings.Count(term, "(") > strings.Count(term, ")")
+}
+
+// closingParens returns the number of trailing ')' in lit that close groups,
+// given that depth groups are open.
+func closingParens(lit string, depth int) int {
+	n := len(lit) - len(strings.TrimRight(lit, ")"))
+	if excess := strings.Count(lit, ")") - strings.Count(lit, "("); excess < n {
+		n = excess
+	}
+	if depth < n {
+		n = depth
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
 func scanQuoted(s *scanner) stateFn {
 	q := s.next()
 	escaped := false
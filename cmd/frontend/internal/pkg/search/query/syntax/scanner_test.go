@@ -67,6 +67,19 @@ func TestScanner(t *testing.T) {
 		"a /b/ c":  {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern, TokenSep, TokenLiteral}, wantValues: []string{"a", " ", "b", " ", "c"}},
 		"a /b c":   {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern}, wantValues: []string{"a", " ", "b c"}},
 		"a /b c/":  {wantTypes: []TokenType{TokenLiteral, TokenSep, TokenPattern}, wantValues: []string{"a", " ", "b c"}},
+		"(a b)":    {wantTypes: []TokenType{TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"(", "a", " ", "b", ")"}},
+		"((a b))":  {wantTypes: []TokenType{TokenLParen, TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen, TokenRParen}},
+		"( a )":    {wantTypes: []TokenType{TokenLParen, TokenSep, TokenLiteral, TokenSep, TokenRParen}},
+		"(a|b)":    {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"(a|b)"}},
+		"f()":      {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"f()"}},
+		"a)":       {wantTypes: []TokenType{TokenLiteral}, wantValues: []string{"a)"}},
+		"(f() b)":  {wantTypes: []TokenType{TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"(", "f()", " ", "b", ")"}},
+		"(a f(b))": {wantTypes: []TokenType{TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"(", "a", " ", "f(b)", ")"}},
+		"(a b) c)": {wantTypes: []TokenType{TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen, TokenSep, TokenLiteral}, wantValues: []string{"(", "a", " ", "b", ")", " ", "c)"}},
+		`("a" b)`:  {wantTypes: []TokenType{TokenLParen, TokenQuoted, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"(", `"a"`, " ", "b", ")"}},
+		"(/a/ b)":  {wantTypes: []TokenType{TokenLParen, TokenPattern, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"(", "a", " ", "b", ")"}},
+		"(a:b c)":  {wantTypes: []TokenType{TokenLParen, TokenLiteral, TokenColon, TokenLiteral, TokenSep, TokenLiteral, TokenRParen}, wantValues: []string{"(", "a", ":", "b", " ", "c", ")"}},
+		"-(a b)":   {wantTypes: []TokenType{TokenMinus, TokenLParen, TokenLiteral, TokenSep, TokenLiteral, TokenRParen}},
 	}
 	for input, test := range tests {
 		t.Run(input, func(t *testing.T) {
@@ -33,6 +33,10 @@ type FieldType struct {
 	Singular  bool      // whether the field may only be used 0 or 1 times
 	Negatable bool      // whether the field can be matched negated (i.e., -field:value)
 
+	// Values are the only values the field accepts, if any. It only applies
+	// to fields of type StringType.
+	Values []string
+
 	// FeatureFlagEnabled returns true if this field is enabled.
 	// The field is always enabled if this is nil.
 	FeatureFlagEnabled func() bool
@@ -44,6 +48,9 @@ func (c *Config) Check(query *syntax.Query) (*Query, error) {
 		Syntax: query,
 		Fields: map[string][]*Value{},
 	}
+	fields := make(map[*syntax.Expr]string, len(query.Expr))
+	values := make(map[*syntax.Expr]*Value, len(query.Expr))
+	negatedPattern := false
 	for _, expr := range query.Expr {
 		field, fieldType, value, err := c.checkExpr(expr)
 		if err != nil {
@@ -53,10 +60,92 @@ func (c *Config) Check(query *syntax.Query) (*Query, error) {
 			return nil, &TypeError{Pos: expr.Pos, Err: fmt.Errorf("field %q may not be used more than once", field)}
 		}
 		checkedQuery.Fields[field] = append(checkedQuery.Fields[field], value)
+		fields[expr], values[expr] = field, value
+		if field == "" && expr.Not {
+			negatedPattern = true
+		}
+	}
+
+	switch {
+	case query.Tree != nil:
+		pattern, err := checkPatternTree(query.Tree, fields, values, true)
+		if err != nil {
+			return nil, err
+		}
+		checkedQuery.Pattern = pattern
+	case negatedPattern:
+		checkedQuery.Pattern = negatedPatternTree(query.Expr, fields, values)
 	}
 	return &checkedQuery, nil
 }
 
+// negatedPatternTree returns the boolean combination of the implicitly ANDed
+// default field's values in exprs, some of which are negated. Negated values
+// can't be combined in order with the others, so they are ANDed with them
+// like an explicit AND (and apply to the whole file). The values that are not
+// negated still match in order on a single line.
+func negatedPatternTree(exprs []*syntax.Expr, fields map[*syntax.Expr]string, values map[*syntax.Expr]*Value) *PatternNode {
+	inOrder := &PatternNode{Op: syntax.OpAnd, InOrder: true}
+	var negated []*PatternNode
+	for _, expr := range exprs {
+		if fields[expr] != "" {
+			continue
+		}
+		if expr.Not {
+			negated = append(negated, &PatternNode{Value: values[expr]})
+		} else {
+			inOrder.Operands = append(inOrder.Operands, &PatternNode{Value: values[expr]})
+		}
+	}
+
+	pattern := &PatternNode{Op: syntax.OpAnd}
+	switch len(inOrder.Operands) {
+	case 0:
+	case 1:
+		pattern.Operands = append(pattern.Operands, inOrder.Operands[0])
+	default:
+		pattern.Operands = append(pattern.Operands, inOrder)
+	}
+	pattern.Operands = append(pattern.Operands, negated...)
+	if len(pattern.Operands) == 1 {
+		return pattern.Operands[0]
+	}
+	return pattern
+}
+
+// checkPatternTree returns the boolean combination of the default field's
+// values in node. Expressions of other fields are omitted from it; they may
+// only be used in the top-level chain of ANDed expressions.
+func checkPatternTree(node *syntax.Node, fields map[*syntax.Expr]string, values map[*syntax.Expr]*Value, topLevel bool) (*PatternNode, error) {
+	if node.Expr != nil {
+		if field := fields[node.Expr]; field != "" {
+			if !topLevel {
+				return nil, &TypeError{Pos: node.Pos, Err: fmt.Errorf("field %q may not be used inside OR or NOT", field)}
+			}
+			return nil, nil
+		}
+		return &PatternNode{Value: values[node.Expr]}, nil
+	}
+
+	pattern := &PatternNode{Op: node.Op}
+	for _, operand := range node.Operands {
+		p, err := checkPatternTree(operand, fields, values, topLevel && node.Op == syntax.OpAnd)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			pattern.Operands = append(pattern.Operands, p)
+		}
+	}
+	switch {
+	case len(pattern.Operands) == 0:
+		return nil, nil
+	case len(pattern.Operands) == 1 && node.Op != syntax.OpNot:
+		return pattern.Operands[0], nil
+	}
+	return pattern, nil
+}
+
 func (c *Config) resolveField(field string, not bool) (resolvedField string, typ FieldType, err error) {
 	// Resolve field alias, if any.
 	if resolvedField, ok := c.FieldAliases[field]; ok {
@@ -115,6 +204,11 @@ func (c *Config) checkExpr(expr *syntax.Expr) (field string, fieldType FieldType
 		}
 	}
 
+	if len(fieldType.Values) > 0 && value.String != nil && !containsString(fieldType.Values, *value.String) {
+		err := fmt.Errorf("invalid value %q for field %q (valid values are: %s)", *value.String, resolvedField, strings.Join(fieldType.Values, ", "))
+		return "", FieldType{}, nil, &TypeError{Pos: expr.Pos, Err: err}
+	}
+
 	return resolvedField, fieldType, value, nil
 }
 
@@ -141,6 +235,15 @@ func setValue(dst *Value, valueString string, valueType ValueType) error {
 	return nil
 }
 
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 var leftParenRx = regexp.MustCompile(`([^\\]|^)\($`)
 var squareBraceRx = regexp.MustCompile(`([^\\]|^)\[$`)
 var leftRightParenRx = regexp.MustCompile(`([^\\]|^)\(\)`)
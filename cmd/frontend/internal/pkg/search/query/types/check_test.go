@@ -2,8 +2,10 @@ package types
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/leanovate/gopter"
@@ -46,6 +48,11 @@ func TestCheck(t *testing.T) {
 				Quoted:   BoolType,
 				Singular: true,
 			},
+			"t": {
+				Literal: StringType,
+				Quoted:  StringType,
+				Values:  []string{"x", "y"},
+			},
 		},
 		FieldAliases: map[string]string{
 			"f":  "",
@@ -73,6 +80,8 @@ func TestCheck(t *testing.T) {
 		"b:yes":   {want: map[string][]value{"b": {{Value: true}}}},
 		"b:no":    {want: map[string][]value{"b": {{Value: false}}}},
 		`b:"yes"`: {want: map[string][]value{"b": {{Value: true}}}},
+		"t:x":     {want: map[string][]value{"t": {{Value: "x"}}}},
+		`t:"y"`:   {want: map[string][]value{"t": {{Value: "y"}}}},
 		`a "b" 'cd'`: {want: map[string][]value{"": {
 			{Value: regexp.MustCompile("a")},
 			{Value: "b"},
@@ -100,6 +109,7 @@ func TestCheck(t *testing.T) {
 		`"\z"`:       {wantErr: &TypeError{Pos: 0, Err: errors.New(`invalid quoted string: "\z"`)}},
 		"b:z":        {wantErr: &TypeError{Pos: 0, Err: errors.New(`invalid boolean "z"`)}},
 		`b:"z"`:      {wantErr: &TypeError{Pos: 0, Err: errors.New(`invalid boolean "z"`)}},
+		"t:z":        {wantErr: &TypeError{Pos: 0, Err: errors.New(`invalid value "z" for field "t" (valid values are: x, y)`)}},
 		"z:a":        {wantErr: &TypeError{Pos: 0, Err: errors.New(`unrecognized field "z"`)}},
 	}
 	for input, test := range tests {
@@ -126,6 +136,84 @@ func TestCheck(t *testing.T) {
 	}
 }
 
+func TestCheck_pattern(t *testing.T) {
+	conf := Config{
+		FieldTypes: map[string]FieldType{
+			"": {
+				Literal:   RegexpType,
+				Quoted:    StringType,
+				Negatable: true,
+			},
+			"r": {
+				Literal:   RegexpType,
+				Quoted:    RegexpType,
+				Negatable: true,
+			},
+		},
+	}
+	tests := map[string]struct {
+		want    string // empty for no pattern
+		wantErr *TypeError
+	}{
+		"a b":                    {},
+		"a -b":                   {want: "(and a (not b))"},
+		"a r:x b -c -d":          {want: "(and (in-order a b) (not c) (not d))"},
+		"-a":                     {want: "(not a)"},
+		"a AND b":                {want: "(and a b)"},
+		`(a OR "b") c`:           {want: "(and (or a b) c)"},
+		"r:x (a OR b)":           {want: "(or a b)"},
+		"r:x AND NOT r:y a":      {want: "a"},
+		"r:x OR r:y":             {wantErr: &TypeError{Pos: 0, Err: errors.New(`field "r" may not be used inside OR or NOT`)}},
+		"NOT (a r:x)":            {wantErr: &TypeError{Pos: 7, Err: errors.New(`field "r" may not be used inside OR or NOT`)}},
+		"r:x AND r:y":            {},
+		"NOT (a OR b) AND NOT c": {want: "(and (not (or a b)) (not c))"},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			syntaxQuery, err := syntax.Parse(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := conf.Check(syntaxQuery)
+			if err != nil && test.wantErr == nil {
+				t.Fatal(err)
+			} else if err == nil && test.wantErr != nil {
+				t.Fatalf("got err == nil, want %q", test.wantErr)
+			} else if test.wantErr != nil && err.Error() != test.wantErr.Error() {
+				t.Fatalf("got err == %q, want %q", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := patternString(query.Pattern); got != test.want {
+				t.Errorf("pattern: got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func patternString(p *PatternNode) string {
+	switch {
+	case p == nil:
+		return ""
+	case p.Value != nil:
+		s := fmt.Sprint(p.Value.Value())
+		if p.Value.Not() {
+			return "(not " + s + ")"
+		}
+		return s
+	}
+	s := make([]string, len(p.Operands))
+	for i, operand := range p.Operands {
+		s[i] = patternString(operand)
+	}
+	op := map[syntax.Operator]string{syntax.OpAnd: "and", syntax.OpOr: "or", syntax.OpNot: "not"}[p.Op]
+	if p.InOrder {
+		op = "in-order"
+	}
+	return "(" + op + " " + strings.Join(s, " ") + ")"
+}
+
 func TestUnquoteString(t *testing.T) {
 	tests := map[string]string{
 		`"ab"`:    "ab",
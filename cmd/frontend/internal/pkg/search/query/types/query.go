@@ -10,6 +10,26 @@ import (
 type Query struct {
 	Syntax *syntax.Query       // the query syntax
 	Fields map[string][]*Value // map of field name -> values
+
+	// Pattern is the boolean combination of the values of the default field
+	// (""). It is nil if the query uses no boolean operators, parentheses or
+	// negated default field values, in which case the values are implicitly
+	// ANDed. It is also nil if the boolean combination has no values.
+	Pattern *PatternNode
+}
+
+// A PatternNode is a node in the boolean expression tree of the values of
+// the default field. It is either a leaf holding a value (which may be
+// negated) or an operator applied to its operands.
+type PatternNode struct {
+	Value    *Value          // if a leaf, the value
+	Op       syntax.Operator // if not a leaf, the operator
+	Operands []*PatternNode  // if not a leaf, the operands (exactly 1 for syntax.OpNot)
+
+	// InOrder, for a syntax.OpAnd node, is whether its operands (which are
+	// leaves) are implicitly ANDed values that must match in order on a
+	// single line, like the values of a query without a pattern tree.
+	InOrder bool
 }
 
 // ValueType is the set of types of values in queries.
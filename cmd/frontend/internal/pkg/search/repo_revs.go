@@ -59,6 +59,10 @@ func (r1 RevisionSpecifier) Less(r2 RevisionSpecifier) bool {
 type RepositoryRevisions struct {
 	Repo *types.Repo
 	Revs []RevisionSpecifier
+
+	// RefGlobLimitHit is whether the ref globs matched more refs than are
+	// searched, so that some of the refs are not in Revs.
+	RefGlobLimitHit bool
 }
 
 // ParseRepositoryRevisions parses strings that refer to a repository and 0
@@ -12,12 +12,20 @@ import (
 type PatternInfo struct {
 	Pattern         string
 	IsRegExp        bool
+	IsStructuralPat bool
 	IsWordMatch     bool
 	IsCaseSensitive bool
 	FileMatchLimit  int32
 
-	// We do not support IsMultiline
-	//IsMultiline     bool
+	// IsMultiline is whether Pattern is matched against whole file contents
+	// instead of line by line, so that matches may span multiple lines.
+	IsMultiline bool
+
+	// CountOnly is whether only the number of matches in each file is
+	// needed. The backends then don't limit the number of matches in a file,
+	// and searcher returns the count instead of the matches.
+	CountOnly bool
+
 	IncludePattern  string
 	IncludePatterns []string
 	ExcludePattern  string
@@ -27,10 +35,49 @@ type PatternInfo struct {
 
 	PatternMatchesContent bool
 	PatternMatchesPath    bool
+
+	// PatternExpr, if non-nil, is a boolean combination of regexp patterns
+	// that is matched instead of Pattern. Pattern is then the union of its
+	// non-negated patterns, for backends that don't support PatternExpr.
+	PatternExpr *PatternExpr
+}
+
+// PatternExpr is a boolean combination of regexp patterns, such as
+// (foo OR bar) AND NOT baz. Keep it in sync with
+// cmd/searcher/protocol.PatternExpr.
+type PatternExpr struct {
+	Op       PatternOp      `json:",omitempty"` // the operator, or empty for a leaf
+	Pattern  string         `json:",omitempty"` // if a leaf, the regexp pattern
+	Operands []*PatternExpr `json:",omitempty"` // the operands of Op (exactly 1 for PatternOpNot)
+}
+
+// PatternOp is a boolean operator in a PatternExpr.
+type PatternOp string
+
+// All PatternOp values.
+const (
+	PatternOpAnd PatternOp = "and"
+	PatternOpOr  PatternOp = "or"
+	PatternOpNot PatternOp = "not"
+)
+
+// Patterns returns the patterns of the leaves of e that are negated or not.
+func (e *PatternExpr) Patterns(negated bool) []string {
+	if e.Op == "" {
+		if negated {
+			return nil
+		}
+		return []string{e.Pattern}
+	}
+	var patterns []string
+	for _, operand := range e.Operands {
+		patterns = append(patterns, operand.Patterns(negated != (e.Op == PatternOpNot))...)
+	}
+	return patterns
 }
 
 func (p *PatternInfo) IsEmpty() bool {
-	return p.Pattern == "" && p.ExcludePattern == "" && len(p.IncludePatterns) == 0 && p.IncludePattern == ""
+	return p.Pattern == "" && p.PatternExpr == nil && p.ExcludePattern == "" && len(p.IncludePatterns) == 0 && p.IncludePattern == ""
 }
 
 // Validate returns a non-nil error if PatternInfo is not valid.
@@ -39,6 +86,15 @@ func (p *PatternInfo) Validate() error {
 		if _, err := syntax.Parse(p.Pattern, syntax.Perl); err != nil {
 			return err
 		}
+		if p.PatternExpr != nil {
+			for _, negated := range []bool{false, true} {
+				for _, pattern := range p.PatternExpr.Patterns(negated) {
+					if _, err := syntax.Parse(pattern, syntax.Perl); err != nil {
+						return err
+					}
+				}
+			}
+		}
 	}
 
 	if p.PathPatternsAreRegExps {
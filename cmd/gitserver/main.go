@@ -2,6 +2,7 @@
 package main // import "github.com/sourcegraph/sourcegraph/cmd/gitserver"
 
 import (
+	"encoding/json"
 	"log"
 	"net"
 	"net/http"
@@ -18,14 +19,18 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/gitserver/server"
 	"github.com/sourcegraph/sourcegraph/pkg/debugserver"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
+	gitserverclient "github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/tracer"
 )
 
 var (
-	reposDir          = env.Get("SRC_REPOS_DIR", "/data/repos", "Root dir containing repos.")
-	runRepoCleanup, _ = strconv.ParseBool(env.Get("SRC_RUN_REPO_CLEANUP", "", "Periodically remove inactive repositories."))
-	wantFreeG         = env.Get("SRC_REPOS_DESIRED_FREE_GB", "10", "How many gigabytes of space to keep free on the disk with the repos")
-	janitorInterval   = env.Get("SRC_REPOS_JANITOR_INTERVAL", "1m", "Interval between cleanup runs")
+	reposDir               = env.Get("SRC_REPOS_DIR", "/data/repos", "Root dir containing repos.")
+	runRepoCleanup, _      = strconv.ParseBool(env.Get("SRC_RUN_REPO_CLEANUP", "", "Periodically remove inactive repositories."))
+	wantFreeG              = env.Get("SRC_REPOS_DESIRED_FREE_GB", "10", "How many gigabytes of space to keep free on the disk with the repos")
+	janitorInterval        = env.Get("SRC_REPOS_JANITOR_INTERVAL", "1m", "Interval between cleanup runs")
+	maintenanceInterval    = env.Get("SRC_REPOS_MAINTENANCE_INTERVAL", "168h", "Interval between git maintenance runs of each repo. 0 disables maintenance, and repos are recloned periodically instead.")
+	maintenanceConcurrency = env.Get("SRC_REPOS_MAINTENANCE_CONCURRENCY", "1", "Maximum number of repos that git maintenance runs on at the same time")
+	auditLogMaxSizeMB      = env.Get("SRC_GITSERVER_AUDIT_LOG_MAX_SIZE_MB", "100", "Maximum size in megabytes of each of the 5 files of the audit log of executed git commands. 0 disables the audit log.")
 )
 
 func main() {
@@ -44,10 +49,26 @@ func main() {
 	if err != nil {
 		log.Fatalf("parsing $SRC_REPOS_DESIRED_FREE_GB: %v", err)
 	}
+	maintenanceInterval2, err := time.ParseDuration(maintenanceInterval)
+	if err != nil {
+		log.Fatalf("parsing $SRC_REPOS_MAINTENANCE_INTERVAL: %v", err)
+	}
+	maintenanceConcurrency2, err := strconv.Atoi(maintenanceConcurrency)
+	if err != nil {
+		log.Fatalf("parsing $SRC_REPOS_MAINTENANCE_CONCURRENCY: %v", err)
+	}
+	auditLogMaxSizeMB2, err := strconv.Atoi(auditLogMaxSizeMB)
+	if err != nil {
+		log.Fatalf("parsing $SRC_GITSERVER_AUDIT_LOG_MAX_SIZE_MB: %v", err)
+	}
 	gitserver := server.Server{
 		ReposDir:                reposDir,
 		DeleteStaleRepositories: runRepoCleanup,
 		DesiredFreeDiskSpace:    uint64(wantFreeG2 * 1024 * 1024 * 1024),
+		MaintenanceInterval:     maintenanceInterval2,
+		MaintenanceConcurrency:  maintenanceConcurrency2,
+		AuditLogMaxSize:         int64(auditLogMaxSizeMB2) * 1024 * 1024,
+		GitServerAddrs:          gitserverclient.DefaultClient.Addrs,
 	}
 	gitserver.RegisterMetrics()
 
@@ -62,7 +83,19 @@ func main() {
 	// Create Handler now since it also initializes state
 	handler := nethttp.Middleware(opentracing.GlobalTracer(), gitserver.Handler())
 
-	go debugserver.Start()
+	go debugserver.Start(debugserver.Endpoint{
+		Name: "Repo Transfers",
+		Path: "/repo-transfers",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, err := json.MarshalIndent(gitserver.TransferStatus(), "", "  ")
+			if err != nil {
+				http.Error(w, "failed to marshal transfer status: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(d)
+		}),
+	})
 
 	janitorInterval2, err := time.ParseDuration(janitorInterval)
 	if err != nil {
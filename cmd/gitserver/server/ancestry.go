@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/golang/groupcache/lru"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// maxAncestryOthers is the maximum number of other commits a single commit
+// ancestry request may relate the commit to.
+const maxAncestryOthers = 100
+
+var commitIDPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+func (s *Server) handleCommitAncestry(w http.ResponseWriter, r *http.Request) {
+	var req protocol.CommitAncestryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !commitIDPattern.MatchString(string(req.Commit)) {
+		http.Error(w, "invalid commit ID "+string(req.Commit), http.StatusBadRequest)
+		return
+	}
+	if len(req.Others) > maxAncestryOthers {
+		http.Error(w, "too many other commits", http.StatusBadRequest)
+		return
+	}
+	for _, other := range req.Others {
+		if !commitIDPattern.MatchString(string(other)) {
+			http.Error(w, "invalid commit ID "+string(other), http.StatusBadRequest)
+			return
+		}
+	}
+
+	req.Repo = protocol.NormalizeRepo(req.Repo)
+	dir := path.Join(s.ReposDir, string(req.Repo))
+	if cloneProgress, cloneInProgress := s.locker.Status(dir); cloneInProgress {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(&protocol.NotFoundPayload{
+			CloneInProgress: true,
+			CloneProgress:   cloneProgress,
+		})
+		return
+	}
+	if !repoCloned(dir) {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(&protocol.NotFoundPayload{CloneInProgress: false})
+		return
+	}
+	// Handle both bare repos and repos with a .git subdirectory.
+	gitDir := dir
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		gitDir = filepath.Join(dir, ".git")
+	}
+	s.ensureCommitGraph(gitDir)
+
+	ctx, cancel := context.WithTimeout(r.Context(), shortGitCommandTimeout(nil))
+	defer cancel()
+
+	var resp protocol.CommitAncestryResponse
+	if req.ContainingRefs {
+		refs, err := s.containingRefs(ctx, req.Repo, gitDir, req.Commit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.ContainingRefs = refs
+	}
+	if len(req.Others) > 0 {
+		resp.Relations = make(map[api.CommitID]protocol.CommitRelation, len(req.Others))
+		for _, other := range req.Others {
+			rel, err := s.commitRelation(ctx, req.Repo, gitDir, req.Commit, other)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			resp.Relations[other] = rel
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// containingRefs returns the full names of the branches and tags of the repo
+// at gitDir whose history contains commit.
+func (s *Server) containingRefs(ctx context.Context, repo api.RepoName, gitDir string, commit api.CommitID) ([]string, error) {
+	// The refs containing a commit only change when the refs of the repo
+	// change, which is tracked by the hash of all refs in sg_refhash. Without
+	// it, we can't tell when to invalidate the result, so don't cache.
+	refHash, err := ioutil.ReadFile(filepath.Join(gitDir, "sg_refhash"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	key := containingRefsKey{repo: repo, commit: commit, refHash: string(refHash)}
+	if len(refHash) > 0 {
+		if refs, ok := s.ancestry.get(key); ok {
+			return refs.([]string), nil
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-c", "core.commitGraph=true", "for-each-ref", "--contains", string(commit), "--format=%(refname)", "refs/heads/", "refs/tags/")
+	cmd.Dir = gitDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, wrapCmdError(cmd, err)
+	}
+	refs := []string{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if ref := strings.TrimSpace(sc.Text()); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+
+	if len(refHash) > 0 {
+		s.ancestry.add(key, refs)
+	}
+	return refs, nil
+}
+
+// commitRelation returns how commit relates to other in the commit graph of
+// the repo at gitDir.
+func (s *Server) commitRelation(ctx context.Context, repo api.RepoName, gitDir string, commit, other api.CommitID) (protocol.CommitRelation, error) {
+	if commit == other {
+		return protocol.CommitRelationEqual, nil
+	}
+	// The history of a commit never changes, so the relation can be cached
+	// for as long as we like.
+	key := commitRelationKey{repo: repo, commit: commit, other: other}
+	if rel, ok := s.ancestry.get(key); ok {
+		return rel.(protocol.CommitRelation), nil
+	}
+
+	rel := protocol.CommitRelationDiverged
+	if ok, err := isAncestor(ctx, gitDir, commit, other); err != nil {
+		return "", err
+	} else if ok {
+		rel = protocol.CommitRelationAncestor
+	} else if ok, err := isAncestor(ctx, gitDir, other, commit); err != nil {
+		return "", err
+	} else if ok {
+		rel = protocol.CommitRelationDescendant
+	}
+
+	s.ancestry.add(key, rel)
+	return rel, nil
+}
+
+// isAncestor reports whether ancestor is in the history of descendant.
+func isAncestor(ctx context.Context, gitDir string, ancestor, descendant api.CommitID) (bool, error) {
+	cmd := exec.CommandContext(ctx, "git", "-c", "core.commitGraph=true", "merge-base", "--is-ancestor", string(ancestor), string(descendant))
+	cmd.Dir = gitDir
+	_, err := cmd.Output()
+	if err == nil {
+		return true, nil
+	}
+	// Exit status of 1 means that it is not an ancestor. Other errors, such
+	// as a missing commit, have a different exit status.
+	if ee, ok := err.(*exec.ExitError); ok && ee.Sys().(syscall.WaitStatus).ExitStatus() == 1 {
+		return false, nil
+	}
+	return false, wrapCmdError(cmd, err)
+}
+
+// ensureCommitGraph writes the commit-graph file of the repo at gitDir in
+// the background if it doesn't have one yet, so that ancestry queries don't
+// need to parse every commit they walk. Repos get one on their first
+// maintenance, but that may be a long time after they were cloned.
+func (s *Server) ensureCommitGraph(gitDir string) {
+	if _, err := os.Stat(filepath.Join(gitDir, "objects", "info", "commit-graph")); !os.IsNotExist(err) {
+		return
+	}
+	if !s.ancestry.startCommitGraphWrite(gitDir) {
+		return
+	}
+	ctx, cancel := s.serverContext()
+	go func() {
+		defer cancel()
+		defer s.ancestry.finishCommitGraphWrite(gitDir)
+		ctx, cancel := context.WithTimeout(ctx, longGitCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "git", "commit-graph", "write", "--reachable")
+		cmd.Dir = gitDir
+		if _, err := cmd.Output(); err != nil {
+			log15.Warn("failed to write commit-graph", "repo", gitDir, "error", wrapCmdError(cmd, err))
+		}
+	}()
+}
+
+type containingRefsKey struct {
+	repo    api.RepoName
+	commit  api.CommitID
+	refHash string
+}
+
+type commitRelationKey struct {
+	repo          api.RepoName
+	commit, other api.CommitID
+}
+
+// ancestryCacheSize is the maximum number of results of ancestry queries
+// that are cached.
+const ancestryCacheSize = 10000
+
+// ancestryCache caches the results of ancestry queries, and tracks the
+// commit-graph files being written.
+type ancestryCache struct {
+	mu      sync.Mutex
+	results *lru.Cache
+	writing map[string]struct{}
+}
+
+func (c *ancestryCache) get(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil {
+		return nil, false
+	}
+	return c.results.Get(key)
+}
+
+func (c *ancestryCache) add(key, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil {
+		c.results = lru.New(ancestryCacheSize)
+	}
+	c.results.Add(key, value)
+}
+
+// startCommitGraphWrite returns false if the commit-graph of the repo at
+// gitDir is already being written.
+func (c *ancestryCache) startCommitGraphWrite(gitDir string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.writing[gitDir]; ok {
+		return false
+	}
+	if c.writing == nil {
+		c.writing = make(map[string]struct{})
+	}
+	c.writing[gitDir] = struct{}{}
+	return true
+}
+
+func (c *ancestryCache) finishCommitGraphWrite(gitDir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.writing, gitDir)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+func TestHandleCommitAncestry(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitserver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dir := filepath.Join(root, "example.com/foo")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=a", "-c", "user.email=a@a.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	// c1 -> c2 (master, v1)
+	//    \-> c3 (feature)
+	git("init", ".")
+	git("commit", "--allow-empty", "-m", "c1")
+	c1 := api.CommitID(git("rev-parse", "HEAD"))
+	git("commit", "--allow-empty", "-m", "c2")
+	c2 := api.CommitID(git("rev-parse", "HEAD"))
+	git("tag", "v1")
+	git("checkout", "-b", "feature", string(c1))
+	git("commit", "--allow-empty", "-m", "c3")
+	c3 := api.CommitID(git("rev-parse", "HEAD"))
+	if err := setLastChanged(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{ReposDir: root, skipCloneForTests: true}
+	h := s.Handler()
+	defer s.Stop()
+
+	do := func(req protocol.CommitAncestryRequest) (int, *protocol.CommitAncestryResponse) {
+		t.Helper()
+		body, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/commit-ancestry", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		var resp protocol.CommitAncestryResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, &resp
+	}
+
+	_, resp := do(protocol.CommitAncestryRequest{
+		Repo:           "example.com/foo",
+		Commit:         c1,
+		ContainingRefs: true,
+		Others:         []api.CommitID{c1, c2, c3},
+	})
+	wantRefs := []string{"refs/heads/feature", "refs/heads/master", "refs/tags/v1"}
+	if !reflect.DeepEqual(resp.ContainingRefs, wantRefs) {
+		t.Errorf("got containing refs %v, want %v", resp.ContainingRefs, wantRefs)
+	}
+	wantRelations := map[api.CommitID]protocol.CommitRelation{
+		c1: protocol.CommitRelationEqual,
+		c2: protocol.CommitRelationAncestor,
+		c3: protocol.CommitRelationAncestor,
+	}
+	if !reflect.DeepEqual(resp.Relations, wantRelations) {
+		t.Errorf("got relations %v, want %v", resp.Relations, wantRelations)
+	}
+
+	_, resp = do(protocol.CommitAncestryRequest{
+		Repo:           "example.com/foo",
+		Commit:         c2,
+		ContainingRefs: true,
+		Others:         []api.CommitID{c1, c3},
+	})
+	wantRefs = []string{"refs/heads/master", "refs/tags/v1"}
+	if !reflect.DeepEqual(resp.ContainingRefs, wantRefs) {
+		t.Errorf("got containing refs %v, want %v", resp.ContainingRefs, wantRefs)
+	}
+	wantRelations = map[api.CommitID]protocol.CommitRelation{
+		c1: protocol.CommitRelationDescendant,
+		c3: protocol.CommitRelationDiverged,
+	}
+	if !reflect.DeepEqual(resp.Relations, wantRelations) {
+		t.Errorf("got relations %v, want %v", resp.Relations, wantRelations)
+	}
+
+	// The cached containing refs are invalidated when the refs change.
+	git("branch", "release", string(c2))
+	if err := setLastChanged(dir); err != nil {
+		t.Fatal(err)
+	}
+	_, resp = do(protocol.CommitAncestryRequest{Repo: "example.com/foo", Commit: c2, ContainingRefs: true})
+	wantRefs = []string{"refs/heads/master", "refs/heads/release", "refs/tags/v1"}
+	if !reflect.DeepEqual(resp.ContainingRefs, wantRefs) {
+		t.Errorf("got containing refs %v, want %v", resp.ContainingRefs, wantRefs)
+	}
+
+	if code, _ := do(protocol.CommitAncestryRequest{Repo: "example.com/foo", Commit: "HEAD"}); code != http.StatusBadRequest {
+		t.Errorf("got status %d for invalid commit, want %d", code, http.StatusBadRequest)
+	}
+	if code, _ := do(protocol.CommitAncestryRequest{Repo: "example.com/bar", Commit: c1}); code != http.StatusNotFound {
+		t.Errorf("got status %d for missing repo, want %d", code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// auditLogDirName is the name of the directory under ReposDir that contains
+// the audit log.
+const auditLogDirName = ".audit"
+
+// auditLogFiles is the number of files of the audit log that are kept,
+// including the one currently written to.
+const auditLogFiles = 5
+
+const (
+	defaultAuditLogLimit = 100
+	maxAuditLogLimit     = 1000
+)
+
+// auditLog is a log of the commands executed by gitserver. Entries are
+// written as JSON lines to audit.log in dir. When the file would exceed
+// maxSize, it is rotated to audit.log.1, audit.log.1 to audit.log.2 and so
+// on, and the oldest file is removed.
+type auditLog struct {
+	dir     string
+	maxSize int64
+
+	mu   sync.Mutex
+	f    *os.File // the current file, opened on the first write
+	size int64    // the size of f
+}
+
+// path returns the path of the nth newest file of the log.
+func (l *auditLog) path(n int) string {
+	if n == 0 {
+		return filepath.Join(l.dir, "audit.log")
+	}
+	return filepath.Join(l.dir, fmt.Sprintf("audit.log.%d", n))
+}
+
+// record appends e to the log. Failures are logged, but don't fail the
+// command.
+func (l *auditLog) record(e *protocol.AuditLogEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		log15.Warn("failed to marshal audit log entry", "error", err)
+		return
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.write(b); err != nil {
+		log15.Warn("failed to write audit log entry", "repo", e.Repo, "error", err)
+	}
+}
+
+func (l *auditLog) write(b []byte) error {
+	if l.f != nil && l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	if l.f == nil {
+		if err := os.MkdirAll(l.dir, os.ModePerm); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(l.path(0), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		l.f, l.size = f, fi.Size()
+		// The file may already be full from before a restart.
+		if l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+			return l.write(b)
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+// rotate closes the current file and shifts the files of the log by one.
+func (l *auditLog) rotate() error {
+	err := l.f.Close()
+	l.f, l.size = nil, 0
+	if err != nil {
+		return err
+	}
+	for n := auditLogFiles - 1; n > 0; n-- {
+		if err := os.Rename(l.path(n-1), l.path(n)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// query returns the newest entries of the log that match req.
+func (l *auditLog) query(req *protocol.AuditLogRequest) ([]*protocol.AuditLogEntry, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultAuditLogLimit
+	} else if limit > maxAuditLogLimit {
+		limit = maxAuditLogLimit
+	}
+	match := func(e *protocol.AuditLogEntry) bool {
+		return (req.Repo == "" || e.Repo == protocol.NormalizeRepo(req.Repo)) &&
+			(req.ActorUID == 0 || e.ActorUID == req.ActorUID) &&
+			(req.Before.IsZero() || e.Time.Before(req.Before))
+	}
+
+	var entries []*protocol.AuditLogEntry
+	for n := 0; n < auditLogFiles && len(entries) < limit; n++ {
+		matches, err := readAuditLogFile(l.path(n), match, limit-len(entries))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, matches...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	return entries, nil
+}
+
+// readAuditLogFile returns the last limit entries of the audit log file at
+// path that match, newest first.
+func readAuditLogFile(path string, match func(*protocol.AuditLogEntry) bool, limit int) ([]*protocol.AuditLogEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var matches []*protocol.AuditLogEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		var e protocol.AuditLogEntry
+		// Skip invalid lines, such as a line that is being written.
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || !match(&e) {
+			continue
+		}
+		matches = append(matches, &e)
+		// Only keep the last limit matches, but avoid copying on every
+		// match.
+		if len(matches) >= 2*limit {
+			matches = append(matches[:0], matches[len(matches)-limit:]...)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(matches) > limit {
+		matches = matches[len(matches)-limit:]
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches, nil
+}
+
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	var req protocol.AuditLogRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp protocol.AuditLogResponse
+	if s.audit != nil {
+		entries, err := s.audit.query(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Entries = entries
+	}
+
+	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitserver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Each file holds a few entries, so that they are spread over all files
+	// and the oldest are rotated away.
+	l := &auditLog{dir: dir, maxSize: 500}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	const total = 50
+	for i := 0; i < total; i++ {
+		e := &protocol.AuditLogEntry{
+			Time: start.Add(time.Duration(i) * time.Second),
+			Repo: "example.com/foo",
+			Args: []string{"rev-parse", "HEAD"},
+		}
+		if i%2 == 0 {
+			e.Repo = "example.com/bar"
+			e.ActorUID = 1
+		}
+		l.record(e)
+	}
+
+	for n := 0; n < auditLogFiles; n++ {
+		fi, err := os.Stat(l.path(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() > l.maxSize {
+			t.Errorf("%s: got size %d, want at most %d", l.path(n), fi.Size(), l.maxSize)
+		}
+	}
+	if _, err := os.Stat(l.path(auditLogFiles)); !os.IsNotExist(err) {
+		t.Errorf("got %s, want only %d files", l.path(auditLogFiles), auditLogFiles)
+	}
+
+	times := func(entries []*protocol.AuditLogEntry) []int {
+		var secs []int
+		for _, e := range entries {
+			secs = append(secs, int(e.Time.Sub(start).Seconds()))
+		}
+		return secs
+	}
+	for _, test := range []struct {
+		name string
+		req  protocol.AuditLogRequest
+		want []int
+	}{
+		{"limit", protocol.AuditLogRequest{Limit: 3}, []int{49, 48, 47}},
+		{"repo", protocol.AuditLogRequest{Repo: "example.com/foo", Limit: 3}, []int{49, 47, 45}},
+		{"actor", protocol.AuditLogRequest{ActorUID: 1, Limit: 3}, []int{48, 46, 44}},
+		{"before", protocol.AuditLogRequest{Before: start.Add(40 * time.Second), Limit: 2}, []int{39, 38}},
+	} {
+		entries, err := l.query(&test.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := times(entries); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got entries %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// The oldest entries were rotated away.
+	entries, err := l.query(&protocol.AuditLogRequest{Limit: total})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || len(entries) >= total {
+		t.Fatalf("got %d entries, want fewer than %d", len(entries), total)
+	}
+	if got := times(entries)[len(entries)-1]; got == 0 {
+		t.Errorf("got oldest entry %d, want it rotated away", got)
+	}
+
+	// A new log continues the current file.
+	l2 := &auditLog{dir: dir, maxSize: l.maxSize}
+	l2.record(&protocol.AuditLogEntry{Time: start.Add(total * time.Second), Repo: api.RepoName("example.com/foo")})
+	entries, err = l2.query(&protocol.AuditLogRequest{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := times(entries), []int{total, total - 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit.log")); err != nil {
+		t.Error(err)
+	}
+}
@@ -49,7 +49,9 @@ var reposRecloned = prometheus.NewCounter(prometheus.CounterOpts{
 // 1. Remove corrupt repos.
 // 2. Remove stale lock files.
 // 3. Remove inactive repos on sourcegraph.com
-// 4. Reclone repos after a while. (simulate git gc)
+// 4. Enforce the maximum size of repos.
+// 5. Start git maintenance of repos that are due for it in the background.
+// 6. Reclone repos after a while, if maintenance is disabled or failed.
 func (s *Server) cleanupRepos() {
 	bCtx, bCancel := s.serverContext()
 	defer bCancel()
@@ -74,7 +76,31 @@ func (s *Server) cleanupRepos() {
 		return false, setGitAttributes(gitDir)
 	}
 
+	// maintain collects the repos that are due for maintenance. They are
+	// maintained in the background once all repos were visited.
+	var maintain []string
+	maybeScheduleMaintenance := func(gitDir string) (done bool, err error) {
+		due, err := s.maintenanceDue(gitDir)
+		if err != nil || !due {
+			return false, err
+		}
+		maintain = append(maintain, gitDir)
+		return true, nil
+	}
+
 	maybeReclone := func(gitDir string) (done bool, err error) {
+		// Maintained repos only need to be recloned if their maintenance
+		// failed.
+		if s.MaintenanceInterval > 0 {
+			r, err := lastMaintenance(gitDir)
+			if err != nil {
+				return false, err
+			}
+			if r == nil || r.Error == "" {
+				return false, nil
+			}
+		}
+
 		recloneTime, err := getRecloneTime(gitDir)
 		if err != nil {
 			return false, err
@@ -98,13 +124,93 @@ func (s *Server) cleanupRepos() {
 			return false, errors.Wrap(err, "failed to get remote URL")
 		}
 
-		if _, err := s.cloneRepo(ctx, repo, remoteURL, &cloneOptions{Block: true, Overwrite: true}); err != nil {
+		// Keep partial clones partial, and keep the maximum size and LFS
+		// settings of the repo. They are only changed by repo updates.
+		filter, err := repoPartialCloneFilter(ctx, gitDir)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get partial clone filter")
+		}
+		maxSize, err := repoMaxSize(gitDir)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get maximum size")
+		}
+		lfsMaxFileSize, err := getRepoConfigInt(gitDir, lfsMaxFileSizeConfigKey)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get LFS maximum file size")
+		}
+
+		opts := &cloneOptions{
+			Block:              true,
+			Overwrite:          true,
+			PartialCloneFilter: filter,
+			MaxRepoSize:        maxSize,
+			LFSMaxFileSize:     lfsMaxFileSize,
+		}
+		if _, err := s.cloneRepo(ctx, repo, remoteURL, opts); err != nil {
 			return true, err
 		}
 		reposRecloned.Inc()
 		return true, nil
 	}
 
+	enforceMaxSize := func(gitDir string) (done bool, err error) {
+		size, err := repoSize(gitDir)
+		if err != nil {
+			return false, err
+		}
+		if size == 0 {
+			// Repos cloned before we kept track of sizes.
+			if size, err = updateRepoSize(gitDir); err != nil {
+				return false, err
+			}
+		}
+		maxSize, err := repoMaxSize(gitDir)
+		if err != nil || maxSize == 0 || size <= maxSize {
+			return false, err
+		}
+
+		ctx, cancel := context.WithTimeout(bCtx, longGitCommandTimeout)
+		defer cancel()
+
+		repo := protocol.NormalizeRepo(api.RepoName(strings.TrimPrefix(filepath.Dir(gitDir), s.ReposDir+"/")))
+
+		remoteURL, err := repoRemoteURL(ctx, gitDir)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get remote URL")
+		}
+		filter, err := repoPartialCloneFilter(ctx, gitDir)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get partial clone filter")
+		}
+
+		// Try to make the repo small enough by cloning it partially. If the
+		// partial clone is still too large, the clone fails and we remove
+		// the repo below.
+		if filter == "" && canClonePartially(remoteURL) {
+			lfsMaxFileSize, err := getRepoConfigInt(gitDir, lfsMaxFileSizeConfigKey)
+			if err != nil {
+				return false, errors.Wrap(err, "failed to get LFS maximum file size")
+			}
+			log15.Info("recloning repo that exceeds its maximum size partially", "repo", repo, "size", size, "maxSize", maxSize)
+			_, err = s.cloneRepo(ctx, repo, remoteURL, &cloneOptions{Block: true, Overwrite: true, PartialCloneFilter: oversizedPartialCloneFilter, MaxRepoSize: maxSize, LFSMaxFileSize: lfsMaxFileSize})
+			if err == nil {
+				reposRecloned.Inc()
+				return true, nil
+			}
+			if _, ok := errors.Cause(err).(*repoTooLargeError); !ok {
+				return true, err
+			}
+		}
+
+		log15.Info("removing repo that exceeds its maximum size", "repo", repo, "size", size, "maxSize", maxSize)
+		s.oversized.add(repo, size)
+		if err := s.removeRepoDirectory(gitDir); err != nil {
+			return true, err
+		}
+		reposRemoved.Inc()
+		return true, nil
+	}
+
 	removeStaleLocks := func(gitDir string) (done bool, err error) {
 		// if removing a lock fails, we still want to try the other locks.
 		var multi error
@@ -154,11 +260,17 @@ func (s *Server) cleanupRepos() {
 		// We always want to have the same git attributes file at
 		// info/attributes.
 		{"ensure git attributes", ensureGitAttributes},
+		// Repos may grow beyond their maximum size when they are updated,
+		// or the maximum size may have changed.
+		{"enforce maximum size", enforceMaxSize},
 	}
 	// Old git clones accumulate loose git objects that waste space and
-	// slow down git operations. Periodically do a fresh clone to avoid
-	// these problems. git gc is slow and resource intensive. It is
-	// cheaper and faster to just reclone the repository.
+	// slow down git operations. Scheduled maintenance avoids these problems
+	// without the random latency of git's automatic gc during fetches.
+	cleanups = append(cleanups, cleanupFn{"maybe schedule maintenance", maybeScheduleMaintenance})
+	// Without maintenance, periodically do a fresh clone instead. git gc is
+	// slow and resource intensive. It is cheaper and faster to just reclone
+	// the repository.
 	cleanups = append(cleanups, cleanupFn{"maybe reclone", maybeReclone})
 
 	err := filepath.Walk(s.ReposDir, func(gitDir string, fi os.FileInfo, fileErr error) error {
@@ -193,6 +305,10 @@ func (s *Server) cleanupRepos() {
 		log15.Error("cleanup: error iterating over repositories", "error", err)
 	}
 
+	if len(maintain) > 0 {
+		s.startMaintenance(maintain)
+	}
+
 	actualFreeBytes, err := s.bytesFreeOnDisk()
 	if err != nil {
 		log15.Error("cleanup: finding the amount of space free on disk", "error", err)
@@ -271,42 +387,61 @@ func device(f string) (int64, error) {
 	return int64(stat.Dev), nil
 }
 
-// freeUpSpace removes git directories under ReposDir, in order from least
-// recently to most recently used, until it has freed howManyBytesToFree.
+// freeUpSpace removes git directories under ReposDir until it has freed
+// howManyBytesToFree. It prefers to remove large repos that haven't been used
+// in a long time: repos are removed in descending order of their size
+// multiplied by the time since they were last used.
 func (s *Server) freeUpSpace(howManyBytesToFree int64) error {
 	if howManyBytesToFree <= 0 {
 		log15.Info("cleanup: skipping repository cleanup, don't need to free disk space", "howManyBytesToFree", howManyBytesToFree)
 		return nil
 	}
 
-	// Get the git directories and their mod times.
+	// Get the git directories and their sizes and last used times.
 	gitDirs, err := s.findGitDirs(s.ReposDir)
 	if err != nil {
 		return errors.Wrap(err, "finding git dirs")
 	}
-	dirModTimes := make(map[string]time.Time, len(gitDirs))
+	now := time.Now()
+	dirLastUsed := make(map[string]time.Time, len(gitDirs))
+	dirSizes := make(map[string]int64, len(gitDirs))
+	dirScores := make(map[string]float64, len(gitDirs))
 	for _, d := range gitDirs {
-		mt, err := gitDirModTime(d)
+		lu, err := repoLastUsed(d)
 		if err != nil {
-			return errors.Wrap(err, "computing mod time of git dir")
+			return errors.Wrap(err, "computing last used time of git dir")
 		}
-		dirModTimes[d] = mt
+		dirLastUsed[d] = lu
+
+		size, err := repoSize(d)
+		if err != nil || size == 0 {
+			// The size is unknown, so compute it.
+			if size, err = dirSize(d); err != nil {
+				return errors.Wrapf(err, "computing size of directory %s", d)
+			}
+		}
+		dirSizes[d] = size
+
+		// Add a second so that the size matters for repos used just now.
+		dirScores[d] = float64(size) * (now.Sub(lu).Seconds() + 1)
 	}
 
-	// Sort the repos from least to most recently used.
+	// Sort the repos from the highest to the lowest score, breaking ties by
+	// removing the least recently used repo first.
 	sort.Slice(gitDirs, func(i, j int) bool {
-		return dirModTimes[gitDirs[i]].Before(dirModTimes[gitDirs[j]])
+		si, sj := dirScores[gitDirs[i]], dirScores[gitDirs[j]]
+		if si != sj {
+			return si > sj
+		}
+		return dirLastUsed[gitDirs[i]].Before(dirLastUsed[gitDirs[j]])
 	})
 
 	// Remove repos until howManyBytesToFree is met or exceeded.
 	var spaceFreed int64
 	for _, d := range gitDirs {
-		delta, err := dirSize(d)
-		if err != nil {
-			return errors.Wrapf(err, "computing size of directory %s", d)
-		}
+		delta := dirSizes[d]
 		gitDirParent := filepath.Dir(d)
-		log15.Info("cleanup: removing repo dir that hasn't been used in a while", "repodir", d, "howlong", time.Since(dirModTimes[d]))
+		log15.Info("cleanup: removing repo dir to free up space", "repodir", d, "size", delta, "howlong", time.Since(dirLastUsed[d]))
 		if err := os.RemoveAll(gitDirParent); err != nil {
 			return errors.Wrap(err, "removing repo directory")
 		}
@@ -392,6 +527,7 @@ func (s *Server) removeRepoDirectory(dir string) error {
 	if err := os.Rename(dir, filepath.Join(tmp, "repo")); err != nil {
 		return err
 	}
+	forgetRepoUsed(dir)
 
 	// Everything after this point is just cleanup, so any error that occurs
 	// should not be returned, just logged.
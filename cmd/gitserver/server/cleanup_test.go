@@ -12,10 +12,12 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
 )
 
 const (
@@ -91,6 +93,14 @@ func TestCleanupExpired(t *testing.T) {
 	if err := cmd.Run(); err != nil {
 		t.Fatal(err)
 	}
+	// The reclone keeps the maximum size and LFS settings of repoB.
+	const maxSize, lfsMaxFileSize = 1 << 30, 1 << 20
+	if err := setRepoConfigInt(repoB, maxRepoSizeConfigKey, maxSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := setRepoConfigInt(repoB, lfsMaxFileSizeConfigKey, lfsMaxFileSize); err != nil {
+		t.Fatal(err)
+	}
 
 	s := &Server{ReposDir: root}
 	s.Handler() // Handler as a side-effect sets up Server
@@ -115,6 +125,110 @@ func TestCleanupExpired(t *testing.T) {
 	if fi.ModTime().Before(ti) {
 		t.Error("expected repoB to be recloned during clean up")
 	}
+	if got, err := repoMaxSize(repoB); err != nil || got != maxSize {
+		t.Errorf("got maximum size %d (error %v) after reclone, want %d", got, err, maxSize)
+	}
+	if got, err := getRepoConfigInt(repoB, lfsMaxFileSizeConfigKey); err != nil || got != lfsMaxFileSize {
+		t.Errorf("got LFS maximum file size %d (error %v) after reclone, want %d", got, err, lfsMaxFileSize)
+	}
+}
+
+func TestCleanupMaintenance(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitserver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// repoA was cloned recently, repoB long ago.
+	for _, name := range []string{testRepoA, testRepoB} {
+		dir := filepath.Join(root, name)
+		for _, args := range [][]string{
+			{"init", "."},
+			{"-c", "user.name=a", "-c", "user.email=a@a.com", "commit", "--allow-empty", "-m", "foo"},
+			{"repack", "-d"},
+			{"-c", "user.name=a", "-c", "user.email=a@a.com", "commit", "--allow-empty", "-m", "bar"},
+		} {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %s: %s", args, err, out)
+			}
+		}
+	}
+	repoA := filepath.Join(root, testRepoA, ".git")
+	repoB := filepath.Join(root, testRepoB, ".git")
+	if _, err := getRecloneTime(repoA); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "config", "--add", "sourcegraph.recloneTimestamp", strconv.FormatInt(time.Now().Add(-(2*repoTTL)).Unix(), 10))
+	cmd.Dir = repoB
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{ReposDir: root, MaintenanceInterval: 24 * time.Hour, MaintenanceConcurrency: 2}
+	s.Handler() // Handler as a side-effect sets up Server
+	waitForMaintenance := func() {
+		t.Helper()
+		for i := 0; atomic.LoadInt32(&s.maintaining) != 0; i++ {
+			if i == 1000 {
+				t.Fatal("timed out waiting for maintenance")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Repos are not maintained while they are updated.
+	mu := s.repoUpdateMutex(protocol.NormalizeRepo(testRepoB))
+	mu.Lock()
+	s.cleanupRepos()
+	time.Sleep(100 * time.Millisecond)
+	if r, err := lastMaintenance(repoB); err != nil {
+		t.Fatal(err)
+	} else if r != nil {
+		t.Errorf("expected repoB not to be maintained during an update, got %+v", r)
+	}
+	mu.Unlock()
+	waitForMaintenance()
+
+	if r, err := lastMaintenance(repoA); err != nil {
+		t.Fatal(err)
+	} else if r != nil {
+		t.Errorf("expected repoA not to be maintained, got %+v", r)
+	}
+
+	r, err := lastMaintenance(repoB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil {
+		t.Fatal("expected repoB to be maintained")
+	}
+	if r.Error != "" {
+		t.Fatalf("expected maintenance of repoB to succeed, got %q", r.Error)
+	}
+	for _, p := range []string{"objects/info/commit-graph", "objects/pack/multi-pack-index"} {
+		if _, err := os.Stat(filepath.Join(repoB, p)); err != nil {
+			t.Errorf("expected maintenance to write %s: %s", p, err)
+		}
+	}
+
+	// Maintained repos are not due again until the interval passed, and are
+	// not recloned.
+	if due, err := s.maintenanceDue(repoB); err != nil {
+		t.Fatal(err)
+	} else if due {
+		t.Error("expected repoB not to be due for maintenance")
+	}
+	if recloneTime, err := getRecloneTime(repoB); err != nil {
+		t.Fatal(err)
+	} else if time.Since(recloneTime) < repoTTL {
+		t.Error("expected repoB not to be recloned")
+	}
 }
 
 func TestCleanupOldLocks(t *testing.T) {
@@ -313,6 +427,44 @@ func TestRemoveRepoDirectory_Empty(t *testing.T) {
 	)
 }
 
+func TestMarkRepoUsed_forget(t *testing.T) {
+	root, cleanup := tmpDir(t)
+	defer cleanup()
+
+	mkFiles(t, root,
+		"github.com/foo/bar/.git/HEAD",
+		"github.com/foo/baz/.git/HEAD",
+	)
+	bar := filepath.Join(root, "github.com/foo/bar/.git")
+	baz := filepath.Join(root, "github.com/foo/baz/.git")
+	stale := filepath.Join(root, "github.com/foo/stale/.git")
+
+	lastMarkedUsedMu.Lock()
+	lastMarkedUsed[stale] = time.Now().Add(-2 * repoLastUsedResolution)
+	lastMarkedUsedPruned = time.Time{}
+	lastMarkedUsedMu.Unlock()
+
+	markRepoUsed(bar)
+	markRepoUsed(baz)
+
+	s := &Server{ReposDir: root}
+	if err := s.removeRepoDirectory(baz); err != nil {
+		t.Fatal(err)
+	}
+
+	lastMarkedUsedMu.Lock()
+	defer lastMarkedUsedMu.Unlock()
+	if _, ok := lastMarkedUsed[stale]; ok {
+		t.Error("stale repo was not pruned")
+	}
+	if _, ok := lastMarkedUsed[baz]; ok {
+		t.Error("removed repo was not forgotten")
+	}
+	if _, ok := lastMarkedUsed[bar]; !ok {
+		t.Error("used repo was forgotten")
+	}
+}
+
 func tmpDir(t *testing.T) (string, func()) {
 	t.Helper()
 	dir, err := ioutil.TempDir("", t.Name())
@@ -465,6 +617,48 @@ func TestFreeUpSpace(t *testing.T) {
 			t.Errorf("repo dir size is %d, want no more than %d", rds, wantSize)
 		}
 	})
+	t.Run("large repo that isn't used gets removed before older small repo", func(t *testing.T) {
+		// Set up.
+		rd, err := ioutil.TempDir("", "freeUpSpace")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(rd)
+		small := filepath.Join(rd, "small")
+		large := filepath.Join(rd, "large")
+		if err := makeFakeRepo(small, 1000); err != nil {
+			t.Fatal(err)
+		}
+		if err := makeFakeRepo(large, 10000); err != nil {
+			t.Fatal(err)
+		}
+		for dir, lastUsed := range map[string]time.Time{
+			small: time.Now().Add(-2 * time.Hour),
+			large: time.Now().Add(-time.Hour),
+		} {
+			markRepoUsed(filepath.Join(dir, ".git"))
+			if err := os.Chtimes(filepath.Join(dir, ".git", repoLastUsedFile), lastUsed, lastUsed); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Run.
+		s := Server{
+			ReposDir: rd,
+		}
+		if err := s.freeUpSpace(1000); err != nil {
+			t.Fatal(err)
+		}
+
+		// Check.
+		files, err := ioutil.ReadDir(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || files[0].Name() != "small" {
+			t.Errorf("got %v in %s, want only small", files, rd)
+		}
+	})
 }
 
 func makeFakeRepo(d string, sizeBytes int) error {
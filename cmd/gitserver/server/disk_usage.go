@@ -0,0 +1,226 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/mercurial"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/svn"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// gitserver keeps the size of each repo as of its last clone or update, and
+// the maximum size the repo may have, in the git config of the repo. The
+// janitor uses them to enforce the maximum size and to decide which repos to
+// remove when it needs to free up disk space.
+const (
+	repoSizeConfigKey    = "sourcegraph.repoSize"
+	maxRepoSizeConfigKey = "sourcegraph.maxRepoSize"
+)
+
+// oversizedPartialCloneFilter is the object filter repos with a maximum size
+// are cloned with. It omits all blobs, which are usually most of a repo's
+// size.
+const oversizedPartialCloneFilter = "blob:none"
+
+// repoLastUsedFile is the file in the git directory whose modification time
+// is when the repo was last used by a command.
+const repoLastUsedFile = "sourcegraph-last-used"
+
+// repoLastUsedResolution is how often the last used time of a repo is
+// updated at most. This avoids writing to the disk on every command.
+const repoLastUsedResolution = 10 * time.Minute
+
+var (
+	lastMarkedUsed       = make(map[string]time.Time)
+	lastMarkedUsedPruned time.Time
+	lastMarkedUsedMu     sync.Mutex
+)
+
+// markRepoUsed records that the repo at gitDir is being used by a command.
+func markRepoUsed(gitDir string) {
+	now := time.Now()
+	lastMarkedUsedMu.Lock()
+	if t, ok := lastMarkedUsed[gitDir]; ok && now.Sub(t) < repoLastUsedResolution {
+		lastMarkedUsedMu.Unlock()
+		return
+	}
+	lastMarkedUsed[gitDir] = now
+	if now.Sub(lastMarkedUsedPruned) >= repoLastUsedResolution {
+		// Entries older than the resolution no longer prevent any writes, so
+		// drop them to keep the map from growing with every repo ever used.
+		for dir, t := range lastMarkedUsed {
+			if now.Sub(t) >= repoLastUsedResolution {
+				delete(lastMarkedUsed, dir)
+			}
+		}
+		lastMarkedUsedPruned = now
+	}
+	lastMarkedUsedMu.Unlock()
+
+	path := filepath.Join(gitDir, repoLastUsedFile)
+	err := os.Chtimes(path, now, now)
+	if os.IsNotExist(err) {
+		var f *os.File
+		if f, err = os.Create(path); err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		log15.Warn("failed to record repo usage", "dir", gitDir, "error", err)
+	}
+}
+
+// forgetRepoUsed forgets when the repo at gitDir was last marked used. It is
+// called when the repo is removed, so that a new clone of it is marked used
+// by its first command.
+func forgetRepoUsed(gitDir string) {
+	lastMarkedUsedMu.Lock()
+	delete(lastMarkedUsed, gitDir)
+	lastMarkedUsedMu.Unlock()
+}
+
+// repoLastUsed returns when the repo at gitDir was last used by a command. If
+// that is not known, it returns when the repo was last modified.
+func repoLastUsed(gitDir string) (time.Time, error) {
+	fi, err := os.Stat(filepath.Join(gitDir, repoLastUsedFile))
+	if os.IsNotExist(err) {
+		return gitDirModTime(gitDir)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// repoSize returns the size in bytes of the repo at gitDir as of its last
+// clone or update, or zero if it is unknown.
+var repoSize = func(gitDir string) (int64, error) {
+	return getRepoConfigInt(gitDir, repoSizeConfigKey)
+}
+
+// updateRepoSize computes the size in bytes of the repo at gitDir and stores
+// it.
+func updateRepoSize(gitDir string) (int64, error) {
+	size, err := dirSize(gitDir)
+	if err != nil {
+		return 0, err
+	}
+	return size, setRepoConfigInt(gitDir, repoSizeConfigKey, size)
+}
+
+// repoMaxSize returns the maximum size in bytes of the repo at gitDir, or
+// zero if there is no limit.
+func repoMaxSize(gitDir string) (int64, error) {
+	return getRepoConfigInt(gitDir, maxRepoSizeConfigKey)
+}
+
+// updateRepoMaxSize sets the maximum size in bytes of the repo at gitDir, if
+// it changed. Zero removes the limit.
+func updateRepoMaxSize(gitDir string, maxSize int64) error {
+	current, err := repoMaxSize(gitDir)
+	if err != nil || current == maxSize {
+		return err
+	}
+	return setRepoConfigInt(gitDir, maxRepoSizeConfigKey, maxSize)
+}
+
+func getRepoConfigInt(gitDir, key string) (int64, error) {
+	cmd := exec.Command("git", "config", "--get", key)
+	cmd.Dir = gitDir
+	out, err := cmd.Output()
+	if err != nil {
+		// Exit code 1 means the key is not set.
+		if ee, ok := err.(*exec.ExitError); ok && ee.Sys().(syscall.WaitStatus).ExitStatus() == 1 {
+			return 0, nil
+		}
+		return 0, wrapCmdError(cmd, err)
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid value of %s", key)
+	}
+	return v, nil
+}
+
+// setRepoConfigInt sets the key in the git config of the repo at gitDir to v,
+// or unsets it if v is zero.
+func setRepoConfigInt(gitDir, key string, v int64) error {
+	args := []string{"config", key, strconv.FormatInt(v, 10)}
+	if v == 0 {
+		args = []string{"config", "--unset-all", key}
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = gitDir
+	if _, err := cmd.Output(); err != nil {
+		// Exit code 5 means the key to unset is not set.
+		if ee, ok := err.(*exec.ExitError); ok && v == 0 && ee.Sys().(syscall.WaitStatus).ExitStatus() == 5 {
+			return nil
+		}
+		return errors.Wrapf(wrapCmdError(cmd, err), "failed to set %s", key)
+	}
+	return nil
+}
+
+// canClonePartially reports whether the repo with the remote URL can be
+// cloned partially. Subversion and Mercurial mirrors can't.
+func canClonePartially(remoteURL string) bool {
+	return !svn.IsRemote(remoteURL) && !mercurial.IsRemote(remoteURL)
+}
+
+// repoTooLargeError is returned when a repo exceeds its maximum size, even
+// when it is cloned partially.
+type repoTooLargeError struct {
+	repo          api.RepoName
+	size, maxSize int64
+}
+
+func (e *repoTooLargeError) Error() string {
+	return fmt.Sprintf("repo %s is too large: its size of %d bytes exceeds the maximum size of %d bytes", e.repo, e.size, e.maxSize)
+}
+
+// oversizedRepos tracks repos that are not mirrored because they exceed their
+// maximum size, so that they aren't cloned over and over again. The zero value
+// is ready to use.
+type oversizedRepos struct {
+	mu    sync.Mutex
+	sizes map[api.RepoName]int64
+}
+
+// add records that repo is not mirrored because its size is too large.
+func (o *oversizedRepos) add(repo api.RepoName, size int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.sizes == nil {
+		o.sizes = make(map[api.RepoName]int64)
+	}
+	o.sizes[protocol.NormalizeRepo(repo)] = size
+}
+
+// remove forgets about repo, which doesn't exceed its maximum size anymore.
+func (o *oversizedRepos) remove(repo api.RepoName) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.sizes, protocol.NormalizeRepo(repo))
+}
+
+// check returns a *repoTooLargeError if repo is not mirrored because its size
+// exceeds maxSize.
+func (o *oversizedRepos) check(repo api.RepoName, maxSize int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if size, ok := o.sizes[protocol.NormalizeRepo(repo)]; ok && maxSize > 0 && size > maxSize {
+		return &repoTooLargeError{repo: repo, size: size, maxSize: maxSize}
+	}
+	return nil
+}
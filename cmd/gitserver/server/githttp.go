@@ -0,0 +1,150 @@
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// handleGitHTTP serves the read-only git smart HTTP protocol for cloned repos
+// at /git/{repo}/info/refs and /git/{repo}/git-upload-pack. Both protocol
+// version 0 and 2 are supported. The frontend proxies requests of
+// authenticated and authorized users to it. Each upload-pack is recorded in
+// the audit log (if enabled), like the commands run by exec requests.
+func (s *Server) handleGitHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	p := strings.TrimPrefix(r.URL.Path, "/git/")
+
+	var repo api.RepoName
+	var advertise bool
+	switch {
+	case strings.HasSuffix(p, "/info/refs"):
+		if r.Method != "GET" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if service := r.URL.Query().Get("service"); service != "git-upload-pack" {
+			// We only support the smart HTTP protocol, and only for
+			// fetches.
+			http.Error(w, fmt.Sprintf("unsupported service %q", service), http.StatusForbidden)
+			return
+		}
+		repo = api.RepoName(strings.TrimSuffix(p, "/info/refs"))
+		advertise = true
+
+	case strings.HasSuffix(p, "/git-upload-pack"):
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		repo = api.RepoName(strings.TrimSuffix(p, "/git-upload-pack"))
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	repo = protocol.NormalizeRepo(repo)
+
+	var (
+		args       = []string{"upload-pack", "--stateless-rpc"}
+		status     = "unknown"
+		exitStatus = -1
+		execErr    error
+		stdoutW    = &writeCounter{w: w}
+	)
+	if advertise {
+		args = append(args, "--advertise-refs")
+	}
+	if s.audit != nil {
+		defer func() {
+			a := actor.FromHTTPHeader(r.Header)
+			e := &protocol.AuditLogEntry{
+				Time:          start,
+				Repo:          repo,
+				Args:          args,
+				ActorUID:      a.UID,
+				ActorInternal: a.Internal,
+				Client:        r.UserAgent(),
+				Duration:      time.Since(start),
+				StdoutBytes:   stdoutW.n,
+				ExitStatus:    exitStatus,
+				Status:        status,
+			}
+			if execErr != nil {
+				e.Error = execErr.Error()
+			}
+			s.audit.record(e)
+		}()
+	}
+
+	dir := path.Join(s.ReposDir, string(repo))
+	if !repoCloned(dir) {
+		status = "repo-not-found"
+		http.Error(w, "repository not cloned", http.StatusNotFound)
+		return
+	}
+
+	// The client requests protocol version 2 with the Git-Protocol header,
+	// which is passed on to upload-pack like git http-backend does.
+	gitProtocol := r.Header.Get("Git-Protocol")
+	v2 := strings.Contains(gitProtocol, "version=2")
+
+	// 🚨 SECURITY: Hide notes, which gitserver uses to store internal data
+	// (such as the Git LFS and Mercurial metadata of repos).
+	cmdArgs := append([]string{"-c", "uploadpack.hideRefs=refs/notes"}, args...)
+	cmd := exec.CommandContext(r.Context(), "git", append(cmdArgs, dir)...)
+	if gitProtocol != "" {
+		cmd.Env = append(os.Environ(), "GIT_PROTOCOL="+gitProtocol)
+	}
+	cmd.Stdout = stdoutW
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if advertise {
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		if !v2 {
+			// Version 2 clients don't expect the service announcement.
+			_, _ = io.WriteString(w, pktLine("# service=git-upload-pack\n"))
+			_, _ = io.WriteString(w, "0000")
+		}
+	} else {
+		body := r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				status = "bad-request"
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer gr.Close()
+			body = gr
+		}
+		cmd.Stdin = body
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+	}
+
+	exitStatus, execErr = runCommand(r.Context(), cmd)
+	status = strconv.Itoa(exitStatus)
+	if execErr != nil {
+		// The response has already started, so all we can do is log the
+		// error. The client will fail to parse the incomplete response.
+		log15.Error("git upload-pack failed", "repo", repo, "advertise", advertise, "error", execErr)
+	}
+}
+
+// pktLine returns s encoded as a git pkt-line.
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
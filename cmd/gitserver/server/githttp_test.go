@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+func TestHandleGitHTTP(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+	clones, cleanup3 := tmpDir(t)
+	defer cleanup3()
+
+	cmd := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s\n%s", name, strings.Join(arg, " "), err, b)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	cmd(remote, "git", "init", ".")
+	cmd(remote, "sh", "-c", "echo hello world > hello.txt")
+	cmd(remote, "git", "add", "hello.txt")
+	cmd(remote, "git", "commit", "-m", "hello")
+	wantCommit := cmd(remote, "git", "rev-parse", "HEAD")
+	cmd(reposDir, "git", "clone", "--mirror", remote, filepath.Join(reposDir, "example.com/foo/bar/.git"))
+	cmd(filepath.Join(reposDir, "example.com/foo/bar/.git"), "git", "notes", "--ref=lfs", "add", "-m", "internal", "HEAD")
+
+	s := &Server{ReposDir: reposDir}
+	s.audit = &auditLog{dir: filepath.Join(reposDir, auditLogDirName), maxSize: 1 << 20}
+	ts := httptest.NewServer(http.HandlerFunc(s.handleGitHTTP))
+	defer ts.Close()
+
+	for _, version := range []string{"0", "2"} {
+		t.Run("protocol version "+version, func(t *testing.T) {
+			dst := filepath.Join(clones, version)
+			cmd(clones, "git", "-c", "protocol.version="+version, "-c", "http.extraHeader=X-Sourcegraph-Actor: 42", "clone", ts.URL+"/git/example.com/foo/bar", dst)
+			if got := cmd(dst, "git", "rev-parse", "HEAD"); got != wantCommit {
+				t.Errorf("got HEAD %s, want %s", got, wantCommit)
+			}
+			if refs := cmd(clones, "git", "-c", "protocol.version="+version, "ls-remote", ts.URL+"/git/example.com/foo/bar"); strings.Contains(refs, "refs/notes/") {
+				t.Errorf("notes were advertised:\n%s", refs)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{"GET", "/git/example.com/foo/bar/info/refs", http.StatusForbidden},
+		{"GET", "/git/example.com/foo/bar/info/refs?service=git-receive-pack", http.StatusForbidden},
+		{"POST", "/git/example.com/foo/bar/git-receive-pack", http.StatusNotFound},
+		{"GET", "/git/example.com/foo/bar/git-upload-pack", http.StatusMethodNotAllowed},
+		{"GET", "/git/example.com/foo/notcloned/info/refs?service=git-upload-pack", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest(tc.method, ts.URL+tc.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, resp.StatusCode, tc.wantStatus)
+		}
+	}
+
+	// The clones are in the audit log, on behalf of the user that made them.
+	entries, err := s.audit.query(&protocol.AuditLogRequest{ActorUID: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var advertisements, uploadPacks int
+	for _, e := range entries {
+		if e.Repo != "example.com/foo/bar" || e.Status != "0" || e.StdoutBytes == 0 {
+			t.Errorf("unexpected audit log entry %+v", e)
+		}
+		if strings.Join(e.Args, " ") == "upload-pack --stateless-rpc --advertise-refs" {
+			advertisements++
+		} else {
+			uploadPacks++
+		}
+	}
+	if advertisements != 2 || uploadPacks < 2 {
+		t.Errorf("got %d ref advertisements and %d upload-packs in the audit log, want 2 and at least 2", advertisements, uploadPacks)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/mercurial"
+)
+
+// Mercurial repositories are mirrored by converting them with hg-fast-export
+// into a bare repository, like any other repository. gitserver keeps a clone of
+// the Mercurial repository in the hgDir subdirectory of the git directory, and
+// after each pull hg-fast-export converts the changesets it hasn't converted
+// yet. It records the changeset each commit was converted from in git notes
+// under refs/notes/hg.
+const hgDir = "sourcegraph-hg"
+
+// cloneHgRepo mirrors the Mercurial repository of the remote URL (see
+// mercurial.RemoteURL) into a new bare repository at gitDir.
+func (s *Server) cloneHgRepo(ctx context.Context, remoteURL, gitDir string, progress io.Writer) error {
+	if err := os.MkdirAll(gitDir, os.ModePerm); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "git", "init", "--bare", ".")
+	cmd.Dir = gitDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "git init failed. Output: %s", string(output))
+	}
+
+	hgURL := strings.TrimPrefix(remoteURL, mercurial.RemotePrefix)
+	if output, err := runHgCommand(ctx, gitDir, progress, "clone", "--noupdate", "--", hgURL, hgDir); err != nil {
+		return errors.Wrapf(err, "hg clone failed. Output: %s", string(output))
+	}
+
+	// Like git clone, store the remote URL as the origin remote, so that the
+	// repo can be updated by requests without a URL.
+	cmd = exec.CommandContext(ctx, "git", "config", "remote.origin.url", remoteURL)
+	cmd.Dir = gitDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "failed to set origin remote. Output: %s", string(output))
+	}
+
+	return convertHg(ctx, gitDir, progress)
+}
+
+// fetchHg pulls new changesets of the Mercurial repository of the remote URL
+// into the mirror at gitDir, and converts them.
+func (s *Server) fetchHg(ctx context.Context, gitDir, remoteURL string, progress io.Writer) error {
+	// Pull from the remote URL rather than the default path of the clone, in
+	// case the URL (or its credentials) changed.
+	hgURL := strings.TrimPrefix(remoteURL, mercurial.RemotePrefix)
+	if output, err := runHgCommand(ctx, filepath.Join(gitDir, hgDir), progress, "pull", "--", hgURL); err != nil {
+		return errors.Wrapf(err, "hg pull failed. Output: %s", string(output))
+	}
+	return convertHg(ctx, gitDir, progress)
+}
+
+// convertHg converts the changesets of the Mercurial clone in the mirror at
+// gitDir that were not converted yet. hg-fast-export keeps its state (the
+// marks and the heads it converted) in gitDir.
+func convertHg(ctx context.Context, gitDir string, progress io.Writer) error {
+	cmd := exec.CommandContext(ctx, "hg-fast-export.sh", "-r", hgDir, "--hg-hash", "--force")
+	cmd.Dir = gitDir
+	cmd.Env = append(os.Environ(), "GIT_DIR=.", "HGPLAIN=1")
+	if output, err := runWithProgress(ctx, cmd, progress); err != nil {
+		return errors.Wrapf(err, "hg-fast-export failed. Output: %s", string(output))
+	}
+
+	// hg-fast-export converts the default branch to master.
+	cmd = exec.CommandContext(ctx, "git", "symbolic-ref", "HEAD", "refs/heads/master")
+	cmd.Dir = gitDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "failed to set HEAD. Output: %s", string(output))
+	}
+	return nil
+}
+
+// runHgCommand runs hg with the given arguments in dir, without prompting for
+// credentials or unknown SSH host keys. If progress is not nil, all output is
+// also written to it.
+func runHgCommand(ctx context.Context, dir string, progress io.Writer, args ...string) ([]byte, error) {
+	args = append([]string{"--noninteractive", "--config", "ui.ssh=ssh -o BatchMode=yes -o ConnectTimeout=30"}, args...)
+	cmd := exec.CommandContext(ctx, "hg", args...)
+	cmd.Dir = dir
+	// hg needs HOME for its configuration, so we keep the environment.
+	// HGPLAIN disables user configuration that changes its output.
+	cmd.Env = append(os.Environ(), "HGPLAIN=1")
+	return runWithProgress(ctx, cmd, progress)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/mercurial"
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+)
+
+func TestCloneRepo_hg(t *testing.T) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("hg not found")
+	}
+	if _, err := exec.LookPath("hg-fast-export.sh"); err != nil {
+		t.Skip("hg-fast-export.sh not found")
+	}
+
+	hgDir, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	cmd := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = append(os.Environ(), "HGPLAIN=1", "HGUSER=Jane Doe <jane@example.com>")
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s\n%s", name, strings.Join(arg, " "), err, b)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	// Create a Mercurial repository with a changeset on the default branch
+	// and a tag.
+	cmd(hgDir, "hg", "init", "repo")
+	repoDir := filepath.Join(hgDir, "repo")
+	cmd(repoDir, "sh", "-c", "echo hello world > hello.txt")
+	cmd(repoDir, "hg", "add", "hello.txt")
+	cmd(repoDir, "hg", "commit", "-m", "hello")
+	changeset := cmd(repoDir, "hg", "log", "-r", ".", "--template", "{node}")
+	cmd(repoDir, "hg", "tag", "v1.0")
+
+	remote := mercurial.RemoteURL("file://" + repoDir)
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+
+	if err := s.isCloneable(context.Background(), remote); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.cloneRepo(context.Background(), "example.com/hg/repo", remote, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(reposDir, "example.com/hg/repo")
+	if got := cmd(dir, "git", "show", "HEAD:hello.txt"); got != "hello world" {
+		t.Errorf("got hello.txt %q, want %q", got, "hello world")
+	}
+	if got, want := cmd(dir, "git", "log", "-1", "--format=%an <%ae>", "HEAD~1"), "Jane Doe <jane@example.com>"; got != want {
+		t.Errorf("got author %q, want %q", got, want)
+	}
+	if got := cmd(dir, "git", "tag"); got != "v1.0" {
+		t.Errorf("got tags %q, want v1.0", got)
+	}
+	if got := cmd(dir, "git", "notes", "--ref=hg", "show", "HEAD~1"); got != changeset {
+		t.Errorf("got changeset %q, want %q", got, changeset)
+	}
+	if got, err := repoRemoteURL(context.Background(), dir); err != nil || got != remote {
+		t.Errorf("got remote URL %q (error %v), want %q", got, err, remote)
+	}
+
+	// New changesets are converted by updates.
+	cmd(repoDir, "sh", "-c", "echo hello again > hello.txt")
+	cmd(repoDir, "hg", "commit", "-m", "hello again")
+	if err := s.doRepoUpdate2("example.com/hg/repo", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := cmd(dir, "git", "show", "HEAD:hello.txt"); got != "hello again" {
+		t.Errorf("got hello.txt %q, want %q", got, "hello again")
+	}
+}
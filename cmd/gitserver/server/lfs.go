@@ -0,0 +1,465 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// lfsMaxFileSizeConfigKey is the git config key of the maximum size in bytes
+// of the LFS objects gitserver fetches for a repo. LFS objects are not fetched
+// if it is not set.
+const lfsMaxFileSizeConfigKey = "sourcegraph.lfsMaxFileSize"
+
+// lfsBatchSize is the number of objects requested from the LFS server at
+// once.
+const lfsBatchSize = 100
+
+// lfsMediaType is the media type of requests to and responses of the LFS
+// batch API.
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+// updateRepoLFSMaxFileSize sets the maximum size in bytes of the LFS objects
+// fetched for the repo at gitDir, if it changed. Zero disables fetching LFS
+// objects.
+func updateRepoLFSMaxFileSize(gitDir string, maxSize int64) error {
+	current, err := getRepoConfigInt(gitDir, lfsMaxFileSizeConfigKey)
+	if err != nil || current == maxSize {
+		return err
+	}
+	return setRepoConfigInt(gitDir, lfsMaxFileSizeConfigKey, maxSize)
+}
+
+// fetchLFSObjects fetches the LFS objects referenced by pointer files at HEAD
+// of the repo at gitDir, which were not fetched yet and don't exceed the
+// maximum size of the repo's LFS objects. The contents of each object are
+// stored as a git note of the pointer blob under git.LFSNotesRef, which
+// readers of the pointer file look up (see git.ReadLFSObject).
+func (s *Server) fetchLFSObjects(ctx context.Context, gitDir, remoteURL string) error {
+	maxSize, err := getRepoConfigInt(gitDir, lfsMaxFileSizeConfigKey)
+	if err != nil || maxSize == 0 {
+		return err
+	}
+	endpoint, err := lfsEndpoint(remoteURL)
+	if err != nil {
+		return err
+	}
+
+	pointers, err := missingLFSPointers(ctx, gitDir, maxSize)
+	if err != nil {
+		return err
+	}
+	if len(pointers) == 0 {
+		return nil
+	}
+	log15.Info("fetching LFS objects", "repo", gitDir, "count", len(pointers))
+
+	tmpDir, err := s.tempDir("lfs-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for len(pointers) > 0 {
+		n := lfsBatchSize
+		if n > len(pointers) {
+			n = len(pointers)
+		}
+		if err := fetchLFSBatch(ctx, gitDir, tmpDir, endpoint, pointers[:n]); err != nil {
+			return err
+		}
+		pointers = pointers[n:]
+	}
+	return nil
+}
+
+// lfsPointerBlob is an LFS pointer file in a repo.
+type lfsPointerBlob struct {
+	git.LFSPointer
+	// Blob is the object ID of the pointer file.
+	Blob string
+}
+
+// missingLFSPointers returns the pointer files at HEAD of the repo at gitDir
+// whose objects don't exceed maxSize and were not fetched yet.
+func missingLFSPointers(ctx context.Context, gitDir string, maxSize int64) ([]*lfsPointerBlob, error) {
+	// Empty repos have no pointer files.
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", "HEAD")
+	cmd.Dir = gitDir
+	if err := cmd.Run(); err != nil {
+		return nil, nil
+	}
+
+	// Pointer files are small blobs, so only look at those.
+	cmd = exec.CommandContext(ctx, "git", "ls-tree", "-r", "-l", "-z", "HEAD")
+	cmd.Dir = gitDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, wrapCmdError(cmd, err)
+	}
+	candidates := map[string]struct{}{}
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		i := bytes.IndexByte(entry, '\t')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(entry[:i]))
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		if size, err := strconv.ParseInt(fields[3], 10, 64); err == nil && size <= git.MaxLFSPointerSize {
+			candidates[fields[2]] = struct{}{}
+		}
+	}
+
+	// Skip pointers whose objects were already fetched.
+	cmd = exec.CommandContext(ctx, "git", "notes", "--ref="+git.LFSNotesRef, "list")
+	cmd.Dir = gitDir
+	out, err = cmd.Output()
+	if err != nil {
+		return nil, wrapCmdError(cmd, err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		// <note object> SP <annotated object>
+		if fields := strings.Fields(line); len(fields) == 2 {
+			delete(candidates, fields[1])
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	blobs := make([]string, 0, len(candidates))
+	for blob := range candidates {
+		blobs = append(blobs, blob)
+	}
+	// The candidates are small, so we can read all of them at once.
+	contents, err := catFileBatch(ctx, gitDir, blobs)
+	if err != nil {
+		return nil, err
+	}
+
+	var pointers []*lfsPointerBlob
+	for blob, b := range contents {
+		if p, ok := git.ParseLFSPointer(b); ok && p.Size <= maxSize {
+			pointers = append(pointers, &lfsPointerBlob{LFSPointer: *p, Blob: blob})
+		}
+	}
+	return pointers, nil
+}
+
+// catFileBatch returns the contents of the objects in the repo at gitDir by
+// object ID. Missing objects are omitted.
+func catFileBatch(ctx context.Context, gitDir string, objects []string) (map[string][]byte, error) {
+	var stdin bytes.Buffer
+	for _, object := range objects {
+		fmt.Fprintln(&stdin, object)
+	}
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch")
+	cmd.Dir = gitDir
+	cmd.Stdin = &stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, wrapCmdError(cmd, err)
+	}
+
+	contents := make(map[string][]byte, len(objects))
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		// <object> SP <type> SP <size> LF <contents> LF
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(header)
+		if len(fields) == 2 && fields[1] == "missing" {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git cat-file output: %q", header)
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git cat-file output: %q", header)
+		}
+		b := make([]byte, size+1)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		contents[fields[0]] = b[:size]
+	}
+	return contents, nil
+}
+
+// readLFSObjects returns the contents of the LFS objects that the pointer
+// blobs of the repo at gitDir reference, by pointer blob. Pointers whose
+// objects were not fetched are omitted.
+func readLFSObjects(ctx context.Context, gitDir string, blobs []string) (map[string][]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "notes", "--ref="+git.LFSNotesRef, "list")
+	cmd.Dir = gitDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, wrapCmdError(cmd, err)
+	}
+	notes := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		// <note object> SP <annotated object>
+		if fields := strings.Fields(line); len(fields) == 2 {
+			notes[fields[1]] = fields[0]
+		}
+	}
+
+	var noteObjects []string
+	for _, blob := range blobs {
+		if note, ok := notes[blob]; ok {
+			noteObjects = append(noteObjects, note)
+		}
+	}
+	if len(noteObjects) == 0 {
+		return nil, nil
+	}
+	contents, err := catFileBatch(ctx, gitDir, noteObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string][]byte, len(noteObjects))
+	for _, blob := range blobs {
+		if b, ok := contents[notes[blob]]; ok {
+			objects[blob] = b
+		}
+	}
+	return objects, nil
+}
+
+func (s *Server) handleLFSObjects(w http.ResponseWriter, r *http.Request) {
+	var req protocol.LFSObjectsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, blob := range req.Blobs {
+		if !commitIDPattern.MatchString(blob) {
+			http.Error(w, "invalid object ID "+blob, http.StatusBadRequest)
+			return
+		}
+	}
+
+	req.Repo = protocol.NormalizeRepo(req.Repo)
+	dir := path.Join(s.ReposDir, string(req.Repo))
+	if cloneProgress, cloneInProgress := s.locker.Status(dir); cloneInProgress {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(&protocol.NotFoundPayload{
+			CloneInProgress: true,
+			CloneProgress:   cloneProgress,
+		})
+		return
+	}
+	if !repoCloned(dir) {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(&protocol.NotFoundPayload{CloneInProgress: false})
+		return
+	}
+	// Handle both bare repos and repos with a .git subdirectory.
+	gitDir := dir
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		gitDir = filepath.Join(dir, ".git")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), shortGitCommandTimeout(nil))
+	defer cancel()
+
+	objects, err := readLFSObjects(ctx, gitDir, req.Blobs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(&protocol.LFSObjectsResponse{Objects: objects}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// lfsEndpoint returns the URL of the LFS server of the remote URL. Only
+// HTTP(S) remotes are supported.
+func lfsEndpoint(remoteURL string) (*url.URL, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.New("LFS objects can only be fetched from HTTP(S) remotes")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(u.Path, ".git") {
+		u.Path += ".git"
+	}
+	u.Path += "/info/lfs"
+	return u, nil
+}
+
+// lfsBatchRequest is a request of the LFS batch API
+// (https://github.com/git-lfs/git-lfs/blob/master/docs/api/batch.md).
+type lfsBatchRequest struct {
+	Operation string         `json:"operation"`
+	Transfers []string       `json:"transfers"`
+	Objects   []lfsBatchItem `json:"objects"`
+}
+
+type lfsBatchItem struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// lfsBatchResponse is a response of the LFS batch API.
+type lfsBatchResponse struct {
+	Objects []struct {
+		lfsBatchItem
+		Actions struct {
+			Download *struct {
+				Href   string            `json:"href"`
+				Header map[string]string `json:"header"`
+			} `json:"download"`
+		} `json:"actions"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"objects"`
+}
+
+// fetchLFSBatch downloads the objects of the pointers from the LFS server at
+// endpoint, and stores them in the repo at gitDir. Objects are downloaded to
+// tmpDir first. Objects the server doesn't have are skipped.
+func fetchLFSBatch(ctx context.Context, gitDir, tmpDir string, endpoint *url.URL, pointers []*lfsPointerBlob) error {
+	batch := lfsBatchRequest{Operation: "download", Transfers: []string{"basic"}}
+	blobs := make(map[string][]string, len(pointers))
+	for _, p := range pointers {
+		if _, ok := blobs[p.OID]; !ok {
+			batch.Objects = append(batch.Objects, lfsBatchItem{OID: p.OID, Size: p.Size})
+		}
+		blobs[p.OID] = append(blobs[p.OID], p.Blob)
+	}
+	body, err := json.Marshal(&batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint.String()+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		// Don't include the endpoint in the error, since it may contain
+		// credentials.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return errors.Wrap(err, "LFS batch request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("LFS batch request failed with status %s", resp.Status)
+	}
+	var batchResp lfsBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batchResp); err != nil {
+		return errors.Wrap(err, "invalid LFS batch response")
+	}
+
+	for _, obj := range batchResp.Objects {
+		if _, ok := blobs[obj.OID]; !ok {
+			continue
+		}
+		if obj.Error != nil || obj.Actions.Download == nil {
+			if obj.Error != nil {
+				log15.Warn("LFS object not available", "repo", gitDir, "oid", obj.OID, "code", obj.Error.Code, "error", obj.Error.Message)
+			}
+			continue
+		}
+		blob, err := downloadLFSObject(ctx, gitDir, tmpDir, obj.Actions.Download.Href, obj.Actions.Download.Header, obj.lfsBatchItem)
+		if err != nil {
+			return errors.Wrapf(err, "failed to download LFS object %s", obj.OID)
+		}
+		for _, pointerBlob := range blobs[obj.OID] {
+			cmd := exec.CommandContext(ctx, "git", "notes", "--ref="+git.LFSNotesRef, "add", "-f", "-C", blob, pointerBlob)
+			cmd.Dir = gitDir
+			cmd.Env = append(os.Environ(),
+				"GIT_AUTHOR_NAME=sourcegraph", "GIT_AUTHOR_EMAIL=support@sourcegraph.com",
+				"GIT_COMMITTER_NAME=sourcegraph", "GIT_COMMITTER_EMAIL=support@sourcegraph.com",
+			)
+			if _, err := cmd.Output(); err != nil {
+				return wrapCmdError(cmd, err)
+			}
+		}
+	}
+	return nil
+}
+
+// downloadLFSObject downloads the LFS object from href to tmpDir, verifies
+// it, and writes it as a blob to the repo at gitDir. It returns the object ID
+// of the blob.
+func downloadLFSObject(ctx context.Context, gitDir, tmpDir, href string, header map[string]string, obj lfsBatchItem) (string, error) {
+	req, err := http.NewRequest("GET", href, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed with status %s", resp.Status)
+	}
+
+	f, err := ioutil.TempFile(tmpDir, "object-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(resp.Body, obj.Size+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if n != obj.Size || hex.EncodeToString(h.Sum(nil)) != obj.OID {
+		return "", errors.New("object does not match its pointer")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "hash-object", "-w", "--no-filters", f.Name())
+	cmd.Dir = gitDir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", wrapCmdError(cmd, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
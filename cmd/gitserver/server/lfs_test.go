@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestFetchLFSObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitserver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	objects := map[string]string{}
+	pointer := func(contents string) string {
+		h := sha256.Sum256([]byte(contents))
+		oid := hex.EncodeToString(h[:])
+		objects[oid] = contents
+		return fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(contents))
+	}
+	files := map[string]string{
+		"regular":  "hello world\n",
+		"small":    pointer("hello lfs\n"),
+		"large":    pointer(strings.Repeat("a", 100)),
+		"notfound": "version https://git-lfs.github.com/spec/v1\noid sha256:" + strings.Repeat("0", 64) + "\nsize 1\n",
+	}
+
+	var batchRequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repo.git/info/lfs/objects/batch":
+			batchRequests++
+			var req lfsBatchRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			var resp struct {
+				Objects []interface{} `json:"objects"`
+			}
+			for _, obj := range req.Objects {
+				if _, ok := objects[obj.OID]; !ok {
+					resp.Objects = append(resp.Objects, map[string]interface{}{
+						"oid": obj.OID, "size": obj.Size,
+						"error": map[string]interface{}{"code": 404, "message": "not found"},
+					})
+					continue
+				}
+				resp.Objects = append(resp.Objects, map[string]interface{}{
+					"oid": obj.OID, "size": obj.Size,
+					"actions": map[string]interface{}{
+						"download": map[string]interface{}{"href": "http://" + r.Host + "/objects/" + obj.OID},
+					},
+				})
+			}
+			w.Header().Set("Content-Type", lfsMediaType)
+			_ = json.NewEncoder(w).Encode(&resp)
+		case strings.HasPrefix(r.URL.Path, "/objects/"):
+			contents, ok := objects[strings.TrimPrefix(r.URL.Path, "/objects/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(contents))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := filepath.Join(root, "repo")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "."},
+		{"add", "."},
+		{"-c", "user.name=a", "-c", "user.email=a@a.com", "commit", "-m", "foo"},
+		{"config", lfsMaxFileSizeConfigKey, "50"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s: %s", args, err, out)
+		}
+	}
+	gitDir := filepath.Join(dir, ".git")
+
+	s := &Server{ReposDir: root}
+	for i := 0; i < 2; i++ {
+		if err := s.fetchLFSObjects(context.Background(), gitDir, srv.URL+"/repo"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"small":    "hello lfs\n",
+		"large":    "",
+		"notfound": "",
+		"regular":  "",
+	} {
+		cmd := exec.Command("git", "notes", "--ref="+git.LFSNotesRef, "show", "HEAD:"+name)
+		cmd.Dir = gitDir
+		got, _ := cmd.Output()
+		if string(got) != want {
+			t.Errorf("%s: got note %q, want %q", name, got, want)
+		}
+	}
+
+	// The second fetch only asks for the object the server doesn't have
+	// again.
+	if batchRequests != 2 {
+		t.Errorf("got %d batch requests, want 2", batchRequests)
+	}
+
+	// The objects of all pointers are read at once.
+	blob := func(name string) string {
+		cmd := exec.Command("git", "rev-parse", "HEAD:"+name)
+		cmd.Dir = gitDir
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	objects, err := readLFSObjects(context.Background(), gitDir, []string{blob("small"), blob("large"), blob("regular")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{blob("small"): []byte("hello lfs\n")}; !reflect.DeepEqual(objects, want) {
+		t.Errorf("got objects %q, want %q", objects, want)
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// maintenanceResultFile is the file in the git directory that records the
+// result of the last maintenance of the repo.
+const maintenanceResultFile = "sourcegraph-maintenance.json"
+
+// maintenanceStep is a git command that is run during maintenance of a repo.
+type maintenanceStep struct {
+	Name string
+	Args []string
+}
+
+// maintenanceSteps are run in order on each repo that is due for
+// maintenance. Maintenance of a repo stops at the first step that fails.
+var maintenanceSteps = []maintenanceStep{
+	// Pack loose objects into a single pack, and prune unreachable objects.
+	{"gc", []string{"gc", "--quiet"}},
+	// Speed up commit graph walks such as git log and merge-base.
+	{"commit-graph", []string{"commit-graph", "write", "--reachable"}},
+	// Index all packs, including the ones added by fetches since the gc.
+	{"multi-pack-index", []string{"multi-pack-index", "write"}},
+}
+
+// maintenanceResult is the result of the last maintenance of a repo.
+type maintenanceResult struct {
+	// Time is when the maintenance finished.
+	Time time.Time
+	// Duration is how long the maintenance took.
+	Duration time.Duration
+	// Error is the error of the step that failed, if any.
+	Error string `json:",omitempty"`
+}
+
+// lastMaintenance returns the result of the last maintenance of the repo at
+// gitDir, or nil if it was never maintained.
+func lastMaintenance(gitDir string) (*maintenanceResult, error) {
+	b, err := ioutil.ReadFile(filepath.Join(gitDir, maintenanceResultFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r maintenanceResult
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, errors.Wrap(err, "invalid maintenance result")
+	}
+	return &r, nil
+}
+
+func writeMaintenanceResult(gitDir string, r *maintenanceResult) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(gitDir, maintenanceResultFile), b, 0600)
+}
+
+// maintenanceDue reports whether the repo at gitDir is due for maintenance.
+// Repos that were never maintained are due an interval after they were
+// cloned.
+func (s *Server) maintenanceDue(gitDir string) (bool, error) {
+	if s.MaintenanceInterval <= 0 {
+		return false, nil
+	}
+	r, err := lastMaintenance(gitDir)
+	if err != nil {
+		return false, err
+	}
+	var last time.Time
+	if r != nil {
+		last = r.Time
+	} else if last, err = getRecloneTime(gitDir); err != nil {
+		return false, err
+	}
+	// Add a jitter to spread out maintenance of repos cloned at the same
+	// time.
+	return time.Since(last) > s.MaintenanceInterval+randDuration(s.MaintenanceInterval/4), nil
+}
+
+// startMaintenance maintains the repos at gitDirs in the background, so that
+// the janitor can free up disk space in the meantime. If the repos due in an
+// earlier janitor run are still being maintained, it does nothing; the repos
+// are still due in the next run.
+func (s *Server) startMaintenance(gitDirs []string) {
+	if !atomic.CompareAndSwapInt32(&s.maintaining, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&s.maintaining, 0)
+		ctx, cancel := s.serverContext()
+		defer cancel()
+		s.maintainRepos(ctx, gitDirs)
+	}()
+}
+
+// maintainRepos runs maintenance on the repos at gitDirs, on at most
+// MaintenanceConcurrency repos at a time. It returns when all are done.
+func (s *Server) maintainRepos(ctx context.Context, gitDirs []string) {
+	concurrency := s.MaintenanceConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	maintenanceQueued.Set(float64(len(gitDirs)))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, gitDir := range gitDirs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(gitDir string) {
+			defer func() {
+				<-sem
+				maintenanceQueued.Dec()
+				wg.Done()
+			}()
+			if err := s.maintainRepo(ctx, gitDir); err != nil {
+				log15.Error("failed to maintain repo", "repo", gitDir, "error", err)
+			}
+		}(gitDir)
+	}
+	wg.Wait()
+}
+
+// maintainRepo runs the maintenance steps on the repo at gitDir and records
+// the result.
+func (s *Server) maintainRepo(ctx context.Context, gitDir string) error {
+	// A clone replaces the repo when it is done, so maintaining it would be
+	// wasted work.
+	if _, cloneInProgress := s.locker.Status(filepath.Dir(gitDir)); cloneInProgress {
+		return nil
+	}
+
+	// Hold the update lock of the repo, so that it is not fetched (or
+	// replaced by a reclone) while git gc runs.
+	repo := protocol.NormalizeRepo(api.RepoName(strings.TrimPrefix(filepath.Dir(gitDir), s.ReposDir+"/")))
+	mu := s.repoUpdateMutex(repo)
+	mu.Lock()
+	defer mu.Unlock()
+	if !repoCloned(filepath.Dir(gitDir)) {
+		// The repo was removed while we waited for the lock.
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, longGitCommandTimeout)
+	defer cancel()
+
+	log15.Debug("maintaining repo", "repo", gitDir)
+
+	start := time.Now()
+	var stepErr error
+	for _, step := range maintenanceSteps {
+		stepStart := time.Now()
+		cmd := exec.CommandContext(ctx, "git", step.Args...)
+		cmd.Dir = gitDir
+		_, err := cmd.Output()
+		maintenanceStepDuration.WithLabelValues(step.Name, statusLabel(err)).Observe(time.Since(stepStart).Seconds())
+		if err != nil {
+			stepErr = errors.Wrapf(wrapCmdError(cmd, err), "maintenance step %s failed", step.Name)
+			break
+		}
+	}
+
+	r := &maintenanceResult{Time: time.Now(), Duration: time.Since(start)}
+	if stepErr != nil {
+		r.Error = stepErr.Error()
+	}
+	maintenanceRuns.WithLabelValues(statusLabel(stepErr)).Inc()
+	maintenanceDuration.Observe(r.Duration.Seconds())
+
+	if err := writeMaintenanceResult(gitDir, r); err != nil {
+		log15.Warn("failed to record maintenance result", "repo", gitDir, "error", err)
+	}
+	return stepErr
+}
+
+func statusLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
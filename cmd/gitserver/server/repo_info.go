@@ -52,6 +52,25 @@ func (s *Server) repoInfo(ctx context.Context, repo api.RepoName) (*protocol.Rep
 		} else {
 			resp.LastChanged = &lastChanged
 		}
+
+		if filter, err := repoPartialCloneFilter(ctx, dir); err != nil {
+			log15.Warn("error getting partial clone filter", "repo", repo, "err", err)
+		} else {
+			resp.PartialCloneFilter = filter
+		}
+
+		if size, err := repoSize(dir); err != nil {
+			log15.Warn("error getting repo size", "repo", repo, "err", err)
+		} else {
+			resp.Size = size
+		}
+
+		if r, err := lastMaintenance(filepath.Join(dir, ".git")); err != nil {
+			log15.Warn("error getting last maintenance", "repo", repo, "err", err)
+		} else if r != nil {
+			resp.LastMaintained = &r.Time
+			resp.MaintenanceError = r.Error
+		}
 	}
 	return &resp, nil
 }
@@ -91,13 +91,23 @@ func TestServer_handleRepoInfo(t *testing.T) {
 		repoRemoteURL = func(context.Context, string) (string, error) { return "u", nil }
 		defer func() { repoRemoteURL = origRepoRemoteURL }()
 
+		origRepoPartialCloneFilter := repoPartialCloneFilter
+		repoPartialCloneFilter = func(context.Context, string) (string, error) { return "blob:none", nil }
+		defer func() { repoPartialCloneFilter = origRepoPartialCloneFilter }()
+
+		origRepoSize := repoSize
+		repoSize = func(string) (int64, error) { return 1234, nil }
+		defer func() { repoSize = origRepoSize }()
+
 		want := protocol.RepoInfoResponse{
 			Results: map[api.RepoName]*protocol.RepoInfo{
 				"x": {
-					Cloned:      true,
-					LastFetched: &lastFetched,
-					LastChanged: &lastChanged,
-					URL:         "u",
+					Cloned:             true,
+					LastFetched:        &lastFetched,
+					LastChanged:        &lastChanged,
+					URL:                "u",
+					PartialCloneFilter: "blob:none",
+					Size:               1234,
 				},
 			},
 		}
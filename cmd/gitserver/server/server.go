@@ -31,9 +31,12 @@ import (
 	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/mercurial"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/svn"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/honey"
 	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
@@ -114,6 +117,27 @@ type Server struct {
 	// DesiredFreeDiskSpace is how much space we need to keep free in bytes.
 	DesiredFreeDiskSpace uint64
 
+	// MaintenanceInterval is how often the Janitor job runs git maintenance
+	// (gc, commit-graph and multi-pack-index) on each repo. When zero, repos
+	// are instead recloned after a while, and git gc runs automatically
+	// during fetches.
+	MaintenanceInterval time.Duration
+
+	// MaintenanceConcurrency is the maximum number of repos maintained at the
+	// same time. It defaults to 1.
+	MaintenanceConcurrency int
+
+	// AuditLogMaxSize is the maximum size in bytes of each file of the audit
+	// log of executed commands, which is kept in ReposDir. When zero, executed
+	// commands are not audited.
+	AuditLogMaxSize int64
+
+	// GitServerAddrs returns the addresses of all gitservers. When set, repos
+	// which are cloned on another gitserver (e.g. their previous owner before
+	// gitservers were added or removed) are transferred from it instead of
+	// being cloned from the code host.
+	GitServerAddrs func(ctx context.Context) []string
+
 	// skipCloneForTests is set by tests to avoid clones.
 	skipCloneForTests bool
 
@@ -136,6 +160,23 @@ type Server struct {
 
 	repoUpdateLocksMu sync.Mutex // protects the map below and also updates to locks.once
 	repoUpdateLocks   map[api.RepoName]*locks
+
+	// maintaining is 1 while repos are maintained in the background (see
+	// startMaintenance). It must be accessed atomically.
+	maintaining int32
+
+	// transfers tracks repos transferred from other gitservers.
+	transfers transferTracker
+
+	// oversized tracks repos that are not mirrored because they are too
+	// large.
+	oversized oversizedRepos
+
+	// ancestry caches the results of commit ancestry queries.
+	ancestry ancestryCache
+
+	// audit is the audit log of executed commands, if enabled.
+	audit *auditLog
 }
 
 type locks struct {
@@ -197,6 +238,9 @@ func (s *Server) Handler() http.Handler {
 	s.ctx, s.cancel = context.WithCancel(context.Background())
 	s.locker = &RepositoryLocker{}
 	s.repoUpdateLocks = make(map[api.RepoName]*locks)
+	if s.AuditLogMaxSize > 0 {
+		s.audit = &auditLog{dir: filepath.Join(s.ReposDir, auditLogDirName), maxSize: s.AuditLogMaxSize}
+	}
 
 	// GitMaxConcurrentClones controls the maximum number of clones that
 	// can happen at once on a single gitserver.
@@ -230,8 +274,13 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/repos", s.handleRepoInfo)
 	mux.HandleFunc("/delete", s.handleRepoDelete)
 	mux.HandleFunc("/repo-update", s.handleRepoUpdate)
+	mux.HandleFunc("/repo-transfer", s.handleRepoTransfer)
+	mux.HandleFunc("/git/", s.handleGitHTTP)
 	mux.HandleFunc("/getGitolitePhabricatorMetadata", s.handleGetGitolitePhabricatorMetadata)
 	mux.HandleFunc("/create-commit-from-patch", s.handleCreateCommitFromPatch)
+	mux.HandleFunc("/commit-ancestry", s.handleCommitAncestry)
+	mux.HandleFunc("/lfs-objects", s.handleLFSObjects)
+	mux.HandleFunc("/audit-log", s.handleAuditLog)
 	mux.HandleFunc("/ping", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
@@ -317,11 +366,12 @@ func (s *Server) tempDir(prefix string) (name string, err error) {
 }
 
 func (s *Server) ignorePath(path string) bool {
-	// We ignore any path which starts with .tmp in ReposDir
+	// We ignore any path which starts with .tmp in ReposDir, and the audit
+	// log.
 	if filepath.Dir(path) != s.ReposDir {
 		return false
 	}
-	return strings.HasPrefix(filepath.Base(path), tempDirName)
+	return strings.HasPrefix(filepath.Base(path), tempDirName) || filepath.Base(path) == auditLogDirName
 }
 
 func (s *Server) handleIsRepoCloneable(w http.ResponseWriter, r *http.Request) {
@@ -399,7 +449,7 @@ func (s *Server) handleRepoUpdate(w http.ResponseWriter, r *http.Request) {
 		// optimistically, we assume that our cloning attempt might
 		// succeed.
 		resp.CloneInProgress = true
-		_, err := s.cloneRepo(ctx, req.Repo, req.URL, nil)
+		_, err := s.cloneRepo(ctx, req.Repo, req.URL, &cloneOptions{PartialCloneFilter: req.PartialCloneFilter, MaxRepoSize: req.MaxRepoSize, LFSMaxFileSize: req.LFSMaxFileSize})
 		if err != nil {
 			log15.Warn("error cloning repo", "repo", req.Repo, "err", err)
 			resp.Error = err.Error()
@@ -408,10 +458,20 @@ func (s *Server) handleRepoUpdate(w http.ResponseWriter, r *http.Request) {
 		resp.Cloned = true
 		var statusErr, updateErr error
 
+		// The update fetches LFS objects with the new maximum size.
+		if err := updateRepoLFSMaxFileSize(dir, req.LFSMaxFileSize); err != nil {
+			log15.Warn("failed to update maximum LFS file size", "repo", req.Repo, "error", err)
+		}
+
 		if debounce(req.Repo, req.Since) {
 			updateErr = s.doRepoUpdate(ctx, req.Repo, req.URL)
 		}
 
+		// The janitor enforces changes of the maximum size.
+		if err := updateRepoMaxSize(dir, req.MaxRepoSize); err != nil {
+			log15.Warn("failed to update maximum repo size", "repo", req.Repo, "error", err)
+		}
+
 		// attempts to acquire these values are not contingent on the success of
 		// the update.
 		lastFetched, err := repoLastFetched(dir)
@@ -535,6 +595,27 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
+			if s.audit != nil {
+				a := actor.FromHTTPHeader(r.Header)
+				e := &protocol.AuditLogEntry{
+					Time:          start,
+					Repo:          req.Repo,
+					Args:          req.Args,
+					ActorUID:      a.UID,
+					ActorInternal: a.Internal,
+					Client:        r.UserAgent(),
+					Duration:      duration,
+					StdoutBytes:   stdoutN,
+					StderrBytes:   stderrN,
+					ExitStatus:    exitStatus,
+					Status:        status,
+				}
+				if execErr != nil {
+					e.Error = execErr.Error()
+				}
+				s.audit.record(e)
+			}
+
 			if cmdDuration > shortGitCommandSlow(req.Args) {
 				log15.Warn("Long exec request", "repo", req.Repo, "args", req.Args, "duration", cmdDuration.Round(time.Millisecond))
 			}
@@ -566,7 +647,7 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 			_ = json.NewEncoder(w).Encode(&protocol.NotFoundPayload{CloneInProgress: false})
 			return
 		}
-		cloneProgress, err := s.cloneRepo(ctx, req.Repo, req.URL, nil)
+		cloneProgress, err := s.cloneRepo(ctx, req.Repo, req.URL, &cloneOptions{PartialCloneFilter: req.PartialCloneFilter, MaxRepoSize: req.MaxRepoSize, LFSMaxFileSize: req.LFSMaxFileSize})
 		if err != nil {
 			log15.Debug("error cloning repo", "repo", req.Repo, "err", err)
 			status = "repo-not-found"
@@ -583,6 +664,8 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	markRepoUsed(filepath.Join(dir, ".git"))
+
 	didUpdate := s.ensureRevision(ctx, req.Repo, req.URL, req.EnsureRevision, dir)
 	if didUpdate {
 		ensureRevisionStatus = "fetched"
@@ -618,6 +701,10 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 	cmd.Stdout = stdoutW
 	cmd.Stderr = stderrW
 
+	// Commands in partial clones fetch missing objects from the remote when
+	// they need them. Make sure those fetches can't hang on prompts.
+	cmd.Env = append(os.Environ(), nonInteractiveRemoteEnv...)
+
 	exitStatus, execErr = runCommand(ctx, cmd)
 
 	status = strconv.Itoa(exitStatus)
@@ -667,8 +754,26 @@ type cloneOptions struct {
 
 	// Overwrite will overwrite the existing clone.
 	Overwrite bool
+
+	// PartialCloneFilter is the object filter (such as "blob:none") to
+	// clone the repo partially with. Missing objects are fetched from the
+	// remote when a command needs them.
+	PartialCloneFilter string
+
+	// MaxRepoSize is the maximum size in bytes of the repo. Zero means
+	// there is no limit.
+	MaxRepoSize int64
+
+	// LFSMaxFileSize is the maximum size in bytes of the Git LFS objects
+	// fetched for the repo. Zero means LFS objects are not fetched.
+	LFSMaxFileSize int64
 }
 
+// partialCloneFilterPattern matches the object filters we allow for partial
+// clones. It matches the pattern of the partialCloneFilter setting of
+// external services.
+var partialCloneFilterPattern = regexp.MustCompile(`^(blob:none|blob:limit=[0-9]+[kmg]?|tree:[0-9]+)$`)
+
 // cloneRepo issues a git clone command for the given repo. It is
 // non-blocking.
 func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, opts *cloneOptions) (string, error) {
@@ -680,6 +785,13 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 		return progress, nil
 	}
 
+	// Don't clone repos again that we know are too large.
+	if opts != nil {
+		if err := s.oversized.check(repo, opts.MaxRepoSize); err != nil {
+			return "", err
+		}
+	}
+
 	// isCloneable causes a network request, so we limit the number that can
 	// run at one time. We use a separate semaphore to cloning since these
 	// checks being blocked by a few slow clones will lead to poor feedback to
@@ -694,6 +806,20 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 		return "", fmt.Errorf("error cloning repo: repo %s (%s) not cloneable: %s", repo, url, err)
 	}
 
+	var filter string
+	var maxSize, lfsMaxFileSize int64
+	if opts != nil {
+		filter, maxSize, lfsMaxFileSize = opts.PartialCloneFilter, opts.MaxRepoSize, opts.LFSMaxFileSize
+	}
+	if filter != "" && !partialCloneFilterPattern.MatchString(filter) {
+		return "", fmt.Errorf("error cloning repo: repo %s has invalid partial clone filter %q", repo, filter)
+	}
+	// Clone repos with a maximum size partially up front. Otherwise a repo
+	// that is too large would take up its full size on disk before we notice.
+	if maxSize > 0 && filter == "" && canClonePartially(url) {
+		filter = oversizedPartialCloneFilter
+	}
+
 	// Mark this repo as currently being cloned. We have to check again if someone else isn't already
 	// cloning since we released the lock. We released the lock since isCloneable is a potentially
 	// slow operation.
@@ -744,15 +870,93 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 		defer os.RemoveAll(tmpPath)
 		tmpPath = filepath.Join(tmpPath, ".git")
 
-		cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", "--progress", url, tmpPath)
-		log15.Info("cloning repo", "repo", repo, "tmp", tmpPath, "dst", dstPath)
+		// Prefer transferring the repo from another gitserver which has it
+		// cloned, since that is a lot cheaper than cloning it from the code
+		// host. Partial clones are never transferred, since bundling them
+		// would fetch all of their missing objects. Neither are Subversion
+		// and Mercurial mirrors, since bundles don't include the conversion
+		// state.
+		var transferred bool
+		if filter == "" && !svn.IsRemote(url) && !mercurial.IsRemote(url) {
+			transferred, err = s.transferRepo(ctx, repo, url, tmpPath, lock)
+			if err != nil {
+				log15.Warn("failed to transfer repo from another gitserver, cloning from code host", "repo", repo, "error", err)
+				if err := os.RemoveAll(tmpPath); err != nil {
+					return err
+				}
+			}
+		}
+
+		// clone clones the repo from the code host into tmpPath.
+		clone := func(filter string) error {
+			pr, pw := io.Pipe()
+			defer pw.Close()
+			go readCloneProgress(repo, url, lock, pr)
+
+			switch {
+			case svn.IsRemote(url):
+				log15.Info("cloning Subversion repo", "repo", repo, "tmp", tmpPath, "dst", dstPath)
+				if err := s.cloneSVNRepo(ctx, url, tmpPath, pw); err != nil {
+					return errors.Wrap(err, "clone failed")
+				}
+			case mercurial.IsRemote(url):
+				log15.Info("cloning Mercurial repo", "repo", repo, "tmp", tmpPath, "dst", dstPath)
+				if err := s.cloneHgRepo(ctx, url, tmpPath, pw); err != nil {
+					return errors.Wrap(err, "clone failed")
+				}
+			default:
+				args := []string{"clone", "--mirror", "--progress"}
+				if filter != "" {
+					args = append(args, "--filter="+filter)
+				}
+				cmd := exec.CommandContext(ctx, "git", append(args, url, tmpPath)...)
+				log15.Info("cloning repo", "repo", repo, "tmp", tmpPath, "dst", dstPath, "filter", filter)
+				if output, err := s.runWithRemoteOpts(ctx, cmd, pw); err != nil {
+					return errors.Wrapf(err, "clone failed. Output: %s", string(output))
+				}
+			}
+			return nil
+		}
 
-		pr, pw := io.Pipe()
-		defer pw.Close()
-		go readCloneProgress(repo, url, lock, pr)
+		// fetchLFS fetches the LFS objects of the repo in tmpPath. Fetching
+		// LFS objects is best effort, so we do not fail the clone if it
+		// fails. They are fetched again on the next update.
+		fetchLFS := func() error {
+			if err := setRepoConfigInt(tmpPath, lfsMaxFileSizeConfigKey, lfsMaxFileSize); err != nil {
+				return err
+			}
+			if canClonePartially(url) {
+				if err := s.fetchLFSObjects(ctx, tmpPath, url); err != nil {
+					log15.Warn("failed to fetch LFS objects", "repo", repo, "error", err)
+				}
+			}
+			return nil
+		}
+		if !transferred {
+			if err := clone(filter); err != nil {
+				return err
+			}
+		}
+		if err := fetchLFS(); err != nil {
+			return err
+		}
 
-		if output, err := s.runWithRemoteOpts(ctx, cmd, pw); err != nil {
-			return errors.Wrapf(err, "clone failed. Output: %s", string(output))
+		// Enforce the maximum size of the repo (including its LFS objects)
+		// before it is used. Repos that are too large are not mirrored.
+		size, err := dirSize(tmpPath)
+		if err != nil {
+			return err
+		}
+		if maxSize > 0 && size > maxSize {
+			s.oversized.add(repo, size)
+			return &repoTooLargeError{repo: repo, size: size, maxSize: maxSize}
+		}
+		s.oversized.remove(repo)
+		if err := setRepoConfigInt(tmpPath, repoSizeConfigKey, size); err != nil {
+			return err
+		}
+		if err := setRepoConfigInt(tmpPath, maxRepoSizeConfigKey, maxSize); err != nil {
+			return err
 		}
 
 		// Update the last-changed stamp.
@@ -765,6 +969,11 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 			return err
 		}
 
+		// Don't replace the repo while it is updated or maintained.
+		mu := s.repoUpdateMutex(protocol.NormalizeRepo(repo))
+		mu.Lock()
+		defer mu.Unlock()
+
 		if overwrite {
 			// remove the current repo by putting it into our temporary directory
 			err := os.Rename(dstPath, filepath.Join(filepath.Dir(tmpPath), "old"))
@@ -842,7 +1051,7 @@ type urlRedactor struct {
 // credentials found in rawurl, and the rawurl itself.
 func newURLRedactor(rawurl string) *urlRedactor {
 	var sensitive []string
-	parsedURL, _ := url.Parse(rawurl)
+	parsedURL, _ := url.Parse(strings.TrimPrefix(strings.TrimPrefix(rawurl, svn.RemotePrefix), mercurial.RemotePrefix))
 	if parsedURL != nil {
 		if pw, _ := parsedURL.User.Password(); pw != "" {
 			sensitive = append(sensitive, pw)
@@ -907,6 +1116,25 @@ func (s *Server) isCloneable(ctx context.Context, url string) error {
 		return testRepoExists(ctx, url)
 	}
 
+	if svn.IsRemote(url) {
+		// git ls-remote can't check Subversion repositories, and the svn
+		// client may not be installed. Errors are reported by the clone.
+		_, err := svn.ParseRemote(url)
+		return err
+	}
+	if mercurial.IsRemote(url) {
+		out, err := runHgCommand(ctx, "", nil, "identify", "--", strings.TrimPrefix(url, mercurial.RemotePrefix))
+		if err != nil {
+			if ctxerr := ctx.Err(); ctxerr != nil {
+				err = ctxerr
+			}
+			if len(out) > 0 {
+				err = fmt.Errorf("%s (output follows)\n\n%s", err, out)
+			}
+		}
+		return err
+	}
+
 	cmd := exec.CommandContext(ctx, "git", args...)
 	out, err := s.runWithRemoteOpts(ctx, cmd, nil)
 	if err != nil {
@@ -972,14 +1200,7 @@ func (s *Server) doRepoUpdate(ctx context.Context, repo api.RepoName, url string
 	defer span.Finish()
 
 	s.repoUpdateLocksMu.Lock()
-	l, ok := s.repoUpdateLocks[repo]
-	if !ok {
-		l = &locks{
-			once: new(sync.Once),
-			mu:   new(sync.Mutex),
-		}
-		s.repoUpdateLocks[repo] = l
-	}
+	l := s.repoUpdateLocksLocked(repo)
 	once := l.once
 	mu := l.mu
 	s.repoUpdateLocksMu.Unlock()
@@ -1012,6 +1233,32 @@ func (s *Server) doRepoUpdate(ctx context.Context, repo api.RepoName, url string
 	}
 }
 
+// repoUpdateLocksLocked returns the locks of repo. The caller must hold
+// s.repoUpdateLocksMu.
+func (s *Server) repoUpdateLocksLocked(repo api.RepoName) *locks {
+	l, ok := s.repoUpdateLocks[repo]
+	if !ok {
+		l = &locks{
+			once: new(sync.Once),
+			mu:   new(sync.Mutex),
+		}
+		if s.repoUpdateLocks == nil {
+			s.repoUpdateLocks = make(map[api.RepoName]*locks)
+		}
+		s.repoUpdateLocks[repo] = l
+	}
+	return l
+}
+
+// repoUpdateMutex returns the mutex that prevents updates of repo from
+// running in parallel. Other operations that must not run concurrently with
+// an update hold it too.
+func (s *Server) repoUpdateMutex(repo api.RepoName) *sync.Mutex {
+	s.repoUpdateLocksMu.Lock()
+	defer s.repoUpdateLocksMu.Unlock()
+	return s.repoUpdateLocksLocked(repo).mu
+}
+
 // setLastChanged discerns an approximate last-changed timestamp for a
 // repository. This can be approximate; it's used to determine how often we
 // should run `git fetch`, but is not relied on strongly. The basic plan
@@ -1190,15 +1437,51 @@ func (s *Server) doRepoUpdate2(repo api.RepoName, url string) error {
 		}
 	}
 
-	cmd := exec.CommandContext(ctx, "git", "fetch", "--prune", url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*")
-	cmd.Dir = dir
-
 	// drop temporary pack files after a fetch. this function won't
 	// return until this fetch has completed or definitely-failed,
 	// either way they can't still be in use. we don't care exactly
 	// when the cleanup happens, just that it does.
 	defer s.cleanTmpFiles(dir)
 
+	// Keep the size of the repo up to date. This runs after cleanTmpFiles.
+	defer func() {
+		if _, err := updateRepoSize(filepath.Join(dir, ".git")); err != nil {
+			log15.Warn("Failed to update repo size", "repo", repo, "error", err)
+		}
+	}()
+
+	if svn.IsRemote(url) {
+		// fetchSVN also updates HEAD.
+		if err := s.fetchSVN(ctx, filepath.Join(dir, ".git"), url, nil); err != nil {
+			log15.Error("Failed to update", "repo", repo, "error", err)
+			return errors.Wrap(err, "failed to update")
+		}
+		if err := setLastChanged(dir); err != nil {
+			log15.Warn("Failed to update last changed time", "repo", repo, "error", err)
+		}
+		return nil
+	}
+	if mercurial.IsRemote(url) {
+		// convertHg also updates HEAD.
+		if err := s.fetchHg(ctx, filepath.Join(dir, ".git"), url, nil); err != nil {
+			log15.Error("Failed to update", "repo", repo, "error", err)
+			return errors.Wrap(err, "failed to update")
+		}
+		if err := setLastChanged(dir); err != nil {
+			log15.Warn("Failed to update last changed time", "repo", repo, "error", err)
+		}
+		return nil
+	}
+
+	args := []string{"fetch", "--prune", url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*"}
+	if s.MaintenanceInterval > 0 {
+		// The janitor maintains the repo, so avoid slowing down the fetch
+		// with an automatic gc.
+		args = append([]string{"-c", "gc.auto=0"}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
 	if output, err := s.runWithRemoteOpts(ctx, cmd, nil); err != nil {
 		log15.Error("Failed to update", "repo", repo, "error", err, "output", string(output))
 		return errors.Wrap(err, "failed to update")
@@ -1253,6 +1536,12 @@ func (s *Server) doRepoUpdate2(repo api.RepoName, url string) error {
 		log15.Error("Failed to set HEAD", "repo", repo, "error", err, "output", string(output))
 		return errors.Wrap(err, "Failed to set HEAD")
 	}
+
+	// Fetch the LFS objects of new pointer files at HEAD. This is best
+	// effort, so we do not fail the update if it fails.
+	if err := s.fetchLFSObjects(ctx, filepath.Join(dir, ".git"), url); err != nil {
+		log15.Warn("Failed to fetch LFS objects", "repo", repo, "error", err)
+	}
 	return nil
 }
 
@@ -388,3 +388,151 @@ func TestCloneRepo(t *testing.T) {
 		t.Fatal("failed to clone")
 	}
 }
+
+func TestCloneRepo_partial(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	cmd := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s\n%s", name, strings.Join(arg, " "), err, b)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	cmd(remote, "git", "init", ".")
+	cmd(remote, "git", "config", "uploadpack.allowFilter", "true")
+	cmd(remote, "sh", "-c", "echo hello world > hello.txt")
+	cmd(remote, "git", "add", "hello.txt")
+	cmd(remote, "git", "commit", "-m", "hello")
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+
+	// Filters are only supported by the smart protocols, so we can't clone
+	// from a local path.
+	url := "file://" + remote
+
+	_, err := s.cloneRepo(context.Background(), "example.com/foo/bar", url, &cloneOptions{Block: true, PartialCloneFilter: "blob:none --upload-pack=evil"})
+	if err == nil {
+		t.Fatal("expected clone with invalid filter to fail")
+	}
+
+	_, err = s.cloneRepo(context.Background(), "example.com/foo/bar", url, &cloneOptions{Block: true, PartialCloneFilter: "blob:none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(reposDir, "example.com/foo/bar")
+	filter, err := repoPartialCloneFilter(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter != "blob:none" {
+		t.Errorf("got partial clone filter %q, want %q", filter, "blob:none")
+	}
+
+	// The blob of hello.txt was not cloned.
+	if missing := cmd(dir, "git", "rev-list", "--objects", "--missing=print", "HEAD"); !strings.Contains(missing, "\n?") {
+		t.Errorf("expected missing objects, got:\n%s", missing)
+	}
+
+	// But it is fetched lazily when needed.
+	if got := cmd(dir, "git", "show", "HEAD:hello.txt"); got != "hello world" {
+		t.Errorf("got hello.txt %q, want %q", got, "hello world")
+	}
+}
+
+func TestCloneRepo_maxRepoSize(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	cmd := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s\n%s", name, strings.Join(arg, " "), err, b)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	// A repo with an incompressible blob of 1 MB.
+	cmd(remote, "git", "init", ".")
+	cmd(remote, "git", "config", "uploadpack.allowFilter", "true")
+	cmd(remote, "sh", "-c", "head -c 1000000 /dev/urandom > big.bin")
+	cmd(remote, "git", "add", "big.bin")
+	cmd(remote, "git", "commit", "-m", "big")
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+	url := "file://" + remote
+
+	t.Run("cloned partially", func(t *testing.T) {
+		const maxSize = 500000
+		if _, err := s.cloneRepo(context.Background(), "example.com/foo/partial", url, &cloneOptions{Block: true, MaxRepoSize: maxSize}); err != nil {
+			t.Fatal(err)
+		}
+
+		dir := filepath.Join(reposDir, "example.com/foo/partial", ".git")
+		if filter, err := repoPartialCloneFilter(context.Background(), dir); err != nil || filter != oversizedPartialCloneFilter {
+			t.Errorf("got partial clone filter %q (error %v), want %q", filter, err, oversizedPartialCloneFilter)
+		}
+		if size, err := repoSize(dir); err != nil || size == 0 || size > maxSize {
+			t.Errorf("got size %d (error %v), want at most %d", size, err, maxSize)
+		}
+		if got, err := repoMaxSize(dir); err != nil || got != maxSize {
+			t.Errorf("got maximum size %d (error %v), want %d", got, err, maxSize)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := s.cloneRepo(context.Background(), "example.com/foo/toolarge", url, &cloneOptions{Block: true, MaxRepoSize: 1000})
+			if _, ok := errors.Cause(err).(*repoTooLargeError); !ok {
+				t.Fatalf("got error %v, want *repoTooLargeError", err)
+			}
+			if repoCloned(filepath.Join(reposDir, "example.com/foo/toolarge")) {
+				t.Fatal("repo that is too large was cloned")
+			}
+		}
+
+		// It is cloned if the limit is removed.
+		if _, err := s.cloneRepo(context.Background(), "example.com/foo/toolarge", url, &cloneOptions{Block: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -9,7 +9,38 @@ import (
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
+var (
+	maintenanceRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_runs_total",
+		Help:      "Number of repos maintained by the janitor.",
+	}, []string{"status"})
+	maintenanceDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_duration_seconds",
+		Help:      "Time spent maintaining a repo.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+	})
+	maintenanceStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_step_duration_seconds",
+		Help:      "Time spent running a step of the maintenance of a repo.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+	}, []string{"step", "status"})
+	maintenanceQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_queued",
+		Help:      "Number of repos due for maintenance in the current janitor run.",
+	})
+)
+
 func (s *Server) RegisterMetrics() {
+	prometheus.MustRegister(maintenanceRuns, maintenanceDuration, maintenanceStepDuration, maintenanceQueued)
+
 	// test the latency of exec, which may increase under certain memory
 	// conditions
 	echoDuration := prometheus.NewGauge(prometheus.GaugeOpts{
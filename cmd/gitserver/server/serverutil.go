@@ -19,16 +19,22 @@ import (
 	log15 "gopkg.in/inconshreveable/log15.v2"
 )
 
-// runWithRemoteOpts runs the command after applying the remote options.
-// If progress is not nil, all output is written to it in a separate goroutine.
-func (s *Server) runWithRemoteOpts(ctx context.Context, cmd *exec.Cmd, progress io.Writer) ([]byte, error) {
-	cmd.Env = append(cmd.Env, "GIT_ASKPASS=true") // disable password prompt
+// nonInteractiveRemoteEnv is the environment of git commands that talk to a
+// remote. It prevents them from hanging on prompts.
+var nonInteractiveRemoteEnv = []string{
+	"GIT_ASKPASS=true", // disable password prompt
 
 	// Suppress asking to add SSH host key to known_hosts (which will hang because
 	// the command is non-interactive).
 	//
 	// And set a timeout to avoid indefinite hangs if the server is unreachable.
-	cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes -o ConnectTimeout=30")
+	"GIT_SSH_COMMAND=ssh -o BatchMode=yes -o ConnectTimeout=30",
+}
+
+// runWithRemoteOpts runs the command after applying the remote options.
+// If progress is not nil, all output is written to it in a separate goroutine.
+func (s *Server) runWithRemoteOpts(ctx context.Context, cmd *exec.Cmd, progress io.Writer) ([]byte, error) {
+	cmd.Env = append(cmd.Env, nonInteractiveRemoteEnv...)
 
 	extraArgs := []string{
 		// Unset credential helper because the command is non-interactive.
@@ -68,6 +74,19 @@ func (s *Server) runWithRemoteOpts(ctx context.Context, cmd *exec.Cmd, progress
 	return b.Bytes(), err
 }
 
+// runWithProgress runs the command and returns its combined output. If
+// progress is not nil, all output is also written to it.
+func runWithProgress(ctx context.Context, cmd *exec.Cmd, progress io.Writer) ([]byte, error) {
+	var buf bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &buf, &buf
+	if progress != nil {
+		w := io.MultiWriter(&buf, progress)
+		cmd.Stdout, cmd.Stderr = w, w
+	}
+	_, err := runCommand(ctx, cmd)
+	return buf.Bytes(), err
+}
+
 // repoCloned checks if dir or `${dir}/.git` is a valid GIT_DIR.
 var repoCloned = func(dir string) bool {
 	if _, err := os.Stat(filepath.Join(dir, "HEAD")); !os.IsNotExist(err) {
@@ -152,6 +171,24 @@ var repoRemoteURL = func(ctx context.Context, dir string) (string, error) {
 	return remoteURLs[0], nil
 }
 
+// repoPartialCloneFilter returns the object filter the repo in dir was
+// partially cloned with, or the empty string if it is a full clone.
+var repoPartialCloneFilter = func(ctx context.Context, dir string) (string, error) {
+	cmd := exec.Command("git", "config", "--get", "remote.origin.partialclonefilter")
+	cmd.Dir = dir
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	exitCode, err := runCommand(ctx, cmd)
+	if exitCode == 1 {
+		// Exit code 1 means the key is not set.
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %s", cmd.Args, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
 // writeCounter wraps an io.WriterCloser and keeps track of bytes written.
 type writeCounter struct {
 	w io.Writer
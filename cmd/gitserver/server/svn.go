@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/svn"
+)
+
+// Subversion repositories are mirrored with git-svn into a bare repository,
+// like any other repository. git-svn fetches into refs/remotes/svn/*, which
+// updateSVNRefs maps to the branches and tags the rest of Sourcegraph sees.
+const svnRefPrefix = "refs/remotes/svn/"
+
+// Files gitserver keeps in the git directory of Subversion mirrors.
+const (
+	svnAuthorsFile = "sourcegraph-svn-authors"
+	svnAuthorsProg = "sourcegraph-svn-authors-prog"
+	svnAskPass     = "sourcegraph-svn-askpass"
+)
+
+// svnAuthorsProgScript is used by git-svn for Subversion users that are not in
+// the authors file. Without it git-svn fails to fetch their commits.
+const svnAuthorsProgScript = `#!/bin/sh
+printf '%s <%s>\n' "$1" "$1"
+`
+
+// svnAskPassScript passes the password of the Subversion remote to git-svn.
+// The password itself is only in the environment of the command.
+const svnAskPassScript = `#!/bin/sh
+printf '%s\n' "$SRC_SVN_PASSWORD"
+`
+
+// cloneSVNRepo mirrors the Subversion repository of the remote URL (see
+// svn.Remote) into a new bare repository at gitDir.
+func (s *Server) cloneSVNRepo(ctx context.Context, remoteURL, gitDir string, progress io.Writer) error {
+	remote, err := svn.ParseRemote(remoteURL)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(gitDir, os.ModePerm); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "git", "init", "--bare", ".")
+	cmd.Dir = gitDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "git init failed. Output: %s", string(output))
+	}
+
+	args := []string{"init", "--prefix=" + strings.TrimPrefix(svnRefPrefix, "refs/remotes/")}
+	if remote.StdLayout {
+		args = append(args, "--stdlayout")
+	}
+	if output, err := s.runSVNCommand(ctx, gitDir, remote, nil, args...); err != nil {
+		return errors.Wrapf(err, "git svn init failed. Output: %s", string(output))
+	}
+
+	// Like git clone, store the remote URL as the origin remote, so that the
+	// repo can be updated by requests without a URL.
+	cmd = exec.CommandContext(ctx, "git", "config", "remote.origin.url", remoteURL)
+	cmd.Dir = gitDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "failed to set origin remote. Output: %s", string(output))
+	}
+
+	return s.fetchSVN(ctx, gitDir, remoteURL, progress)
+}
+
+// fetchSVN fetches new revisions of the Subversion repository of the remote
+// URL into the mirror at gitDir, and updates its branches and tags.
+func (s *Server) fetchSVN(ctx context.Context, gitDir, remoteURL string, progress io.Writer) error {
+	remote, err := svn.ParseRemote(remoteURL)
+	if err != nil {
+		return err
+	}
+
+	// The author mapping may have changed since the last fetch. It only
+	// applies to revisions fetched from now on.
+	var authors bytes.Buffer
+	users := make([]string, 0, len(remote.Authors))
+	for user := range remote.Authors {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		fmt.Fprintf(&authors, "%s = %s\n", user, remote.Authors[user])
+	}
+	authorsFile := filepath.Join(gitDir, svnAuthorsFile)
+	if _, err := updateFileIfDifferent(authorsFile, authors.Bytes()); err != nil {
+		return errors.Wrap(err, "failed to write Subversion authors file")
+	}
+	authorsProg, err := writeSVNScript(gitDir, svnAuthorsProg, svnAuthorsProgScript)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"fetch", "--authors-file=" + authorsFile, "--authors-prog=" + authorsProg}
+	if output, err := s.runSVNCommand(ctx, gitDir, remote, progress, args...); err != nil {
+		return errors.Wrapf(err, "git svn fetch failed. Output: %s", string(output))
+	}
+
+	return updateSVNRefs(ctx, gitDir)
+}
+
+// runSVNCommand runs git svn with the given arguments in gitDir. It
+// authenticates to the Subversion server with the credentials of the remote.
+// If progress is not nil, all output is also written to it.
+func (s *Server) runSVNCommand(ctx context.Context, gitDir string, remote *svn.Remote, progress io.Writer, args ...string) ([]byte, error) {
+	u := *remote.URL
+	var username, password string
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	if u.Scheme == "svn+ssh" {
+		// ssh authenticates, and needs the username in the URL.
+		if username != "" {
+			u.User = url.User(username)
+		}
+		username = ""
+	} else {
+		u.User = nil
+	}
+
+	if username != "" {
+		args = append(args, "--username="+username)
+	}
+	if args[0] == "init" {
+		args = append(args, u.String())
+	}
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"svn"}, args...)...)
+	cmd.Dir = gitDir
+	// Subversion prompts for credentials and to accept unknown certificates,
+	// so the command must be non-interactive. svn needs HOME for its
+	// configuration, so we keep the environment.
+	cmd.Env = append(os.Environ(), nonInteractiveRemoteEnv...)
+	if password != "" {
+		askPass, err := writeSVNScript(gitDir, svnAskPass, svnAskPassScript)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, "GIT_ASKPASS="+askPass, "SRC_SVN_PASSWORD="+password)
+	}
+
+	return runWithProgress(ctx, cmd, progress)
+}
+
+// writeSVNScript writes the executable script to name in gitDir and returns
+// its path.
+func writeSVNScript(gitDir, name, script string) (string, error) {
+	path := filepath.Join(gitDir, name)
+	if _, err := updateFileIfDifferent(path, []byte(script)); err != nil {
+		return "", errors.Wrapf(err, "failed to write %s", name)
+	}
+	if err := os.Chmod(path, 0700); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// updateSVNRefs updates the branches and tags of the Subversion mirror at
+// gitDir to match the refs git-svn fetched:
+//
+//	refs/remotes/svn/trunk (or git-svn without the standard layout) -> refs/heads/master
+//	refs/remotes/svn/tags/<tag>                                     -> refs/tags/<tag>
+//	refs/remotes/svn/<branch>                                       -> refs/heads/<branch>
+//
+// Branches and tags that were deleted in Subversion are deleted, and HEAD
+// points to master.
+func updateSVNRefs(ctx context.Context, gitDir string) error {
+	svnRefs, err := listRefs(ctx, gitDir, svnRefPrefix)
+	if err != nil {
+		return err
+	}
+
+	want := make(map[string]string, len(svnRefs))
+	for ref, oid := range svnRefs {
+		name := strings.TrimPrefix(ref, svnRefPrefix)
+		switch {
+		case strings.Contains(name, "@"):
+			// git-svn keeps the history of branches that were replaced in
+			// refs such as trunk@123. They are not branches in Subversion.
+			continue
+		case name == "trunk" || name == "git-svn":
+			want["refs/heads/master"] = oid
+		case strings.HasPrefix(name, "tags/"):
+			want["refs/tags/"+strings.TrimPrefix(name, "tags/")] = oid
+		case name == "master":
+			// trunk is mirrored as master.
+			continue
+		default:
+			want["refs/heads/"+name] = oid
+		}
+	}
+
+	have, err := listRefs(ctx, gitDir, "refs/heads/", "refs/tags/")
+	if err != nil {
+		return err
+	}
+
+	var updates bytes.Buffer
+	for ref, oid := range want {
+		if have[ref] != oid {
+			fmt.Fprintf(&updates, "update %s %s\n", ref, oid)
+		}
+	}
+	for ref := range have {
+		if _, ok := want[ref]; !ok {
+			fmt.Fprintf(&updates, "delete %s\n", ref)
+		}
+	}
+
+	if updates.Len() > 0 {
+		cmd := exec.CommandContext(ctx, "git", "update-ref", "--stdin")
+		cmd.Dir = gitDir
+		cmd.Stdin = &updates
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "failed to update Subversion refs. Output: %s", string(output))
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "symbolic-ref", "HEAD", "refs/heads/master")
+	cmd.Dir = gitDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "failed to set HEAD. Output: %s", string(output))
+	}
+	return nil
+}
+
+// listRefs returns the object names of the refs in gitDir that start with
+// any of the prefixes, keyed by ref name.
+func listRefs(ctx context.Context, gitDir string, prefixes ...string) (map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"for-each-ref", "--format=%(objectname) %(refname)"}, prefixes...)...)
+	cmd.Dir = gitDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list refs")
+	}
+
+	refs := map[string]string{}
+	scan := bufio.NewScanner(bytes.NewReader(out))
+	for scan.Scan() {
+		fields := strings.SplitN(scan.Text(), " ", 2)
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	return refs, scan.Err()
+}
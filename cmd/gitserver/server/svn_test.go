@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/svn"
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+)
+
+func TestUpdateSVNRefs(t *testing.T) {
+	gitDir, cleanup := tmpDir(t)
+	defer cleanup()
+
+	cmd := func(name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = gitDir
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		b, err := c.Output()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s", name, strings.Join(arg, " "), err)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	cmd("git", "init", "--bare", ".")
+	tree := cmd("git", "hash-object", "-t", "tree", "-w", "/dev/null")
+	trunk := cmd("git", "commit-tree", "-m", "trunk", tree)
+	branch := cmd("git", "commit-tree", "-m", "branch", "-p", trunk, tree)
+	tag := cmd("git", "commit-tree", "-m", "tag", "-p", trunk, tree)
+
+	for ref, oid := range map[string]string{
+		"refs/remotes/svn/trunk":     trunk,
+		"refs/remotes/svn/trunk@12":  branch,
+		"refs/remotes/svn/feature":   branch,
+		"refs/remotes/svn/master":    branch,
+		"refs/remotes/svn/tags/v1.0": tag,
+		"refs/heads/deleted":         branch,
+		"refs/heads/feature":         trunk,
+		"refs/tags/deleted":          tag,
+	} {
+		cmd("git", "update-ref", ref, oid)
+	}
+
+	if err := updateSVNRefs(context.Background(), gitDir); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := listRefs(context.Background(), gitDir, "refs/heads/", "refs/tags/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"refs/heads/master":  trunk,
+		"refs/heads/feature": branch,
+		"refs/tags/v1.0":     tag,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got refs %v, want %v", got, want)
+	}
+	if head := cmd("git", "symbolic-ref", "HEAD"); head != "refs/heads/master" {
+		t.Errorf("got HEAD %s, want refs/heads/master", head)
+	}
+}
+
+func TestCloneRepo_svn(t *testing.T) {
+	if _, err := exec.LookPath("svnadmin"); err != nil {
+		t.Skip("svnadmin not found")
+	}
+	if err := exec.Command("git", "svn", "--version").Run(); err != nil {
+		t.Skip("git svn not found")
+	}
+
+	svnDir, cleanup1 := tmpDir(t)
+	defer cleanup1()
+	wc, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	cmd := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s\n%s", name, strings.Join(arg, " "), err, b)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	// Create a Subversion repository with the standard layout, a commit on
+	// trunk and a tag.
+	cmd(svnDir, "svnadmin", "create", "repo")
+	svnURL := "file://" + filepath.Join(svnDir, "repo")
+	cmd(svnDir, "svn", "mkdir", "--username", "jdoe", "-m", "layout", svnURL+"/trunk", svnURL+"/branches", svnURL+"/tags")
+	cmd(wc, "svn", "checkout", svnURL+"/trunk", ".")
+	cmd(wc, "sh", "-c", "echo hello world > hello.txt")
+	cmd(wc, "svn", "add", "hello.txt")
+	cmd(wc, "svn", "commit", "--username", "jdoe", "-m", "hello")
+	cmd(wc, "svn", "copy", "--username", "jdoe", "-m", "tag", svnURL+"/trunk", svnURL+"/tags/v1.0")
+
+	u, err := url.Parse(svnURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := (&svn.Remote{
+		URL:       u,
+		StdLayout: true,
+		Authors:   map[string]string{"jdoe": "Jane Doe <jane@example.com>"},
+	}).String()
+
+	reposDir, cleanup3 := tmpDir(t)
+	defer cleanup3()
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+
+	if _, err := s.cloneRepo(context.Background(), "example.com/svn/repo", remote, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(reposDir, "example.com/svn/repo")
+	if got := cmd(dir, "git", "show", "HEAD:hello.txt"); got != "hello world" {
+		t.Errorf("got hello.txt %q, want %q", got, "hello world")
+	}
+	if got, want := cmd(dir, "git", "log", "-1", "--format=%an <%ae>", "HEAD"), "Jane Doe <jane@example.com>"; got != want {
+		t.Errorf("got author %q, want %q", got, want)
+	}
+	if got := cmd(dir, "git", "tag"); got != "v1.0" {
+		t.Errorf("got tags %q, want v1.0", got)
+	}
+	if got, err := repoRemoteURL(context.Background(), dir); err != nil || got != remote {
+		t.Errorf("got remote URL %q (error %v), want %q", got, err, remote)
+	}
+
+	// New revisions by authors that are not mapped are fetched by updates.
+	cmd(wc, "sh", "-c", "echo hello again > hello.txt")
+	cmd(wc, "svn", "commit", "--username", "bob", "-m", "hello again")
+	if err := s.doRepoUpdate2("example.com/svn/repo", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := cmd(dir, "git", "show", "HEAD:hello.txt"); got != "hello again" {
+		t.Errorf("got hello.txt %q, want %q", got, "hello again")
+	}
+	if got, want := cmd(dir, "git", "log", "-1", "--format=%an <%ae>", "HEAD"), "bob <bob>"; got != want {
+		t.Errorf("got author %q, want %q", got, want)
+	}
+}
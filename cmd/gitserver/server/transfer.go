@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// When gitserver replicas are added or removed, repos are hashed onto a
+// different gitserver. Instead of recloning those repos from the code host,
+// the new owner transfers them from the gitserver which still has them
+// cloned. The transfer is a git bundle of all refs served by
+// /repo-transfer. The transferred repo is fetched from the code host before
+// it is used, since the bundle may be behind it.
+
+// transferProbeTimeout is how long we wait for other gitservers to report
+// whether they have a repo cloned before falling back to a regular clone.
+const transferProbeTimeout = 5 * time.Second
+
+// maxFinishedTransfers is the number of finished transfers we remember for
+// the status page.
+const maxFinishedTransfers = 100
+
+// handleRepoTransfer streams a git bundle of all refs of a cloned repo.
+func (s *Server) handleRepoTransfer(w http.ResponseWriter, r *http.Request) {
+	var req protocol.RepoTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Repo = protocol.NormalizeRepo(req.Repo)
+	dir := path.Join(s.ReposDir, string(req.Repo))
+	if !repoCloned(dir) {
+		http.Error(w, "repository not cloned", http.StatusNotFound)
+		return
+	}
+	if filter, err := repoPartialCloneFilter(r.Context(), dir); err != nil || filter != "" {
+		// Bundling a partial clone would fetch all of its missing objects
+		// from the code host.
+		http.Error(w, "partial clones can't be transferred", http.StatusConflict)
+		return
+	}
+
+	var stderr bytes.Buffer
+	cw := &countingWriter{w: w}
+	cmd := exec.CommandContext(r.Context(), "git", "bundle", "create", "-", "--all")
+	cmd.Dir = dir
+	cmd.Stdout = cw
+	cmd.Stderr = &stderr
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err := runCommand(r.Context(), cmd); err != nil {
+		log15.Error("failed to create bundle for repo transfer", "repo", req.Repo, "error", err, "output", stderr.String())
+		if cw.n == 0 {
+			// Nothing has been written yet, so we can still tell the
+			// client. Otherwise the client will fail to read the truncated
+			// bundle.
+			http.Error(w, fmt.Sprintf("failed to create bundle: %s", stderr.String()), http.StatusInternalServerError)
+		}
+	}
+}
+
+// transferRepo clones repo to tmpPath from another gitserver that has it
+// cloned. The origin remote of the transferred clone is set to url, and it is
+// fetched from the code host to catch up with the changes the other
+// gitserver didn't have yet. It returns false if no other gitserver has the
+// repo cloned.
+func (s *Server) transferRepo(ctx context.Context, repo api.RepoName, url, tmpPath string, lock *RepositoryLock) (transferred bool, err error) {
+	src := s.findTransferSource(ctx, repo)
+	if src == "" {
+		return false, nil
+	}
+
+	t := s.transfers.start(repo, src)
+	defer func() { s.transfers.finish(t, err) }()
+
+	log15.Info("transferring repo", "repo", repo, "source", src, "tmp", tmpPath)
+	lock.SetStatus(fmt.Sprintf("transferring from %s", src))
+
+	body, err := json.Marshal(&protocol.RepoTransferRequest{Repo: repo})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest("POST", "http://"+src+"/repo-transfer", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("repo transfer from %s failed with status %s", src, resp.Status)
+	}
+
+	// The bundle lives next to tmpPath, so it is removed together with the
+	// temporary clone directory.
+	bundlePath := filepath.Join(filepath.Dir(tmpPath), "transfer.bundle")
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		return false, err
+	}
+	_, err = io.Copy(f, &transferReader{r: resp.Body, t: t})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to download bundle")
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", bundlePath, tmpPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return false, errors.Wrapf(err, "clone from bundle failed. Output: %s", string(output))
+	}
+
+	cmd = exec.CommandContext(ctx, "git", "remote", "set-url", "origin", url)
+	cmd.Dir = tmpPath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return false, errors.Wrapf(err, "failed to set origin. Output: %s", string(output))
+	}
+
+	// The fetch is best effort: the transferred repo is still a lot more
+	// useful than no repo, and it is fetched again on its next update.
+	lock.SetStatus("fetching after transfer")
+	cmd = exec.CommandContext(ctx, "git", "fetch", "--prune", url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*")
+	cmd.Dir = tmpPath
+	if output, err := s.runWithRemoteOpts(ctx, cmd, nil); err != nil {
+		log15.Warn("failed to fetch transferred repo", "repo", repo, "error", err, "output", string(output))
+	}
+
+	repoTransferredCounter.Inc()
+	return true, nil
+}
+
+// findTransferSource returns the address of a gitserver that has repo
+// cloned, or the empty string if there is none.
+func (s *Server) findTransferSource(ctx context.Context, repo api.RepoName) string {
+	if s.GitServerAddrs == nil {
+		return ""
+	}
+	addrs := s.GitServerAddrs(ctx)
+	if len(addrs) < 2 {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, transferProbeTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(&protocol.IsRepoClonedRequest{Repo: repo})
+	if err != nil {
+		return ""
+	}
+
+	// We don't know our own address, but asking ourselves is harmless since
+	// we are about to clone repo and so don't have it.
+	for _, addr := range addrs {
+		req, err := http.NewRequest("POST", "http://"+addr+"/is-repo-cloned", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return addr
+		}
+	}
+	return ""
+}
+
+// RepoTransfer is the state of a repo transfer from another gitserver.
+type RepoTransfer struct {
+	Repo     api.RepoName
+	Source   string
+	Started  time.Time
+	Finished *time.Time `json:",omitempty"`
+	Bytes    int64
+	Error    string `json:",omitempty"`
+}
+
+// TransferStatus summarizes the repo transfers of a gitserver since it
+// started.
+type TransferStatus struct {
+	Succeeded int
+	Failed    int
+
+	// Running are the transfers in progress.
+	Running []RepoTransfer
+
+	// Finished are the most recently finished transfers, most recent first.
+	Finished []RepoTransfer
+}
+
+// TransferStatus returns the status of repo transfers to s.
+func (s *Server) TransferStatus() TransferStatus {
+	return s.transfers.status()
+}
+
+// transferTracker tracks the progress of repo transfers. The zero value is
+// ready to use.
+type transferTracker struct {
+	mu                sync.Mutex
+	running           map[*RepoTransfer]struct{}
+	finished          []*RepoTransfer
+	succeeded, failed int
+}
+
+func (tt *transferTracker) start(repo api.RepoName, src string) *RepoTransfer {
+	t := &RepoTransfer{Repo: repo, Source: src, Started: time.Now()}
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	if tt.running == nil {
+		tt.running = make(map[*RepoTransfer]struct{})
+	}
+	tt.running[t] = struct{}{}
+	return t
+}
+
+func (tt *transferTracker) finish(t *RepoTransfer, err error) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	now := time.Now()
+	t.Finished = &now
+	if err != nil {
+		t.Error = err.Error()
+		tt.failed++
+	} else {
+		tt.succeeded++
+	}
+	delete(tt.running, t)
+	tt.finished = append(tt.finished, t)
+	if len(tt.finished) > maxFinishedTransfers {
+		tt.finished = tt.finished[len(tt.finished)-maxFinishedTransfers:]
+	}
+}
+
+func (tt *transferTracker) status() TransferStatus {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	st := TransferStatus{
+		Succeeded: tt.succeeded,
+		Failed:    tt.failed,
+		Running:   make([]RepoTransfer, 0, len(tt.running)),
+		Finished:  make([]RepoTransfer, 0, len(tt.finished)),
+	}
+	for t := range tt.running {
+		// Bytes is updated concurrently while the transfer is running, so
+		// we can't just copy t.
+		st.Running = append(st.Running, RepoTransfer{
+			Repo:    t.Repo,
+			Source:  t.Source,
+			Started: t.Started,
+			Bytes:   atomic.LoadInt64(&t.Bytes),
+		})
+	}
+	sort.Slice(st.Running, func(i, j int) bool {
+		return st.Running[i].Started.Before(st.Running[j].Started)
+	})
+	for i := len(tt.finished) - 1; i >= 0; i-- {
+		st.Finished = append(st.Finished, *tt.finished[i])
+	}
+	return st
+}
+
+// transferReader counts the bytes read into the transfer's Bytes.
+type transferReader struct {
+	r io.Reader
+	t *RepoTransfer
+}
+
+func (r *transferReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(&r.t.Bytes, int64(n))
+	return n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+var repoTransferredCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "repo_transferred",
+	Help:      "number of repos transferred from another gitserver instead of cloned from the code host",
+})
+
+func init() {
+	prometheus.MustRegister(repoTransferredCounter)
+}
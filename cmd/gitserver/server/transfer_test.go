@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+)
+
+func TestCloneRepo_transfer(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	cmd := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		b, err := c.Output()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s", name, strings.Join(arg, " "), err)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	cmd(remote, "git", "init", ".")
+	cmd(remote, "sh", "-c", "echo hello world > hello.txt")
+	cmd(remote, "git", "add", "hello.txt")
+	cmd(remote, "git", "commit", "-m", "hello")
+
+	newServer := func(addrs ...string) (*Server, func()) {
+		reposDir, cleanup := tmpDir(t)
+		s := &Server{
+			ReposDir:         reposDir,
+			ctx:              context.Background(),
+			locker:           &RepositoryLocker{},
+			cloneLimiter:     mutablelimiter.New(1),
+			cloneableLimiter: mutablelimiter.New(1),
+		}
+		if len(addrs) > 0 {
+			s.GitServerAddrs = func(context.Context) []string { return addrs }
+		}
+		return s, cleanup
+	}
+
+	// The previous owner has the repo cloned.
+	src, cleanup2 := newServer()
+	defer cleanup2()
+	if _, err := src.cloneRepo(context.Background(), "example.com/foo/bar", remote, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/is-repo-cloned", src.handleIsRepoCloned)
+	mux.HandleFunc("/repo-transfer", src.handleRepoTransfer)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	srcAddr := strings.TrimPrefix(ts.URL, "http://")
+
+	// The code host moved on, so the transferred repo has to catch up with
+	// it.
+	cmd(remote, "sh", "-c", "echo hello again > hello.txt")
+	cmd(remote, "git", "commit", "-am", "hello again")
+	newCommit := cmd(remote, "git", "rev-parse", "HEAD")
+
+	t.Run("transfer", func(t *testing.T) {
+		dst, cleanup := newServer("127.0.0.1:1", srcAddr)
+		defer cleanup()
+		if _, err := dst.cloneRepo(context.Background(), "example.com/foo/bar", remote, &cloneOptions{Block: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		gitDir := filepath.Join(dst.ReposDir, "example.com/foo/bar", ".git")
+		if got := cmd(gitDir, "git", "rev-parse", "HEAD"); got != newCommit {
+			t.Errorf("got HEAD %s, want fetched commit %s", got, newCommit)
+		}
+		if got := cmd(gitDir, "git", "config", "remote.origin.url"); got != remote {
+			t.Errorf("got origin %q, want %q", got, remote)
+		}
+
+		st := dst.TransferStatus()
+		if st.Succeeded != 1 || st.Failed != 0 || len(st.Running) != 0 || len(st.Finished) != 1 {
+			t.Fatalf("unexpected transfer status %+v", st)
+		}
+		if f := st.Finished[0]; f.Source != srcAddr || f.Bytes == 0 || f.Finished == nil {
+			t.Errorf("unexpected finished transfer %+v", f)
+		}
+	})
+
+	t.Run("not cloned elsewhere", func(t *testing.T) {
+		dst, cleanup := newServer("127.0.0.1:1", srcAddr)
+		defer cleanup()
+		if _, err := dst.cloneRepo(context.Background(), "example.com/foo/baz", remote, &cloneOptions{Block: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		gitDir := filepath.Join(dst.ReposDir, "example.com/foo/baz", ".git")
+		if got := cmd(gitDir, "git", "rev-parse", "HEAD"); got != newCommit {
+			t.Errorf("got HEAD %s, want cloned commit %s", got, newCommit)
+		}
+		if st := dst.TransferStatus(); st.Succeeded != 0 || st.Failed != 0 {
+			t.Errorf("unexpected transfer status %+v", st)
+		}
+	})
+}
@@ -50,3 +50,15 @@ type RewriteSpecification struct {
 
 // GitserverRepo returns the repository information necessary to perform gitserver requests.
 func (r Request) GitserverRepo() gitserver.Repo { return gitserver.Repo{Name: r.Repo, URL: r.URL} }
+
+// FileMatch is a single line of the JSON lines response to a Request. The
+// replacer responds with one FileMatch for each file that the rewrite
+// changes. Files that are unchanged are omitted.
+type FileMatch struct {
+	// URI is the path of the file in the repository.
+	URI string `json:"uri"`
+
+	// Diff is a unified diff from the original contents of the file to the
+	// rewritten contents. The file names in its header are both URI.
+	Diff string `json:"diff"`
+}
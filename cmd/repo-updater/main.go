@@ -106,6 +106,10 @@ func main() {
 			"GITHUB",
 			"GITLAB",
 			"BITBUCKETSERVER",
+			"BITBUCKETCLOUD",
+			"GITEA",
+			"MERCURIAL",
+			"SVN",
 			"OTHER",
 		)
 		migrations = append(migrations,
@@ -119,6 +123,12 @@ func main() {
 		}
 	}
 
+	// Restore the update schedule of repos before any of them are added to
+	// the scheduler, so we don't update all of them at once after a restart.
+	if err := repos.Scheduler.Restore(ctx, repos.NewDBStore(ctx, db, sql.TxOptions{})); err != nil {
+		log15.Error("failed to restore repo update schedule", "error", err)
+	}
+
 	newSyncerEnabled := make(map[string]bool, len(kinds))
 	for _, kind := range kinds {
 		newSyncerEnabled[kind] = true
@@ -129,10 +139,14 @@ func main() {
 	for _, kind := range []string{
 		"AWSCODECOMMIT",
 		"BITBUCKETSERVER",
+		"BITBUCKETCLOUD",
+		"GITEA",
 		"GITHUB",
 		"GITLAB",
 		"GITOLITE",
+		"MERCURIAL",
 		"PHABRICATOR",
+		"SVN",
 		"OTHER",
 	} {
 		if newSyncerEnabled[kind] {
@@ -146,6 +160,10 @@ func main() {
 		case "BITBUCKETSERVER":
 			go repos.SyncBitbucketServerConnections(ctx)
 			go repos.RunBitbucketServerRepositorySyncWorker(ctx)
+		case "BITBUCKETCLOUD":
+			log15.Warn("Bitbucket Cloud external service kind only supported with SRC_SYNCER_ENABLED=true")
+		case "GITEA":
+			log15.Warn("Gitea external service kind only supported with SRC_SYNCER_ENABLED=true")
 		case "GITHUB":
 			go repos.SyncGitHubConnections(ctx)
 			go repos.RunGitHubRepositorySyncWorker(ctx)
@@ -154,8 +172,12 @@ func main() {
 			go repos.RunGitLabRepositorySyncWorker(ctx)
 		case "GITOLITE":
 			go repos.RunGitoliteRepositorySyncWorker(ctx)
+		case "MERCURIAL":
+			log15.Warn("Mercurial external service kind only supported with SRC_SYNCER_ENABLED=true")
 		case "PHABRICATOR":
 			go repos.RunPhabricatorRepositorySyncWorker(ctx, store)
+		case "SVN":
+			log15.Warn("Subversion external service kind only supported with SRC_SYNCER_ENABLED=true")
 		case "OTHER":
 			log15.Warn("Other external service kind only supported with SRC_SYNCER_ENABLED=true")
 		default:
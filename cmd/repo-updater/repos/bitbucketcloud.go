@@ -0,0 +1,171 @@
+package repos
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketcloud"
+	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func newBitbucketCloudConnection(config *schema.BitbucketCloudConnection, cf *httpcli.Factory) (*bitbucketCloudConnection, error) {
+	rawBaseURL := config.Url
+	if rawBaseURL == "" {
+		rawBaseURL = "https://bitbucket.org"
+	}
+	baseURL, err := url.Parse(rawBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	baseURL = NormalizeBaseURL(baseURL)
+
+	rawAPIURL := config.ApiURL
+	if rawAPIURL == "" {
+		rawAPIURL = "https://api.bitbucket.org"
+	}
+	apiURL, err := url.Parse(rawAPIURL)
+	if err != nil {
+		return nil, err
+	}
+	apiURL = NormalizeBaseURL(apiURL)
+
+	if cf == nil {
+		cf = NewHTTPClientFactory()
+	}
+
+	cli, err := cf.Doer()
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := make(map[string]bool, len(config.Exclude))
+	var excludePatterns []*regexp.Regexp
+	for _, r := range config.Exclude {
+		if r.Name != "" {
+			exclude[strings.ToLower(r.Name)] = true
+		}
+
+		if r.Uuid != "" {
+			exclude[r.Uuid] = true
+		}
+
+		if r.Pattern != "" {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			excludePatterns = append(excludePatterns, re)
+		}
+	}
+
+	client := bitbucketcloud.NewClient(apiURL, cli)
+	client.Username = config.Username
+	client.AppPassword = config.AppPassword
+
+	return &bitbucketCloudConnection{
+		config:          config,
+		baseURL:         baseURL,
+		exclude:         exclude,
+		excludePatterns: excludePatterns,
+		client:          client,
+	}, nil
+}
+
+type bitbucketCloudConnection struct {
+	config          *schema.BitbucketCloudConnection
+	baseURL         *url.URL
+	exclude         map[string]bool
+	excludePatterns []*regexp.Regexp
+	client          *bitbucketcloud.Client
+}
+
+func (c *bitbucketCloudConnection) excludes(r *bitbucketcloud.Repo) bool {
+	// Bitbucket Cloud also hosts Mercurial repositories, which we can't clone.
+	if r.SCM != "git" ||
+		c.exclude[strings.ToLower(r.FullName)] ||
+		c.exclude[r.UUID] {
+		return true
+	}
+
+	for _, re := range c.excludePatterns {
+		if re.MatchString(r.FullName) {
+			return true
+		}
+	}
+	return false
+}
+
+// listAllRepos lists the repositories of the configured teams, or all the
+// repositories the user is a member of if no teams are configured.
+func (c *bitbucketCloudConnection) listAllRepos(ctx context.Context) ([]*bitbucketcloud.Repo, error) {
+	owners := c.config.Teams
+	if len(owners) == 0 {
+		owners = []string{""}
+	}
+
+	seen := make(map[string]bool)
+	errs := new(multierror.Error)
+	var repos []*bitbucketcloud.Repo
+
+	for _, owner := range owners {
+		var page *bitbucketcloud.PageToken
+		for page.HasMore() {
+			var err error
+			var rs []*bitbucketcloud.Repo
+
+			if rs, page, err = c.client.Repos(ctx, page, owner); err != nil {
+				errs = multierror.Append(errs, errors.Wrapf(err, "bitbucketcloud.teams: item=%q", owner))
+				break
+			}
+
+			for _, r := range rs {
+				if !seen[r.UUID] && !c.excludes(r) {
+					repos = append(repos, r)
+					seen[r.UUID] = true
+				}
+			}
+		}
+	}
+
+	return repos, errs.ErrorOrNil()
+}
+
+// authenticatedRemoteURL returns the URL gitserver uses to clone repo. HTTPS
+// clone URLs are authenticated with the configured username and app password.
+func (c *bitbucketCloudConnection) authenticatedRemoteURL(repo *bitbucketcloud.Repo) string {
+	var httpsURL, sshURL string
+	for _, l := range repo.Links.Clone {
+		switch l.Name {
+		case "https":
+			httpsURL = l.Href
+		case "ssh":
+			sshURL = l.Href
+		}
+	}
+
+	if c.config.GitURLType == "ssh" && sshURL != "" {
+		return sshURL
+	}
+	if httpsURL == "" {
+		return sshURL
+	}
+
+	u, err := url.Parse(httpsURL)
+	if err != nil {
+		return httpsURL
+	}
+	// The clone links contain the name of the authenticated user, which is
+	// replaced with the configured credentials.
+	u.User = url.UserPassword(c.config.Username, c.config.AppPassword)
+	return u.String()
+}
+
+func (c *bitbucketCloudConnection) repoName(repo *bitbucketcloud.Repo) string {
+	return string(reposource.BitbucketCloudRepoName(c.config.RepositoryPathPattern, c.baseURL.Hostname(), repo.FullName))
+}
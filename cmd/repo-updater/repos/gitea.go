@@ -0,0 +1,195 @@
+package repos
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitea"
+	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+// giteaPerPage is the number of repositories requested per page. Gitea
+// caps it at its MAX_RESPONSE_ITEMS setting, which defaults to 50.
+const giteaPerPage = 50
+
+func newGiteaConnection(config *schema.GiteaConnection, cf *httpcli.Factory) (*giteaConnection, error) {
+	baseURL, err := url.Parse(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	baseURL = NormalizeBaseURL(baseURL)
+
+	if cf == nil {
+		cf = NewHTTPClientFactory()
+	}
+
+	var opts []httpcli.Opt
+	if config.Certificate != "" {
+		pool, err := newCertPool(config.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, httpcli.NewCertPoolOpt(pool))
+	}
+
+	cli, err := cf.Doer(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := make(map[string]bool, len(config.Exclude))
+	var excludePatterns []*regexp.Regexp
+	for _, r := range config.Exclude {
+		if r.Name != "" {
+			exclude[strings.ToLower(r.Name)] = true
+		}
+
+		if r.Id != 0 {
+			exclude[strconv.Itoa(r.Id)] = true
+		}
+
+		if r.Pattern != "" {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			excludePatterns = append(excludePatterns, re)
+		}
+	}
+
+	client := gitea.NewClient(baseURL, cli)
+	client.Token = config.Token
+
+	return &giteaConnection{
+		config:          config,
+		baseURL:         baseURL,
+		exclude:         exclude,
+		excludePatterns: excludePatterns,
+		client:          client,
+	}, nil
+}
+
+type giteaConnection struct {
+	config          *schema.GiteaConnection
+	baseURL         *url.URL
+	exclude         map[string]bool
+	excludePatterns []*regexp.Regexp
+	client          *gitea.Client
+}
+
+func (c *giteaConnection) excludes(r *gitea.Repo) bool {
+	if c.exclude[strings.ToLower(r.FullName)] ||
+		c.exclude[strconv.FormatInt(r.ID, 10)] {
+		return true
+	}
+
+	for _, re := range c.excludePatterns {
+		if re.MatchString(r.FullName) {
+			return true
+		}
+	}
+	return false
+}
+
+// listAllRepos lists the repositories named in "repos" and those returned by
+// the "repositoryQuery" API endpoints, excluding those matched by "exclude".
+func (c *giteaConnection) listAllRepos(ctx context.Context) ([]*gitea.Repo, error) {
+	seen := make(map[int64]bool)
+	errs := new(multierror.Error)
+	var repos []*gitea.Repo
+
+	add := func(r *gitea.Repo) {
+		if !seen[r.ID] && !c.excludes(r) {
+			repos = append(repos, r)
+			seen[r.ID] = true
+		}
+	}
+
+	for _, nameWithOwner := range c.config.Repos {
+		owner, name := splitNameWithOwner(nameWithOwner)
+		r, err := c.client.Repo(ctx, owner, name)
+		if err != nil {
+			errs = multierror.Append(errs, errors.Wrapf(err, "gitea.repos: item=%q", nameWithOwner))
+			continue
+		}
+		add(r)
+	}
+
+	for _, query := range c.config.RepositoryQuery {
+		if query == "none" {
+			continue
+		}
+
+		urlStr := giteaRepositoryQueryToURL(query)
+		listed := make(map[int64]bool)
+		for page := 1; ; page++ {
+			rs, err := c.client.ListRepos(ctx, urlStr, page, giteaPerPage)
+			if err != nil {
+				errs = multierror.Append(errs, errors.Wrapf(err, "gitea.repositoryQuery: item=%q", query))
+				break
+			}
+
+			// Gitea doesn't report whether there are more pages, so we stop
+			// at the first empty one, or at one we've already seen in case
+			// the endpoint ignores the page parameter.
+			more := false
+			for _, r := range rs {
+				if !listed[r.ID] {
+					listed[r.ID] = true
+					more = true
+				}
+				add(r)
+			}
+			if !more {
+				break
+			}
+		}
+	}
+
+	return repos, errs.ErrorOrNil()
+}
+
+// giteaRepositoryQueryToURL returns the API URL, relative to the Gitea API root,
+// of a "repositoryQuery" item. A query without a path searches all
+// repositories.
+func giteaRepositoryQueryToURL(query string) string {
+	if strings.HasPrefix(query, "?") {
+		return "repos/search" + query
+	}
+	return strings.TrimPrefix(query, "/")
+}
+
+func splitNameWithOwner(nameWithOwner string) (owner, name string) {
+	if i := strings.Index(nameWithOwner, "/"); i >= 0 {
+		return nameWithOwner[:i], nameWithOwner[i+1:]
+	}
+	return "", nameWithOwner
+}
+
+// authenticatedRemoteURL returns the URL gitserver uses to clone repo. HTTP(S)
+// clone URLs are authenticated with the configured token.
+func (c *giteaConnection) authenticatedRemoteURL(repo *gitea.Repo) string {
+	if c.config.GitURLType == "ssh" && repo.SSHURL != "" {
+		return repo.SSHURL
+	}
+
+	u, err := url.Parse(repo.CloneURL)
+	if err != nil {
+		return repo.CloneURL
+	}
+	// Gitea accepts an access token as the username if the password is
+	// "x-oauth-basic".
+	u.User = url.UserPassword(c.config.Token, "x-oauth-basic")
+	return u.String()
+}
+
+func (c *giteaConnection) repoName(repo *gitea.Repo) string {
+	return string(reposource.GiteaRepoName(c.config.RepositoryPathPattern, c.baseURL.Hostname(), repo.FullName))
+}
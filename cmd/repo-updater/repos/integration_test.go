@@ -47,6 +47,7 @@ func TestIntegration(t *testing.T) {
 		test func(*testing.T)
 	}{
 		{"DBStore/Transact", testDBStoreTransact(dbstore)},
+		{"DBStore/RepoSchedules", testDBStoreRepoSchedules(dbstore)},
 		{"DBStore/ListExternalServices", testStoreListExternalServices(store)},
 		{"DBStore/UpsertExternalServices", testStoreUpsertExternalServices(store)},
 		{"DBStore/UpsertRepos", testStoreUpsertRepos(store)},
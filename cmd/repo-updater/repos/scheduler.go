@@ -95,6 +95,10 @@ const (
 //
 // When it is time for a repo to update, the scheduler inserts the repo into a queue.
 //
+// Once a ScheduleStore is set with Restore, the schedule of each updated repo is
+// persisted to it, so that the scheduler can pick up where it left off after a restart
+// instead of updating all repos at once.
+//
 // A worker continuously dequeues repos and sends updates to gitserver, but its concurrency
 // is limited by the gitMaxConcurrentClones site configuration.
 type updateScheduler struct {
@@ -106,6 +110,9 @@ type updateScheduler struct {
 
 	updateQueue *updateQueue
 	schedule    *schedule
+
+	// store persists the schedule of repos, if set.
+	store ScheduleStore
 }
 
 // A configuredRepo2 represents the configuration data for a given repo from
@@ -116,6 +123,18 @@ type configuredRepo2 struct {
 	ID      uint32
 	Name    api.RepoName
 	Enabled bool
+
+	// PartialCloneFilter is the object filter to clone the repo partially
+	// with, if any.
+	PartialCloneFilter string
+
+	// MaxRepoSize is the maximum size in bytes of the repo on gitserver, or
+	// zero if there is no limit.
+	MaxRepoSize int64
+
+	// LFSMaxFileSize is the maximum size in bytes of the Git LFS objects
+	// gitserver fetches for the repo, or zero if they are not fetched.
+	LFSMaxFileSize int64
 }
 
 // sourceRepoMap is the set of repositories associated with a specific configuration source.
@@ -135,8 +154,9 @@ func newUpdateScheduler() *updateScheduler {
 			notifyEnqueue: make(chan struct{}, notifyChanBuffer),
 		},
 		schedule: &schedule{
-			index:  make(map[uint32]*scheduledRepoUpdate),
-			wakeup: make(chan struct{}, notifyChanBuffer),
+			index:    make(map[uint32]*scheduledRepoUpdate),
+			restored: make(map[uint32]*RepoSchedule),
+			wakeup:   make(chan struct{}, notifyChanBuffer),
 		},
 	}
 }
@@ -214,14 +234,81 @@ func (s *updateScheduler) runUpdateLoop(ctx context.Context) {
 					interval := resp.LastFetched.Sub(*resp.LastChanged) / 2
 					s.schedule.updateInterval(repo, interval)
 				}
+				// The due time of the repo was advanced when it was enqueued,
+				// so persist the schedule even if the update failed.
+				s.persist(ctx, repo, resp)
 			}(ctx, repo, cancel)
 		}
 	}
 }
 
+// persist saves the schedule of repo, which was just updated, to the store.
+// It does nothing if no store is set or if the repo is not in the schedule.
+// resp is the response to the update request, if any; the fetch times it
+// doesn't include keep their persisted values.
+func (s *updateScheduler) persist(ctx context.Context, repo *configuredRepo2, resp *gitserverprotocol.RepoUpdateResponse) {
+	s.mu.Lock()
+	store := s.store
+	s.mu.Unlock()
+
+	if store == nil {
+		return
+	}
+
+	s.schedule.mu.Lock()
+	update := s.schedule.index[repo.ID]
+	if update == nil {
+		s.schedule.mu.Unlock()
+		return
+	}
+	rs := &RepoSchedule{
+		RepoID:   repo.ID,
+		Interval: update.Interval,
+		Due:      update.Due,
+	}
+	s.schedule.mu.Unlock()
+
+	if resp != nil && resp.LastFetched != nil {
+		rs.LastFetched = *resp.LastFetched
+	}
+	if resp != nil && resp.LastChanged != nil {
+		rs.LastChanged = *resp.LastChanged
+	}
+
+	if err := store.UpsertRepoSchedules(ctx, rs); err != nil {
+		schedError.Inc()
+		log15.Warn("error persisting repo update schedule", "uri", repo.Name, "err", err)
+	}
+}
+
+// Restore sets the store that the schedule of updated repos is persisted to
+// and restores the schedule persisted in it before. Repos added to the schedule
+// from now on keep the interval and due time they had before the restart.
+func (s *updateScheduler) Restore(ctx context.Context, store ScheduleStore) error {
+	s.mu.Lock()
+	s.store = store
+	s.mu.Unlock()
+
+	schedules, err := store.ListRepoSchedules(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.schedule.restore(schedules...)
+	log15.Info("restored repo update schedule", "repos", len(schedules))
+
+	return nil
+}
+
 // requestRepoUpdate sends a request to gitserver to request an update.
 var requestRepoUpdate = func(ctx context.Context, repo *configuredRepo2, since time.Duration) (*gitserverprotocol.RepoUpdateResponse, error) {
-	return gitserver.DefaultClient.RequestRepoUpdate(ctx, gitserver.Repo{Name: repo.Name, URL: repo.URL}, since)
+	return gitserver.DefaultClient.RequestRepoUpdate(ctx, gitserver.Repo{
+		Name:               repo.Name,
+		URL:                repo.URL,
+		PartialCloneFilter: repo.PartialCloneFilter,
+		MaxRepoSize:        repo.MaxRepoSize,
+		LFSMaxFileSize:     repo.LFSMaxFileSize,
+	}, since)
 }
 
 // configuredLimiter returns a mutable limiter that is
@@ -260,8 +347,14 @@ func (s *updateScheduler) Update(rs ...*Repo) {
 func (s *updateScheduler) upsert(r *Repo) {
 	repo := configuredRepo2FromRepo(r)
 
-	updated := s.schedule.upsert(repo)
-	log15.Debug("scheduler.schedule.upserted", "repo", r.Name, "updated", updated)
+	updated, restored := s.schedule.upsert(repo)
+	log15.Debug("scheduler.schedule.upserted", "repo", r.Name, "updated", updated, "restored", restored)
+
+	// Only repos that are new to the scheduler are updated right away. All
+	// others are updated when they are due.
+	if updated || restored {
+		return
+	}
 
 	updated = s.updateQueue.enqueue(repo, priorityLow)
 	log15.Debug("scheduler.updateQueue.enqueued", "repo", r.Name, "updated", updated)
@@ -281,9 +374,12 @@ func (s *updateScheduler) remove(r *Repo) {
 
 func configuredRepo2FromRepo(r *Repo) *configuredRepo2 {
 	repo := configuredRepo2{
-		ID:      r.ID,
-		Name:    api.RepoName(r.Name),
-		Enabled: r.Enabled,
+		ID:                 r.ID,
+		Name:               api.RepoName(r.Name),
+		Enabled:            r.Enabled,
+		PartialCloneFilter: r.PartialCloneFilter(),
+		MaxRepoSize:        r.MaxRepoSize(),
+		LFSMaxFileSize:     r.LFSMaxFileSize(),
 	}
 
 	if urls := r.CloneURLs(); len(urls) > 0 {
@@ -607,6 +703,10 @@ type schedule struct {
 	heap  []*scheduledRepoUpdate // min heap of scheduledRepoUpdates based on their due time.
 	index map[uint32]*scheduledRepoUpdate
 
+	// restored holds the persisted schedules of repos that have not been
+	// upserted since they were restored.
+	restored map[uint32]*RepoSchedule
+
 	// timer sends a value on the wakeup channel when it is time
 	timer  *time.Timer
 	wakeup chan struct{}
@@ -621,7 +721,8 @@ type scheduledRepoUpdate struct {
 }
 
 // upsert inserts or updates a repo in the schedule.
-func (s *schedule) upsert(repo *configuredRepo2) (updated bool) {
+// A repo that is inserted with a restored schedule keeps its interval and due time.
+func (s *schedule) upsert(repo *configuredRepo2) (updated, restored bool) {
 	if repo.ID == 0 {
 		panic("repo.id is zero")
 	}
@@ -631,18 +732,52 @@ func (s *schedule) upsert(repo *configuredRepo2) (updated bool) {
 
 	if update := s.index[repo.ID]; update != nil {
 		update.Repo = repo
-		return true
+		return true, false
 	}
 
-	heap.Push(s, &scheduledRepoUpdate{
+	update := &scheduledRepoUpdate{
 		Repo:     repo,
 		Interval: minDelay,
 		Due:      timeNow().Add(minDelay),
-	})
+	}
+
+	if rs := s.restored[repo.ID]; rs != nil {
+		update.Interval = rs.Interval
+		update.Due = rs.Due
+		delete(s.restored, repo.ID)
+		restored = true
+	}
+
+	heap.Push(s, update)
 
 	s.rescheduleTimer()
 
-	return false
+	return false, restored
+}
+
+// restore restores the persisted schedules of repos. Repos that are already
+// in the schedule are rescheduled, all others when they are upserted.
+func (s *schedule) restore(schedules ...*RepoSchedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rs := range schedules {
+		if rs.RepoID == 0 {
+			continue
+		}
+
+		update := s.index[rs.RepoID]
+		if update == nil {
+			s.restored[rs.RepoID] = rs
+			continue
+		}
+
+		update.Interval = rs.Interval
+		update.Due = rs.Due
+		heap.Fix(s, update.Index)
+	}
+
+	s.rescheduleTimer()
 }
 
 // updateInterval updates the update interval of a repo in the schedule.
@@ -4,11 +4,13 @@ import (
 	"container/heap"
 	"context"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kylelemons/godebug/pretty"
+	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	gitserverprotocol "github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
@@ -699,6 +701,97 @@ func TestSchedule_upsert(t *testing.T) {
 	}
 }
 
+func TestSchedule_restore(t *testing.T) {
+	a := &configuredRepo2{ID: 1, Name: "a", URL: "a.com"}
+	b := &configuredRepo2{ID: 2, Name: "b", URL: "b.com"}
+
+	tests := []struct {
+		name                string
+		initialSchedule     []*scheduledRepoUpdate
+		restored            []*RepoSchedule
+		upserts             []*configuredRepo2
+		finalSchedule       []*scheduledRepoUpdate
+		timeAfterFuncDelays []time.Duration
+		wakeupNotifications int
+	}{
+		{
+			name: "restored repo is upserted",
+			restored: []*RepoSchedule{
+				{RepoID: 2, Interval: time.Hour, Due: defaultTime.Add(30 * time.Minute)},
+			},
+			upserts: []*configuredRepo2{a, b},
+			finalSchedule: []*scheduledRepoUpdate{
+				{
+					Interval: minDelay,
+					Due:      defaultTime.Add(minDelay),
+					Repo:     a,
+				},
+				{
+					Interval: time.Hour,
+					Due:      defaultTime.Add(30 * time.Minute),
+					Repo:     b,
+				},
+			},
+			timeAfterFuncDelays: []time.Duration{minDelay, minDelay},
+			wakeupNotifications: 2,
+		},
+		{
+			name: "scheduled repo is restored",
+			initialSchedule: []*scheduledRepoUpdate{
+				{
+					Interval: minDelay,
+					Due:      defaultTime.Add(minDelay),
+					Repo:     a,
+				},
+				{
+					Interval: minDelay,
+					Due:      defaultTime.Add(time.Minute),
+					Repo:     b,
+				},
+			},
+			restored: []*RepoSchedule{
+				{RepoID: 1, Interval: time.Hour, Due: defaultTime.Add(2 * time.Hour)},
+			},
+			finalSchedule: []*scheduledRepoUpdate{
+				{
+					Interval: minDelay,
+					Due:      defaultTime.Add(time.Minute),
+					Repo:     b,
+				},
+				{
+					Interval: time.Hour,
+					Due:      defaultTime.Add(2 * time.Hour),
+					Repo:     a,
+				},
+			},
+			timeAfterFuncDelays: []time.Duration{time.Minute},
+			wakeupNotifications: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, stop := startRecording()
+			defer stop()
+
+			s := newUpdateScheduler()
+			setupInitialSchedule(s, test.initialSchedule)
+
+			s.schedule.restore(test.restored...)
+			for _, repo := range test.upserts {
+				s.schedule.upsert(repo)
+			}
+
+			if len(s.schedule.restored) != 0 {
+				t.Errorf("expected all restored schedules to be used, got %s", spew.Sdump(s.schedule.restored))
+			}
+
+			verifySchedule(t, s, test.finalSchedule)
+			verifyScheduleRecording(t, s, test.timeAfterFuncDelays, test.wakeupNotifications, r)
+		})
+	}
+}
+
 func TestSchedule_updateInterval(t *testing.T) {
 	a := &configuredRepo2{ID: 1, Name: "a", URL: "a.com"}
 	b := &configuredRepo2{ID: 2, Name: "b", URL: "b.com"}
@@ -1155,6 +1248,7 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 		mockRequestRepoUpdates []*mockRequestRepoUpdate
 		finalSchedule          []*scheduledRepoUpdate
 		finalQueue             []*repoUpdate
+		finalPersisted         []*RepoSchedule
 		timeAfterFuncDelays    []time.Duration
 		expectedNotifications  func(s *updateScheduler) []chan struct{}
 	}{
@@ -1213,11 +1307,43 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 			finalSchedule: []*scheduledRepoUpdate{
 				{Repo: a, Interval: time.Minute, Due: defaultTime.Add(time.Minute)},
 			},
+			finalPersisted: []*RepoSchedule{
+				{
+					RepoID:      1,
+					Interval:    time.Minute,
+					Due:         defaultTime.Add(time.Minute),
+					LastFetched: defaultTime.Add(2 * time.Minute),
+					LastChanged: defaultTime,
+				},
+			},
 			timeAfterFuncDelays: []time.Duration{time.Minute},
 			expectedNotifications: func(s *updateScheduler) []chan struct{} {
 				return []chan struct{}{s.schedule.wakeup}
 			},
 		},
+		{
+			name:                   "schedule persisted on error",
+			gitMaxConcurrentClones: 1,
+			initialSchedule: []*scheduledRepoUpdate{
+				{Repo: a, Interval: time.Hour, Due: defaultTime.Add(time.Hour)},
+			},
+			initialQueue: []*repoUpdate{
+				{Repo: a, Seq: 1},
+			},
+			mockRequestRepoUpdates: []*mockRequestRepoUpdate{
+				{repo: a, err: errors.New("gitserver unavailable")},
+			},
+			finalSchedule: []*scheduledRepoUpdate{
+				{Repo: a, Interval: time.Hour, Due: defaultTime.Add(time.Hour)},
+			},
+			finalPersisted: []*RepoSchedule{
+				{
+					RepoID:   1,
+					Interval: time.Hour,
+					Due:      defaultTime.Add(time.Hour),
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -1256,6 +1382,9 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 
 			s := newUpdateScheduler()
 
+			store := &fakeScheduleStore{}
+			s.store = store
+
 			// unbuffer the channel
 			s.updateQueue.notifyEnqueue = make(chan struct{})
 
@@ -1286,6 +1415,10 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 			verifyQueue(t, s, test.finalQueue)
 			verifyRecording(t, s, test.timeAfterFuncDelays, test.expectedNotifications, r)
 
+			if !reflect.DeepEqual(test.finalPersisted, store.schedules) {
+				t.Fatalf("\nexpected persisted schedules\n%s\ngot\n%s", spew.Sdump(test.finalPersisted), spew.Sdump(store.schedules))
+			}
+
 			// Cancel the context.
 			cancel()
 
@@ -1315,6 +1448,55 @@ func timePtr(t time.Time) *time.Time {
 	return &t
 }
 
+type fakeScheduleStore struct {
+	mu        sync.Mutex
+	schedules []*RepoSchedule
+}
+
+func (s *fakeScheduleStore) ListRepoSchedules(context.Context) ([]*RepoSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.schedules, nil
+}
+
+func (s *fakeScheduleStore) UpsertRepoSchedules(ctx context.Context, schedules ...*RepoSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedules = append(s.schedules, schedules...)
+	return nil
+}
+
+func TestUpdateScheduler_Update(t *testing.T) {
+	_, stop := startRecording()
+	defer stop()
+
+	s := newUpdateScheduler()
+	err := s.Restore(context.Background(), &fakeScheduleStore{
+		schedules: []*RepoSchedule{
+			{RepoID: 2, Interval: time.Hour, Due: defaultTime.Add(time.Hour)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Repo{ID: 1, Name: "a", Enabled: true}
+	b := &Repo{ID: 2, Name: "b", Enabled: true}
+
+	// Only a is new to the scheduler, b was scheduled before the restart.
+	s.Update(a, b)
+	// Repos that are already scheduled aren't enqueued again.
+	s.Update(a, b)
+
+	verifyQueue(t, s, []*repoUpdate{
+		{Repo: configuredRepo2FromRepo(a), Priority: priorityLow, Seq: 1},
+	})
+	verifySchedule(t, s, []*scheduledRepoUpdate{
+		{Repo: configuredRepo2FromRepo(a), Interval: minDelay, Due: defaultTime.Add(minDelay)},
+		{Repo: configuredRepo2FromRepo(b), Interval: time.Hour, Due: defaultTime.Add(time.Hour)},
+	})
+}
+
 func TestUpdateScheduler_updateSource(t *testing.T) {
 	type updateSourceCall struct {
 		source  string
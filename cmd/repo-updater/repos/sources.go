@@ -15,11 +15,15 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketcloud"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitea"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitolite"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/mercurial"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/phabricator"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/svn"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
 	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
@@ -82,10 +86,18 @@ func NewSource(svc *ExternalService, cf *httpcli.Factory) (Source, error) {
 		return NewGitLabSource(svc, cf)
 	case "bitbucketserver":
 		return NewBitbucketServerSource(svc, cf)
+	case "bitbucketcloud":
+		return NewBitbucketCloudSource(svc, cf)
+	case "gitea":
+		return NewGiteaSource(svc, cf)
 	case "gitolite":
 		return NewGitoliteSource(svc, cf)
+	case "mercurial":
+		return NewMercurialSource(svc)
 	case "phabricator":
 		return NewPhabricatorSource(svc, cf)
+	case "svn":
+		return NewSVNSource(svc)
 	case "other":
 		return NewOtherSource(svc)
 	default:
@@ -212,6 +224,23 @@ func group(srcs []Source) map[string]Sources {
 	return groups
 }
 
+// defaultLFSMaxFileSizeMB is the default of the lfsMaxFileSizeMB option of
+// external services.
+const defaultLFSMaxFileSizeMB = 100
+
+// lfsMaxFileSize returns the maximum size in bytes of the Git LFS objects
+// gitserver fetches for repos of an external service with the given fetchLFS
+// and lfsMaxFileSizeMB options, or zero if it fetches none.
+func lfsMaxFileSize(fetchLFS bool, maxFileSizeMB int) int64 {
+	if !fetchLFS {
+		return 0
+	}
+	if maxFileSizeMB <= 0 {
+		maxFileSizeMB = defaultLFSMaxFileSizeMB
+	}
+	return int64(maxFileSizeMB) << 20
+}
+
 // A GithubSource yields repositories from a single Github connection configured
 // in Sourcegraph via the external services configuration.
 type GithubSource struct {
@@ -278,8 +307,11 @@ func githubRepoToRepo(
 		Archived:     ghrepo.IsArchived,
 		Sources: map[string]*SourceInfo{
 			urn: {
-				ID:       urn,
-				CloneURL: conn.authenticatedRemoteURL(ghrepo),
+				ID:                 urn,
+				CloneURL:           conn.authenticatedRemoteURL(ghrepo),
+				PartialCloneFilter: conn.config.PartialCloneFilter,
+				MaxRepoSize:        int64(conn.config.MaxRepoSizeMB) << 20,
+				LFSMaxFileSize:     lfsMaxFileSize(conn.config.FetchLFS, conn.config.LFSMaxFileSizeMB),
 			},
 		},
 		Metadata: ghrepo,
@@ -340,8 +372,11 @@ func gitlabProjectToRepo(
 		Archived:     proj.Archived,
 		Sources: map[string]*SourceInfo{
 			urn: {
-				ID:       urn,
-				CloneURL: conn.authenticatedRemoteURL(proj),
+				ID:                 urn,
+				CloneURL:           conn.authenticatedRemoteURL(proj),
+				PartialCloneFilter: conn.config.PartialCloneFilter,
+				MaxRepoSize:        int64(conn.config.MaxRepoSizeMB) << 20,
+				LFSMaxFileSize:     lfsMaxFileSize(conn.config.FetchLFS, conn.config.LFSMaxFileSizeMB),
 			},
 		},
 		Metadata: proj,
@@ -401,10 +436,136 @@ func bitbucketserverRepoToRepo(
 		Fork:         info.Fork,
 		Enabled:      true,
 		Archived:     info.Archived,
+		Sources: map[string]*SourceInfo{
+			urn: {
+				ID:                 urn,
+				CloneURL:           info.VCS.URL,
+				PartialCloneFilter: conn.config.PartialCloneFilter,
+				MaxRepoSize:        int64(conn.config.MaxRepoSizeMB) << 20,
+				LFSMaxFileSize:     lfsMaxFileSize(conn.config.FetchLFS, conn.config.LFSMaxFileSizeMB),
+			},
+		},
+		Metadata: repo,
+	}
+}
+
+// A BitbucketCloudSource yields repositories from a single BitbucketCloud connection configured
+// in Sourcegraph via the external services configuration.
+type BitbucketCloudSource struct {
+	svc  *ExternalService
+	conn *bitbucketCloudConnection
+}
+
+// NewBitbucketCloudSource returns a new BitbucketCloudSource from the given external service.
+func NewBitbucketCloudSource(svc *ExternalService, cf *httpcli.Factory) (*BitbucketCloudSource, error) {
+	var c schema.BitbucketCloudConnection
+	if err := jsonc.Unmarshal(svc.Config, &c); err != nil {
+		return nil, fmt.Errorf("external service id=%d config error: %s", svc.ID, err)
+	}
+	return newBitbucketCloudSource(svc, &c, cf)
+}
+
+func newBitbucketCloudSource(svc *ExternalService, c *schema.BitbucketCloudConnection, cf *httpcli.Factory) (*BitbucketCloudSource, error) {
+	conn, err := newBitbucketCloudConnection(c, cf)
+	if err != nil {
+		return nil, err
+	}
+	return &BitbucketCloudSource{svc: svc, conn: conn}, nil
+}
+
+// ListRepos returns all BitbucketCloud repositories accessible to all connections configured
+// in Sourcegraph via the external services configuration.
+func (s BitbucketCloudSource) ListRepos(ctx context.Context) (repos []*Repo, err error) {
+	rs, err := s.conn.listAllRepos(ctx)
+	for _, r := range rs {
+		repos = append(repos, bitbucketcloudRepoToRepo(s.svc, r, s.conn))
+	}
+	return repos, err
+}
+
+// ExternalServices returns a singleton slice containing the external service.
+func (s BitbucketCloudSource) ExternalServices() ExternalServices {
+	return ExternalServices{s.svc}
+}
+
+func bitbucketcloudRepoToRepo(
+	svc *ExternalService,
+	repo *bitbucketcloud.Repo,
+	conn *bitbucketCloudConnection,
+) *Repo {
+	urn := svc.URN()
+	return &Repo{
+		Name:         conn.repoName(repo),
+		ExternalRepo: *bitbucketcloud.ExternalRepoSpec(repo, *conn.baseURL),
+		Description:  repo.Description,
+		Fork:         repo.Parent != nil,
+		Enabled:      true,
 		Sources: map[string]*SourceInfo{
 			urn: {
 				ID:       urn,
-				CloneURL: info.VCS.URL,
+				CloneURL: conn.authenticatedRemoteURL(repo),
+			},
+		},
+		Metadata: repo,
+	}
+}
+
+// A GiteaSource yields repositories from a single Gitea connection configured
+// in Sourcegraph via the external services configuration.
+type GiteaSource struct {
+	svc  *ExternalService
+	conn *giteaConnection
+}
+
+// NewGiteaSource returns a new GiteaSource from the given external service.
+func NewGiteaSource(svc *ExternalService, cf *httpcli.Factory) (*GiteaSource, error) {
+	var c schema.GiteaConnection
+	if err := jsonc.Unmarshal(svc.Config, &c); err != nil {
+		return nil, fmt.Errorf("external service id=%d config error: %s", svc.ID, err)
+	}
+	return newGiteaSource(svc, &c, cf)
+}
+
+func newGiteaSource(svc *ExternalService, c *schema.GiteaConnection, cf *httpcli.Factory) (*GiteaSource, error) {
+	conn, err := newGiteaConnection(c, cf)
+	if err != nil {
+		return nil, err
+	}
+	return &GiteaSource{svc: svc, conn: conn}, nil
+}
+
+// ListRepos returns all Gitea repositories accessible to all connections configured
+// in Sourcegraph via the external services configuration.
+func (s GiteaSource) ListRepos(ctx context.Context) (repos []*Repo, err error) {
+	rs, err := s.conn.listAllRepos(ctx)
+	for _, r := range rs {
+		repos = append(repos, giteaRepoToRepo(s.svc, r, s.conn))
+	}
+	return repos, err
+}
+
+// ExternalServices returns a singleton slice containing the external service.
+func (s GiteaSource) ExternalServices() ExternalServices {
+	return ExternalServices{s.svc}
+}
+
+func giteaRepoToRepo(
+	svc *ExternalService,
+	repo *gitea.Repo,
+	conn *giteaConnection,
+) *Repo {
+	urn := svc.URN()
+	return &Repo{
+		Name:         conn.repoName(repo),
+		ExternalRepo: *gitea.ExternalRepoSpec(repo, *conn.baseURL),
+		Description:  repo.Description,
+		Fork:         repo.Fork,
+		Enabled:      true,
+		Archived:     repo.Archived,
+		Sources: map[string]*SourceInfo{
+			urn: {
+				ID:       urn,
+				CloneURL: conn.authenticatedRemoteURL(repo),
 			},
 		},
 		Metadata: repo,
@@ -670,6 +831,151 @@ func (s *PhabricatorSource) client(ctx context.Context) (*phabricator.Client, er
 	return s.cli, err
 }
 
+// A MercurialSource yields the Mercurial repositories of a single Mercurial
+// connection configured in Sourcegraph via the external services
+// configuration. gitserver converts them to Git repositories.
+type MercurialSource struct {
+	svc  *ExternalService
+	conn *schema.MercurialConnection
+}
+
+// NewMercurialSource returns a new MercurialSource from the given external service.
+func NewMercurialSource(svc *ExternalService) (*MercurialSource, error) {
+	var c schema.MercurialConnection
+	if err := jsonc.Unmarshal(svc.Config, &c); err != nil {
+		return nil, errors.Wrapf(err, "external service id=%d config error", svc.ID)
+	}
+	return &MercurialSource{svc: svc, conn: &c}, nil
+}
+
+// ListRepos returns the Mercurial repositories listed in the connection's
+// configuration. Mercurial servers have no API to discover repositories.
+func (s MercurialSource) ListRepos(ctx context.Context) ([]*Repo, error) {
+	base, err := url.Parse(s.conn.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	urn := s.svc.URN()
+	repos := make([]*Repo, 0, len(s.conn.Repos))
+	for _, path := range s.conn.Repos {
+		if path = strings.Trim(path, "/"); path != "" {
+			repos = append(repos, mercurialRepoToRepo(urn, s.conn, *base, path))
+		}
+	}
+
+	return repos, nil
+}
+
+// ExternalServices returns a singleton slice containing the external service.
+func (s MercurialSource) ExternalServices() ExternalServices {
+	return ExternalServices{s.svc}
+}
+
+func mercurialRepoToRepo(urn string, conn *schema.MercurialConnection, base url.URL, path string) *Repo {
+	u := base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + path
+	u.RawPath = ""
+
+	return &Repo{
+		Name:         pathPatternRepoName(conn.RepositoryPathPattern, base, path),
+		ExternalRepo: *mercurial.ExternalRepoSpec(path, base),
+		Enabled:      true,
+		Sources: map[string]*SourceInfo{
+			urn: {
+				ID:       urn,
+				CloneURL: mercurial.RemoteURL(u.String()),
+			},
+		},
+	}
+}
+
+// pathPatternRepoName returns the repository name for the repository at path
+// relative to base, generated by a repositoryPathPattern with the {host} and
+// {path} variables.
+func pathPatternRepoName(pattern string, base url.URL, path string) string {
+	host := base.Hostname()
+	if host == "" {
+		// file:// URLs have no host.
+		host = "localhost"
+	}
+
+	if pattern == "" {
+		pattern = "{host}/{path}"
+	}
+	return strings.NewReplacer("{host}", host, "{path}", path).Replace(pattern)
+}
+
+// A SVNSource yields the Subversion repositories of a single SVN connection
+// configured in Sourcegraph via the external services configuration. gitserver
+// mirrors them with git-svn.
+type SVNSource struct {
+	svc  *ExternalService
+	conn *schema.SVNConnection
+}
+
+// NewSVNSource returns a new SVNSource from the given external service.
+func NewSVNSource(svc *ExternalService) (*SVNSource, error) {
+	var c schema.SVNConnection
+	if err := jsonc.Unmarshal(svc.Config, &c); err != nil {
+		return nil, errors.Wrapf(err, "external service id=%d config error", svc.ID)
+	}
+	return &SVNSource{svc: svc, conn: &c}, nil
+}
+
+// ListRepos returns the Subversion repositories listed in the connection's
+// configuration. Subversion has no API to discover repositories.
+func (s SVNSource) ListRepos(ctx context.Context) ([]*Repo, error) {
+	base, err := url.Parse(s.conn.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	urn := s.svc.URN()
+	repos := make([]*Repo, 0, len(s.conn.Repos))
+	for _, path := range s.conn.Repos {
+		if path = strings.Trim(path, "/"); path != "" {
+			repos = append(repos, svnRepoToRepo(urn, s.conn, *base, path))
+		}
+	}
+
+	return repos, nil
+}
+
+// ExternalServices returns a singleton slice containing the external service.
+func (s SVNSource) ExternalServices() ExternalServices {
+	return ExternalServices{s.svc}
+}
+
+func svnRepoToRepo(urn string, conn *schema.SVNConnection, base url.URL, path string) *Repo {
+	u := base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + path
+	u.RawPath = ""
+	switch {
+	case conn.Password != "":
+		u.User = url.UserPassword(conn.Username, conn.Password)
+	case conn.Username != "":
+		u.User = url.User(conn.Username)
+	}
+	remote := svn.Remote{
+		URL:       &u,
+		StdLayout: conn.Layout != "none",
+		Authors:   conn.Authors,
+	}
+
+	return &Repo{
+		Name:         pathPatternRepoName(conn.RepositoryPathPattern, base, path),
+		ExternalRepo: *svn.ExternalRepoSpec(path, base),
+		Enabled:      true,
+		Sources: map[string]*SourceInfo{
+			urn: {
+				ID:       urn,
+				CloneURL: remote.String(),
+			},
+		},
+	}
+}
+
 // A OtherSource yields repositories from a single Other connection configured
 // in Sourcegraph via the external services configuration.
 type OtherSource struct {
@@ -10,7 +10,9 @@ import (
 
 	"github.com/keegancsmith/sqlf"
 	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketcloud"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitea"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
 )
@@ -24,6 +26,13 @@ type Store interface {
 	UpsertRepos(ctx context.Context, repos ...*Repo) error
 }
 
+// A ScheduleStore exposes methods to read and write the persisted update
+// schedules of repos.
+type ScheduleStore interface {
+	ListRepoSchedules(context.Context) ([]*RepoSchedule, error)
+	UpsertRepoSchedules(ctx context.Context, schedules ...*RepoSchedule) error
+}
+
 // StoreListReposArgs is a query arguments type used by
 // the ListRepos method of Store implementations.
 //
@@ -651,6 +660,100 @@ LEFT JOIN batch ON batch.name = inserted.name
 ORDER BY batch.ordinality
 `
 
+// ListRepoSchedules lists the persisted update schedules of all repos.
+func (s DBStore) ListRepoSchedules(ctx context.Context) (schedules []*RepoSchedule, _ error) {
+	return schedules, s.paginate(ctx, 0, 0, listRepoSchedulesQuery,
+		func(sc scanner) (last, count int64, err error) {
+			var rs RepoSchedule
+			if err = scanRepoSchedule(&rs, sc); err != nil {
+				return 0, 0, err
+			}
+			schedules = append(schedules, &rs)
+			return int64(rs.RepoID), 1, nil
+		},
+	)
+}
+
+const listRepoSchedulesQueryFmtstr = `
+-- source: cmd/repo-updater/repos/store.go:DBStore.ListRepoSchedules
+SELECT
+  repo_id,
+  interval_seconds,
+  due_at,
+  last_fetched,
+  last_changed
+FROM repo_update_schedule
+WHERE repo_id > %s
+ORDER BY repo_id ASC LIMIT %s
+`
+
+func listRepoSchedulesQuery(cursor, limit int64) *sqlf.Query {
+	return sqlf.Sprintf(listRepoSchedulesQueryFmtstr, cursor, limit)
+}
+
+// UpsertRepoSchedules updates or inserts the given repo update schedules.
+// Schedules of repos that don't exist are ignored. Zero LastFetched and
+// LastChanged times don't overwrite the persisted ones.
+func (s DBStore) UpsertRepoSchedules(ctx context.Context, schedules ...*RepoSchedule) error {
+	if len(schedules) == 0 {
+		return nil
+	}
+
+	q := upsertRepoSchedulesQuery(schedules)
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+func upsertRepoSchedulesQuery(schedules []*RepoSchedule) *sqlf.Query {
+	vals := make([]*sqlf.Query, 0, len(schedules))
+	for _, rs := range schedules {
+		vals = append(vals, sqlf.Sprintf(
+			upsertRepoSchedulesQueryValueFmtstr,
+			rs.RepoID,
+			int64(rs.Interval/time.Second),
+			rs.Due.UTC(),
+			nullTimeColumn(rs.LastFetched.UTC()),
+			nullTimeColumn(rs.LastChanged.UTC()),
+		))
+	}
+
+	return sqlf.Sprintf(
+		upsertRepoSchedulesQueryFmtstr,
+		sqlf.Join(vals, ",\n"),
+	)
+}
+
+const upsertRepoSchedulesQueryValueFmtstr = `
+  (%s::integer, %s::integer, %s::timestamptz, %s::timestamptz, %s::timestamptz)
+`
+
+// The repo a schedule belongs to may have been deleted in the meantime, in
+// which case the foreign key constraint would fail the whole batch. So we only
+// insert the schedules of repos that still exist.
+const upsertRepoSchedulesQueryFmtstr = `
+-- source: cmd/repo-updater/repos/store.go:DBStore.UpsertRepoSchedules
+INSERT INTO repo_update_schedule (
+  repo_id,
+  interval_seconds,
+  due_at,
+  last_fetched,
+  last_changed
+)
+SELECT * FROM (VALUES %s)
+AS batch (repo_id, interval_seconds, due_at, last_fetched, last_changed)
+WHERE EXISTS (SELECT 1 FROM repo WHERE repo.id = batch.repo_id)
+ON CONFLICT(repo_id) DO UPDATE
+SET
+  interval_seconds = excluded.interval_seconds,
+  due_at           = excluded.due_at,
+  last_fetched     = COALESCE(excluded.last_fetched, repo_update_schedule.last_fetched),
+  last_changed     = COALESCE(excluded.last_changed, repo_update_schedule.last_changed),
+  updated_at       = now()
+`
+
 func nullTimeColumn(t time.Time) *time.Time {
 	if t.IsZero() {
 		return nil
@@ -723,6 +826,19 @@ func scanExternalService(svc *ExternalService, s scanner) error {
 	)
 }
 
+func scanRepoSchedule(rs *RepoSchedule, s scanner) error {
+	var interval int64
+	err := s.Scan(
+		&rs.RepoID,
+		&interval,
+		&rs.Due,
+		&nullTime{&rs.LastFetched},
+		&nullTime{&rs.LastChanged},
+	)
+	rs.Interval = time.Duration(interval) * time.Second
+	return err
+}
+
 func scanRepo(r *Repo, s scanner) error {
 	var sources, metadata json.RawMessage
 	err := s.Scan(
@@ -759,6 +875,10 @@ func scanRepo(r *Repo, s scanner) error {
 		r.Metadata = new(gitlab.Project)
 	case "bitbucketserver":
 		r.Metadata = new(bitbucketserver.Repo)
+	case "bitbucketcloud":
+		r.Metadata = new(bitbucketcloud.Repo)
+	case "gitea":
+		r.Metadata = new(gitea.Repo)
 	default:
 		return nil
 	}
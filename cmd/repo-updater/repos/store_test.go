@@ -731,6 +731,112 @@ func testStoreListReposPagination(store repos.Store) func(*testing.T) {
 	}
 }
 
+func testDBStoreRepoSchedules(store *repos.DBStore) func(*testing.T) {
+	github := repos.Repo{
+		Name:      "github.com/foo/bar",
+		Enabled:   true,
+		CreatedAt: time.Now(),
+		ExternalRepo: api.ExternalRepoSpec{
+			ID:          "AAAAA==",
+			ServiceType: "github",
+			ServiceID:   "http://github.com",
+		},
+		Sources: map[string]*repos.SourceInfo{
+			"extsvc:1": {
+				ID:       "extsvc:1",
+				CloneURL: "git@github.com:foo/bar.git",
+			},
+		},
+		Metadata: new(github.Repository),
+	}
+
+	return func(t *testing.T) {
+		ctx := context.Background()
+
+		txstore, err := store.Transact(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txstore.Done(&errRollback)
+
+		tx := txstore.(*repos.DBStore)
+
+		stored := mkRepos(2, &github)
+		if err := tx.UpsertRepos(ctx, stored...); err != nil {
+			t.Fatalf("UpsertRepos error: %s", err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		want := []*repos.RepoSchedule{
+			{
+				RepoID:      stored[0].ID,
+				Interval:    time.Hour,
+				Due:         now.Add(time.Hour),
+				LastFetched: now,
+				LastChanged: now.Add(-2 * time.Hour),
+			},
+			{
+				RepoID:   stored[1].ID,
+				Interval: 45 * time.Second,
+				Due:      now.Add(45 * time.Second),
+			},
+		}
+
+		// The schedule of a repo that doesn't exist is ignored.
+		missing := &repos.RepoSchedule{RepoID: stored[1].ID + 1000, Interval: time.Hour, Due: now}
+
+		for i := 0; i < 2; i++ {
+			if i > 0 {
+				// Upserting again updates the existing schedules.
+				want[0].Interval = 2 * time.Hour
+				want[0].Due = now.Add(2 * time.Hour)
+			}
+
+			if err := tx.UpsertRepoSchedules(ctx, append(want, missing)...); err != nil {
+				t.Fatalf("UpsertRepoSchedules error: %s", err)
+			}
+
+			have, err := tx.ListRepoSchedules(ctx)
+			if err != nil {
+				t.Fatalf("ListRepoSchedules error: %s", err)
+			}
+
+			for _, rs := range have {
+				rs.Due = rs.Due.UTC()
+				rs.LastFetched = rs.LastFetched.UTC()
+				rs.LastChanged = rs.LastChanged.UTC()
+			}
+
+			if !reflect.DeepEqual(have, want) {
+				t.Fatalf("ListRepoSchedules:\n%s", pretty.Compare(have, want))
+			}
+		}
+
+		// Upserting a schedule without fetch times (because the update
+		// failed) keeps the persisted ones.
+		failed := *want[0]
+		failed.Due = now.Add(3 * time.Hour)
+		failed.LastFetched, failed.LastChanged = time.Time{}, time.Time{}
+		if err := tx.UpsertRepoSchedules(ctx, &failed); err != nil {
+			t.Fatalf("UpsertRepoSchedules error: %s", err)
+		}
+		want[0].Due = failed.Due
+
+		have, err := tx.ListRepoSchedules(ctx)
+		if err != nil {
+			t.Fatalf("ListRepoSchedules error: %s", err)
+		}
+		for _, rs := range have {
+			rs.Due = rs.Due.UTC()
+			rs.LastFetched = rs.LastFetched.UTC()
+			rs.LastChanged = rs.LastChanged.UTC()
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("ListRepoSchedules:\n%s", pretty.Compare(have, want))
+		}
+	}
+}
+
 func testDBStoreTransact(store *repos.DBStore) func(*testing.T) {
 	return func(t *testing.T) {
 		ctx := context.Background()
@@ -75,14 +75,22 @@ func (e ExternalService) Configuration() (cfg interface{}, _ error) {
 		cfg = &schema.AWSCodeCommitConnection{}
 	case "bitbucketserver":
 		cfg = &schema.BitbucketServerConnection{}
+	case "bitbucketcloud":
+		cfg = &schema.BitbucketCloudConnection{}
+	case "gitea":
+		cfg = &schema.GiteaConnection{}
 	case "github":
 		cfg = &schema.GitHubConnection{}
 	case "gitlab":
 		cfg = &schema.GitLabConnection{}
 	case "gitolite":
 		cfg = &schema.GitoliteConnection{}
+	case "mercurial":
+		cfg = &schema.MercurialConnection{}
 	case "phabricator":
 		cfg = &schema.PhabricatorConnection{}
+	case "svn":
+		cfg = &schema.SVNConnection{}
 	case "other":
 		cfg = &schema.OtherExternalServiceConnection{}
 	default:
@@ -101,6 +109,10 @@ func (e *ExternalService) Exclude(rs ...*Repo) error {
 		return e.excludeGitLabRepos(rs...)
 	case "bitbucketserver":
 		return e.excludeBitbucketServerRepos(rs...)
+	case "bitbucketcloud":
+		return e.excludeBitbucketCloudRepos(rs...)
+	case "gitea":
+		return e.excludeGiteaRepos(rs...)
 	case "other":
 		return e.updateOtherRepos(false, rs...)
 	default:
@@ -118,6 +130,8 @@ func (e *ExternalService) Include(rs ...*Repo) error {
 		return e.includeGitLabRepos(rs...)
 	case "bitbucketserver":
 		return e.includeBitbucketServerRepos(rs...)
+	case "gitea":
+		return e.includeGiteaRepos(rs...)
 	case "other":
 		return e.updateOtherRepos(true, rs...)
 	default:
@@ -290,6 +304,103 @@ func (e *ExternalService) excludeBitbucketServerRepos(rs ...*Repo) error {
 	})
 }
 
+// excludeBitbucketCloudRepos changes the configuration of a BitbucketCloud external service to exclude the
+// given repos from being synced.
+func (e *ExternalService) excludeBitbucketCloudRepos(rs ...*Repo) error {
+	if len(rs) == 0 {
+		return nil
+	}
+
+	return e.config("bitbucketcloud", func(v interface{}) (string, interface{}, error) {
+		c := v.(*schema.BitbucketCloudConnection)
+		set := make(map[string]bool, len(c.Exclude)*2)
+		for _, ex := range c.Exclude {
+			if ex.Uuid != "" {
+				set[ex.Uuid] = true
+			}
+
+			if ex.Name != "" {
+				set[strings.ToLower(ex.Name)] = true
+			}
+		}
+
+		for _, r := range rs {
+			if strings.ToLower(r.ExternalRepo.ServiceType) != "bitbucketcloud" {
+				continue
+			}
+
+			id := r.ExternalRepo.ID
+			name := nameWithOwner(r.Name)
+
+			if !set[name] && !set[id] {
+				c.Exclude = append(c.Exclude, &schema.ExcludedBitbucketCloudRepo{
+					Name: name,
+					Uuid: id,
+				})
+
+				if id != "" {
+					set[id] = true
+				}
+
+				if name != "" {
+					set[name] = true
+				}
+			}
+		}
+
+		return "exclude", c.Exclude, nil
+	})
+}
+
+// excludeGiteaRepos changes the configuration of a Gitea external service to exclude the
+// given repos from being synced.
+func (e *ExternalService) excludeGiteaRepos(rs ...*Repo) error {
+	if len(rs) == 0 {
+		return nil
+	}
+
+	return e.config("gitea", func(v interface{}) (string, interface{}, error) {
+		c := v.(*schema.GiteaConnection)
+		set := make(map[string]bool, len(c.Exclude)*2)
+		for _, ex := range c.Exclude {
+			if ex.Id != 0 {
+				set[strconv.Itoa(ex.Id)] = true
+			}
+
+			if ex.Name != "" {
+				set[strings.ToLower(ex.Name)] = true
+			}
+		}
+
+		for _, r := range rs {
+			if r.ExternalRepo.ServiceType != "gitea" {
+				continue
+			}
+
+			id := r.ExternalRepo.ID
+			name := nameWithOwner(r.Name)
+
+			if !set[name] && !set[id] {
+				n, _ := strconv.Atoi(id)
+				c.Exclude = append(c.Exclude, &schema.ExcludedGiteaRepo{
+					Name: name,
+					Id:   n,
+				})
+
+				if id != "" {
+					set[id] = true
+				}
+
+				if name != "" {
+					set[name] = true
+				}
+			}
+		}
+
+		return "exclude", c.Exclude, nil
+	})
+}
+
 // excludeGithubRepos changes the configuration of a Github external service to exclude the
 // given repos from being synced.
 func (e *ExternalService) excludeGithubRepos(rs ...*Repo) error {
@@ -442,6 +553,36 @@ func (e *ExternalService) includeBitbucketServerRepos(rs ...*Repo) error {
 	})
 }
 
+// includeGiteaRepos changes the configuration of a Gitea external service to explicitly enlist the
+// given repos to be synced.
+func (e *ExternalService) includeGiteaRepos(rs ...*Repo) error {
+	if len(rs) == 0 {
+		return nil
+	}
+
+	return e.config("gitea", func(v interface{}) (string, interface{}, error) {
+		c := v.(*schema.GiteaConnection)
+
+		set := make(map[string]bool, len(c.Repos))
+		for _, name := range c.Repos {
+			set[strings.ToLower(name)] = true
+		}
+
+		for _, r := range rs {
+			if r.ExternalRepo.ServiceType != "gitea" {
+				continue
+			}
+
+			if name := nameWithOwner(r.Name); !set[name] {
+				c.Repos = append(c.Repos, name)
+				set[name] = true
+			}
+		}
+
+		return "repos", c.Repos, nil
+	})
+}
+
 func (e *ExternalService) config(kind string, opt func(c interface{}) (string, interface{}, error)) error {
 	if strings.ToLower(e.Kind) != kind {
 		return fmt.Errorf("config: unexpected external service kind %q", e.Kind)
@@ -474,14 +615,22 @@ func (e ExternalService) schema() string {
 		return schema.AWSCodeCommitSchemaJSON
 	case "bitbucketserver":
 		return schema.BitbucketServerSchemaJSON
+	case "bitbucketcloud":
+		return schema.BitbucketCloudSchemaJSON
+	case "gitea":
+		return schema.GiteaSchemaJSON
 	case "github":
 		return schema.GitHubSchemaJSON
 	case "gitlab":
 		return schema.GitLabSchemaJSON
 	case "gitolite":
 		return schema.GitoliteSchemaJSON
+	case "mercurial":
+		return schema.MercurialSchemaJSON
 	case "phabricator":
 		return schema.PhabricatorSchemaJSON
+	case "svn":
+		return schema.SVNSchemaJSON
 	case "other":
 		return schema.OtherExternalServiceSchemaJSON
 	default:
@@ -578,6 +727,19 @@ type Repo struct {
 type SourceInfo struct {
 	ID       string
 	CloneURL string
+
+	// PartialCloneFilter is the object filter (such as "blob:none") the
+	// source wants the repo to be cloned partially with.
+	PartialCloneFilter string `json:",omitempty"`
+
+	// MaxRepoSize is the maximum size in bytes the source allows the repo
+	// to have on gitserver. Zero means there is no limit.
+	MaxRepoSize int64 `json:",omitempty"`
+
+	// LFSMaxFileSize is the maximum size in bytes of the Git LFS objects the
+	// source wants gitserver to fetch for the repo. Zero means LFS objects
+	// are not fetched.
+	LFSMaxFileSize int64 `json:",omitempty"`
 }
 
 // ExternalServiceID returns the ID of the external service this
@@ -607,6 +769,56 @@ func (r *Repo) CloneURLs() []string {
 	return urls
 }
 
+// PartialCloneFilter returns the object filter this repo should be cloned
+// partially with. It is empty, i.e. the repo is cloned fully, unless all of
+// the repo's sources want it to be cloned with the same filter.
+func (r *Repo) PartialCloneFilter() string {
+	var filter string
+	for _, src := range r.Sources {
+		if src == nil || src.CloneURL == "" {
+			continue
+		}
+		if src.PartialCloneFilter == "" || (filter != "" && filter != src.PartialCloneFilter) {
+			return ""
+		}
+		filter = src.PartialCloneFilter
+	}
+	return filter
+}
+
+// MaxRepoSize returns the maximum size in bytes this repo may have on
+// gitserver. It is the largest size any of the repo's sources allows, and zero
+// (no limit) if any of them has no limit.
+func (r *Repo) MaxRepoSize() int64 {
+	var max int64
+	for _, src := range r.Sources {
+		if src == nil || src.CloneURL == "" {
+			continue
+		}
+		if src.MaxRepoSize == 0 {
+			return 0
+		}
+		if src.MaxRepoSize > max {
+			max = src.MaxRepoSize
+		}
+	}
+	return max
+}
+
+// LFSMaxFileSize returns the maximum size in bytes of the Git LFS objects
+// gitserver fetches for this repo. It is the largest size any of the repo's
+// sources wants, and zero (LFS objects are not fetched) if none of them wants
+// LFS objects.
+func (r *Repo) LFSMaxFileSize() int64 {
+	var max int64
+	for _, src := range r.Sources {
+		if src != nil && src.CloneURL != "" && src.LFSMaxFileSize > max {
+			max = src.LFSMaxFileSize
+		}
+	}
+	return max
+}
+
 // ExternalServiceIDs returns the IDs of the external services this
 // repo belongs to.
 func (r *Repo) ExternalServiceIDs() []int64 {
@@ -857,3 +1069,13 @@ func (es ExternalServices) Filter(pred func(*ExternalService) bool) (fs External
 	}
 	return fs
 }
+
+// A RepoSchedule is the persisted update schedule of a single repo, which
+// survives restarts of the scheduler.
+type RepoSchedule struct {
+	RepoID      uint32
+	Interval    time.Duration // how regularly the repo is updated
+	Due         time.Time     // the next time that the repo will be updated
+	LastFetched time.Time     // the last time gitserver fetched the repo
+	LastChanged time.Time     // the last time the repo changed on fetch
+}
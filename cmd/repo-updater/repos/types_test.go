@@ -502,6 +502,156 @@ func TestExternalService_IncludeExclude(t *testing.T) {
 	}
 }
 
+func TestRepo_PartialCloneFilter(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sources map[string]*SourceInfo
+		want    string
+	}{
+		{
+			name: "no sources",
+		},
+		{
+			name: "full clone",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a"},
+			},
+		},
+		{
+			name: "partial clone",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", PartialCloneFilter: "blob:none"},
+				"b": {ID: "b", CloneURL: "https://b", PartialCloneFilter: "blob:none"},
+			},
+			want: "blob:none",
+		},
+		{
+			name: "source without clone url",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", PartialCloneFilter: "blob:none"},
+				"b": {ID: "b"},
+			},
+			want: "blob:none",
+		},
+		{
+			name: "one source clones fully",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", PartialCloneFilter: "blob:none"},
+				"b": {ID: "b", CloneURL: "https://b"},
+			},
+		},
+		{
+			name: "different filters",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", PartialCloneFilter: "blob:none"},
+				"b": {ID: "b", CloneURL: "https://b", PartialCloneFilter: "tree:0"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &Repo{Sources: tc.sources}
+			if got := r.PartialCloneFilter(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepo_MaxRepoSize(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sources map[string]*SourceInfo
+		want    int64
+	}{
+		{
+			name: "no sources",
+		},
+		{
+			name: "no limit",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a"},
+			},
+		},
+		{
+			name: "largest limit",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", MaxRepoSize: 1 << 20},
+				"b": {ID: "b", CloneURL: "https://b", MaxRepoSize: 2 << 20},
+			},
+			want: 2 << 20,
+		},
+		{
+			name: "source without clone url",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", MaxRepoSize: 1 << 20},
+				"b": {ID: "b"},
+			},
+			want: 1 << 20,
+		},
+		{
+			name: "one source without limit",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", MaxRepoSize: 1 << 20},
+				"b": {ID: "b", CloneURL: "https://b"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &Repo{Sources: tc.sources}
+			if got := r.MaxRepoSize(); got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepo_LFSMaxFileSize(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sources map[string]*SourceInfo
+		want    int64
+	}{
+		{
+			name: "no sources",
+		},
+		{
+			name: "not fetched",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a"},
+			},
+		},
+		{
+			name: "largest size",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", LFSMaxFileSize: 1 << 20},
+				"b": {ID: "b", CloneURL: "https://b", LFSMaxFileSize: 2 << 20},
+			},
+			want: 2 << 20,
+		},
+		{
+			name: "one source doesn't fetch",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", CloneURL: "https://a", LFSMaxFileSize: 1 << 20},
+				"b": {ID: "b", CloneURL: "https://b"},
+			},
+			want: 1 << 20,
+		},
+		{
+			name: "source without clone url",
+			sources: map[string]*SourceInfo{
+				"a": {ID: "a", LFSMaxFileSize: 1 << 20},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &Repo{Sources: tc.sources}
+			if got := r.LFSMaxFileSize(); got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
 func formatJSON(t testing.TB, s string) string {
 	formatted, err := jsonc.Format(s, true, 2)
 	if err != nil {
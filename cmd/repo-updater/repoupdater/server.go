@@ -15,9 +15,12 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/errcode"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/awscodecommit"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketcloud"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitea"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/mercurial"
 	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/trace"
 	log15 "gopkg.in/inconshreveable/log15.v2"
@@ -427,7 +430,7 @@ func newRepoInfo(r *repos.Repo) (*protocol.RepoInfo, error) {
 		Description:  r.Description,
 		Fork:         r.Fork,
 		Archived:     r.Archived,
-		VCS:          protocol.VCSInfo{URL: urls[0]},
+		VCS:          protocol.VCSInfo{URL: urls[0], PartialCloneFilter: r.PartialCloneFilter(), MaxRepoSize: r.MaxRepoSize(), LFSMaxFileSize: r.LFSMaxFileSize()},
 		ExternalRepo: &r.ExternalRepo,
 	}
 
@@ -462,6 +465,46 @@ func newRepoInfo(r *repos.Repo) (*protocol.RepoInfo, error) {
 			Blob:   pathAppend(root, "/browse/{path}?at={rev}"),
 			Commit: pathAppend(root, "/commits/{commit}"),
 		}
+	case "bitbucketcloud":
+		repo := r.Metadata.(*bitbucketcloud.Repo)
+		if repo.Links.HTML.Href == "" {
+			break
+		}
+
+		href := repo.Links.HTML.Href
+		info.Links = &protocol.RepoLinks{
+			Root:   href,
+			Tree:   pathAppend(href, "/src/{rev}/{path}"),
+			Blob:   pathAppend(href, "/src/{rev}/{path}"),
+			Commit: pathAppend(href, "/commits/{commit}"),
+		}
+	case "gitea":
+		repo := r.Metadata.(*gitea.Repo)
+		if repo.HTMLURL == "" {
+			break
+		}
+
+		// Gitea resolves {rev} as a branch, tag or commit in /src/ URLs.
+		info.Links = &protocol.RepoLinks{
+			Root:   repo.HTMLURL,
+			Tree:   pathAppend(repo.HTMLURL, "/src/{rev}/{path}"),
+			Blob:   pathAppend(repo.HTMLURL, "/src/{rev}/{path}"),
+			Commit: pathAppend(repo.HTMLURL, "/commit/{commit}"),
+		}
+	case "mercurial":
+		root := mercurial.WebURL(&r.ExternalRepo)
+		if root == "" {
+			break
+		}
+
+		// hgweb links need Mercurial changeset IDs instead of Git commit IDs,
+		// which the frontend looks up when it renders them.
+		info.Links = &protocol.RepoLinks{
+			Root:   root,
+			Tree:   pathAppend(root, "/file/{rev}/{path}"),
+			Blob:   pathAppend(root, "/file/{rev}/{path}"),
+			Commit: pathAppend(root, "/rev/{commit}"),
+		}
 	}
 
 	return &info, nil
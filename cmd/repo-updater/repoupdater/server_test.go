@@ -730,6 +730,22 @@ func TestRepoLookup_syncer(t *testing.T) {
 			NameWithOwner: "foo/bar",
 		},
 	})
+	_ = store.UpsertRepos(ctx, &repos.Repo{
+		Name:      "hg.example.com/team/app",
+		Enabled:   true,
+		CreatedAt: now,
+		ExternalRepo: api.ExternalRepoSpec{
+			ID:          "team/app",
+			ServiceType: "mercurial",
+			ServiceID:   "https://hg.example.com/",
+		},
+		Sources: map[string]*repos.SourceInfo{
+			"extsvc:456": {
+				ID:       "extsvc:456",
+				CloneURL: "hg::https://hg.example.com/team/app",
+			},
+		},
+	})
 
 	s := Server{
 		Syncer:      &repos.Syncer{},
@@ -776,6 +792,34 @@ func TestRepoLookup_syncer(t *testing.T) {
 			t.Fatalf("ListRepos:\n%s", diff)
 		}
 	})
+
+	t.Run("found mercurial", func(t *testing.T) {
+		want := &protocol.RepoLookupResult{
+			Repo: &protocol.RepoInfo{
+				ExternalRepo: &api.ExternalRepoSpec{
+					ID:          "team/app",
+					ServiceType: "mercurial",
+					ServiceID:   "https://hg.example.com/",
+				},
+				Name: "hg.example.com/team/app",
+				VCS:  protocol.VCSInfo{URL: "hg::https://hg.example.com/team/app"},
+				Links: &protocol.RepoLinks{
+					Root:   "https://hg.example.com/team/app",
+					Tree:   "https://hg.example.com/team/app/file/{rev}/{path}",
+					Blob:   "https://hg.example.com/team/app/file/{rev}/{path}",
+					Commit: "https://hg.example.com/team/app/rev/{commit}",
+				},
+			},
+		}
+
+		result, err := s.repoLookup(ctx, protocol.RepoLookupArgs{Repo: "hg.example.com/team/app"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := pretty.Compare(result, want); diff != "" {
+			t.Fatalf("ListRepos:\n%s", diff)
+		}
+	})
 }
 
 type internalAPIFake struct {
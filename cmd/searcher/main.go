@@ -31,6 +31,7 @@ import (
 
 var cacheDir = env.Get("CACHE_DIR", "/tmp", "directory to store cached archives.")
 var cacheSizeMB = env.Get("SEARCHER_CACHE_SIZE_MB", "100000", "maximum size of the on disk cache in megabytes")
+var trigramIndexSizeMB = env.Get("SEARCHER_TRIGRAM_INDEX_SIZE_MB", "10000", "maximum total size of the trigram indexes of cached archives in megabytes (0 disables them)")
 
 const port = "3181"
 
@@ -49,13 +50,22 @@ func main() {
 		cacheSizeBytes = i * 1000 * 1000
 	}
 
+	var trigramIndexSizeBytes int64
+	if i, err := strconv.ParseInt(trigramIndexSizeMB, 10, 64); err != nil {
+		log.Fatalf("invalid int %q for SEARCHER_TRIGRAM_INDEX_SIZE_MB: %s", trigramIndexSizeMB, err)
+	} else {
+		trigramIndexSizeBytes = i * 1000 * 1000
+	}
+
 	service := &search.Service{
 		Store: &store.Store{
 			FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
 				return git.Archive(ctx, repo, git.ArchiveOptions{Treeish: string(commit), Format: "tar"})
 			},
-			Path:              filepath.Join(cacheDir, "searcher-archives"),
-			MaxCacheSizeBytes: cacheSizeBytes,
+			FetchLFSObjects:          git.ReadLFSObjects,
+			Path:                     filepath.Join(cacheDir, "searcher-archives"),
+			MaxCacheSizeBytes:        cacheSizeBytes,
+			MaxTrigramIndexSizeBytes: trigramIndexSizeBytes,
 		},
 		Log: log15.Root(),
 	}
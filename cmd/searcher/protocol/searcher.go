@@ -2,6 +2,10 @@
 package protocol
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 )
@@ -39,6 +43,15 @@ type Request struct {
 	// The deadline for the search request.
 	// It is parsed with time.Time.UnmarshalText.
 	Deadline string
+
+	// Stream if true will make searcher respond with newline-delimited JSON
+	// StreamEvents (Content-Type StreamContentType) instead of a single
+	// Response. Each FileMatch is written as soon as it is found, followed by
+	// a final event carrying the StreamTrailer.
+	//
+	// Searchers which do not understand Stream ignore it and respond with a
+	// Response, so clients must check the Content-Type of the response.
+	Stream bool
 }
 
 // GitserverRepo returns the repository information necessary to perform gitserver requests.
@@ -54,9 +67,27 @@ type PatternInfo struct {
 	// IsRegExp if true will treat the Pattern as a regular expression.
 	IsRegExp bool
 
+	// PatternExpr, if non-empty, is the JSON encoding of a PatternExpr. It
+	// is matched instead of Pattern, and its patterns are interpreted like
+	// Pattern. Clients still set Pattern for searchers which don't support
+	// PatternExpr. Use ParsePatternExpr to decode it.
+	PatternExpr string
+
+	// IsStructuralPat if true will treat the Pattern as a comby-style
+	// structural match template, eg "foo(:[args])". Holes (:[name]) match
+	// text with balanced delimiters. Structural patterns are always case
+	// sensitive, only match file contents and take precedence over IsRegExp.
+	IsStructuralPat bool
+
 	// IsWordMatch if true will only match the pattern at word boundaries.
 	IsWordMatch bool
 
+	// IsMultiline if true will match the pattern against the whole content
+	// of files instead of line by line, so matches may span multiple lines
+	// (eg "func \w+\(\)\s*\{\s*\}"). The full extent of each match is
+	// reported in LineMatch.Ranges.
+	IsMultiline bool
+
 	// IsCaseSensitive if false will ignore the case of text and pattern
 	// when finding matches.
 	IsCaseSensitive bool
@@ -90,6 +121,13 @@ type PatternInfo struct {
 	// FileMatchLimit limits the number of files with matches that are returned.
 	FileMatchLimit int
 
+	// CountOnly is whether only the number of matches in each file is
+	// returned (in FileMatch.MatchCount) instead of the matches. The number of
+	// matches in a file and the number of files are not limited then (and
+	// FileMatchLimit is ignored), so the counts are exact unless the deadline
+	// is hit.
+	CountOnly bool
+
 	// PatternMatchesPath is whether the pattern should be matched against the content
 	// of files.
 	PatternMatchesContent bool
@@ -114,6 +152,74 @@ func (p PatternInfo) AllIncludePatterns() []string {
 	return all
 }
 
+// PatternExpr is a boolean combination of patterns, such as
+// (foo OR bar) AND NOT baz. A file's content matches it if the boolean
+// combination of whether each pattern matches anywhere in the content is
+// true.
+type PatternExpr struct {
+	// Op is the operator applied to Operands, or empty for a leaf.
+	Op PatternOp `json:",omitempty"`
+
+	// Pattern is the pattern of a leaf.
+	Pattern string `json:",omitempty"`
+
+	// Operands are the operands of Op. A PatternOpNot has exactly 1
+	// operand, PatternOpAnd and PatternOpOr have at least 1.
+	Operands []*PatternExpr `json:",omitempty"`
+}
+
+// PatternOp is a boolean operator in a PatternExpr.
+type PatternOp string
+
+// All PatternOp values.
+const (
+	PatternOpAnd PatternOp = "and"
+	PatternOpOr  PatternOp = "or"
+	PatternOpNot PatternOp = "not"
+)
+
+// ParsePatternExpr decodes and validates the JSON encoding of a PatternExpr
+// (see PatternInfo.PatternExpr).
+func ParsePatternExpr(s string) (*PatternExpr, error) {
+	var e PatternExpr
+	if err := json.Unmarshal([]byte(s), &e); err != nil {
+		return nil, err
+	}
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (e *PatternExpr) validate() error {
+	switch e.Op {
+	case "":
+		if len(e.Operands) != 0 {
+			return errors.New("pattern expression leaf has operands")
+		}
+		return nil
+	case PatternOpNot:
+		if len(e.Operands) != 1 {
+			return fmt.Errorf("pattern expression %q has %d operands, want 1", e.Op, len(e.Operands))
+		}
+	case PatternOpAnd, PatternOpOr:
+		if len(e.Operands) == 0 {
+			return fmt.Errorf("pattern expression %q has no operands", e.Op)
+		}
+	default:
+		return fmt.Errorf("unknown pattern expression operator %q", e.Op)
+	}
+	for _, operand := range e.Operands {
+		if operand == nil {
+			return errors.New("pattern expression operand is null")
+		}
+		if err := operand.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Response represents the response from a Search request.
 type Response struct {
 	Matches []FileMatch
@@ -125,6 +231,39 @@ type Response struct {
 	DeadlineHit bool
 }
 
+// StreamContentType is the Content-Type of a streamed search response. See
+// Request.Stream.
+const StreamContentType = "application/x-ndjson"
+
+// StreamEvent is a single event in a streamed search response. Exactly one
+// of its fields is set.
+type StreamEvent struct {
+	// Match is a file match found by searcher.
+	Match *FileMatch `json:",omitempty"`
+
+	// Done is set on the last event of the stream. A stream which ends
+	// without a Done event was interrupted and is incomplete.
+	Done *StreamTrailer `json:",omitempty"`
+}
+
+// StreamTrailer is the last event of a streamed search response. It
+// describes the stream as a whole, similar to the non-match fields of
+// Response.
+type StreamTrailer struct {
+	// LimitHit is true if the stream may not include all FileMatches because
+	// a match limit was hit.
+	LimitHit bool
+
+	// DeadlineHit is true if the stream may not include all FileMatches
+	// because a deadline was hit.
+	DeadlineHit bool
+
+	// Error is non-empty if the search failed after the stream had
+	// started. Errors which happen before any match is written are reported
+	// with a non-200 status code instead.
+	Error string `json:",omitempty"`
+}
+
 // FileMatch is the struct used by vscode to receive search results
 type FileMatch struct {
 	Path        string
@@ -132,6 +271,10 @@ type FileMatch struct {
 
 	// LimitHit is true if LineMatches may not include all LineMatches.
 	LimitHit bool
+
+	// MatchCount is the number of LineMatches the file has, if the search
+	// was CountOnly. LineMatches is empty then.
+	MatchCount int `json:",omitempty"`
 }
 
 // LineMatch is the struct used by vscode to receive search results for a line.
@@ -150,4 +293,27 @@ type LineMatch struct {
 
 	// LimitHit is true if OffsetAndLengths may not include all OffsetAndLengths.
 	LimitHit bool
+
+	// Ranges, if set, is the range of each match which starts on the line,
+	// in the same order as OffsetAndLengths. Unlike OffsetAndLengths, which
+	// are clipped to the end of the line, a range may end on a later line.
+	// It is only set for searches whose matches may span multiple lines
+	// (IsMultiline or IsStructuralPat).
+	Ranges []Range `json:",omitempty"`
+}
+
+// Range is a range of a file's content, such as a match.
+type Range struct {
+	Start Location // inclusive
+	End   Location // exclusive
+}
+
+// Location is a position in a file's content.
+type Location struct {
+	// Line is the 0-based line number.
+	Line int
+
+	// Column is the 0-based offset in the line, measured in characters (not
+	// bytes).
+	Column int
 }
@@ -0,0 +1,151 @@
+package search
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+)
+
+// This file implements matching boolean combinations of patterns, such as
+// (foo OR bar) AND NOT baz (see protocol.PatternExpr). Unlike a single
+// pattern, which must match within a line, the patterns are matched against
+// the whole file: a file matches if the boolean combination of whether each
+// pattern matches anywhere in it is true. The lines matched by the patterns
+// which are not negated are reported as LineMatches.
+
+// booleanMatcher matches a protocol.PatternExpr. It is a tree with a regexp
+// at each leaf. It is not concurrency safe, use Copy.
+type booleanMatcher struct {
+	op       protocol.PatternOp // the operator, or empty for a leaf
+	re       *regexp.Regexp     // if a leaf, the regexp to match
+	operands []*booleanMatcher
+
+	// literalSubstring is used to test if a file is worth running re on (see
+	// readerGrep).
+	literalSubstring []byte
+}
+
+// compileBoolean returns a booleanMatcher for expr. Its patterns are
+// interpreted according to p, like p.Pattern.
+func compileBoolean(expr *protocol.PatternExpr, p *protocol.PatternInfo) (*booleanMatcher, error) {
+	if expr.Op == "" {
+		re, literalSubstring, err := compileRegexp(expr.Pattern, p)
+		if err != nil {
+			return nil, err
+		}
+		return &booleanMatcher{re: re, literalSubstring: literalSubstring}, nil
+	}
+	m := &booleanMatcher{op: expr.Op, operands: make([]*booleanMatcher, len(expr.Operands))}
+	for i, operand := range expr.Operands {
+		var err error
+		m.operands[i], err = compileBoolean(operand, p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Copy returns a copied version of m that is safe to use from another
+// goroutine. It returns nil if m is nil.
+func (m *booleanMatcher) Copy() *booleanMatcher {
+	if m == nil {
+		return nil
+	}
+	c := &booleanMatcher{op: m.op, literalSubstring: m.literalSubstring}
+	if m.re != nil {
+		c.re = m.re.Copy()
+	}
+	for _, operand := range m.operands {
+		c.operands = append(c.operands, operand.Copy())
+	}
+	return c
+}
+
+// match reports whether b matches m.
+func (m *booleanMatcher) match(b []byte) bool {
+	switch m.op {
+	case "":
+		return bytes.Contains(b, m.literalSubstring) && m.re.Match(b)
+	case protocol.PatternOpNot:
+		return !m.operands[0].match(b)
+	case protocol.PatternOpOr:
+		for _, operand := range m.operands {
+			if operand.match(b) {
+				return true
+			}
+		}
+		return false
+	default:
+		for _, operand := range m.operands {
+			if !operand.match(b) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// positive calls f with the regexp of each leaf of m that is not negated,
+// given whether m itself is negated.
+func (m *booleanMatcher) positive(negated bool, f func(*regexp.Regexp)) {
+	if m.op == "" {
+		if !negated {
+			f(m.re)
+		}
+		return
+	}
+	for _, operand := range m.operands {
+		operand.positive(negated != (m.op == protocol.PatternOpNot), f)
+	}
+}
+
+// firstIndex returns the offset of the first match in b of the patterns of m
+// which are not negated, or -1 if there is none.
+func (m *booleanMatcher) firstIndex(b []byte) int {
+	first := -1
+	m.positive(false, func(re *regexp.Regexp) {
+		if loc := re.FindIndex(b); loc != nil && (first < 0 || loc[0] < first) {
+			first = loc[0]
+		}
+	})
+	return first
+}
+
+// findAllIndex is like (*regexp.Regexp).FindAllIndex for the patterns of m
+// which are not negated. The matches are sorted by offset.
+func (m *booleanMatcher) findAllIndex(b []byte, n int) [][]int {
+	var locs [][]int
+	m.positive(false, func(re *regexp.Regexp) {
+		locs = append(locs, re.FindAllIndex(b, n)...)
+	})
+	sort.Slice(locs, func(i, j int) bool {
+		return locs[i][0] < locs[j][0] || (locs[i][0] == locs[j][0] && locs[i][1] < locs[j][1])
+	})
+	// Remove duplicate matches of different patterns.
+	dedup := locs[:0]
+	for _, loc := range locs {
+		if last := len(dedup) - 1; last >= 0 && loc[0] == dedup[last][0] && loc[1] == dedup[last][1] {
+			continue
+		}
+		dedup = append(dedup, loc)
+	}
+	if n >= 0 && len(dedup) > n {
+		dedup = dedup[:n]
+	}
+	return dedup
+}
+
+func (m *booleanMatcher) String() string {
+	if m.op == "" {
+		return m.re.String()
+	}
+	s := make([]string, len(m.operands))
+	for i, operand := range m.operands {
+		s[i] = operand.String()
+	}
+	return "(" + string(m.op) + " " + strings.Join(s, " ") + ")"
+}
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/store"
+)
+
+func TestBooleanMatch(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go":   "foo\nbar\n",
+		"b.go":   "foo\nbaz\n",
+		"c.go":   "BAR\n",
+		"d.go":   "qux\n",
+		"foo.md": "qux\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := store.MockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf := func(pattern string) *protocol.PatternExpr { return &protocol.PatternExpr{Pattern: pattern} }
+	op := func(op protocol.PatternOp, operands ...*protocol.PatternExpr) *protocol.PatternExpr {
+		return &protocol.PatternExpr{Op: op, Operands: operands}
+	}
+
+	tests := []struct {
+		name  string
+		expr  *protocol.PatternExpr
+		paths bool
+		want  []string // path:line for each LineMatch, or path for files without any
+	}{
+		{
+			name: "and",
+			expr: op(protocol.PatternOpAnd, leaf("foo"), leaf("bar")),
+			want: []string{"a.go:0", "a.go:1"},
+		},
+		{
+			name: "or and not",
+			expr: op(protocol.PatternOpAnd, op(protocol.PatternOpOr, leaf("foo"), leaf("bar")), op(protocol.PatternOpNot, leaf("baz"))),
+			want: []string{"a.go:0", "a.go:1", "c.go:0"},
+		},
+		{
+			name: "not",
+			expr: op(protocol.PatternOpNot, op(protocol.PatternOpOr, leaf("foo"), leaf("bar"))),
+			want: []string{"d.go", "foo.md"},
+		},
+		{
+			name: "double negation",
+			expr: op(protocol.PatternOpNot, op(protocol.PatternOpNot, leaf("baz"))),
+			want: []string{"b.go:1"},
+		},
+		{
+			name:  "path",
+			expr:  op(protocol.PatternOpAnd, leaf("foo"), op(protocol.PatternOpNot, leaf("bar"))),
+			paths: true,
+			want:  []string{"b.go:0", "foo.md"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := json.Marshal(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rg, err := compile(&protocol.PatternInfo{PatternExpr: string(b), IsRegExp: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 0, true, test.paths, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, fm := range fileMatches {
+				if len(fm.LineMatches) == 0 {
+					got = append(got, fm.Path)
+				}
+				for _, lm := range fm.LineMatches {
+					got = append(got, fm.Path+":"+strconv.Itoa(lm.LineNumber))
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParsePatternExpr(t *testing.T) {
+	for _, s := range []string{
+		`{"Op":"and"}`,
+		`{"Op":"not","Operands":[{"Pattern":"a"},{"Pattern":"b"}]}`,
+		`{"Op":"xor","Operands":[{"Pattern":"a"}]}`,
+		`{"Pattern":"a","Operands":[{"Pattern":"b"}]}`,
+		`{"Op":"or","Operands":[null]}`,
+	} {
+		if _, err := protocol.ParsePatternExpr(s); err == nil {
+			t.Errorf("%s: got nil error, want error", s)
+		}
+	}
+}
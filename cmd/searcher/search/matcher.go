@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"math"
 	"regexp"
 	"regexp/syntax"
 	"strings"
@@ -65,9 +66,29 @@ type readerGrep struct {
 	// re is the regexp to match, or nil if empty ("match all files' content").
 	re *regexp.Regexp
 
+	// structural is the structural match template to match. If it is set, re
+	// is nil.
+	structural *structuralMatcher
+
+	// boolean is the boolean combination of regexps to match. If it is set,
+	// re is nil.
+	boolean *booleanMatcher
+
 	// ignoreCase if true means we need to do case insensitive matching.
 	ignoreCase bool
 
+	// multiline if true means re (or boolean) is matched against the whole
+	// file content, so matches may span multiple lines.
+	multiline bool
+
+	// countOnly if true means only the number of LineMatches of each file
+	// is returned (see protocol.PatternInfo.CountOnly).
+	countOnly bool
+
+	// lineMatchLimit is the limit on the number of LineMatches found in a
+	// file.
+	lineMatchLimit int
+
 	// transformBuf is reused between file searches to avoid
 	// re-allocating. It is only used if we need to transform the input
 	// before matching. For example we lower case the input in the case of
@@ -83,55 +104,44 @@ type readerGrep struct {
 	// re. It is the output of the longestLiteral function. It is only set if
 	// the regex has an empty LiteralPrefix.
 	literalSubstring []byte
+
+	// indexLiterals are the literals every match found by re contains. They
+	// are looked up in the trigram index of an archive to skip files that
+	// cannot match. See indexLiterals.
+	indexLiterals []string
 }
 
 // compile returns a readerGrep for matching p.
 func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 	var (
 		re               *regexp.Regexp
+		structural       *structuralMatcher
+		boolean          *booleanMatcher
 		literalSubstring []byte
+		lits             []string
 	)
-	if p.Pattern != "" {
-		expr := p.Pattern
-		if !p.IsRegExp {
-			expr = regexp.QuoteMeta(expr)
-		}
-		if p.IsWordMatch {
-			expr = `\b` + expr + `\b`
-		}
-		if p.IsRegExp {
-			// We don't do the search line by line, therefore we want the
-			// regex engine to consider newlines for anchors (^$).
-			expr = "(?m:" + expr + ")"
-		}
-		if !p.IsCaseSensitive {
-			// We don't just use (?i) because regexp library doesn't seem
-			// to contain good optimizations for case insensitive
-			// search. Instead we lowercase the input and pattern.
-			re, err := syntax.Parse(expr, syntax.Perl)
-			if err != nil {
-				return nil, err
-			}
-			lowerRegexpASCII(re)
-			expr = re.String()
-		}
-
+	if p.IsStructuralPat {
 		var err error
-		re, err = regexp.Compile(expr)
+		structural, err = compileStructural(p.Pattern)
 		if err != nil {
 			return nil, err
 		}
-
-		// Only use literalSubstring optimization if the regex engine doesn't
-		// have a prefix to use.
-		if pre, _ := re.LiteralPrefix(); pre == "" {
-			ast, err := syntax.Parse(expr, syntax.Perl)
-			if err != nil {
-				return nil, err
-			}
-			ast = ast.Simplify()
-			literalSubstring = []byte(longestLiteral(ast))
+	} else if p.PatternExpr != "" {
+		expr, err := protocol.ParsePatternExpr(p.PatternExpr)
+		if err != nil {
+			return nil, err
+		}
+		boolean, err = compileBoolean(expr, p)
+		if err != nil {
+			return nil, err
 		}
+	} else if p.Pattern != "" {
+		var err error
+		re, literalSubstring, err = compileRegexp(p.Pattern, p)
+		if err != nil {
+			return nil, err
+		}
+		lits = indexLiterals(re)
 	}
 
 	pathOptions := pathmatch.CompileOptions{
@@ -143,14 +153,74 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 		return nil, err
 	}
 
+	// Structural patterns are always matched case sensitively.
+	ignoreCase := !p.IsCaseSensitive && !p.IsStructuralPat
+
+	lineMatchLimit := maxLineMatches
+	if p.CountOnly {
+		// Counting doesn't return the matches, so all of them are found.
+		lineMatchLimit = math.MaxInt32 / maxOffsets
+	}
+
 	return &readerGrep{
 		re:               re,
-		ignoreCase:       !p.IsCaseSensitive,
+		structural:       structural,
+		boolean:          boolean,
+		ignoreCase:       ignoreCase,
+		multiline:        p.IsMultiline,
+		countOnly:        p.CountOnly,
+		lineMatchLimit:   lineMatchLimit,
 		matchPath:        matchPath,
 		literalSubstring: literalSubstring,
+		indexLiterals:    lits,
 	}, nil
 }
 
+// compileRegexp compiles pattern, which is interpreted according to p. It
+// also returns the literalSubstring to use for the regexp (see readerGrep).
+func compileRegexp(pattern string, p *protocol.PatternInfo) (re *regexp.Regexp, literalSubstring []byte, err error) {
+	expr := pattern
+	if !p.IsRegExp {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if p.IsWordMatch {
+		expr = `\b` + expr + `\b`
+	}
+	if p.IsRegExp {
+		// We don't do the search line by line, therefore we want the
+		// regex engine to consider newlines for anchors (^$).
+		expr = "(?m:" + expr + ")"
+	}
+	if !p.IsCaseSensitive {
+		// We don't just use (?i) because regexp library doesn't seem
+		// to contain good optimizations for case insensitive
+		// search. Instead we lowercase the input and pattern.
+		re, err := syntax.Parse(expr, syntax.Perl)
+		if err != nil {
+			return nil, nil, err
+		}
+		lowerRegexpASCII(re)
+		expr = re.String()
+	}
+
+	re, err = regexp.Compile(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Only use literalSubstring optimization if the regex engine doesn't
+	// have a prefix to use.
+	if pre, _ := re.LiteralPrefix(); pre == "" {
+		ast, err := syntax.Parse(expr, syntax.Perl)
+		if err != nil {
+			return nil, nil, err
+		}
+		ast = ast.Simplify()
+		literalSubstring = []byte(longestLiteral(ast))
+	}
+	return re, literalSubstring, nil
+}
+
 // Copy returns a copied version of rg that is safe to use from another
 // goroutine.
 func (rg *readerGrep) Copy() *readerGrep {
@@ -160,21 +230,34 @@ func (rg *readerGrep) Copy() *readerGrep {
 	}
 	return &readerGrep{
 		re:               reCopy,
+		structural:       rg.structural,
+		boolean:          rg.boolean.Copy(),
 		ignoreCase:       rg.ignoreCase,
+		multiline:        rg.multiline,
+		countOnly:        rg.countOnly,
+		lineMatchLimit:   rg.lineMatchLimit,
 		matchPath:        rg.matchPath.Copy(),
 		literalSubstring: rg.literalSubstring,
+		indexLiterals:    rg.indexLiterals,
 	}
 }
 
 // matchString returns whether rg's regexp pattern matches s. It is intended to be
 // used to match file paths.
 func (rg *readerGrep) matchString(s string) bool {
-	if rg.re == nil {
+	if rg.structural != nil {
+		// Structural patterns describe code, not paths.
+		return false
+	}
+	if rg.re == nil && rg.boolean == nil {
 		return true
 	}
 	if rg.ignoreCase {
 		s = strings.ToLower(s)
 	}
+	if rg.boolean != nil {
+		return rg.boolean.match([]byte(s))
+	}
 	return rg.re.MatchString(s)
 }
 
@@ -182,6 +265,14 @@ func (rg *readerGrep) matchString(s string) bool {
 // LimitHit is true if some matches may not have been included in the result.
 // NOTE: This is not safe to use concurrently.
 func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []protocol.LineMatch, limitHit bool, err error) {
+	matches, limitHit, _, err = rg.find(zf, f)
+	return matches, limitHit, err
+}
+
+// find is like Find, but also returns whether the content of f matches. It
+// may match without any LineMatches if rg.boolean only matches because of
+// negated patterns.
+func (rg *readerGrep) find(zf *store.ZipFile, f *store.SrcFile) (matches []protocol.LineMatch, limitHit, match bool, err error) {
 	if rg.ignoreCase && rg.transformBuf == nil {
 		rg.transformBuf = make([]byte, zf.MaxLen)
 	}
@@ -189,6 +280,11 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 	// fileMatchBuf is what we run match on, fileBuf is the original
 	// data (for Preview).
 	fileBuf := zf.DataFor(f)
+
+	if rg.structural != nil {
+		matches, limitHit = rg.findStructural(fileBuf)
+		return matches, limitHit, len(matches) > 0, nil
+	}
 	fileMatchBuf := fileBuf
 
 	// If we are ignoring case, we transform the input instead of
@@ -201,6 +297,23 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 		bytesToLowerASCII(fileMatchBuf, fileBuf)
 	}
 
+	if rg.boolean != nil {
+		if !rg.boolean.match(fileMatchBuf) {
+			return nil, false, false, nil
+		}
+		// Report the lines matched by the patterns which are not negated.
+		if rg.multiline {
+			matches, limitHit = findRanges(fileBuf, rg.boolean.findAllIndex(fileMatchBuf, rg.lineMatchLimit*maxOffsets), rg.lineMatchLimit)
+			return matches, limitHit, true, nil
+		}
+		first := rg.boolean.firstIndex(fileMatchBuf)
+		if first < 0 {
+			return nil, false, true, nil
+		}
+		matches, limitHit, err = findLines(fileBuf, fileMatchBuf, first, rg.boolean.findAllIndex, rg.lineMatchLimit)
+		return matches, limitHit, true, err
+	}
+
 	// Most files will not have a match and we bound the number of matched
 	// files we return. So we can avoid the overhead of parsing out new lines
 	// and repeatedly running the regex engine by running a single match over
@@ -209,15 +322,27 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 	// per-line. Additionally if we have a non-empty literalSubstring, we use
 	// that to prune out files since doing bytes.Index is very fast.
 	if !bytes.Contains(fileMatchBuf, rg.literalSubstring) {
-		return nil, false, nil
+		return nil, false, false, nil
+	}
+	if rg.multiline {
+		matches, limitHit = findRanges(fileBuf, rg.re.FindAllIndex(fileMatchBuf, rg.lineMatchLimit*maxOffsets), rg.lineMatchLimit)
+		return matches, limitHit, len(matches) > 0, nil
 	}
 	first := rg.re.FindIndex(fileMatchBuf)
 	if first == nil {
-		return nil, false, nil
+		return nil, false, false, nil
 	}
 
+	matches, limitHit, err = findLines(fileBuf, fileMatchBuf, first[0], rg.re.FindAllIndex, rg.lineMatchLimit)
+	return matches, limitHit, len(matches) > 0, err
+}
+
+// findLines returns a LineMatch for each line of fileBuf with matches found
+// by findAll, up to limit. fileMatchBuf is the (possibly transformed) data to
+// run findAll on, and first is the offset of the first match in it.
+func findLines(fileBuf, fileMatchBuf []byte, first int, findAll func(b []byte, n int) [][]int, limit int) (matches []protocol.LineMatch, limitHit bool, err error) {
 	idx := 0
-	for i := 0; len(matches) < maxLineMatches; i++ {
+	for i := 0; len(matches) < limit; i++ {
 		advance, lineBuf, err := bufio.ScanLines(fileBuf, true)
 		if err != nil {
 			// ScanLines should never return an err
@@ -238,7 +363,7 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 
 		// Check whether we're before the first match.
 		idx += advance
-		if idx < first[0] {
+		if idx < first {
 			continue
 		}
 
@@ -247,7 +372,7 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 			continue
 		}
 
-		locs := rg.re.FindAllIndex(matchBuf, maxOffsets)
+		locs := findAll(matchBuf, maxOffsets)
 		if len(locs) > 0 {
 			lineLimitHit := len(locs) == maxOffsets
 			offsetAndLengths := make([][2]int, len(locs))
@@ -271,27 +396,127 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 			})
 		}
 	}
-	limitHit = len(matches) == maxLineMatches
+	limitHit = len(matches) == limit
 	return matches, limitHit, nil
 }
 
-// FindZip is a convenience function to run Find on f.
-func (rg *readerGrep) FindZip(zf *store.ZipFile, f *store.SrcFile) (protocol.FileMatch, error) {
-	lm, limitHit, err := rg.Find(zf, f)
+// findRanges returns a LineMatch for each line of fileBuf on which one of
+// locs (the byte offsets of matches, sorted by offset) starts, up to limit.
+// Matches may span lines: OffsetAndLengths are clipped to the end of the line
+// the match starts on, and Ranges holds the full extent of each match.
+func findRanges(fileBuf []byte, locs [][]int, limit int) (matches []protocol.LineMatch, limitHit bool) {
+	if len(locs) == limit*maxOffsets {
+		limitHit = true
+	}
+
+	var (
+		lineNumber = 0
+		lineStart  = 0
+		cur        *protocol.LineMatch
+	)
+	for _, loc := range locs {
+		start, end := loc[0], loc[1]
+
+		// Advance to the line containing start.
+		for {
+			nl := bytes.IndexByte(fileBuf[lineStart:], '\n')
+			if nl < 0 || lineStart+nl >= start {
+				break
+			}
+			lineStart += nl + 1
+			lineNumber++
+		}
+		lineEnd := len(fileBuf)
+		if nl := bytes.IndexByte(fileBuf[lineStart:], '\n'); nl >= 0 {
+			lineEnd = lineStart + nl
+		}
+		if lineEnd-lineStart > maxLineSize {
+			continue
+		}
+
+		if cur == nil || cur.LineNumber != lineNumber {
+			if len(matches) == limit {
+				limitHit = true
+				break
+			}
+			matches = append(matches, protocol.LineMatch{
+				Preview:    string(fileBuf[lineStart:lineEnd]),
+				LineNumber: lineNumber,
+			})
+			cur = &matches[len(matches)-1]
+		}
+		if len(cur.OffsetAndLengths) == maxOffsets {
+			cur.LimitHit = true
+			continue
+		}
+		line := fileBuf[lineStart:lineEnd]
+		lineMatchEnd := end
+		if lineMatchEnd > lineEnd {
+			lineMatchEnd = lineEnd
+		}
+		offset := utf8.RuneCount(line[:start-lineStart])
+		length := utf8.RuneCount(line[start-lineStart : lineMatchEnd-lineStart])
+		cur.OffsetAndLengths = append(cur.OffsetAndLengths, [2]int{offset, length})
+		cur.Ranges = append(cur.Ranges, protocol.Range{
+			Start: protocol.Location{Line: lineNumber, Column: offset},
+			End:   location(fileBuf, lineNumber, lineStart, end),
+		})
+	}
+	if len(matches) == limit {
+		limitHit = true
+	}
+	return matches, limitHit
+}
+
+// location returns the Location of offset in fileBuf. offset must be on the
+// line lineNumber, which starts at lineStart, or on a later line.
+func location(fileBuf []byte, lineNumber, lineStart, offset int) protocol.Location {
+	for {
+		nl := bytes.IndexByte(fileBuf[lineStart:offset], '\n')
+		if nl < 0 {
+			break
+		}
+		lineStart += nl + 1
+		lineNumber++
+	}
+	return protocol.Location{Line: lineNumber, Column: utf8.RuneCount(fileBuf[lineStart:offset])}
+}
+
+// FindZip is a convenience function to run Find on f. It also returns
+// whether the content of f matches (see find).
+func (rg *readerGrep) FindZip(zf *store.ZipFile, f *store.SrcFile) (protocol.FileMatch, bool, error) {
+	lm, limitHit, match, err := rg.find(zf, f)
+	if rg.countOnly {
+		return protocol.FileMatch{
+			Path:       f.Name,
+			MatchCount: len(lm),
+			LimitHit:   limitHit,
+		}, match, err
+	}
 	return protocol.FileMatch{
 		Path:        f.Name,
 		LineMatches: lm,
 		LimitHit:    limitHit,
-	}, err
+	}, match, err
 }
 
 // concurrentFind searches files in zr looking for matches using rg.
-func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool) (fm []protocol.FileMatch, limitHit bool, err error) {
+//
+// If onMatch is non-nil, it is called with each match as soon as it is found
+// instead of collecting the matches into the returned slice (which will then
+// be empty). onMatch is never called concurrently.
+func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool, onMatch func(protocol.FileMatch)) (fm []protocol.FileMatch, limitHit bool, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "ConcurrentFind")
 	ext.Component.Set(span, "matcher")
 	if rg.re != nil {
 		span.SetTag("re", rg.re.String())
 	}
+	if rg.structural != nil {
+		span.SetTag("structural", rg.structural.String())
+	}
+	if rg.boolean != nil {
+		span.SetTag("boolean", rg.boolean.String())
+	}
 	span.SetTag("path", rg.matchPath.String())
 	defer func() {
 		if err != nil {
@@ -305,7 +530,11 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 		patternMatchesContent = true
 	}
 
-	if fileMatchLimit > maxFileMatches || fileMatchLimit <= 0 {
+	if rg.countOnly {
+		// The matches of a count are small, so the number of files with
+		// matches isn't limited.
+		fileMatchLimit = math.MaxInt32
+	} else if fileMatchLimit > maxFileMatches || fileMatchLimit <= 0 {
 		fileMatchLimit = maxFileMatches
 	}
 
@@ -322,30 +551,59 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 	defer cancel()
 
 	var (
-		filesmu   sync.Mutex // protects files
-		files     = zf.Files
-		matchesmu sync.Mutex // protects matches, limitHit
-		matches   = []protocol.FileMatch{}
+		filesmu    sync.Mutex // protects files
+		files      = zf.Files
+		matchesmu  sync.Mutex // protects matchCount, limitHit
+		matches    = []protocol.FileMatch{}
+		matchCount int
 	)
 
-	if patternMatchesPaths && (!patternMatchesContent || rg.re == nil) {
+	// reserveMatch returns false if fileMatchLimit has been reached, so that
+	// the next match must be dropped. The caller must hold matchesmu.
+	reserveMatch := func() bool {
+		if matchCount >= fileMatchLimit {
+			return false
+		}
+		matchCount++
+		return true
+	}
+
+	// addMatch passes fm to onMatch, or records it. It must not be called
+	// concurrently.
+	addMatch := func(fm protocol.FileMatch) {
+		if onMatch != nil {
+			onMatch(fm)
+		} else {
+			matches = append(matches, fm)
+		}
+	}
+
+	if patternMatchesPaths && (!patternMatchesContent || (rg.re == nil && rg.structural == nil && rg.boolean == nil)) {
 		// Fast path for only matching file paths (or with a nil pattern, which matches all files,
 		// so is effectively matching only on file paths).
 		for _, f := range files {
 			if rg.matchPath.MatchPath(f.Name) && rg.matchString(f.Name) {
-				if len(matches) < fileMatchLimit {
-					matches = append(matches, protocol.FileMatch{Path: f.Name})
-				} else {
+				if !reserveMatch() {
 					limitHit = true
 					break
 				}
+				addMatch(protocol.FileMatch{Path: f.Name})
 			}
 		}
 		return matches, limitHit, nil
 	}
 
+	// candidates (if non-nil) is whether each file of zf.Files may match the
+	// content pattern, according to the trigram index of zf.
+	var candidates []bool
+	if rg.re != nil {
+		candidates = rg.trigramCandidates(zf)
+		span.SetTag("trigramIndex", candidates != nil)
+	}
+
 	var (
 		done          = ctx.Done()
+		matchc        = make(chan protocol.FileMatch)
 		wg            sync.WaitGroup
 		wgErrOnce     sync.Once
 		wgErr         error
@@ -353,7 +611,7 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 		filesSearched uint32 // accessed atomically
 	)
 
-	// Start workers. They read from files and write to matches.
+	// Start workers. They read from files and send their matches to matchc.
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
 		go func(rg *readerGrep) {
@@ -373,6 +631,7 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 					filesmu.Unlock()
 					return
 				}
+				i := len(zf.Files) - len(files)
 				f := &files[0]
 				files = files[1:]
 				filesmu.Unlock()
@@ -385,16 +644,21 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 				atomic.AddUint32(&filesSearched, 1)
 
 				// process
-				var fm protocol.FileMatch
-				fm, err := rg.FindZip(zf, f)
-				if err != nil {
-					wgErrOnce.Do(func() {
-						wgErr = err
-						cancel()
-					})
-					return
+				var (
+					fm    protocol.FileMatch
+					match bool
+				)
+				if candidates == nil || candidates[i] {
+					var err error
+					fm, match, err = rg.FindZip(zf, f)
+					if err != nil {
+						wgErrOnce.Do(func() {
+							wgErr = err
+							cancel()
+						})
+						return
+					}
 				}
-				match := len(fm.LineMatches) > 0
 				if !match && patternMatchesPaths {
 					// Try matching against the file path.
 					match = rg.matchString(f.Name)
@@ -404,19 +668,30 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 				}
 				if match {
 					matchesmu.Lock()
-					if len(matches) < fileMatchLimit {
-						matches = append(matches, fm)
-					} else {
+					reserved := reserveMatch()
+					if !reserved {
 						limitHit = true
 						cancel()
 					}
 					matchesmu.Unlock()
+					if reserved {
+						matchc <- fm
+					}
 				}
 			}
 		}(rg.Copy())
 	}
 
-	wg.Wait()
+	// Add the matches as they arrive. This way onMatch, which may be slow
+	// (e.g. write to the network), is called serially without blocking the
+	// workers on matchesmu.
+	go func() {
+		wg.Wait()
+		close(matchc)
+	}()
+	for fm := range matchc {
+		addMatch(fm)
+	}
 
 	err = wgErr
 	if err == nil && ctx.Err() == context.DeadlineExceeded {
@@ -264,7 +264,7 @@ func benchConcurrentFind(b *testing.B, p *protocol.Request) {
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
-		_, _, err := concurrentFind(ctx, rg, zf, 0, p.PatternMatchesContent, p.PatternMatchesPath)
+		_, _, err := concurrentFind(ctx, rg, zf, 0, p.PatternMatchesContent, p.PatternMatchesPath, nil)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -466,6 +466,50 @@ func TestLineLimit(t *testing.T) {
 	}
 }
 
+func TestMultiline(t *testing.T) {
+	data := []byte("func a() {\n}\n\nfunc b() { return }\n// éé func c() {}\n")
+	zf := &store.ZipFile{MaxLen: len(data), Data: data}
+	f := &store.SrcFile{Len: int32(len(data))}
+	rng := func(startLine, startColumn, endLine, endColumn int) protocol.Range {
+		return protocol.Range{
+			Start: protocol.Location{Line: startLine, Column: startColumn},
+			End:   protocol.Location{Line: endLine, Column: endColumn},
+		}
+	}
+
+	rg, err := compile(&protocol.PatternInfo{Pattern: `FUNC \w+\(\)\s*\{\s*\}`, IsRegExp: true, IsMultiline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, limitHit, err := rg.Find(zf, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limitHit {
+		t.Fatal("unexpected limitHit")
+	}
+	want := []protocol.LineMatch{
+		{Preview: "func a() {", LineNumber: 0, OffsetAndLengths: [][2]int{{0, 10}}, Ranges: []protocol.Range{rng(0, 0, 1, 1)}},
+		{Preview: "// éé func c() {}", LineNumber: 4, OffsetAndLengths: [][2]int{{6, 11}}, Ranges: []protocol.Range{rng(4, 6, 4, 17)}},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("got %+v, want %+v", matches, want)
+	}
+
+	// Without IsMultiline, patterns are matched line by line.
+	rg, err = compile(&protocol.PatternInfo{Pattern: `FUNC \w+\(\)\s*\{\s*\}`, IsRegExp: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, _, err = rg.Find(zf, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].LineNumber != 4 || matches[0].Ranges != nil {
+		t.Errorf("got %+v, want a single match on line 4 without ranges", matches)
+	}
+}
+
 func TestMaxMatches(t *testing.T) {
 	pattern := "foo"
 
@@ -501,7 +545,7 @@ func TestMaxMatches(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	fileMatches, limitHit, err := concurrentFind(context.Background(), rg, zf, 0, true, false)
+	fileMatches, limitHit, err := concurrentFind(context.Background(), rg, zf, 0, true, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -530,6 +574,63 @@ func TestMaxMatches(t *testing.T) {
 	}
 }
 
+func TestMaxMatches_countOnly(t *testing.T) {
+	pattern := "foo"
+
+	// Create a zip archive which contains more files and lines than the
+	// limits of searches that return matches.
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for i := 0; i < maxFileMatches+1; i++ {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   strconv.Itoa(i),
+			Method: zip.Store,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < maxLineMatches+1; j++ {
+			w.Write([]byte(pattern))
+			w.Write([]byte{'\n'})
+		}
+	}
+	err := zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := store.MockZipFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{Pattern: pattern, CountOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMatches, limitHit, err := concurrentFind(context.Background(), rg, zf, 0, true, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limitHit {
+		t.Fatalf("expected no limitHit on concurrentFind")
+	}
+
+	if len(fileMatches) != maxFileMatches+1 {
+		t.Fatalf("expected %d file matches, got %d", maxFileMatches+1, len(fileMatches))
+	}
+	for _, fm := range fileMatches {
+		if fm.LimitHit {
+			t.Fatalf("expected no limitHit on file match")
+		}
+		if fm.MatchCount != maxLineMatches+1 {
+			t.Fatalf("expected match count %d, got %d", maxLineMatches+1, fm.MatchCount)
+		}
+		if len(fm.LineMatches) != 0 {
+			t.Fatalf("expected no line matches, got %d", len(fm.LineMatches))
+		}
+	}
+}
+
 // Tests that:
 //
 // - IncludePatterns can match the path in any order
@@ -561,7 +662,7 @@ func TestPathMatches(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, true)
+	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
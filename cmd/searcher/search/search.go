@@ -24,6 +24,7 @@ import (
 	log15 "gopkg.in/inconshreveable/log15.v2"
 
 	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/store"
 
 	"github.com/pkg/errors"
@@ -49,7 +50,9 @@ func init() {
 
 // ServeHTTP handles HTTP based search requests
 func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	// The frontend tells us which actor the search is on behalf of, so that
+	// gitserver can attribute the archive fetch to it.
+	ctx := actor.WithActor(r.Context(), actor.FromHTTPHeader(r.Header))
 	running.Inc()
 	defer running.Dec()
 
@@ -85,17 +88,14 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	matches, limitHit, deadlineHit, err := s.search(ctx, &p)
+	if p.Stream {
+		s.serveStream(ctx, w, &p)
+		return
+	}
+
+	matches, limitHit, deadlineHit, err := s.search(ctx, &p, nil)
 	if err != nil {
-		code := http.StatusInternalServerError
-		if isBadRequest(err) || ctx.Err() == context.Canceled {
-			code = http.StatusBadRequest
-		} else if isTemporary(err) {
-			code = http.StatusServiceUnavailable
-		} else {
-			log.Printf("internal error serving %#+v: %s", p, err)
-		}
-		http.Error(w, err.Error(), code)
+		writeSearchError(ctx, w, &p, err)
 		return
 	}
 	if matches == nil {
@@ -116,7 +116,80 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(&resp)
 }
 
-func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []protocol.FileMatch, limitHit, deadlineHit bool, err error) {
+// serveStream responds to p with newline-delimited protocol.StreamEvents,
+// flushing each FileMatch as soon as it is found.
+func (s *Service) serveStream(ctx context.Context, w http.ResponseWriter, p *protocol.Request) {
+	var (
+		enc     = json.NewEncoder(w)
+		flusher = func() {}
+		started bool
+	)
+	if f, ok := w.(http.Flusher); ok {
+		flusher = f.Flush
+	}
+	start := func() {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", protocol.StreamContentType)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+
+	// onMatch is called serially by concurrentFind. Similar to the
+	// non-streaming response, the only reasonable encoding error is the
+	// client going away, in which case ctx will be canceled and the search
+	// stops.
+	onMatch := func(fm protocol.FileMatch) {
+		start()
+		_ = enc.Encode(&protocol.StreamEvent{Match: &fm})
+		flusher()
+	}
+
+	_, limitHit, deadlineHit, err := s.search(ctx, p, onMatch)
+	if err != nil && !started {
+		// Nothing has been written yet, so we can still report the error
+		// with an appropriate status code.
+		writeSearchError(ctx, w, p, err)
+		return
+	}
+
+	start()
+	trailer := protocol.StreamTrailer{
+		LimitHit:    limitHit,
+		DeadlineHit: deadlineHit,
+	}
+	if err != nil {
+		trailer.Error = err.Error()
+	}
+	_ = enc.Encode(&protocol.StreamEvent{Done: &trailer})
+	flusher()
+}
+
+// writeSearchError writes err as the HTTP response for a failed search of p.
+func writeSearchError(ctx context.Context, w http.ResponseWriter, p *protocol.Request, err error) {
+	code := http.StatusInternalServerError
+	if isBadRequest(err) || ctx.Err() == context.Canceled {
+		code = http.StatusBadRequest
+	} else if isTemporary(err) {
+		code = http.StatusServiceUnavailable
+	} else {
+		log.Printf("internal error serving %#+v: %s", p, err)
+	}
+	http.Error(w, err.Error(), code)
+}
+
+// search runs p. If onMatch is non-nil, matches are passed to it as they
+// are found rather than returned. See concurrentFind.
+func (s *Service) search(ctx context.Context, p *protocol.Request, onMatch func(protocol.FileMatch)) (matches []protocol.FileMatch, limitHit, deadlineHit bool, err error) {
+	matchCount := 0
+	if onMatch != nil {
+		send := onMatch
+		onMatch = func(fm protocol.FileMatch) {
+			matchCount++
+			send(fm)
+		}
+	}
+
 	tr := trace.New("search", fmt.Sprintf("%s@%s", p.Repo, p.Commit))
 	tr.LazyPrintf("%s", p.Pattern)
 
@@ -126,8 +199,14 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	span.SetTag("url", p.URL)
 	span.SetTag("commit", p.Commit)
 	span.SetTag("pattern", p.Pattern)
+	if p.PatternExpr != "" {
+		span.SetTag("patternExpr", p.PatternExpr)
+	}
 	span.SetTag("isRegExp", strconv.FormatBool(p.IsRegExp))
+	span.SetTag("isStructuralPat", strconv.FormatBool(p.IsStructuralPat))
 	span.SetTag("isWordMatch", strconv.FormatBool(p.IsWordMatch))
+	span.SetTag("isMultiline", strconv.FormatBool(p.IsMultiline))
+	span.SetTag("countOnly", strconv.FormatBool(p.CountOnly))
 	span.SetTag("isCaseSensitive", strconv.FormatBool(p.IsCaseSensitive))
 	span.SetTag("pathPatternsAreRegExps", strconv.FormatBool(p.PathPatternsAreRegExps))
 	span.SetTag("pathPatternsAreCaseSensitive", strconv.FormatBool(p.PathPatternsAreCaseSensitive))
@@ -135,7 +214,11 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	span.SetTag("patternMatchesContent", p.PatternMatchesContent)
 	span.SetTag("patternMatchesPath", p.PatternMatchesPath)
 	span.SetTag("deadline", p.Deadline)
+	span.SetTag("stream", p.Stream)
 	defer func(start time.Time) {
+		if onMatch == nil {
+			matchCount = len(matches)
+		}
 		code := "200"
 		// We often have canceled and timed out requests. We do not want to
 		// record them as errors to avoid noise
@@ -160,15 +243,15 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 				code = "500"
 			}
 		}
-		tr.LazyPrintf("code=%s matches=%d limitHit=%v deadlineHit=%v", code, len(matches), limitHit, deadlineHit)
+		tr.LazyPrintf("code=%s matches=%d limitHit=%v deadlineHit=%v", code, matchCount, limitHit, deadlineHit)
 		tr.Finish()
 		requestTotal.WithLabelValues(code).Inc()
-		span.LogFields(otlog.Int("matches.len", len(matches)))
+		span.LogFields(otlog.Int("matches.len", matchCount))
 		span.SetTag("limitHit", limitHit)
 		span.SetTag("deadlineHit", deadlineHit)
 		span.Finish()
 		if s.Log != nil {
-			s.Log.Debug("search request", "repo", p.Repo, "commit", p.Commit, "pattern", p.Pattern, "isRegExp", p.IsRegExp, "isWordMatch", p.IsWordMatch, "isCaseSensitive", p.IsCaseSensitive, "patternMatchesContent", p.PatternMatchesContent, "patternMatchesPath", p.PatternMatchesPath, "matches", len(matches), "code", code, "duration", time.Since(start), "err", err)
+			s.Log.Debug("search request", "repo", p.Repo, "commit", p.Commit, "pattern", p.Pattern, "isRegExp", p.IsRegExp, "isStructuralPat", p.IsStructuralPat, "isWordMatch", p.IsWordMatch, "isMultiline", p.IsMultiline, "countOnly", p.CountOnly, "isCaseSensitive", p.IsCaseSensitive, "patternMatchesContent", p.PatternMatchesContent, "patternMatchesPath", p.PatternMatchesPath, "stream", p.Stream, "matches", matchCount, "code", code, "duration", time.Since(start), "err", err)
 		}
 	}(time.Now())
 
@@ -211,7 +294,7 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	archiveFiles.Observe(float64(nFiles))
 	archiveSize.Observe(float64(bytes))
 
-	matches, limitHit, err = concurrentFind(ctx, rg, zf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath)
+	matches, limitHit, err = concurrentFind(ctx, rg, zf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath, onMatch)
 	return matches, limitHit, false, err
 }
 
@@ -223,7 +306,7 @@ func validateParams(p *protocol.Request) error {
 	if len(p.Commit) != 40 {
 		return errors.Errorf("Commit must be resolved (Commit=%q)", p.Commit)
 	}
-	if p.Pattern == "" && p.ExcludePattern == "" && len(p.IncludePatterns) == 0 && p.IncludePattern == "" {
+	if p.Pattern == "" && p.PatternExpr == "" && p.ExcludePattern == "" && len(p.IncludePatterns) == 0 && p.IncludePattern == "" {
 		return errors.New("At least one of pattern and include/exclude pattners must be non-empty")
 	}
 	return nil
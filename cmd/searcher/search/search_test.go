@@ -127,6 +127,15 @@ main.go:6:	fmt.Println("Hello world")
 `},
 		{protocol.PatternInfo{Pattern: "world", IncludePattern: `\.(MD|go)`, PathPatternsAreRegExps: true, PathPatternsAreCaseSensitive: true}, `
 main.go:6:	fmt.Println("Hello world")
+`},
+
+		{protocol.PatternInfo{Pattern: "fmt.Println(:[args])", IsStructuralPat: true}, `
+main.go:6:	fmt.Println("Hello world")
+`},
+		{protocol.PatternInfo{Pattern: "fmt.println(:[args])", IsStructuralPat: true}, ""},
+
+		{protocol.PatternInfo{Pattern: `main\(\) \{\s+fmt`, IsRegExp: true, IsMultiline: true}, `
+main.go:5:func main() {
 `},
 
 		{protocol.PatternInfo{Pattern: "doesnotmatch"}, ""},
@@ -155,27 +164,36 @@ milton.png
 			PatternInfo:  test.arg,
 			FetchTimeout: "500ms",
 		}
-		m, err := doSearch(ts.URL, &req)
-		if err != nil {
-			t.Errorf("%v failed: %s", test.arg, err)
-			continue
-		}
-		sort.Sort(sortByPath(m))
-		got := toString(m)
-		err = sanityCheckSorted(m)
-		if err != nil {
-			t.Errorf("%v malformed response: %s\n%s", test.arg, err, got)
-		}
 		// We have an extra newline to make expected readable
 		if len(test.want) > 0 {
 			test.want = test.want[1:]
 		}
-		if got != test.want {
-			d, err := diff(test.want, got)
+		failed := false
+		for _, stream := range []bool{false, true} {
+			req.Stream = stream
+			m, err := doSearch(ts.URL, &req)
 			if err != nil {
-				t.Fatal(err)
+				t.Errorf("%v stream=%v failed: %s", test.arg, stream, err)
+				failed = true
+				break
+			}
+			sort.Sort(sortByPath(m))
+			got := toString(m)
+			err = sanityCheckSorted(m)
+			if err != nil {
+				t.Errorf("%v stream=%v malformed response: %s\n%s", test.arg, stream, err, got)
+			}
+			if got != test.want {
+				d, err := diff(test.want, got)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Errorf("%v stream=%v unexpected response:\n%s", test.arg, stream, d)
+				failed = true
+				break
 			}
-			t.Errorf("%v unexpected response:\n%s", test.arg, d)
+		}
+		if failed {
 			continue
 		}
 
@@ -183,7 +201,7 @@ milton.png
 		if !test.arg.PathPatternsAreRegExps && (len(test.arg.IncludePatterns) > 0 || test.arg.IncludePattern != "" || test.arg.ExcludePattern != "") {
 			continue
 		}
-		if test.arg.IsWordMatch {
+		if test.arg.IsWordMatch || test.arg.IsStructuralPat || test.arg.IsMultiline {
 			continue
 		}
 
@@ -203,7 +221,7 @@ milton.png
 		sort.Slice(sr.Files, func(i, j int) bool {
 			return sr.Files[i].Path < sr.Files[j].Path
 		})
-		got = toStringNew(sr)
+		got := toStringNew(sr)
 		if got != test.want {
 			d, err := diff(test.want, got)
 			if err != nil {
@@ -323,12 +341,15 @@ func TestSearch_badrequest(t *testing.T) {
 
 	for _, p := range cases {
 		p.PatternInfo.PatternMatchesContent = true
-		_, err := doSearch(ts.URL, &p)
-		if err == nil {
-			t.Fatalf("%v expected to fail", p)
-		}
-		if !strings.HasPrefix(err.Error(), "non-200 response: code=400 ") {
-			t.Fatalf("%v expected to have HTTP 400 response. Got %s", p, err)
+		for _, stream := range []bool{false, true} {
+			p.Stream = stream
+			_, err := doSearch(ts.URL, &p)
+			if err == nil {
+				t.Fatalf("%v expected to fail", p)
+			}
+			if !strings.HasPrefix(err.Error(), "non-200 response: code=400 ") {
+				t.Fatalf("%v expected to have HTTP 400 response. Got %s", p, err)
+			}
 		}
 	}
 }
@@ -346,6 +367,9 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	if p.IsRegExp {
 		form.Set("IsRegExp", "true")
 	}
+	if p.IsStructuralPat {
+		form.Set("IsStructuralPat", "true")
+	}
 	if p.IsWordMatch {
 		form.Set("IsWordMatch", "true")
 	}
@@ -364,6 +388,9 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	if p.PatternMatchesPath {
 		form.Set("PatternMatchesPath", "true")
 	}
+	if p.Stream {
+		form.Set("Stream", "true")
+	}
 	resp, err := http.PostForm(u, form)
 	if err != nil {
 		return nil, err
@@ -377,6 +404,10 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 		return nil, fmt.Errorf("non-200 response: code=%d body=%s", resp.StatusCode, string(body))
 	}
 
+	if p.Stream {
+		return decodeStream(resp.Header.Get("Content-Type"), body)
+	}
+
 	var r protocol.Response
 	err = json.Unmarshal(body, &r)
 	if err != nil {
@@ -385,6 +416,41 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	return r.Matches, err
 }
 
+func decodeStream(contentType string, body []byte) ([]protocol.FileMatch, error) {
+	if contentType != protocol.StreamContentType {
+		return nil, fmt.Errorf("unexpected Content-Type for stream: %q", contentType)
+	}
+	var (
+		matches []protocol.FileMatch
+		done    bool
+	)
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for dec.More() {
+		var ev protocol.StreamEvent
+		if err := dec.Decode(&ev); err != nil {
+			return nil, err
+		}
+		if done {
+			return nil, errors.New("stream has events after the trailer")
+		}
+		switch {
+		case ev.Match != nil:
+			matches = append(matches, *ev.Match)
+		case ev.Done != nil:
+			if ev.Done.Error != "" {
+				return nil, errors.New(ev.Done.Error)
+			}
+			done = true
+		default:
+			return nil, errors.New("stream event has no fields set")
+		}
+	}
+	if !done {
+		return nil, errors.New("stream ended without a trailer")
+	}
+	return matches, nil
+}
+
 func newStore(files map[string]string) (*store.Store, func(), error) {
 	buf := new(bytes.Buffer)
 	w := tar.NewWriter(buf)
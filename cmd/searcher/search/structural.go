@@ -0,0 +1,338 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+)
+
+// This file implements structural search. A structural pattern is a
+// comby-style match template (the same syntax accepted by cmd/replacer's
+// MatchTemplate), for example
+//
+//   foo(:[args])
+//
+// The template is made up of literal text, whitespace and holes. Literal text
+// must match exactly. A run of whitespace matches any run of whitespace in the
+// file. A hole :[name] matches any text in which the delimiters (), [] and {}
+// are balanced and which does not split a string literal. Such a hole only
+// spans lines inside of delimiters. A hole :[[name]]
+// only matches identifier characters. Holes with the same name (other than
+// the anonymous hole :[_]) must match the same text.
+
+// maxStructuralSteps bounds the amount of work we do matching a template
+// against a single file. Holes make matching quadratic in the worst case, so
+// we give up on a file (and report that the limit was hit) instead of
+// stalling a search worker.
+const maxStructuralSteps = 10 * 1000 * 1000
+
+type templatePartKind int
+
+const (
+	partLiteral templatePartKind = iota
+	partSpace
+	partHole
+)
+
+type templatePart struct {
+	kind templatePartKind
+
+	// value is the text to match for partLiteral, or the hole name for
+	// partHole.
+	value []byte
+
+	// word is true for :[[name]] holes, which only match identifier
+	// characters.
+	word bool
+
+	// nested is true for holes which appear inside of delimiters opened
+	// earlier in the template, such as the hole in foo(:[args]).
+	nested bool
+}
+
+// structuralMatcher matches a parsed match template. It is immutable and
+// safe to use concurrently.
+type structuralMatcher struct {
+	template string
+	parts    []templatePart
+
+	// literal is the longest literal part of the template. Every match
+	// contains it, so it is used to cheaply skip files.
+	literal []byte
+}
+
+// compileStructural parses the match template. Leading and trailing
+// whitespace in the template is ignored.
+func compileStructural(template string) (*structuralMatcher, error) {
+	t := bytes.TrimSpace([]byte(template))
+	if len(t) == 0 {
+		return nil, badRequestError{"structural pattern must be non-empty"}
+	}
+
+	m := &structuralMatcher{template: template}
+	depth := 0 // delimiters opened by literals so far
+	for i := 0; i < len(t); {
+		switch {
+		case isSpace(t[i]):
+			for i < len(t) && isSpace(t[i]) {
+				i++
+			}
+			m.parts = append(m.parts, templatePart{kind: partSpace})
+
+		case bytes.HasPrefix(t[i:], []byte(":[")):
+			part, n, err := parseHole(t[i:])
+			if err != nil {
+				return nil, err
+			}
+			part.nested = depth > 0
+			m.parts = append(m.parts, part)
+			i += n
+
+		default:
+			start := i
+			for i < len(t) && !isSpace(t[i]) && !bytes.HasPrefix(t[i:], []byte(":[")) {
+				i++
+			}
+			for _, c := range t[start:i] {
+				switch c {
+				case '(', '[', '{':
+					depth++
+				case ')', ']', '}':
+					depth--
+				}
+			}
+			m.parts = append(m.parts, templatePart{kind: partLiteral, value: t[start:i]})
+			if i-start > len(m.literal) {
+				m.literal = t[start:i]
+			}
+		}
+	}
+	return m, nil
+}
+
+// parseHole parses the hole at the start of t. It returns the hole and the
+// number of bytes it occupies in t.
+func parseHole(t []byte) (templatePart, int, error) {
+	openDelim, closeDelim, word := ":[", "]", false
+	if bytes.HasPrefix(t, []byte(":[[")) {
+		openDelim, closeDelim, word = ":[[", "]]", true
+	}
+	end := bytes.Index(t[len(openDelim):], []byte(closeDelim))
+	if end < 0 {
+		return templatePart{}, 0, badRequestError{fmt.Sprintf("unterminated hole in structural pattern: %q", t)}
+	}
+	name := t[len(openDelim) : len(openDelim)+end]
+	if len(name) == 0 {
+		return templatePart{}, 0, badRequestError{"hole in structural pattern must be named (use :[_] for an anonymous hole)"}
+	}
+	for _, c := range name {
+		if !isWordByte(c) {
+			return templatePart{}, 0, badRequestError{fmt.Sprintf("invalid hole name in structural pattern: %q", name)}
+		}
+	}
+	return templatePart{kind: partHole, value: name, word: word}, len(openDelim) + end + len(closeDelim), nil
+}
+
+func (m *structuralMatcher) String() string {
+	return "structural(" + m.template + ")"
+}
+
+// structuralMatch is the state for matching a template against one file.
+type structuralMatch struct {
+	m     *structuralMatcher
+	buf   []byte
+	env   map[string][]byte
+	steps int
+}
+
+// errStructuralSteps is used to unwind matching once maxStructuralSteps has
+// been reached.
+var errStructuralSteps = fmt.Errorf("structural search exceeded %d steps", maxStructuralSteps)
+
+// FindAllIndex returns the [start, end) byte ranges of successive
+// non-overlapping matches of the template in buf, returning at most n
+// matches. limitHit is true if matching was abandoned because it was taking
+// too long.
+func (m *structuralMatcher) FindAllIndex(buf []byte, n int) (locs [][]int, limitHit bool) {
+	s := &structuralMatch{m: m, buf: buf}
+	for start := 0; start <= len(buf) && len(locs) < n; {
+		// Fast path: if the template starts with a literal, jump to its
+		// next occurrence.
+		if first := m.parts[0]; first.kind == partLiteral {
+			idx := bytes.Index(buf[start:], first.value)
+			if idx < 0 {
+				break
+			}
+			start += idx
+		} else if start < len(buf) && isSpace(buf[start]) {
+			// Matches do not start with whitespace.
+			start++
+			continue
+		}
+
+		end, ok, err := s.matchAt(0, start)
+		if err != nil {
+			return locs, true
+		}
+		if ok && end > start {
+			locs = append(locs, []int{start, end})
+			start = end
+		} else {
+			start++
+		}
+	}
+	return locs, false
+}
+
+// matchAt reports whether m.parts[i:] matches buf at pos, and if so where the
+// match ends.
+func (s *structuralMatch) matchAt(i, pos int) (int, bool, error) {
+	s.steps++
+	if s.steps > maxStructuralSteps {
+		return 0, false, errStructuralSteps
+	}
+	if i == len(s.m.parts) {
+		return pos, true, nil
+	}
+
+	p := s.m.parts[i]
+	switch p.kind {
+	case partLiteral:
+		if !bytes.HasPrefix(s.buf[pos:], p.value) {
+			return 0, false, nil
+		}
+		return s.matchAt(i+1, pos+len(p.value))
+
+	case partSpace:
+		end := pos
+		for end < len(s.buf) && isSpace(s.buf[end]) {
+			end++
+		}
+		// Whitespace may only be omitted where it does not join two
+		// identifiers, so that "func :[name]" does not match "funcfoo".
+		if end == pos && pos > 0 && pos < len(s.buf) && isWordByte(s.buf[pos-1]) && isWordByte(s.buf[pos]) {
+			return 0, false, nil
+		}
+		return s.matchAt(i+1, end)
+
+	default: // partHole
+		name := string(p.value)
+		if bound, ok := s.env[name]; ok {
+			if !bytes.HasPrefix(s.buf[pos:], bound) {
+				return 0, false, nil
+			}
+			return s.matchAt(i+1, pos+len(bound))
+		}
+		return s.matchHole(i, pos)
+	}
+}
+
+// matchHole tries each possible extent of the hole m.parts[i] starting at
+// pos, shortest first, and returns the first one for which the rest of the
+// template matches.
+func (s *structuralMatch) matchHole(i, pos int) (int, bool, error) {
+	p := s.m.parts[i]
+	name := string(p.value)
+	try := func(end int) (int, bool, error) {
+		if name != "_" {
+			if s.env == nil {
+				s.env = map[string][]byte{}
+			}
+			s.env[name] = s.buf[pos:end]
+			defer delete(s.env, name)
+		}
+		return s.matchAt(i+1, end)
+	}
+
+	var closers []byte // stack of expected closing delimiters
+	for e := pos; ; {
+		if len(closers) == 0 && (!p.word || e > pos) {
+			end, ok, err := try(e)
+			if err != nil || ok {
+				return end, ok, err
+			}
+		}
+		if e >= len(s.buf) {
+			return 0, false, nil
+		}
+
+		c := s.buf[e]
+		if p.word {
+			if !isWordByte(c) {
+				return 0, false, nil
+			}
+			e++
+			continue
+		}
+		switch c {
+		case '(':
+			closers = append(closers, ')')
+		case '[':
+			closers = append(closers, ']')
+		case '{':
+			closers = append(closers, '}')
+		case ')', ']', '}':
+			if len(closers) == 0 || closers[len(closers)-1] != c {
+				// A hole never contains an unbalanced closing delimiter.
+				return 0, false, nil
+			}
+			closers = closers[:len(closers)-1]
+		case '\n':
+			if len(closers) == 0 && !p.nested {
+				// Outside of delimiters a hole does not span lines.
+				return 0, false, nil
+			}
+		case '"', '`':
+			if end := skipString(s.buf, e); end > e {
+				e = end
+				continue
+			}
+		}
+		e++
+	}
+}
+
+// skipString returns the index just past the string literal starting at
+// buf[start], or start if there is no terminated string literal there.
+// Double quoted strings may contain backslash escapes and may not span lines.
+func skipString(buf []byte, start int) int {
+	quote := buf[start]
+	for i := start + 1; i < len(buf); i++ {
+		switch buf[i] {
+		case quote:
+			return i + 1
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case '\n':
+			if quote == '"' {
+				return start
+			}
+		}
+	}
+	return start
+}
+
+// findStructural returns a LineMatch for each line on which a match of the
+// template starts (see findRanges).
+func (rg *readerGrep) findStructural(fileBuf []byte) (matches []protocol.LineMatch, limitHit bool) {
+	if !bytes.Contains(fileBuf, rg.structural.literal) {
+		return nil, false
+	}
+	locs, limitHit := rg.structural.FindAllIndex(fileBuf, rg.lineMatchLimit*maxOffsets)
+	if len(locs) == 0 {
+		return nil, limitHit
+	}
+	matches, rangesLimitHit := findRanges(fileBuf, locs, rg.lineMatchLimit)
+	return matches, limitHit || rangesLimitHit
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/store"
+)
+
+func TestStructuralFindAllIndex(t *testing.T) {
+	cases := []struct {
+		template string
+		input    string
+		want     []string
+	}{
+		{
+			template: "foo(:[args])",
+			input:    `x := foo(a, b(c)) + foo() ; foo(")")`,
+			want:     []string{"foo(a, b(c))", "foo()", `foo(")")`},
+		},
+		{
+			// Holes never contain unbalanced delimiters.
+			template: "foo(:[args])",
+			input:    "foo(a, b(c)",
+			want:     nil,
+		},
+		{
+			// Whitespace in the template matches any whitespace, and holes
+			// only span lines inside of delimiters.
+			template: "func :[name]() {}",
+			input:    "func a() {}\nfunc bb() {\n}\nfuncx() {}",
+			want:     []string{"func a() {}"},
+		},
+		{
+			template: "foo(:[args])",
+			input:    "foo(\n\ta,\n\tb,\n)",
+			want:     []string{"foo(\n\ta,\n\tb,\n)"},
+		},
+		{
+			// Holes with the same name must match the same text.
+			template: "if :[x] == :[x] {",
+			input:    "if a == a { if a == b {",
+			want:     []string{"if a == a {"},
+		},
+		{
+			template: ":[[x]].Close()",
+			input:    "defer f.Close()\ng(x).Close()",
+			want:     []string{"f.Close()"},
+		},
+	}
+	for _, tc := range cases {
+		m, err := compileStructural(tc.template)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.template, err)
+		}
+		locs, limitHit := m.FindAllIndex([]byte(tc.input), 100)
+		if limitHit {
+			t.Errorf("%q: unexpected limitHit", tc.template)
+		}
+		var got []string
+		for _, loc := range locs {
+			got = append(got, tc.input[loc[0]:loc[1]])
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q on %q: got %q, want %q", tc.template, tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestStructuralCompileError(t *testing.T) {
+	for _, template := range []string{"", "  ", "foo(:[", ":[]", ":[a b]"} {
+		_, err := compileStructural(template)
+		if err == nil {
+			t.Errorf("%q: expected error", template)
+		} else if !isBadRequest(err) {
+			t.Errorf("%q: expected bad request error, got %v", template, err)
+		}
+	}
+}
+
+func TestStructuralFind(t *testing.T) {
+	rg, err := compile(&protocol.PatternInfo{Pattern: "foo(:[args])", IsStructuralPat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("a := foo(1)\nb := FOO(2)\nc := foo(\n\t3,\n) + foo(4)\n")
+	zf := &store.ZipFile{MaxLen: len(data), Data: data}
+	matches, limitHit, err := rg.Find(zf, &store.SrcFile{Len: int32(len(data))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limitHit {
+		t.Fatal("unexpected limitHit")
+	}
+	want := []protocol.LineMatch{
+		{Preview: "a := foo(1)", LineNumber: 0, OffsetAndLengths: [][2]int{{5, 6}}, Ranges: []protocol.Range{{Start: protocol.Location{Line: 0, Column: 5}, End: protocol.Location{Line: 0, Column: 11}}}},
+		// A match spanning lines is clipped to the line it starts on, but
+		// its range spans them.
+		{Preview: "c := foo(", LineNumber: 2, OffsetAndLengths: [][2]int{{5, 4}}, Ranges: []protocol.Range{{Start: protocol.Location{Line: 2, Column: 5}, End: protocol.Location{Line: 4, Column: 1}}}},
+		{Preview: ") + foo(4)", LineNumber: 4, OffsetAndLengths: [][2]int{{4, 6}}, Ranges: []protocol.Range{{Start: protocol.Location{Line: 4, Column: 4}, End: protocol.Location{Line: 4, Column: 10}}}},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("got %+v, want %+v", matches, want)
+	}
+	if rg.matchString("foo(x).go") {
+		t.Error("structural patterns should not match paths")
+	}
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"regexp"
+	"regexp/syntax"
+
+	"github.com/sourcegraph/sourcegraph/pkg/store"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// indexLiterals returns the literal strings which every match of re
+// contains, for looking up candidate files in a trigram index (see
+// store.TrigramIndex). Literals shorter than a trigram are omitted.
+func indexLiterals(re *regexp.Regexp) []string {
+	ast, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	var lits []string
+	for _, lit := range requiredLiterals(ast.Simplify()) {
+		if len(lit) >= 3 {
+			lits = append(lits, lit)
+		}
+	}
+	return lits
+}
+
+// requiredLiterals returns literal strings which every match of re contains.
+// Unlike longestLiteral, it returns all of them.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		// The index only folds ASCII case, but (?i) also folds some
+		// non-ASCII runes (such as the Kelvin sign K into k).
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil
+		}
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var lits []string
+		for _, sub := range re.Sub {
+			lits = append(lits, requiredLiterals(sub)...)
+		}
+		return lits
+	}
+	return nil
+}
+
+// trigramCandidates returns whether each file in zf.Files may contain a match
+// of rg, according to the trigram index of zf. It returns nil if all files
+// need to be searched, because zf has no trigram index (yet) or rg has no
+// literals to look up.
+func (rg *readerGrep) trigramCandidates(zf *store.ZipFile) []bool {
+	if len(rg.indexLiterals) == 0 {
+		trigramIndexSearches.WithLabelValues("unsupported").Inc()
+		return nil
+	}
+	idx := zf.TrigramIndex()
+	if idx == nil {
+		trigramIndexSearches.WithLabelValues("miss").Inc()
+		return nil
+	}
+	trigramIndexSearches.WithLabelValues("hit").Inc()
+
+	candidates := idx.Candidates(rg.indexLiterals)
+	var n int
+	for _, ok := range candidates {
+		if ok {
+			n++
+		}
+	}
+	trigramIndexFiles.WithLabelValues("candidate").Add(float64(n))
+	trigramIndexFiles.WithLabelValues("skipped").Add(float64(len(candidates) - n))
+	return candidates
+}
+
+var (
+	trigramIndexSearches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "searcher",
+		Subsystem: "service",
+		Name:      "trigram_index_searches",
+		Help:      "Number of content searches by whether a trigram index was used (hit), the archive is not indexed (miss) or the pattern has no literals to look up (unsupported).",
+	}, []string{"result"})
+	trigramIndexFiles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "searcher",
+		Subsystem: "service",
+		Name:      "trigram_index_files",
+		Help:      "Number of files of searches using a trigram index that are candidates (searched) or skipped.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(trigramIndexSearches)
+	prometheus.MustRegister(trigramIndexFiles)
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/store"
+)
+
+func TestIndexLiterals(t *testing.T) {
+	tests := []struct {
+		pattern string
+		p       protocol.PatternInfo
+		want    []string
+	}{
+		{pattern: "foo", want: []string{"foo"}},
+		{pattern: "fo", want: nil},
+		{pattern: "Foo.Bar", want: []string{"foo.bar"}},
+		{pattern: "Foo.Bar", p: protocol.PatternInfo{IsRegExp: true}, want: []string{"foo", "bar"}},
+		{pattern: "Foo.Bar", p: protocol.PatternInfo{IsRegExp: true, IsCaseSensitive: true}, want: []string{"Foo", "Bar"}},
+		{pattern: "foo(bar)+baz?", p: protocol.PatternInfo{IsRegExp: true}, want: []string{"foo", "bar"}},
+		{pattern: "foo|bar", p: protocol.PatternInfo{IsRegExp: true}, want: nil},
+		{pattern: "(?i)foo", p: protocol.PatternInfo{IsRegExp: true, IsCaseSensitive: true}, want: nil},
+		{pattern: "func", p: protocol.PatternInfo{IsWordMatch: true}, want: []string{"func"}},
+	}
+	for _, test := range tests {
+		p := test.p
+		p.Pattern = test.pattern
+		rg, err := compile(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rg.indexLiterals, test.want) {
+			t.Errorf("%q (%+v): got literals %q, want %q", test.pattern, test.p, rg.indexLiterals, test.want)
+		}
+	}
+}
+
+func TestConcurrentFind_trigramIndex(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go":    "package main\n\nfunc Foo() {}\n",
+		"b.go":    "package main\n\nvar foobar = 1\n",
+		"foo.txt": "hello world\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pattern               string
+		patternMatchesContent bool
+		patternMatchesPath    bool
+		want                  []string
+	}{
+		{pattern: "foo", patternMatchesContent: true, want: []string{"a.go", "b.go"}},
+		{pattern: "foo", patternMatchesContent: true, patternMatchesPath: true, want: []string{"a.go", "b.go", "foo.txt"}},
+		{pattern: "func foo\\(", patternMatchesContent: true, want: []string{"a.go"}},
+		{pattern: "hello|foobar", patternMatchesContent: true, want: []string{"b.go", "foo.txt"}},
+	}
+	for _, indexed := range []bool{false, true} {
+		zf, err := store.MockZipFile(zipData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if indexed {
+			if err := store.MockTrigramIndex(zf); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, test := range tests {
+			rg, err := compile(&protocol.PatternInfo{Pattern: test.pattern, IsRegExp: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, test.patternMatchesContent, test.patternMatchesPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, fm := range fileMatches {
+				got = append(got, fm.Path)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%q (indexed=%v): got file matches %v, want %v", test.pattern, indexed, got, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package symbols
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// This file implements "search-based" code intelligence: the identifier at a
+// position is resolved to candidate definitions by looking it up in the
+// symbols database, and to candidate references by searching for it as a
+// whole word with searcher (see Service.SearchReferences).
+
+// defaultPositionFirst is the number of results returned by definitions and
+// references requests that do not specify First.
+const defaultPositionFirst = 100
+
+func (s *Service) handleDefinitions(w http.ResponseWriter, r *http.Request) {
+	var args protocol.PositionArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.definitions(r.Context(), args)
+	if err != nil {
+		if err == context.Canceled && r.Context().Err() == context.Canceled {
+			return // client went away
+		}
+		log15.Error("Symbol definitions failed", "args", args, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Service) handleReferences(w http.ResponseWriter, r *http.Request) {
+	var args protocol.PositionArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.references(r.Context(), args)
+	if err != nil {
+		if err == context.Canceled && r.Context().Err() == context.Canceled {
+			return // client went away
+		}
+		log15.Error("Symbol references failed", "args", args, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Service) definitions(ctx context.Context, args protocol.PositionArgs) (result *protocol.DefinitionsResult, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "definitions")
+	span.SetTag("repo", args.Repo)
+	span.SetTag("commitID", args.CommitID)
+	span.SetTag("path", args.Path)
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+
+	identifier, err := s.identifierAtPosition(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	result = &protocol.DefinitionsResult{Identifier: identifier}
+	if identifier == "" {
+		return result, nil
+	}
+	span.SetTag("identifier", identifier)
+
+	dbFile, err := s.getDBFile(ctx, protocol.SearchArgs{Repo: args.Repo, CommitID: args.CommitID})
+	if err != nil {
+		return nil, err
+	}
+	db, err := sqlx.Open("sqlite3_with_pcre", dbFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	symbols, err := filterSymbols(ctx, db, protocol.SearchArgs{
+		Query:           "^" + regexp.QuoteMeta(identifier) + "$",
+		IsCaseSensitive: true,
+		First:           -1, // as many as filterSymbols allows, so that we rank every candidate
+	})
+	if err != nil {
+		return nil, err
+	}
+	rankDefinitions(symbols, args.Path)
+	if first := positionFirst(args); len(symbols) > first {
+		symbols = symbols[:first]
+	}
+	result.Symbols = symbols
+	return result, nil
+}
+
+func (s *Service) references(ctx context.Context, args protocol.PositionArgs) (result *protocol.ReferencesResult, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "references")
+	span.SetTag("repo", args.Repo)
+	span.SetTag("commitID", args.CommitID)
+	span.SetTag("path", args.Path)
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+
+	if s.SearchReferences == nil {
+		return nil, errors.New("references are not supported because no searcher is configured")
+	}
+
+	identifier, err := s.identifierAtPosition(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	result = &protocol.ReferencesResult{Identifier: identifier}
+	if identifier == "" {
+		return result, nil
+	}
+	span.SetTag("identifier", identifier)
+
+	result.References, result.LimitHit, err = s.SearchReferences(ctx, gitserver.Repo{Name: args.Repo}, args.CommitID, identifier, positionFirst(args))
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func positionFirst(args protocol.PositionArgs) int {
+	if args.First <= 0 {
+		return defaultPositionFirst
+	}
+	return args.First
+}
+
+// rankDefinitions sorts candidate definitions so that those most likely to be
+// the definition of an identifier used in the file at filePath come first:
+// definitions in the same file, then definitions in files with the same
+// extension (ie most likely in the same language), then all others.
+func rankDefinitions(symbols []protocol.Symbol, filePath string) {
+	rank := func(s protocol.Symbol) int {
+		switch {
+		case s.Path == filePath:
+			return 0
+		case path.Ext(s.Path) == path.Ext(filePath):
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return rank(symbols[i]) < rank(symbols[j])
+	})
+}
+
+// identifierAtPosition fetches the file in args and returns the identifier at
+// the position in args, or "" if there is none.
+func (s *Service) identifierAtPosition(ctx context.Context, args protocol.PositionArgs) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parseRequests, errCh, err := s.fetchRepositoryArchive(ctx, args.Repo, args.CommitID, []string{args.Path})
+	if err != nil {
+		return "", err
+	}
+	var data []byte
+	for req := range parseRequests {
+		if req.path == args.Path {
+			data = req.data
+		}
+	}
+	if err := <-errCh; err != nil {
+		return "", err
+	}
+	return identifierAt(data, args.Line, args.Character), nil
+}
+
+// identifierAt returns the identifier at the zero-based line and character
+// (in Unicode code points) in data, or "" if there is none. A position just
+// after the end of an identifier also refers to the identifier.
+func identifierAt(data []byte, line, character int) string {
+	if line < 0 || character < 0 {
+		return ""
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	if line >= len(lines) {
+		return ""
+	}
+	runes := []rune(string(lines[line]))
+
+	isIdentifierRune := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	pos := character
+	if pos >= len(runes) || !isIdentifierRune(runes[pos]) {
+		pos--
+	}
+	if pos < 0 || pos >= len(runes) || !isIdentifierRune(runes[pos]) {
+		return ""
+	}
+
+	start, end := pos, pos+1
+	for start > 0 && isIdentifierRune(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isIdentifierRune(runes[end]) {
+		end++
+	}
+	if unicode.IsDigit(runes[start]) {
+		// A number, not an identifier.
+		return ""
+	}
+	return string(runes[start:end])
+}
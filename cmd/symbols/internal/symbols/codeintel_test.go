@@ -0,0 +1,56 @@
+package symbols
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+func TestIdentifierAt(t *testing.T) {
+	data := []byte("package main\n\nfunc fooBar(x int) int { return x + 42 }\nvar héllo = 1\n")
+	tests := []struct {
+		line, character int
+		want            string
+	}{
+		{0, 0, "package"},
+		{0, 3, "package"},
+		{0, 7, "package"}, // just after the identifier
+		{0, 8, "main"},
+		{1, 0, ""},
+		{2, 5, "fooBar"},
+		{2, 10, "fooBar"},
+		{2, 12, "x"},
+		{2, 36, ""}, // a number
+		{3, 6, "héllo"},
+		{3, 9, "héllo"},
+		{3, 10, ""},
+		{2, 100, ""},
+		{100, 0, ""},
+		{-1, 0, ""},
+		{0, -1, ""},
+	}
+	for _, test := range tests {
+		if got := identifierAt(data, test.line, test.character); got != test.want {
+			t.Errorf("identifierAt(%d, %d): got %q, want %q", test.line, test.character, got, test.want)
+		}
+	}
+}
+
+func TestRankDefinitions(t *testing.T) {
+	symbols := []protocol.Symbol{
+		{Name: "a", Path: "lib.py"},
+		{Name: "b", Path: "other.go"},
+		{Name: "c", Path: "main.go"},
+		{Name: "d", Path: "README"},
+		{Name: "e", Path: "main.go"},
+	}
+	rankDefinitions(symbols, "main.go")
+	var got []string
+	for _, s := range symbols {
+		got = append(got, s.Name)
+	}
+	if want := []string{"c", "e", "b", "a", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
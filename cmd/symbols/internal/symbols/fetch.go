@@ -19,7 +19,9 @@ type parseRequest struct {
 	data []byte
 }
 
-func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoName, commitID api.CommitID) (<-chan parseRequest, <-chan error, error) {
+// fetchRepositoryArchive fetches the files of repo@commitID to parse. If
+// paths is non-empty only those files are fetched.
+func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoName, commitID api.CommitID, paths []string) (<-chan parseRequest, <-chan error, error) {
 	fetchQueueSize.Inc()
 	s.fetchSem <- 1 // acquire concurrent fetches semaphore
 	fetchQueueSize.Dec()
@@ -29,6 +31,7 @@ func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoName,
 	ext.Component.Set(span, "store")
 	span.SetTag("repo", repo)
 	span.SetTag("commit", commitID)
+	span.SetTag("paths", len(paths))
 
 	requestCh := make(chan parseRequest, s.NumParserProcesses)
 	errCh := make(chan error, 1)
@@ -59,11 +62,22 @@ func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoName,
 		span.Finish()
 	}
 
-	r, err := s.FetchTar(ctx, gitserver.Repo{Name: repo}, commitID)
+	r, err := s.FetchTar(ctx, gitserver.Repo{Name: repo}, commitID, paths)
 	if err != nil {
+		done(err)
 		return nil, nil, err
 	}
 
+	// git archive interprets paths as pathspecs, so it may include more
+	// files than we asked for.
+	var wantPath map[string]bool
+	if len(paths) > 0 {
+		wantPath = make(map[string]bool, len(paths))
+		for _, p := range paths {
+			wantPath[p] = true
+		}
+	}
+
 	// After this point we are not allowed to return an error. Instead we can
 	// return an error via the errChan we return. If you do want to update this
 	// code please ensure we still always call done once.
@@ -91,6 +105,9 @@ func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoName,
 			if path.Ext(hdr.Name) == ".json" {
 				continue
 			}
+			if wantPath != nil && !wantPath[hdr.Name] {
+				continue
+			}
 
 			// We only care about files
 			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
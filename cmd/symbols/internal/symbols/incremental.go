@@ -0,0 +1,206 @@
+package symbols
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+const (
+	// maxIncrementalAncestors is the number of ancestors of a commit we look
+	// at for a database to index the commit incrementally.
+	maxIncrementalAncestors = 20
+
+	// maxIncrementalPaths is the number of changed paths above which we
+	// index a commit from scratch. Past this point it is usually cheaper to
+	// stream the whole archive than to update the ancestor's database.
+	maxIncrementalPaths = 1000
+)
+
+// Changes are the paths that changed between two commits.
+type Changes struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// ParseGitDiffNameStatus parses the output of `git diff -z --name-status A B`
+// into the paths that changed between A and B. A renamed or copied path is
+// treated as a deletion (for renames) of the old path and an addition of the
+// new path.
+func ParseGitDiffNameStatus(out []byte) (Changes, error) {
+	var changes Changes
+	fields := bytes.Split(bytes.TrimSuffix(out, []byte{0}), []byte{0})
+	if len(out) == 0 {
+		fields = nil
+	}
+	for i := 0; i < len(fields); i++ {
+		status := fields[i]
+		if len(status) == 0 {
+			return Changes{}, fmt.Errorf("unexpected empty status in git diff output")
+		}
+		if i+1 >= len(fields) {
+			return Changes{}, fmt.Errorf("missing path for status %q in git diff output", status)
+		}
+		path := string(fields[i+1])
+		i++
+
+		switch status[0] {
+		case 'A':
+			changes.Added = append(changes.Added, path)
+		case 'M', 'T':
+			changes.Modified = append(changes.Modified, path)
+		case 'D':
+			changes.Deleted = append(changes.Deleted, path)
+		case 'R', 'C':
+			if i+1 >= len(fields) {
+				return Changes{}, fmt.Errorf("missing destination path for status %q in git diff output", status)
+			}
+			if status[0] == 'R' {
+				changes.Deleted = append(changes.Deleted, path)
+			}
+			changes.Added = append(changes.Added, string(fields[i+1]))
+			i++
+		default:
+			return Changes{}, fmt.Errorf("unexpected status %q in git diff output", status)
+		}
+	}
+	return changes, nil
+}
+
+// writeSymbolsIncrementally creates the database for repo@commitID in the
+// blank database file dbFile by copying the database of the nearest ancestor
+// of commitID that is already in the cache, and then reparsing only the files
+// that changed since that ancestor.
+//
+// It returns false if incremental indexing is not possible (eg no ancestor is
+// cached or too many files changed), in which case the caller should index
+// all files. If it returns an error dbFile may have been partially written.
+func (s *Service) writeSymbolsIncrementally(ctx context.Context, dbFile string, repoName api.RepoName, commitID api.CommitID) (ok bool, err error) {
+	if s.ListAncestors == nil || s.GitDiff == nil {
+		return false, nil
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "writeSymbolsIncrementally")
+	span.SetTag("repo", string(repoName))
+	span.SetTag("commit", string(commitID))
+	defer func() {
+		span.SetTag("ok", ok)
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			incrementalIndexes.WithLabelValues("error").Inc()
+		} else if ok {
+			incrementalIndexes.WithLabelValues("success").Inc()
+		} else {
+			incrementalIndexes.WithLabelValues("skipped").Inc()
+		}
+		span.Finish()
+	}()
+
+	repo := gitserver.Repo{Name: repoName}
+	ancestors, err := s.ListAncestors(ctx, repo, commitID, maxIncrementalAncestors)
+	if err != nil {
+		return false, err
+	}
+	var (
+		base     api.CommitID
+		baseFile *os.File
+	)
+	for _, ancestor := range ancestors {
+		f, err := s.cache.OpenIfExists(dbCacheKey(repoName, ancestor))
+		if err == nil {
+			base, baseFile = ancestor, f.File
+			break
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	if baseFile == nil {
+		return false, nil
+	}
+	defer baseFile.Close()
+	span.SetTag("base", string(base))
+
+	changes, err := s.GitDiff(ctx, repo, base, commitID)
+	if err != nil {
+		return false, err
+	}
+	paths := append(append([]string{}, changes.Added...), changes.Modified...)
+	if len(paths)+len(changes.Deleted) > maxIncrementalPaths {
+		return false, nil
+	}
+	span.LogFields(otlog.Int("added", len(changes.Added)), otlog.Int("modified", len(changes.Modified)), otlog.Int("deleted", len(changes.Deleted)))
+
+	if err := copyFile(dbFile, baseFile); err != nil {
+		return false, err
+	}
+
+	db, err := sqlx.Open("sqlite3_with_pcre", dbFile)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Modified files are reparsed, so their old symbols are deleted along
+	// with the symbols of deleted files.
+	for _, removed := range [][]string{changes.Modified, changes.Deleted} {
+		for _, path := range removed {
+			if _, err := tx.Exec(`DELETE FROM symbols WHERE path = ?`, path); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if len(paths) > 0 {
+		if err := s.writeSymbols(ctx, tx, repoName, commitID, paths); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// copyFile overwrites the file at dst with the contents of src.
+func copyFile(dst string, src io.Reader) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var incrementalIndexes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "symbols",
+	Subsystem: "store",
+	Name:      "incremental_indexes",
+	Help:      "The total number of attempts to index a commit incrementally, by outcome.",
+}, []string{"outcome"})
+
+func init() {
+	prometheus.MustRegister(incrementalIndexes)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"regexp/syntax"
 	"strings"
 	"time"
@@ -116,8 +117,20 @@ func (s *Service) search(ctx context.Context, args protocol.SearchArgs) (result
 // specified in `args`. If the database doesn't already exist in the disk cache,
 // it will create a new one and write all the symbols into it.
 func (s *Service) getDBFile(ctx context.Context, args protocol.SearchArgs) (string, error) {
-	diskcacheFile, err := s.cache.OpenWithPath(ctx, fmt.Sprintf("%d-%s@%s", symbolsDBVersion, args.Repo, args.CommitID), func(fetcherCtx context.Context, tempDBFile string) error {
-		err := s.writeAllSymbolsToNewDB(fetcherCtx, tempDBFile, args.Repo, args.CommitID)
+	diskcacheFile, err := s.cache.OpenWithPath(ctx, dbCacheKey(args.Repo, args.CommitID), func(fetcherCtx context.Context, tempDBFile string) error {
+		ok, err := s.writeSymbolsIncrementally(fetcherCtx, tempDBFile, args.Repo, args.CommitID)
+		if ok {
+			return nil
+		}
+		if err != nil {
+			log15.Warn("Unable to index repository symbols incrementally, indexing all files", "repo", args.Repo, "commit", args.CommitID, "error", err)
+			// Discard whatever the incremental attempt wrote.
+			if err := os.Truncate(tempDBFile, 0); err != nil {
+				return err
+			}
+		}
+
+		err = s.writeAllSymbolsToNewDB(fetcherCtx, tempDBFile, args.Repo, args.CommitID)
 		if err != nil {
 			if err == context.Canceled {
 				log15.Error("Unable to parse repository symbols within the context", "repo", args.Repo, "commit", args.CommitID, "query", args.Query)
@@ -134,6 +147,12 @@ func (s *Service) getDBFile(ctx context.Context, args protocol.SearchArgs) (stri
 	return diskcacheFile.File.Name(), err
 }
 
+// dbCacheKey is the key of the sqlite3 database for repo@commitID in the disk
+// cache.
+func dbCacheKey(repo api.RepoName, commitID api.CommitID) string {
+	return fmt.Sprintf("%d-%s@%s", symbolsDBVersion, repo, commitID)
+}
+
 // isLiteralEquality checks if the given regex matches literal strings exactly.
 // Returns whether or not the regex is exact, along with the literal string if
 // so.
@@ -208,6 +227,19 @@ func filterSymbols(ctx context.Context, db *sqlx.DB, args protocol.SearchArgs) (
 		conditions = append(conditions, makeCondition("path", includePattern)...)
 	}
 	conditions = append(conditions, negateAll(makeCondition("path", args.ExcludePattern))...)
+	if len(args.Kinds) > 0 {
+		conditions = append(conditions, sqlf.Sprintf("kindlowercase IN (%s)", joinLowercase(args.Kinds)))
+	}
+	if len(args.Containers) > 0 {
+		if args.IsCaseSensitive {
+			conditions = append(conditions, sqlf.Sprintf("parent IN (%s)", join(args.Containers)))
+		} else {
+			conditions = append(conditions, sqlf.Sprintf("parentlowercase IN (%s)", joinLowercase(args.Containers)))
+		}
+	}
+	if len(args.Languages) > 0 {
+		conditions = append(conditions, sqlf.Sprintf("languagelowercase IN (%s)", joinLowercase(args.Languages)))
+	}
 
 	var sqlQuery *sqlf.Query
 	if len(conditions) == 0 {
@@ -230,44 +262,68 @@ func filterSymbols(ctx context.Context, db *sqlx.DB, args protocol.SearchArgs) (
 	return res, nil
 }
 
+// join returns a query for the comma separated list of values.
+func join(values []string) *sqlf.Query {
+	queries := make([]*sqlf.Query, 0, len(values))
+	for _, v := range values {
+		queries = append(queries, sqlf.Sprintf("%s", v))
+	}
+	return sqlf.Join(queries, ",")
+}
+
+// joinLowercase is like join, but lowercases each value.
+func joinLowercase(values []string) *sqlf.Query {
+	lowercase := make([]string, len(values))
+	for i, v := range values {
+		lowercase[i] = strings.ToLower(v)
+	}
+	return join(lowercase)
+}
+
 // The version of the symbols database schema. This is included in the database
 // filenames to prevent a newer version of the symbols service from attempting
 // to read from a database created by an older (and likely incompatible) symbols
 // service. Increment this when you change the database schema.
-const symbolsDBVersion = 2
+const symbolsDBVersion = 3
 
-// symbolInDB is the same as `protocol.Symbol`, but with two additional columns:
-// namelowercase and pathlowercase, which enable indexed case insensitive
-// queries.
+// symbolInDB is the same as `protocol.Symbol`, but with additional lowercase
+// columns (namelowercase, pathlowercase, etc.), which enable indexed case
+// insensitive queries.
 type symbolInDB struct {
-	Name          string
-	NameLowercase string // derived from `Name`
-	Path          string
-	PathLowercase string // derived from `Path`
-	Line          int
-	Kind          string
-	Language      string
-	Parent        string
-	ParentKind    string
-	Signature     string
-	Pattern       string
+	Name              string
+	NameLowercase     string // derived from `Name`
+	Path              string
+	PathLowercase     string // derived from `Path`
+	Line              int
+	Kind              string
+	KindLowercase     string // derived from `Kind`
+	Language          string
+	LanguageLowercase string // derived from `Language`
+	Parent            string
+	ParentLowercase   string // derived from `Parent`
+	ParentKind        string
+	Signature         string
+	Pattern           string
 
 	FileLimited bool
 }
 
 func symbolToSymbolInDB(symbol protocol.Symbol) symbolInDB {
 	return symbolInDB{
-		Name:          symbol.Name,
-		NameLowercase: strings.ToLower(symbol.Name),
-		Path:          symbol.Path,
-		PathLowercase: strings.ToLower(symbol.Path),
-		Line:          symbol.Line,
-		Kind:          symbol.Kind,
-		Language:      symbol.Language,
-		Parent:        symbol.Parent,
-		ParentKind:    symbol.ParentKind,
-		Signature:     symbol.Signature,
-		Pattern:       symbol.Pattern,
+		Name:              symbol.Name,
+		NameLowercase:     strings.ToLower(symbol.Name),
+		Path:              symbol.Path,
+		PathLowercase:     strings.ToLower(symbol.Path),
+		Line:              symbol.Line,
+		Kind:              symbol.Kind,
+		KindLowercase:     strings.ToLower(symbol.Kind),
+		Language:          symbol.Language,
+		LanguageLowercase: strings.ToLower(symbol.Language),
+		Parent:            symbol.Parent,
+		ParentLowercase:   strings.ToLower(symbol.Parent),
+		ParentKind:        symbol.ParentKind,
+		Signature:         symbol.Signature,
+		Pattern:           symbol.Pattern,
 
 		FileLimited: symbol.FileLimited,
 	}
@@ -303,6 +359,7 @@ func (s *Service) writeAllSymbolsToNewDB(ctx context.Context, dbFile string, rep
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
 	// The column names are the lowercase version of fields in `symbolInDB`
 	// because sqlx lowercases struct fields by default. See
@@ -315,8 +372,11 @@ func (s *Service) writeAllSymbolsToNewDB(ctx context.Context, dbFile string, rep
 			pathlowercase VARCHAR(256) NOT NULL,
 			line INT NOT NULL,
 			kind VARCHAR(255) NOT NULL,
+			kindlowercase VARCHAR(255) NOT NULL,
 			language VARCHAR(255) NOT NULL,
+			languagelowercase VARCHAR(255) NOT NULL,
 			parent VARCHAR(255) NOT NULL,
+			parentlowercase VARCHAR(255) NOT NULL,
 			parentkind VARCHAR(255) NOT NULL,
 			signature VARCHAR(255) NOT NULL,
 			pattern VARCHAR(255) NOT NULL,
@@ -347,20 +407,29 @@ func (s *Service) writeAllSymbolsToNewDB(ctx context.Context, dbFile string, rep
 		return err
 	}
 
-	insertStatement, err := tx.PrepareNamed(
-		fmt.Sprintf(
-			"INSERT INTO symbols %s VALUES %s",
-			"( name,  namelowercase,  path,  pathlowercase,  line,  kind,  language,  parent,  parentkind,  signature,  pattern,  filelimited)",
-			"(:name, :namelowercase, :path, :pathlowercase, :line, :kind, :language, :parent, :parentkind, :signature, :pattern, :filelimited)"))
+	// The remaining indexes are for the kind, container and language
+	// filters.
+	_, err = tx.Exec(`CREATE INDEX kindlowercase_index ON symbols(kindlowercase);`)
 	if err != nil {
 		return err
 	}
 
-	err = s.parseUncached(ctx, repoName, commitID, func(symbol protocol.Symbol) error {
-		symbolInDBValue := symbolToSymbolInDB(symbol)
-		_, err := insertStatement.Exec(&symbolInDBValue)
+	_, err = tx.Exec(`CREATE INDEX languagelowercase_index ON symbols(languagelowercase);`)
+	if err != nil {
 		return err
-	})
+	}
+
+	_, err = tx.Exec(`CREATE INDEX parent_index ON symbols(parent);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`CREATE INDEX parentlowercase_index ON symbols(parentlowercase);`)
+	if err != nil {
+		return err
+	}
+
+	err = s.writeSymbols(ctx, tx, repoName, commitID, nil)
 	if err != nil {
 		return err
 	}
@@ -372,3 +441,25 @@ func (s *Service) writeAllSymbolsToNewDB(ctx context.Context, dbFile string, rep
 
 	return nil
 }
+
+// writeSymbols parses the symbols in repo@commit and inserts them into the
+// symbols table. If paths is non-empty only the symbols in those files are
+// inserted.
+func (s *Service) writeSymbols(ctx context.Context, tx *sqlx.Tx, repoName api.RepoName, commitID api.CommitID, paths []string) error {
+	insertStatement, err := tx.PrepareNamed(
+		fmt.Sprintf(
+			"INSERT INTO symbols %s VALUES %s",
+			"( name,  namelowercase,  path,  pathlowercase,  line,  kind,  kindlowercase,  language,  languagelowercase,  parent,  parentlowercase,  parentkind,  signature,  pattern,  filelimited)",
+			"(:name, :namelowercase, :path, :pathlowercase, :line, :kind, :kindlowercase, :language, :languagelowercase, :parent, :parentlowercase, :parentkind, :signature, :pattern, :filelimited)"))
+	if err != nil {
+		return err
+	}
+
+	defer insertStatement.Close()
+
+	return s.parseUncached(ctx, repoName, commitID, paths, func(symbol protocol.Symbol) error {
+		symbolInDBValue := symbolToSymbolInDB(symbol)
+		_, err := insertStatement.Exec(&symbolInDBValue)
+		return err
+	})
+}
@@ -3,12 +3,15 @@ package symbols
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"testing"
 
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/testutil"
 	log15 "gopkg.in/inconshreveable/log15.v2"
@@ -21,7 +24,9 @@ func BenchmarkSearch(b *testing.B) {
 	log15.Root().SetHandler(log15.LvlFilterHandler(log15.LvlError, log15.Root().GetHandler()))
 
 	service := Service{
-		FetchTar: testutil.FetchTarFromGithub,
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			return testutil.FetchTarFromGithub(ctx, repo, commit)
+		},
 		NewParser: func() (ctags.Parser, error) {
 			return ctags.NewParser(ctagsCommand)
 		},
@@ -13,14 +13,30 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/diskcache"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
 )
 
 // Service is the symbols service.
 type Service struct {
 	// FetchTar returns an io.ReadCloser to a tar archive of a repository at the specified Git
-	// remote URL and commit ID. If the error implements "BadRequest() bool", it will be used to
-	// determine if the error is a bad request (eg invalid repo).
-	FetchTar func(context.Context, gitserver.Repo, api.CommitID) (io.ReadCloser, error)
+	// remote URL and commit ID. If paths is non-empty, the archive only needs to contain those
+	// paths. If the error implements "BadRequest() bool", it will be used to determine if the
+	// error is a bad request (eg invalid repo).
+	FetchTar func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error)
+
+	// ListAncestors returns up to n ancestors of commit, nearest first. The
+	// database of the nearest ancestor that is already in the cache is used
+	// to index commit incrementally. It is optional.
+	ListAncestors func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, n int) ([]api.CommitID, error)
+
+	// GitDiff returns the paths that changed between two commits. It is
+	// required for incremental indexing (see ListAncestors).
+	GitDiff func(ctx context.Context, repo gitserver.Repo, commitA, commitB api.CommitID) (Changes, error)
+
+	// SearchReferences returns up to limit occurrences of identifier as a
+	// whole word in the repository at commit. It is used to find candidate
+	// references; if it is nil, references requests fail.
+	SearchReferences func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, identifier string, limit int) (refs []protocol.Reference, limitHit bool, err error)
 
 	// MaxConcurrentFetchTar is the maximum number of concurrent calls allowed
 	// to FetchTar. It defaults to 15.
@@ -81,6 +97,8 @@ func (s *Service) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/definitions", s.handleDefinitions)
+	mux.HandleFunc("/references", s.handleReferences)
 	mux.HandleFunc("/healthz", s.handleHealthCheck)
 
 	return mux
@@ -13,6 +13,7 @@ import (
 	"path"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 
@@ -83,11 +84,14 @@ func TestService(t *testing.T) {
 
 	files := map[string]string{"a.js": "var x = 1"}
 	service := Service{
-		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
 			return createTar(files)
 		},
 		NewParser: func() (ctags.Parser, error) {
-			return mockParser{"x", "y"}, nil
+			return mockParser{
+				{Name: "x", Path: "a.js", Kind: "variable", Language: "JavaScript"},
+				{Name: "y", Path: "a.js", Kind: "function", Language: "JavaScript", Parent: "X", ParentKind: "variable"},
+			}, nil
 		},
 		Path: tmpDir,
 	}
@@ -98,8 +102,8 @@ func TestService(t *testing.T) {
 	server := httptest.NewServer(service.Handler())
 	defer server.Close()
 	client := symbolsclient.Client{URL: server.URL}
-	x := protocol.Symbol{Name: "x", Path: "a.js"}
-	y := protocol.Symbol{Name: "y", Path: "a.js"}
+	x := protocol.Symbol{Name: "x", Path: "a.js", Kind: "variable", Language: "JavaScript"}
+	y := protocol.Symbol{Name: "y", Path: "a.js", Kind: "function", Language: "JavaScript", Parent: "X", ParentKind: "variable"}
 
 	tests := map[string]struct {
 		args protocol.SearchArgs
@@ -145,6 +149,34 @@ func TestService(t *testing.T) {
 			args: protocol.SearchArgs{ExcludePattern: "a.js", IsCaseSensitive: true, First: 10},
 			want: protocol.SearchResult{},
 		},
+		"kind": {
+			args: protocol.SearchArgs{Kinds: []string{"Function"}, First: 10},
+			want: protocol.SearchResult{Symbols: []protocol.Symbol{y}},
+		},
+		"kinds": {
+			args: protocol.SearchArgs{Kinds: []string{"function", "variable"}, First: 10},
+			want: protocol.SearchResult{Symbols: []protocol.Symbol{x, y}},
+		},
+		"caseinsensitivecontainer": {
+			args: protocol.SearchArgs{Containers: []string{"x"}, First: 10},
+			want: protocol.SearchResult{Symbols: []protocol.Symbol{y}},
+		},
+		"casesensitivenocontainer": {
+			args: protocol.SearchArgs{Containers: []string{"x"}, IsCaseSensitive: true, First: 10},
+			want: protocol.SearchResult{},
+		},
+		"language": {
+			args: protocol.SearchArgs{Languages: []string{"javascript"}, First: 10},
+			want: protocol.SearchResult{Symbols: []protocol.Symbol{x, y}},
+		},
+		"nolanguage": {
+			args: protocol.SearchArgs{Languages: []string{"go"}, First: 10},
+			want: protocol.SearchResult{},
+		},
+		"kindandquery": {
+			args: protocol.SearchArgs{Query: "x", Kinds: []string{"function"}, First: 10},
+			want: protocol.SearchResult{},
+		},
 	}
 	for label, test := range tests {
 		t.Run(label, func(t *testing.T) {
@@ -159,6 +191,108 @@ func TestService(t *testing.T) {
 	}
 }
 
+func TestServiceIncremental(t *testing.T) {
+	MustRegisterSqlite3WithPcre()
+
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { os.RemoveAll(tmpDir) }()
+
+	// The symbol in each file is named after the contents of the file.
+	commits := map[api.CommitID]map[string]string{
+		"a": {"a.js": "a", "b.js": "b", "c.js": "c"},
+		"b": {"b.js": "b2", "c.js": "c", "d.js": "d"},
+	}
+	var fetchedPaths []string
+	service := Service{
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			files := commits[commit]
+			if len(paths) > 0 {
+				fetchedPaths = paths
+				files = map[string]string{}
+				for _, p := range paths {
+					files[p] = commits[commit][p]
+				}
+			}
+			return createTar(files)
+		},
+		ListAncestors: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, n int) ([]api.CommitID, error) {
+			if commit == "b" {
+				return []api.CommitID{"a"}, nil
+			}
+			return nil, nil
+		},
+		GitDiff: func(ctx context.Context, repo gitserver.Repo, commitA, commitB api.CommitID) (Changes, error) {
+			if commitA != "a" || commitB != "b" {
+				t.Fatalf("unexpected diff %s..%s", commitA, commitB)
+			}
+			return Changes{Added: []string{"d.js"}, Modified: []string{"b.js"}, Deleted: []string{"a.js"}}, nil
+		},
+		NewParser: func() (ctags.Parser, error) {
+			return contentsParser{}, nil
+		},
+		Path: tmpDir,
+	}
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(service.Handler())
+	defer server.Close()
+	client := symbolsclient.Client{URL: server.URL}
+
+	search := func(commit api.CommitID) []string {
+		result, err := client.Search(context.Background(), protocol.SearchArgs{CommitID: commit, First: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, s := range result.Symbols {
+			names = append(names, s.Path+":"+s.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if got, want := search("a"), []string{"a.js:a", "b.js:b", "c.js:c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if fetchedPaths != nil {
+		t.Errorf("expected commit a to be indexed from scratch, but fetched %v", fetchedPaths)
+	}
+
+	if got, want := search("b"), []string{"b.js:b2", "c.js:c", "d.js:d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if want := []string{"d.js", "b.js"}; !reflect.DeepEqual(fetchedPaths, want) {
+		t.Errorf("expected commit b to be indexed incrementally from %v, but fetched %v", want, fetchedPaths)
+	}
+}
+
+func TestParseGitDiffNameStatus(t *testing.T) {
+	out := []byte("A\x00d.js\x00M\x00b.js\x00D\x00a.js\x00R100\x00old.js\x00new.js\x00C75\x00c.js\x00e.js\x00")
+	got, err := ParseGitDiffNameStatus(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Changes{
+		Added:    []string{"d.js", "new.js", "e.js"},
+		Modified: []string{"b.js"},
+		Deleted:  []string{"a.js", "old.js"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if got, err := ParseGitDiffNameStatus(nil); err != nil || !reflect.DeepEqual(got, Changes{}) {
+		t.Errorf("got %+v, %v for empty diff", got, err)
+	}
+	if _, err := ParseGitDiffNameStatus([]byte("R100\x00old.js\x00")); err == nil {
+		t.Error("expected error for truncated rename")
+	}
+}
+
 func createTar(files map[string]string) (io.ReadCloser, error) {
 	buf := new(bytes.Buffer)
 	w := tar.NewWriter(buf)
@@ -183,14 +317,20 @@ func createTar(files map[string]string) (io.ReadCloser, error) {
 	return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
 }
 
-type mockParser []string
+type mockParser []ctags.Entry
 
 func (m mockParser) Parse(name string, content []byte) ([]ctags.Entry, error) {
-	entries := make([]ctags.Entry, len(m))
-	for i, name := range m {
-		entries[i] = ctags.Entry{Name: name, Path: "a.js"}
-	}
-	return entries, nil
+	return append([]ctags.Entry(nil), m...), nil
 }
 
 func (mockParser) Close() {}
+
+// contentsParser returns a single symbol for each file, named after the
+// contents of the file.
+type contentsParser struct{}
+
+func (contentsParser) Parse(name string, content []byte) ([]ctags.Entry, error) {
+	return []ctags.Entry{{Name: string(content), Path: name}}, nil
+}
+
+func (contentsParser) Close() {}
@@ -13,6 +13,7 @@ import (
 	"os/signal"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
@@ -49,9 +50,37 @@ func main() {
 	go debugserver.Start()
 
 	service := symbols.Service{
-		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
-			return git.Archive(ctx, repo, git.ArchiveOptions{Treeish: string(commit), Format: "tar"})
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			return git.Archive(ctx, repo, git.ArchiveOptions{Treeish: string(commit), Format: "tar", Paths: paths})
 		},
+		ListAncestors: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, n int) ([]api.CommitID, error) {
+			// rev-list includes commit itself, so ask for one more.
+			stdout, stderr, exitCode, err := git.ExecSafe(ctx, repo, []string{"rev-list", "--max-count=" + strconv.Itoa(n+1), string(commit)})
+			if err != nil {
+				return nil, err
+			}
+			if exitCode != 0 {
+				return nil, fmt.Errorf("git rev-list failed: %s", stderr)
+			}
+			var ancestors []api.CommitID
+			for _, line := range strings.Fields(string(stdout)) {
+				if line != string(commit) {
+					ancestors = append(ancestors, api.CommitID(line))
+				}
+			}
+			return ancestors, nil
+		},
+		GitDiff: func(ctx context.Context, repo gitserver.Repo, commitA, commitB api.CommitID) (symbols.Changes, error) {
+			stdout, stderr, exitCode, err := git.ExecSafe(ctx, repo, []string{"diff", "-z", "--name-status", string(commitA), string(commitB)})
+			if err != nil {
+				return symbols.Changes{}, err
+			}
+			if exitCode != 0 {
+				return symbols.Changes{}, fmt.Errorf("git diff failed: %s", stderr)
+			}
+			return symbols.ParseGitDiffNameStatus(stdout)
+		},
+		SearchReferences: searchReferences,
 		NewParser: func() (ctags.Parser, error) {
 			parser, err := ctags.NewParser(ctags.GetCommand())
 			if err != nil {
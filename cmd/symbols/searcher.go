@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context/ctxhttp"
+
+	searcherprotocol "github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/endpoint"
+	"github.com/sourcegraph/sourcegraph/pkg/env"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+var (
+	searcherURL = env.Get("SEARCHER_URL", "k8s+http://searcher:3181", "searcher server URL (used to find references)")
+
+	searcherURLsOnce sync.Once
+	searcherURLs     *endpoint.Map
+)
+
+// searchReferences finds the occurrences of identifier as a whole word in
+// repo@commit with searcher.
+func searchReferences(ctx context.Context, repo gitserver.Repo, commit api.CommitID, identifier string, limit int) ([]protocol.Reference, bool, error) {
+	searcherURLsOnce.Do(func() {
+		if len(strings.Fields(searcherURL)) == 0 {
+			searcherURLs = endpoint.Empty(errors.New("a searcher service has not been configured"))
+		} else {
+			searcherURLs = endpoint.New(searcherURL)
+		}
+	})
+
+	// Searcher caches the archive for repo@commit, so we use the same
+	// consistent hashing key as the frontend to increase cache hits.
+	u, err := searcherURLs.Get(string(repo.Name)+"@"+string(commit), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	q := url.Values{
+		"Repo":                  []string{string(repo.Name)},
+		"URL":                   []string{repo.URL},
+		"Commit":                []string{string(commit)},
+		"Pattern":               []string{identifier},
+		"IsWordMatch":           []string{"true"},
+		"IsCaseSensitive":       []string{"true"},
+		"PatternMatchesContent": []string{"true"},
+		"PatternMatchesPath":    []string{"false"},
+		"FileMatchLimit":        []string{strconv.Itoa(limit)},
+		"FetchTimeout":          []string{"10s"},
+	}
+	resp, err := ctxhttp.Get(ctx, nil, u+"?"+q.Encode())
+	if err != nil {
+		return nil, false, errors.Wrap(err, "searcher request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, false, fmt.Errorf("searcher request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var r searcherprotocol.Response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, false, errors.Wrap(err, "searcher response invalid")
+	}
+	var refs []protocol.Reference
+	limitHit := r.LimitHit
+	for _, fm := range r.Matches {
+		for _, lm := range fm.LineMatches {
+			for _, ol := range lm.OffsetAndLengths {
+				if len(refs) == limit {
+					return refs, true, nil
+				}
+				refs = append(refs, protocol.Reference{Path: fm.Path, Line: lm.LineNumber, Character: ol[0]})
+			}
+			limitHit = limitHit || lm.LimitHit
+		}
+		limitHit = limitHit || fm.LimitHit
+	}
+	return refs, limitHit, nil
+}
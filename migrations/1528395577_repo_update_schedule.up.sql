@@ -0,0 +1,12 @@
+BEGIN;
+
+CREATE TABLE repo_update_schedule (
+  repo_id integer PRIMARY KEY REFERENCES repo(id) ON DELETE CASCADE,
+  interval_seconds integer NOT NULL,
+  due_at timestamp with time zone NOT NULL,
+  last_fetched timestamp with time zone,
+  last_changed timestamp with time zone,
+  updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+COMMIT;
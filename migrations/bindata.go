@@ -216,6 +216,8 @@
 // 1528395575_.up.sql (1.662kB)
 // 1528395576_.down.sql (771B)
 // 1528395576_.up.sql (559B)
+// 1528395577_repo_update_schedule.down.sql (60B)
+// 1528395577_repo_update_schedule.up.sql (349B)
 
 package migrations
 
@@ -4604,6 +4606,46 @@ func _1528395576_UpSql() (*asset, error) {
 	return a, nil
 }
 
+var __1528395577_repo_update_scheduleDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x3c\x00\xc3\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x72\x65\x70\x6f\x5f\x75\x70\x64\x61\x74\x65\x5f\x73\x63\x68\x65\x64\x75\x6c\x65\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\xb0\xbf\x92\xc4\x3c\x00\x00\x00")
+
+func _1528395577_repo_update_scheduleDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395577_repo_update_scheduleDownSql,
+		"1528395577_repo_update_schedule.down.sql",
+	)
+}
+
+func _1528395577_repo_update_scheduleDownSql() (*asset, error) {
+	bytes, err := _1528395577_repo_update_scheduleDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395577_repo_update_schedule.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x2c, 0xaa, 0x7f, 0x73, 0x93, 0x72, 0x71, 0xe2, 0x24, 0x16, 0x69, 0xa3, 0x6b, 0x30, 0xe0, 0x65, 0x9c, 0x45, 0x81, 0x40, 0x5e, 0x47, 0xc6, 0x54, 0x97, 0x9a, 0x20, 0x4f, 0xfc, 0x94, 0x65, 0xa8}}
+	return a, nil
+}
+
+var __1528395577_repo_update_scheduleUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xcf\xb1\x6a\xc3\x30\x10\x06\xe0\x5d\x4f\x71\xa3\x0d\x7d\x03\x4f\x8a\x7c\x29\xa6\xb2\x5c\x14\x65\xc8\x24\x84\x75\x8d\x05\x8e\x6c\x2c\xb9\x81\x3e\x7d\x89\x03\x86\x0e\xa5\x1d\x8f\xfb\xfe\xe3\xbf\x03\xbe\x36\xaa\x62\x4c\x68\xe4\x06\xc1\xf0\x83\x44\x58\x68\x9e\xec\x3a\x7b\x97\xc9\xa6\x7e\x20\xbf\x8e\x04\x05\x83\xe7\x22\x78\x08\x31\xd3\x95\x16\x78\xd7\x4d\xcb\xf5\x05\xde\xf0\x02\x1a\x8f\xa8\x51\x09\x3c\x6d\xac\x08\xbe\x84\x4e\x41\x8d\x12\x0d\x82\xe0\x27\xc1\x6b\x7c\x61\xb0\x85\x97\x4f\x37\xda\x44\xfd\x14\x7d\xda\xaf\xa9\xce\x80\x3a\x4b\xf9\x40\x7e\x25\xeb\x32\xe4\x70\xa3\x94\xdd\x6d\x86\x7b\xc8\xc3\x36\xc2\xd7\x14\xe9\x87\x1d\x5d\xca\xf6\x83\xf2\xa3\xe9\xaf\x89\x1d\xf6\x83\x8b\xd7\x3f\xe0\xf3\x77\xff\xaf\x06\x50\xe3\x91\x9f\xa5\x81\x38\xdd\x8b\x92\x95\x15\x63\xa2\x6b\xdb\xc6\x54\xec\x7b\x00\x9d\x2e\xcd\x2e\x5d\x01\x00\x00")
+
+func _1528395577_repo_update_scheduleUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395577_repo_update_scheduleUpSql,
+		"1528395577_repo_update_schedule.up.sql",
+	)
+}
+
+func _1528395577_repo_update_scheduleUpSql() (*asset, error) {
+	bytes, err := _1528395577_repo_update_scheduleUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395577_repo_update_schedule.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc0, 0x68, 0x43, 0x48, 0xe0, 0xbc, 0x38, 0xfa, 0x3b, 0xbb, 0xb0, 0x49, 0x5d, 0xb0, 0x82, 0xec, 0xf4, 0x9c, 0x8, 0x24, 0x96, 0x1a, 0x7d, 0x69, 0xa4, 0xa1, 0x93, 0x91, 0x58, 0x1d, 0x20, 0xf1}}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -5126,6 +5168,10 @@ var _bindata = map[string]func() (*asset, error){
 	"1528395576_.down.sql": _1528395576_DownSql,
 
 	"1528395576_.up.sql": _1528395576_UpSql,
+
+	"1528395577_repo_update_schedule.down.sql": _1528395577_repo_update_scheduleDownSql,
+
+	"1528395577_repo_update_schedule.up.sql": _1528395577_repo_update_scheduleUpSql,
 }
 
 // AssetDir returns the file names below a certain
@@ -5385,6 +5431,8 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"1528395575_.up.sql":                                          {_1528395575_UpSql, map[string]*bintree{}},
 	"1528395576_.down.sql":                                        {_1528395576_DownSql, map[string]*bintree{}},
 	"1528395576_.up.sql":                                          {_1528395576_UpSql, map[string]*bintree{}},
+	"1528395577_repo_update_schedule.down.sql":                    {_1528395577_repo_update_scheduleDownSql, map[string]*bintree{}},
+	"1528395577_repo_update_schedule.up.sql":                      {_1528395577_repo_update_scheduleUpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory.
@@ -0,0 +1,48 @@
+package actor
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headerName is the HTTP header that internal clients use to tell internal
+// services which actor a request is made on behalf of. Its value is the UID
+// of the user, or headerValueInternal for internal actors. It is absent for
+// anonymous actors.
+//
+// Internal services must only use it for informational purposes (such as
+// logging), not to make access control decisions.
+const headerName = "X-Sourcegraph-Actor"
+
+const headerValueInternal = "internal"
+
+// SetHTTPHeader sets the header that tells an internal service which actor a
+// request to it is made on behalf of. Use FromHTTPHeader in the internal
+// service to read it.
+func SetHTTPHeader(h http.Header, a *Actor) {
+	switch {
+	case a == nil:
+		h.Del(headerName)
+	case a.Internal:
+		h.Set(headerName, headerValueInternal)
+	case a.UID != 0:
+		h.Set(headerName, a.UIDString())
+	default:
+		h.Del(headerName)
+	}
+}
+
+// FromHTTPHeader returns the actor that was set on the header by
+// SetHTTPHeader. It returns an anonymous actor if the header is absent or
+// invalid.
+func FromHTTPHeader(h http.Header) *Actor {
+	v := h.Get(headerName)
+	if v == headerValueInternal {
+		return &Actor{Internal: true}
+	}
+	uid, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return &Actor{}
+	}
+	return FromUser(int32(uid))
+}
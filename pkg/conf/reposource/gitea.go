@@ -0,0 +1,44 @@
+package reposource
+
+import (
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+type Gitea struct {
+	*schema.GiteaConnection
+}
+
+var _ RepoSource = Gitea{}
+
+func (c Gitea) CloneURLToRepoName(cloneURL string) (repoName api.RepoName, err error) {
+	parsedCloneURL, baseURL, match, err := parseURLs(cloneURL, c.Url)
+	if err != nil {
+		return "", err
+	}
+	if !match {
+		return "", nil
+	}
+
+	// Gitea may be served from a subpath (such as https://example.com/gitea), which is part of
+	// HTTP(S) clone URLs but not of SSH clone URLs.
+	nameWithOwner := strings.TrimPrefix(parsedCloneURL.Path, strings.TrimSuffix(baseURL.Path, "/"))
+	nameWithOwner = strings.TrimPrefix(strings.TrimSuffix(nameWithOwner, ".git"), "/")
+	if i := strings.Index(nameWithOwner, "/"); i <= 0 || i == len(nameWithOwner)-1 { // Not a Gitea clone URL
+		return "", nil
+	}
+	return GiteaRepoName(c.RepositoryPathPattern, baseURL.Hostname(), nameWithOwner), nil
+}
+
+func GiteaRepoName(repositoryPathPattern, host, nameWithOwner string) api.RepoName {
+	if repositoryPathPattern == "" {
+		repositoryPathPattern = "{host}/{nameWithOwner}"
+	}
+
+	return api.RepoName(strings.NewReplacer(
+		"{host}", host,
+		"{nameWithOwner}", nameWithOwner,
+	).Replace(repositoryPathPattern))
+}
@@ -0,0 +1,48 @@
+package reposource
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestGitea_cloneURLToRepoName(t *testing.T) {
+	var tests = []struct {
+		conn schema.GiteaConnection
+		urls []urlToRepoName
+	}{{
+		conn: schema.GiteaConnection{
+			Url: "https://gitea.example.com",
+		},
+		urls: []urlToRepoName{
+			{"https://gitea.example.com/myorg/myrepo.git", "gitea.example.com/myorg/myrepo"},
+			{"git@gitea.example.com:myorg/myrepo.git", "gitea.example.com/myorg/myrepo"},
+			{"ssh://git@gitea.example.com/myorg/myrepo.git", "gitea.example.com/myorg/myrepo"},
+
+			{"https://gitea.example.com/myorg", ""},
+			{"https://asdf.com/myorg/myrepo.git", ""},
+			{"git@asdf.com:myorg/myrepo.git", ""},
+		},
+	}, {
+		conn: schema.GiteaConnection{
+			Url:                   "https://example.com/gitea",
+			RepositoryPathPattern: "gitea/{nameWithOwner}",
+		},
+		urls: []urlToRepoName{
+			{"https://example.com/gitea/myorg/myrepo.git", "gitea/myorg/myrepo"},
+			{"git@example.com:myorg/myrepo.git", "gitea/myorg/myrepo"},
+		},
+	}}
+
+	for _, test := range tests {
+		for _, u := range test.urls {
+			repoName, err := Gitea{&test.conn}.CloneURLToRepoName(u.cloneURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.repoName != string(repoName) {
+				t.Errorf("expected %q but got %q for clone URL %q (connection: %+v)", u.repoName, repoName, u.cloneURL, test.conn)
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package bitbucketcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
+	"github.com/sourcegraph/sourcegraph/pkg/metrics"
+	"golang.org/x/time/rate"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var requestCounter = metrics.NewRequestCounter("bitbucket_cloud", "Total number of requests sent to the Bitbucket Cloud API.")
+
+// These fields define the self-imposed Bitbucket Cloud rate limit.
+//
+// See https://godoc.org/golang.org/x/time/rate#Limiter for an explanation of these fields.
+//
+// Bitbucket Cloud limits requests to repository endpoints to 1,000/hr per
+// user. We list 100 repositories per request, so this lets us list 50,000
+// repositories per hour with some requests to spare for repository lookups.
+const (
+	rateLimitRequestsPerSecond = 1000.0 / 3600
+	RateLimitMaxBurstRequests  = 500
+)
+
+// Client access Bitbucket Cloud via the REST API 2.0.
+type Client struct {
+	// HTTP Client used to communicate with the API
+	httpClient httpcli.Doer
+
+	// URL is the base URL of the Bitbucket Cloud API, such as
+	// https://api.bitbucket.org.
+	URL *url.URL
+
+	// The username and app password credentials for accessing the API.
+	Username, AppPassword string
+
+	// RateLimit is the self-imposed rate limiter (since Bitbucket Cloud does
+	// not report its rate limits in HTTP response headers).
+	RateLimit *rate.Limiter
+}
+
+// NewClient returns a new Bitbucket Cloud API client at url. If a nil
+// httpClient is provided, http.DefaultClient will be used. To use API methods
+// which require authentication, set the Username and AppPassword fields of
+// the returned client.
+func NewClient(url *url.URL, httpClient httpcli.Doer) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	httpClient = requestCounter.Doer(httpClient, categorize)
+
+	return &Client{
+		httpClient: httpClient,
+		URL:        url,
+		RateLimit:  rate.NewLimiter(rateLimitRequestsPerSecond, RateLimitMaxBurstRequests),
+	}
+}
+
+// Repo returns the repository with the given full name ("owner/slug").
+func (c *Client) Repo(ctx context.Context, fullName string) (*Repo, error) {
+	req, err := http.NewRequest("GET", "2.0/repositories/"+fullName, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp Repo
+	err = c.do(ctx, req, &resp)
+	return &resp, err
+}
+
+// Repos returns a page of the repositories owned by the given team or user.
+// If owner is empty, it returns a page of the repositories that the
+// authenticated user is a member of.
+//
+// A nil pageToken requests the first page. The returned PageToken is used to
+// request the next page.
+func (c *Client) Repos(ctx context.Context, pageToken *PageToken, owner string) ([]*Repo, *PageToken, error) {
+	u := "2.0/repositories?role=member&pagelen=100"
+	if owner != "" {
+		u = "2.0/repositories/" + url.PathEscape(owner) + "?pagelen=100"
+	}
+	if pageToken != nil && pageToken.Next != "" {
+		// Next is the absolute URL of the next page. We send our credentials
+		// to it, so it must be on the API host.
+		next, err := url.Parse(pageToken.Next)
+		if err != nil {
+			return nil, nil, err
+		}
+		if next.Host != c.URL.Host {
+			return nil, nil, errors.Errorf("next page URL %q is not on the API host %q", pageToken.Next, c.URL.Host)
+		}
+		u = pageToken.Next
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var resp struct {
+		PageToken
+		Values []*Repo `json:"values"`
+	}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return nil, nil, err
+	}
+	return resp.Values, &resp.PageToken, nil
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request, result interface{}) error {
+	req.URL = c.URL.ResolveReference(req.URL)
+	req.Header.Set("Accept", "application/json")
+
+	if c.Username != "" || c.AppPassword != "" {
+		req.SetBasicAuth(c.Username, c.AppPassword)
+	}
+
+	req, ht := nethttp.TraceRequest(opentracing.GlobalTracer(), req,
+		nethttp.OperationName("Bitbucket Cloud"),
+		nethttp.ClientTrace(false))
+	defer ht.Finish()
+
+	// Do not lose the context returned by TraceRequest
+	ctx = req.Context()
+
+	startWait := time.Now()
+	if err := c.RateLimit.Wait(ctx); err != nil {
+		return err
+	}
+
+	if d := time.Since(startWait); d > 200*time.Millisecond {
+		log15.Warn("Bitbucket Cloud self-enforced API rate limit: request delayed longer than expected due to rate limit", "delay", d)
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.WithStack(&httpError{URL: req.URL, StatusCode: resp.StatusCode})
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// categorize returns a category for an API URL. Used by metrics.
+func categorize(u *url.URL) string {
+	// API to URL mapping looks like this:
+	//
+	// 	Repos -> 2.0/repositories or 2.0/repositories/{owner}
+	// 	Repo -> 2.0/repositories/{owner}/{slug}
+	switch parts := strings.Split(strings.Trim(u.Path, "/"), "/"); {
+	case len(parts) < 2 || parts[1] != "repositories":
+		// don't return the path directly as that could introduce too much dimensionality
+		return "unknown"
+	case len(parts) <= 3:
+		return "Repos"
+	default:
+		return "Repo"
+	}
+}
+
+// PageToken is the pagination information of a paged Bitbucket Cloud API
+// response.
+type PageToken struct {
+	Size    int    `json:"size"`
+	Page    int    `json:"page"`
+	Pagelen int    `json:"pagelen"`
+	Next    string `json:"next"`
+}
+
+// HasMore reports whether there are more pages after the page of t. A nil
+// PageToken has more pages, because it refers to the page before the first.
+func (t *PageToken) HasMore() bool {
+	if t == nil {
+		return true
+	}
+	return t.Next != ""
+}
+
+// Repo is a Bitbucket Cloud repository.
+type Repo struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	UUID        string `json:"uuid"`
+	SCM         string `json:"scm"`
+	Description string `json:"description"`
+	Parent      *Repo  `json:"parent"`
+	IsPrivate   bool   `json:"is_private"`
+	Links       Links  `json:"links"`
+}
+
+// Links are the links of a Bitbucket Cloud repository.
+type Links struct {
+	Clone []Link `json:"clone"`
+	HTML  Link   `json:"html"`
+}
+
+// Link is a link of a Bitbucket Cloud repository. Name is only set for
+// clone links ("https" or "ssh").
+type Link struct {
+	Href string `json:"href"`
+	Name string `json:"name,omitempty"`
+}
+
+// IsNotFound reports whether err is a Bitbucket Cloud API not found error.
+func IsNotFound(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case *httpError:
+		return e.NotFound()
+	}
+	return false
+}
+
+type httpError struct {
+	StatusCode int
+	URL        *url.URL
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("unexpected %d response from Bitbucket Cloud REST API at %s", e.StatusCode, e.URL)
+}
+
+func (e *httpError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized
+}
+
+func (e *httpError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
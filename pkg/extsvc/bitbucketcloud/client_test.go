@@ -0,0 +1,86 @@
+package bitbucketcloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestClient_Repos(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path + "?" + r.URL.RawQuery {
+		case "/2.0/repositories/myteam?pagelen=100":
+			fmt.Fprintf(w, `{"pagelen": 1, "page": 1, "size": 2, "next": "%s/2.0/repositories/myteam?pagelen=100&page=2", "values": [{"full_name": "myteam/a", "uuid": "{a}"}]}`, srv.URL)
+		case "/2.0/repositories/myteam?pagelen=100&page=2":
+			fmt.Fprint(w, `{"pagelen": 1, "page": 2, "size": 2, "values": [{"full_name": "myteam/b", "uuid": "{b}"}]}`)
+		case "/2.0/repositories?role=member&pagelen=100":
+			fmt.Fprint(w, `{"pagelen": 100, "page": 1, "size": 1, "values": [{"full_name": "alice/c", "uuid": "{c}"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	c := NewClient(u, nil)
+	c.Username, c.AppPassword = "alice", "secret"
+
+	list := func(owner string) ([]string, error) {
+		var names []string
+		var page *PageToken
+		for page.HasMore() {
+			var repos []*Repo
+			var err error
+			if repos, page, err = c.Repos(context.Background(), page, owner); err != nil {
+				return nil, err
+			}
+			for _, r := range repos {
+				names = append(names, r.FullName+r.UUID)
+			}
+		}
+		return names, nil
+	}
+
+	for owner, want := range map[string][]string{
+		"myteam": {"myteam/a{a}", "myteam/b{b}"},
+		"":       {"alice/c{c}"},
+	} {
+		got, err := list(owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("owner %q: got %v, want %v", owner, got, want)
+		}
+	}
+
+	if _, err := list("missing"); !IsNotFound(err) {
+		t.Errorf("got error %v, want not found", err)
+	}
+
+	if _, _, err := c.Repos(context.Background(), &PageToken{Next: "https://example.com/2.0/repositories"}, ""); err == nil {
+		t.Error("expected error for next page URL on another host")
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	for path, want := range map[string]string{
+		"/2.0/repositories":             "Repos",
+		"/2.0/repositories/myteam":      "Repos",
+		"/2.0/repositories/myteam/repo": "Repo",
+		"/2.0/user":                     "unknown",
+	} {
+		if got := categorize(&url.URL{Path: path}); got != want {
+			t.Errorf("categorize(%q): got %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package bitbucketcloud
+
+import (
+	"net/url"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+)
+
+// ServiceType is the (api.ExternalRepoSpec).ServiceType value for Bitbucket Cloud repositories. The
+// ServiceID value is the base URL of Bitbucket Cloud (https://bitbucket.org/).
+const ServiceType = "bitbucketCloud"
+
+// ExternalRepoSpec returns an api.ExternalRepoSpec that refers to the specified Bitbucket Cloud repository.
+func ExternalRepoSpec(repo *Repo, baseURL url.URL) *api.ExternalRepoSpec {
+	return &api.ExternalRepoSpec{
+		ID:          repo.UUID,
+		ServiceType: ServiceType,
+		ServiceID:   extsvc.NormalizeBaseURL(&baseURL).String(),
+	}
+}
@@ -0,0 +1,209 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
+	"github.com/sourcegraph/sourcegraph/pkg/metrics"
+)
+
+var requestCounter = metrics.NewRequestCounter("gitea", "Total number of requests sent to the Gitea API.")
+
+// Client access a Gitea (or Gogs) instance via the REST API v1.
+type Client struct {
+	// HTTP Client used to communicate with the API
+	httpClient httpcli.Doer
+
+	// URL is the base URL of the Gitea instance, such as
+	// https://gitea.example.com.
+	URL *url.URL
+
+	// Token is the access token used to authenticate API requests.
+	Token string
+}
+
+// NewClient returns a new Gitea API client for the instance at url. If a nil
+// httpClient is provided, http.DefaultClient will be used. To use API methods
+// which require authentication, set the Token field of the returned client.
+func NewClient(url *url.URL, httpClient httpcli.Doer) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	httpClient = requestCounter.Doer(httpClient, categorize)
+
+	return &Client{
+		httpClient: httpClient,
+		URL:        url,
+	}
+}
+
+// Repo returns the repository with the given owner and name.
+func (c *Client) Repo(ctx context.Context, owner, name string) (*Repo, error) {
+	req, err := http.NewRequest("GET", "api/v1/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp Repo
+	err = c.do(ctx, req, &resp)
+	return &resp, err
+}
+
+// ListRepos returns the given page (starting at 1) of the repositories
+// returned by the API endpoint at urlStr, which is relative to the API root
+// (such as "orgs/myorg/repos" or "repos/search?q=foo").
+//
+// An empty result means there are no more pages.
+func (c *Client) ListRepos(ctx context.Context, urlStr string, page, perPage int) ([]*Repo, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "" || u.Host != "" {
+		return nil, errors.Errorf("repository query %q must be a path relative to the Gitea API", urlStr)
+	}
+
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	q.Set("limit", strconv.Itoa(perPage))
+	u.RawQuery = q.Encode()
+	u.Path = "api/v1/" + strings.TrimPrefix(u.Path, "/")
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err := c.do(ctx, req, &raw); err != nil {
+		return nil, err
+	}
+
+	// Most endpoints return a list of repositories, but the search endpoint
+	// wraps it in an object: {"ok": true, "data": [...]}.
+	var repos []*Repo
+	if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '{' {
+		var resp struct {
+			Data []*Repo `json:"data"`
+		}
+		err = json.Unmarshal(raw, &resp)
+		repos = resp.Data
+	} else {
+		err = json.Unmarshal(raw, &repos)
+	}
+	return repos, err
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request, result interface{}) error {
+	req.URL = c.URL.ResolveReference(req.URL)
+	req.Header.Set("Accept", "application/json")
+
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+
+	req, ht := nethttp.TraceRequest(opentracing.GlobalTracer(), req.WithContext(ctx),
+		nethttp.OperationName("Gitea"),
+		nethttp.ClientTrace(false))
+	defer ht.Finish()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.WithStack(&httpError{URL: req.URL, StatusCode: resp.StatusCode, Body: body})
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// categorize returns a category for an API URL. Used by metrics.
+func categorize(u *url.URL) string {
+	// API to URL mapping looks like this:
+	//
+	// 	Repo -> api/v1/repos/{owner}/{name}
+	// 	SearchRepos -> api/v1/repos/search
+	// 	ListRepos -> api/v1/orgs/{org}/repos, api/v1/users/{user}/repos, api/v1/user/repos
+	switch parts := strings.Split(strings.Trim(u.Path, "/"), "/"); {
+	case len(parts) < 3 || parts[0] != "api" || parts[1] != "v1":
+		// don't return the path directly as that could introduce too much dimensionality
+		return "unknown"
+	case len(parts) == 4 && parts[2] == "repos" && parts[3] == "search":
+		return "SearchRepos"
+	case len(parts) == 5 && parts[2] == "repos":
+		return "Repo"
+	case parts[len(parts)-1] == "repos":
+		return "ListRepos"
+	default:
+		return "unknown"
+	}
+}
+
+// Repo is a Gitea repository.
+type Repo struct {
+	ID            int64  `json:"id"`
+	Owner         *User  `json:"owner"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Description   string `json:"description"`
+	Empty         bool   `json:"empty"`
+	Private       bool   `json:"private"`
+	Fork          bool   `json:"fork"`
+	Parent        *Repo  `json:"parent"`
+	Mirror        bool   `json:"mirror"`
+	Archived      bool   `json:"archived"`
+	HTMLURL       string `json:"html_url"`
+	SSHURL        string `json:"ssh_url"`
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// User is a Gitea user or organization.
+type User struct {
+	ID       int64  `json:"id"`
+	Login    string `json:"login"`
+	FullName string `json:"full_name"`
+}
+
+// IsNotFound reports whether err is a Gitea API not found error.
+func IsNotFound(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case *httpError:
+		return e.NotFound()
+	}
+	return false
+}
+
+type httpError struct {
+	StatusCode int
+	URL        *url.URL
+	Body       []byte
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("unexpected %d response from Gitea API at %s: %s", e.StatusCode, e.URL, e.Body)
+}
+
+func (e *httpError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized
+}
+
+func (e *httpError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
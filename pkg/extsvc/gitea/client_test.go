@@ -0,0 +1,92 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestClient_ListRepos(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path + "?" + r.URL.RawQuery {
+		case "/api/v1/orgs/myorg/repos?limit=1&page=1":
+			fmt.Fprint(w, `[{"id": 1, "full_name": "myorg/a"}]`)
+		case "/api/v1/orgs/myorg/repos?limit=1&page=2":
+			fmt.Fprint(w, `[{"id": 2, "full_name": "myorg/b"}]`)
+		case "/api/v1/orgs/myorg/repos?limit=1&page=3":
+			fmt.Fprint(w, `[]`)
+		case "/api/v1/repos/search?limit=1&page=1&q=foo":
+			fmt.Fprint(w, `{"ok": true, "data": [{"id": 3, "full_name": "alice/foo"}]}`)
+		case "/api/v1/repos/search?limit=1&page=2&q=foo":
+			fmt.Fprint(w, `{"ok": true, "data": []}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	c := NewClient(u, nil)
+	c.Token = "secret"
+
+	list := func(query string) ([]string, error) {
+		var names []string
+		for page := 1; ; page++ {
+			repos, err := c.ListRepos(context.Background(), query, page, 1)
+			if err != nil {
+				return nil, err
+			}
+			if len(repos) == 0 {
+				return names, nil
+			}
+			for _, r := range repos {
+				names = append(names, fmt.Sprintf("%s#%d", r.FullName, r.ID))
+			}
+		}
+	}
+
+	for query, want := range map[string][]string{
+		"orgs/myorg/repos":   {"myorg/a#1", "myorg/b#2"},
+		"repos/search?q=foo": {"alice/foo#3"},
+	} {
+		got, err := list(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("query %q: got %v, want %v", query, got, want)
+		}
+	}
+
+	if _, err := list("orgs/missing/repos"); !IsNotFound(err) {
+		t.Errorf("got error %v, want not found", err)
+	}
+
+	if _, err := c.ListRepos(context.Background(), "https://example.com/api/v1/repos/search", 1, 1); err == nil {
+		t.Error("expected error for absolute repository query URL")
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	for path, want := range map[string]string{
+		"/api/v1/repos/search":       "SearchRepos",
+		"/api/v1/repos/myorg/myrepo": "Repo",
+		"/api/v1/orgs/myorg/repos":   "ListRepos",
+		"/api/v1/users/alice/repos":  "ListRepos",
+		"/api/v1/user/repos":         "ListRepos",
+		"/api/v1/version":            "unknown",
+		"/foo":                       "unknown",
+	} {
+		if got := categorize(&url.URL{Path: path}); got != want {
+			t.Errorf("categorize(%q): got %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package mercurial
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+)
+
+// ServiceType is the (api.ExternalRepoSpec).ServiceType value for Mercurial repositories. The
+// ServiceID value is the base URL of the Mercurial server (https://hg.example.com/repos/), and the
+// ID is the path of the repository relative to it.
+const ServiceType = "mercurial"
+
+// RemotePrefix is the prefix of the remote URLs of Mercurial repositories. It follows git's
+// <transport>::<address> syntax for remote helpers, which tells gitserver to convert the
+// repository from Mercurial instead of cloning it with git.
+const RemotePrefix = "hg::"
+
+// IsRemote reports whether the git remote URL refers to a Mercurial repository.
+func IsRemote(remoteURL string) bool {
+	return strings.HasPrefix(remoteURL, RemotePrefix)
+}
+
+// RemoteURL returns the git remote URL of the Mercurial repository at hgURL.
+func RemoteURL(hgURL string) string {
+	return RemotePrefix + hgURL
+}
+
+// ExternalRepoSpec returns an api.ExternalRepoSpec that refers to the Mercurial repository at path
+// relative to baseURL.
+func ExternalRepoSpec(path string, baseURL url.URL) *api.ExternalRepoSpec {
+	baseURL.User = nil
+	return &api.ExternalRepoSpec{
+		ID:          path,
+		ServiceType: ServiceType,
+		ServiceID:   extsvc.NormalizeBaseURL(&baseURL).String(),
+	}
+}
+
+// WebURL returns the URL of the hgweb page of the Mercurial repository, or an empty string if it
+// is not served over HTTP(S). hgweb serves repositories at the same URL they are cloned from.
+func WebURL(spec *api.ExternalRepoSpec) string {
+	if spec == nil || spec.ServiceType != ServiceType {
+		return ""
+	}
+	if !strings.HasPrefix(spec.ServiceID, "http://") && !strings.HasPrefix(spec.ServiceID, "https://") {
+		return ""
+	}
+	return spec.ServiceID + spec.ID
+}
@@ -0,0 +1,24 @@
+package svn
+
+import (
+	"net/url"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+)
+
+// ServiceType is the (api.ExternalRepoSpec).ServiceType value for Subversion repositories. The
+// ServiceID value is the base URL of the Subversion server (https://svn.example.com/repos/), and
+// the ID is the path of the repository relative to it.
+const ServiceType = "svn"
+
+// ExternalRepoSpec returns an api.ExternalRepoSpec that refers to the Subversion repository at
+// path relative to baseURL.
+func ExternalRepoSpec(path string, baseURL url.URL) *api.ExternalRepoSpec {
+	baseURL.User = nil
+	return &api.ExternalRepoSpec{
+		ID:          path,
+		ServiceType: ServiceType,
+		ServiceID:   extsvc.NormalizeBaseURL(&baseURL).String(),
+	}
+}
@@ -0,0 +1,107 @@
+package svn
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// RemotePrefix is the prefix of the remote URLs of Subversion repositories. It follows git's
+// <transport>::<address> syntax for remote helpers, which tells gitserver to mirror the
+// repository with git-svn instead of cloning it with git.
+const RemotePrefix = "svn::"
+
+// IsRemote reports whether the git remote URL refers to a Subversion repository.
+func IsRemote(remoteURL string) bool {
+	return strings.HasPrefix(remoteURL, RemotePrefix)
+}
+
+// A Remote describes how gitserver mirrors a Subversion repository. It is encoded as a git remote
+// URL, so that it is passed to gitserver (and stored as the remote of the mirror) like the clone
+// URL of any other repository.
+type Remote struct {
+	// URL is the URL of the Subversion repository. Its user info holds the credentials, if any.
+	URL *url.URL
+
+	// StdLayout is whether the repository has the standard trunk/branches/tags layout. If false,
+	// the repository is mirrored as a single branch.
+	StdLayout bool
+
+	// Authors maps Subversion usernames to git authors, such as "Jane Doe <jane@example.com>".
+	Authors map[string]string
+}
+
+// String returns the git remote URL of the form svn::<url>#<options>.
+func (r *Remote) String() string {
+	u := *r.URL
+	u.Fragment = ""
+
+	opts := url.Values{}
+	if r.StdLayout {
+		opts.Set("layout", "standard")
+	} else {
+		opts.Set("layout", "none")
+	}
+
+	users := make([]string, 0, len(r.Authors))
+	for user := range r.Authors {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		opts.Add("author", user+"="+r.Authors[user])
+	}
+
+	return RemotePrefix + u.String() + "#" + opts.Encode()
+}
+
+// ParseRemote parses a git remote URL returned by (*Remote).String.
+func ParseRemote(remoteURL string) (*Remote, error) {
+	if !IsRemote(remoteURL) {
+		return nil, fmt.Errorf("not a Subversion remote URL: missing %q prefix", RemotePrefix)
+	}
+	rawURL := strings.TrimPrefix(remoteURL, RemotePrefix)
+
+	// The options are query-encoded, so we parse them ourselves instead of
+	// using the unescaped url.URL.Fragment.
+	var rawOpts string
+	if i := strings.Index(rawURL, "#"); i >= 0 {
+		rawURL, rawOpts = rawURL[:i], rawURL[i+1:]
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("Subversion URL is not absolute")
+	}
+
+	opts, err := url.ParseQuery(rawOpts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Subversion remote options: %s", err)
+	}
+
+	r := &Remote{URL: u}
+	switch layout := opts.Get("layout"); layout {
+	case "", "standard":
+		r.StdLayout = true
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown Subversion repository layout %q", layout)
+	}
+
+	for _, author := range opts["author"] {
+		i := strings.Index(author, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid Subversion author mapping %q", author)
+		}
+		if r.Authors == nil {
+			r.Authors = map[string]string{}
+		}
+		r.Authors[author[:i]] = author[i+1:]
+	}
+
+	return r, nil
+}
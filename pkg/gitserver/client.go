@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,6 +27,7 @@ import (
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitolite"
@@ -138,6 +140,13 @@ func (c *Client) addrForRepo(ctx context.Context, repo api.RepoName) string {
 	return c.addrForKey(ctx, string(repo))
 }
 
+// AddrForRepo returns the address of the gitserver that stores the given
+// repo. It is used to proxy requests for the repo, such as git smart HTTP, to
+// its gitserver.
+func (c *Client) AddrForRepo(ctx context.Context, repo api.RepoName) string {
+	return c.addrForRepo(ctx, repo)
+}
+
 // addrForKey returns the gitserver address to use for the given string key,
 // which is hashed for sharding purposes.
 func (c *Client) addrForKey(ctx context.Context, key string) string {
@@ -173,10 +182,13 @@ func (c *Cmd) sendExec(ctx context.Context) (_ io.ReadCloser, _ http.Header, err
 	}
 
 	req := &protocol.ExecRequest{
-		Repo:           repoName,
-		URL:            c.Repo.URL,
-		EnsureRevision: c.EnsureRevision,
-		Args:           c.Args[1:],
+		Repo:               repoName,
+		URL:                c.Repo.URL,
+		PartialCloneFilter: c.Repo.PartialCloneFilter,
+		MaxRepoSize:        c.Repo.MaxRepoSize,
+		LFSMaxFileSize:     c.Repo.LFSMaxFileSize,
+		EnsureRevision:     c.EnsureRevision,
+		Args:               c.Args[1:],
 	}
 	resp, err := c.client.httpPost(ctx, repoName, "exec", req)
 	if err != nil {
@@ -232,6 +244,19 @@ type Repo struct {
 	// this field is optional (it will use the last-used Git remote URL). If the repository is not
 	// cloned on the gitserver, the request will fail.
 	URL string
+
+	// PartialCloneFilter is the object filter (such as "blob:none") to clone
+	// the repository partially with. It is only used when the repository is
+	// cloned.
+	PartialCloneFilter string
+
+	// MaxRepoSize is the maximum size in bytes of the repository on
+	// gitserver. Zero means there is no limit.
+	MaxRepoSize int64
+
+	// LFSMaxFileSize is the maximum size in bytes of the Git LFS objects
+	// fetched for the repository. Zero means LFS objects are not fetched.
+	LFSMaxFileSize int64
 }
 
 // Command creates a new Cmd. Command name must be 'git',
@@ -476,9 +501,12 @@ func (c *Client) doListOne(ctx context.Context, urlSuffix, addr string) ([]strin
 // update won't happen.
 func (c *Client) RequestRepoUpdate(ctx context.Context, repo Repo, since time.Duration) (*protocol.RepoUpdateResponse, error) {
 	req := &protocol.RepoUpdateRequest{
-		Repo:  repo.Name,
-		URL:   repo.URL,
-		Since: since,
+		Repo:               repo.Name,
+		URL:                repo.URL,
+		Since:              since,
+		PartialCloneFilter: repo.PartialCloneFilter,
+		MaxRepoSize:        repo.MaxRepoSize,
+		LFSMaxFileSize:     repo.LFSMaxFileSize,
 	}
 	resp, err := c.httpPost(ctx, repo.Name, "repo-update", req)
 	if err != nil {
@@ -680,6 +708,11 @@ func (c *Client) Remove(ctx context.Context, repo api.RepoName) error {
 // httpPost performs a POST request to a gitserver, sharding based on the given
 // repo name (the repo name is otherwise not used).
 func (c *Client) httpPost(ctx context.Context, repo api.RepoName, method string, payload interface{}) (resp *http.Response, err error) {
+	return c.httpPostAddr(ctx, c.addrForRepo(ctx, repo), method, payload)
+}
+
+// httpPostAddr performs a POST request to the gitserver at addr.
+func (c *Client) httpPostAddr(ctx context.Context, addr, method string, payload interface{}) (resp *http.Response, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Client.httpPost")
 	defer func() {
 		if err != nil {
@@ -694,7 +727,6 @@ func (c *Client) httpPost(ctx context.Context, repo api.RepoName, method string,
 		return nil, err
 	}
 
-	addr := c.addrForRepo(ctx, repo)
 	req, err := http.NewRequest("POST", "http://"+addr+"/"+method, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, err
@@ -702,6 +734,7 @@ func (c *Client) httpPost(ctx context.Context, repo api.RepoName, method string,
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", c.UserAgent)
+	actor.SetHTTPHeader(req.Header, actor.FromContext(ctx))
 	req = req.WithContext(ctx)
 
 	if c.HTTPLimiter != nil {
@@ -718,6 +751,119 @@ func (c *Client) httpPost(ctx context.Context, repo api.RepoName, method string,
 	return c.HTTPClient.Do(req)
 }
 
+// AuditLog returns the newest entries of the audit logs of all gitservers
+// that match req, newest first.
+//
+// If some gitservers could not be queried, the entries of the others are
+// returned along with a *multierror.Error.
+func (c *Client) AuditLog(ctx context.Context, req protocol.AuditLogRequest) ([]*protocol.AuditLogEntry, error) {
+	type op struct {
+		entries []*protocol.AuditLogEntry
+		err     error
+	}
+
+	addrs := c.Addrs(ctx)
+	ch := make(chan op, len(addrs))
+	for _, addr := range addrs {
+		go func(addr string) {
+			var o op
+			defer func() { ch <- o }()
+
+			resp, err := c.httpPostAddr(ctx, addr, "audit-log", req)
+			if err != nil {
+				o.err = err
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 200))
+				o.err = &url.Error{URL: resp.Request.URL.String(), Op: "AuditLog", Err: fmt.Errorf("AuditLog: http status %d: %s", resp.StatusCode, string(body))}
+				return
+			}
+			var res protocol.AuditLogResponse
+			o.err = json.NewDecoder(resp.Body).Decode(&res)
+			o.entries = res.Entries
+		}(addr)
+	}
+
+	err := new(multierror.Error)
+	var entries []*protocol.AuditLogEntry
+	for range addrs {
+		o := <-ch
+		if o.err != nil {
+			err = multierror.Append(err, o.err)
+			continue
+		}
+		entries = append(entries, o.entries...)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	if req.Limit > 0 && len(entries) > req.Limit {
+		entries = entries[:req.Limit]
+	}
+	return entries, err.ErrorOrNil()
+}
+
+// CommitAncestry returns the refs of the repository that contain a commit,
+// and how the commit relates to other commits, as requested by req.
+func (c *Client) CommitAncestry(ctx context.Context, req protocol.CommitAncestryRequest) (*protocol.CommitAncestryResponse, error) {
+	resp, err := c.httpPost(ctx, req.Repo, "commit-ancestry", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var res protocol.CommitAncestryResponse
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return nil, err
+		}
+		return &res, nil
+
+	case http.StatusNotFound:
+		var payload protocol.NotFoundPayload
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			return nil, err
+		}
+		return nil, &vcs.RepoNotExistError{Repo: req.Repo, CloneInProgress: payload.CloneInProgress, CloneProgress: payload.CloneProgress}
+
+	default:
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, &url.Error{URL: resp.Request.URL.String(), Op: "CommitAncestry", Err: fmt.Errorf("CommitAncestry: http status %d %s", resp.StatusCode, string(b))}
+	}
+}
+
+// LFSObjects returns the contents of the Git LFS objects that the pointer
+// files of req reference, if gitserver fetched them.
+func (c *Client) LFSObjects(ctx context.Context, req protocol.LFSObjectsRequest) (*protocol.LFSObjectsResponse, error) {
+	resp, err := c.httpPost(ctx, req.Repo, "lfs-objects", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var res protocol.LFSObjectsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return nil, err
+		}
+		return &res, nil
+
+	case http.StatusNotFound:
+		var payload protocol.NotFoundPayload
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			return nil, err
+		}
+		return nil, &vcs.RepoNotExistError{Repo: req.Repo, CloneInProgress: payload.CloneInProgress, CloneProgress: payload.CloneProgress}
+
+	default:
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, &url.Error{URL: resp.Request.URL.String(), Op: "LFSObjects", Err: fmt.Errorf("LFSObjects: http status %d %s", resp.StatusCode, string(b))}
+	}
+}
+
 func (c *Client) CreateCommitFromPatch(ctx context.Context, req protocol.CreateCommitFromPatchRequest) (string, error) {
 	resp, err := c.httpPost(ctx, req.Repo, "create-commit-from-patch", req)
 	if err != nil {
@@ -15,6 +15,19 @@ type ExecRequest struct {
 	// cloned on the gitserver, the request will fail.
 	URL string `json:"url,omitempty"`
 
+	// PartialCloneFilter is the object filter (such as "blob:none") to
+	// clone the repository partially with, if the request clones it.
+	PartialCloneFilter string `json:"partialCloneFilter,omitempty"`
+
+	// MaxRepoSize is the maximum size in bytes of the repository on
+	// gitserver, if the request clones it. Zero means there is no limit.
+	MaxRepoSize int64 `json:"maxRepoSize,omitempty"`
+
+	// LFSMaxFileSize is the maximum size in bytes of the Git LFS objects
+	// fetched for the repository, if the request clones it. Zero means LFS
+	// objects are not fetched.
+	LFSMaxFileSize int64 `json:"lfsMaxFileSize,omitempty"`
+
 	EnsureRevision string      `json:"ensureRevision"`
 	Args           []string    `json:"args"`
 	Opt            *RemoteOpts `json:"opt"`
@@ -44,6 +57,18 @@ type RepoUpdateRequest struct {
 	Repo  api.RepoName  `json:"repo"`  // identifying URL for repo
 	URL   string        `json:"url"`   // repo's remote URL
 	Since time.Duration `json:"since"` // debounce interval for queries, used only with request-repo-update
+
+	// PartialCloneFilter is the object filter (such as "blob:none") to
+	// clone the repository partially with, if it isn't cloned yet.
+	PartialCloneFilter string `json:"partialCloneFilter,omitempty"`
+
+	// MaxRepoSize is the maximum size in bytes of the repository on
+	// gitserver. Zero means there is no limit.
+	MaxRepoSize int64 `json:"maxRepoSize,omitempty"`
+
+	// LFSMaxFileSize is the maximum size in bytes of the Git LFS objects
+	// fetched for the repository. Zero means LFS objects are not fetched.
+	LFSMaxFileSize int64 `json:"lfsMaxFileSize,omitempty"`
 }
 
 // RepoUpdateResponse returns meta information of the repo enqueued for
@@ -92,6 +117,14 @@ type IsRepoClonedRequest struct {
 	Repo api.RepoName
 }
 
+// RepoTransferRequest is a request for a bundle of all refs of a repo that
+// is cloned on gitserver. Another gitserver uses it to take over the repo
+// instead of recloning it from the code host.
+type RepoTransferRequest struct {
+	// Repo is the repository to transfer.
+	Repo api.RepoName
+}
+
 // DeprecatedRepoInfoRequest is a request for information about a repository on gitserver.
 //
 // TODO(slimsag): Remove this after 3.3 is released.
@@ -143,6 +176,19 @@ type RepoInfo struct {
 	// recloned automatically, so this time is likely to move forward
 	// periodically.
 	CloneTime *time.Time
+
+	// PartialCloneFilter is the object filter the repository was partially
+	// cloned with. It is empty if the repository is fully cloned.
+	PartialCloneFilter string `json:",omitempty"`
+
+	// Size is the size in bytes of the repository on gitserver, as of its
+	// last clone or update. It is zero if it is unknown.
+	Size int64 `json:",omitempty"`
+
+	// LastMaintained is when git maintenance (such as gc) last ran on the
+	// repository, and MaintenanceError the error it failed with, if any.
+	LastMaintained   *time.Time `json:",omitempty"`
+	MaintenanceError string     `json:",omitempty"`
 }
 
 // RepoInfoResponse is the response to a repository information request
@@ -152,6 +198,119 @@ type RepoInfoResponse struct {
 	Results map[api.RepoName]*RepoInfo
 }
 
+// AuditLogEntry records a command that gitserver executed.
+type AuditLogEntry struct {
+	// Time is when the request for the command was received.
+	Time time.Time
+	// Repo is the repository the command ran in.
+	Repo api.RepoName
+	// Args are the arguments of the git command.
+	Args []string
+	// ActorUID is the ID of the user that the command was executed on behalf
+	// of, or 0 for internal or anonymous actors.
+	ActorUID int32 `json:",omitempty"`
+	// ActorInternal is whether the command was executed on behalf of an
+	// internal Sourcegraph service.
+	ActorInternal bool `json:",omitempty"`
+	// Client is the user agent of the client that requested the command.
+	Client string `json:",omitempty"`
+	// Duration is how long the request took, including any fetch of the
+	// repository.
+	Duration time.Duration
+	// StdoutBytes and StderrBytes are the sizes of the output of the command.
+	StdoutBytes, StderrBytes int64
+	// ExitStatus is the exit status of the command. It is negative if the
+	// command didn't run (e.g. because the repository is not cloned).
+	ExitStatus int
+	// Status is the outcome of the request: the exit status of the command
+	// if it ran, or why it didn't run (such as "repo-not-found").
+	Status string
+	// Error is the error that occurred when running the command, if any.
+	Error string `json:",omitempty"`
+}
+
+// AuditLogRequest is a request for the entries of the audit log of
+// gitserver, newest first.
+type AuditLogRequest struct {
+	// Repo restricts the entries to commands that ran in the repository, if
+	// set.
+	Repo api.RepoName `json:",omitempty"`
+	// ActorUID restricts the entries to commands executed on behalf of the
+	// user, if set.
+	ActorUID int32 `json:",omitempty"`
+	// Before restricts the entries to commands received before the time, if
+	// set.
+	Before time.Time `json:",omitempty"`
+	// Limit is the maximum number of entries to return.
+	Limit int
+}
+
+// AuditLogResponse is the response to an AuditLogRequest.
+type AuditLogResponse struct {
+	// Entries are the matching entries, newest first.
+	Entries []*AuditLogEntry
+}
+
+// CommitAncestryRequest is a request for the refs of a repository that contain
+// a commit, and for how the commit relates to other commits.
+type CommitAncestryRequest struct {
+	// Repo is the repository of the commits.
+	Repo api.RepoName
+	// Commit is the commit to look up.
+	Commit api.CommitID
+	// ContainingRefs requests the branches and tags whose history contains
+	// Commit.
+	ContainingRefs bool `json:",omitempty"`
+	// Others are the commits to relate Commit to.
+	Others []api.CommitID `json:",omitempty"`
+}
+
+// CommitAncestryResponse is the response to a CommitAncestryRequest.
+type CommitAncestryResponse struct {
+	// ContainingRefs are the full names (such as refs/heads/master) of the
+	// branches and tags whose history contains the commit, if they were
+	// requested.
+	ContainingRefs []string `json:",omitempty"`
+	// Relations maps each of the other commits of the request to how the
+	// commit relates to it.
+	Relations map[api.CommitID]CommitRelation `json:",omitempty"`
+}
+
+// LFSObjectsRequest is a request for the contents of the Git LFS objects
+// that pointer files reference.
+type LFSObjectsRequest struct {
+	// Repo is the repository of the pointer files.
+	Repo api.RepoName
+	// Blobs are the object IDs of the pointer files.
+	Blobs []string
+}
+
+// LFSObjectsResponse is the response to a LFSObjectsRequest.
+type LFSObjectsResponse struct {
+	// Objects maps the object ID of each pointer file whose LFS object
+	// gitserver fetched to the contents of the object. Pointer files whose
+	// objects were not fetched are omitted.
+	Objects map[string][]byte
+}
+
+// CommitRelation is how a commit relates to another commit in the commit
+// graph.
+type CommitRelation string
+
+const (
+	// CommitRelationEqual means that the commits are the same.
+	CommitRelationEqual CommitRelation = "equal"
+	// CommitRelationAncestor means that the commit is an ancestor of the
+	// other commit, i.e. the other commit's history contains it.
+	CommitRelationAncestor CommitRelation = "ancestor"
+	// CommitRelationDescendant means that the commit is a descendant of the
+	// other commit, i.e. its history contains the other commit.
+	CommitRelationDescendant CommitRelation = "descendant"
+	// CommitRelationDiverged means that neither commit's history contains
+	// the other commit.
+	CommitRelationDiverged CommitRelation = "diverged"
+)
+
 // CreateCommitFromPatchRequest is the request information needed for creating
 // the simulated staging area git object for a repo.
 type CreateCommitFromPatchRequest struct {
@@ -134,6 +134,19 @@ func (r *RepoInfo) String() string {
 // VCSInfo describes how to access an external repository's Git data (to clone or update it).
 type VCSInfo struct {
 	URL string // the Git remote URL
+
+	// PartialCloneFilter is the object filter (such as "blob:none") to clone
+	// the repository partially with. It is empty if the repository is cloned
+	// fully.
+	PartialCloneFilter string `json:",omitempty"`
+
+	// MaxRepoSize is the maximum size in bytes of the repository on
+	// gitserver. Zero means there is no limit.
+	MaxRepoSize int64 `json:",omitempty"`
+
+	// LFSMaxFileSize is the maximum size in bytes of the Git LFS objects
+	// fetched for the repository. Zero means LFS objects are not fetched.
+	LFSMaxFileSize int64 `json:",omitempty"`
 }
 
 // RepoLinks contains URLs and URL patterns for objects in this repository.
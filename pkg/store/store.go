@@ -15,11 +15,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/diskcache"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
@@ -55,6 +57,13 @@ type Store struct {
 	// determine if the error is a bad request (eg invalid repo).
 	FetchTar func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error)
 
+	// FetchLFSObjects returns the contents of the Git LFS objects that the
+	// pointer files with the given blob object IDs reference, by blob object
+	// ID. Pointers whose objects are not available are omitted. If set, text
+	// files stored with Git LFS are searched by their contents instead of
+	// their pointer.
+	FetchLFSObjects func(ctx context.Context, repo gitserver.Repo, blobs []string) (map[string][]byte, error)
+
 	// Path is the directory to store the cache
 	Path string
 
@@ -64,6 +73,17 @@ type Store struct {
 	// MaxCacheSizeBytes.
 	MaxCacheSizeBytes int64
 
+	// MaxTrigramIndexSizeBytes is the maximum total size in bytes of the
+	// trigram indexes stored next to the cached archives (see
+	// ZipFile.TrigramIndex). It is separate from MaxCacheSizeBytes, and is
+	// enforced in the same way. If it is zero, no trigram indexes are built.
+	MaxTrigramIndexSizeBytes int64
+
+	// indexQueue holds the paths of freshly fetched archives whose trigram
+	// index is still to be built. Start starts a goroutine which builds them
+	// one at a time. It is nil if no trigram indexes are built.
+	indexQueue chan string
+
 	// once protects Start
 	once sync.Once
 
@@ -102,7 +122,11 @@ func (s *Store) Start() {
 			Dir:               s.Path,
 			Component:         "store",
 			BackgroundTimeout: 2 * time.Minute,
-			BeforeEvict:       s.ZipCache.delete,
+			BeforeEvict:       s.beforeEvict,
+		}
+		if s.MaxTrigramIndexSizeBytes > 0 {
+			s.indexQueue = make(chan string, indexQueueSize)
+			go s.indexArchives()
 		}
 		go s.watchAndEvict()
 	})
@@ -148,7 +172,12 @@ func (s *Store) PrepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 		// TODO: consider adding a cache method that doesn't actually bother opening the file,
 		// since we're just going to close it again immediately.
 		bgctx := opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(ctx))
+		// Keep the actor, so that gitserver attributes the fetch to the
+		// actor whose search caused it.
+		bgctx = actor.WithActor(bgctx, actor.FromContext(ctx))
+		var fetched bool
 		f, err := s.cache.Open(bgctx, key, func(ctx context.Context) (io.ReadCloser, error) {
+			fetched = true
 			return s.fetch(ctx, repo, commit, largeFilePatterns)
 		})
 		var path string
@@ -158,6 +187,9 @@ func (s *Store) PrepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 				f.File.Close()
 			}
 		}
+		if err == nil && fetched {
+			s.queueIndex(path)
+		}
 		resC <- result{path, err}
 	}()
 
@@ -173,6 +205,39 @@ func (s *Store) PrepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 	}
 }
 
+// indexQueueSize is the maximum number of archives waiting for their trigram
+// index to be built.
+const indexQueueSize = 100
+
+// queueIndex queues the freshly fetched archive at path to have its trigram
+// index built. If the queue is full, the archive is not indexed.
+func (s *Store) queueIndex(path string) {
+	if s.indexQueue == nil {
+		return
+	}
+	select {
+	case s.indexQueue <- path:
+	default:
+		trigramIndexBuilds.WithLabelValues("dropped").Inc()
+	}
+}
+
+// indexArchives builds the trigram indexes of the queued archives, one at a
+// time.
+func (s *Store) indexArchives() {
+	for path := range s.indexQueue {
+		if err := s.writeTrigramIndex(path, s.MaxTrigramIndexSizeBytes); err != nil {
+			log.Printf("failed to build trigram index of %s: %s", path, err)
+		}
+	}
+}
+
+// beforeEvict is called before the archive at path is evicted from the cache.
+func (s *Store) beforeEvict(path string) {
+	s.ZipCache.delete(path)
+	removeTrigramIndex(path)
+}
+
 // fetch fetches an archive from the network and stores it on disk. It does
 // not populate the in-memory cache. You should probably be calling
 // prepareZip.
@@ -233,11 +298,18 @@ func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.Commi
 
 	// Write tr to zw. Return the first error encountered, but clean up if
 	// we encounter an error.
+	var fetchLFSObjects func(blobs []string) (map[string][]byte, error)
+	if s.FetchLFSObjects != nil {
+		fetchLFSObjects = func(blobs []string) (map[string][]byte, error) {
+			return s.FetchLFSObjects(ctx, repo, blobs)
+		}
+	}
+
 	go func() {
 		defer r.Close()
 		tr := tar.NewReader(r)
 		zw := zip.NewWriter(pw)
-		err := copySearchable(tr, zw, largeFilePatterns)
+		err := copySearchable(tr, zw, largeFilePatterns, fetchLFSObjects)
 		if err1 := zw.Close(); err == nil {
 			err = err1
 		}
@@ -250,14 +322,18 @@ func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.Commi
 
 // copySearchable copies searchable files from tr to zw. A searchable file is
 // any file that is a candidate for being searched (under size limit and
-// non-binary).
-func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string) error {
+// non-binary). If fetchLFSObjects is not nil, Git LFS pointer files are
+// replaced by the contents of their object, if it is searchable. The objects
+// of all pointer files are fetched at once, after the other files are copied.
+// Pointer files whose objects can't be fetched are copied as they are.
+func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string, fetchLFSObjects func(blobs []string) (map[string][]byte, error)) error {
 	// 32*1024 is the same size used by io.Copy
 	buf := make([]byte, 32*1024)
+	var pointers []*lfsPointerFile
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
-			return nil
+			break
 		}
 		if err != nil {
 			return err
@@ -268,6 +344,28 @@ func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string)
 			continue
 		}
 
+		n, err := tr.Read(buf)
+		switch err {
+		case io.EOF:
+		case nil:
+		default:
+			return err
+		}
+
+		// Pointer files are copied once the objects of all of them are
+		// fetched.
+		if fetchLFSObjects != nil && hdr.Size <= git.MaxLFSPointerSize && int64(n) == hdr.Size {
+			if p, ok := git.ParseLFSPointer(buf[:n]); ok {
+				pointers = append(pointers, &lfsPointerFile{
+					name:       hdr.Name,
+					pointer:    append([]byte(nil), buf[:n]...),
+					blob:       git.BlobObjectID(buf[:n]),
+					searchable: p.Size <= maxFileSize || ignoreSizeMax(hdr.Name, largeFilePatterns),
+				})
+				continue
+			}
+		}
+
 		// We are happy with the file, so we can write it to zw.
 		w, err := zw.CreateHeader(&zip.FileHeader{
 			Name:   hdr.Name,
@@ -277,15 +375,8 @@ func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string)
 			return err
 		}
 
-		n, err := tr.Read(buf)
-		switch err {
-		case io.EOF:
-			if n == 0 {
-				continue
-			}
-		case nil:
-		default:
-			return err
+		if n == 0 {
+			continue
 		}
 
 		// We do not search the content of large files unless they are
@@ -296,7 +387,7 @@ func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string)
 
 		// Heuristic: Assume file is binary if first 256 bytes contain a
 		// 0x00. Best effort, so ignore err. We only search names of binary files.
-		if n > 0 && bytes.IndexByte(buf[:n], 0x00) >= 0 {
+		if bytes.IndexByte(buf[:n], 0x00) >= 0 {
 			continue
 		}
 
@@ -313,8 +404,66 @@ func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string)
 		if err != nil {
 			return err
 		}
+	}
 
+	if len(pointers) == 0 {
+		return nil
+	}
+	var blobs []string
+	for _, p := range pointers {
+		if p.searchable {
+			blobs = append(blobs, p.blob)
+		}
+	}
+	var objects map[string][]byte
+	if len(blobs) > 0 {
+		var err error
+		objects, err = fetchLFSObjects(blobs)
+		if err != nil {
+			// The archive is still useful without the objects, so search
+			// the pointer files instead.
+			log.Printf("failed to fetch LFS objects: %s", err)
+		}
+	}
+	for _, p := range pointers {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   p.name,
+			Method: zip.Store,
+		})
+		if err != nil {
+			return err
+		}
+		if !p.searchable {
+			// Like other large files, only the name is searched.
+			continue
+		}
+		content, ok := objects[p.blob]
+		if !ok {
+			content = p.pointer
+		}
+		head := content
+		if len(head) > len(buf) {
+			head = head[:len(buf)]
+		}
+		if bytes.IndexByte(head, 0x00) >= 0 {
+			continue
+		}
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// lfsPointerFile is a Git LFS pointer file whose object copySearchable
+// fetches.
+type lfsPointerFile struct {
+	name    string
+	pointer []byte
+	// blob is the object ID of the pointer file.
+	blob string
+	// searchable is whether the object is small enough to be searched.
+	searchable bool
 }
 
 func (s *Store) String() string {
@@ -347,6 +496,12 @@ func (s *Store) watchAndEvict() {
 		}
 		cacheSizeBytes.Set(float64(stats.CacheSize))
 		evictions.Add(float64(stats.Evicted))
+
+		if s.MaxTrigramIndexSizeBytes > 0 {
+			if err := s.evictTrigramIndexes(s.MaxTrigramIndexSizeBytes); err != nil {
+				log.Printf("failed to evict trigram indexes: %s", err)
+			}
+		}
 	}
 }
 
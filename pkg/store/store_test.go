@@ -2,18 +2,24 @@ package store
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"context"
 	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 )
 
 func TestPrepareZip(t *testing.T) {
@@ -26,12 +32,14 @@ func TestPrepareZip(t *testing.T) {
 	returnFetch := make(chan struct{})
 	var gotRepo gitserver.Repo
 	var gotCommit api.CommitID
+	var gotActor *actor.Actor
 	var fetchZipCalled int64
 	s.FetchTar = func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
 		<-returnFetch
 		atomic.AddInt64(&fetchZipCalled, 1)
 		gotRepo = repo
 		gotCommit = commit
+		gotActor = actor.FromContext(ctx)
 		return emptyTar(t), nil
 	}
 
@@ -41,7 +49,8 @@ func TestPrepareZip(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		go func() {
 			<-startPrepareZip
-			_, err := s.PrepareZip(context.Background(), wantRepo, wantCommit)
+			ctx := actor.WithActor(context.Background(), actor.FromUser(1))
+			_, err := s.PrepareZip(ctx, wantRepo, wantCommit)
 			prepareZipErr <- err
 		}()
 	}
@@ -54,6 +63,9 @@ func TestPrepareZip(t *testing.T) {
 		}
 	}
 
+	if gotActor.UID != 1 {
+		t.Errorf("fetched on behalf of %v, want user 1", gotActor)
+	}
 	if gotCommit != wantCommit {
 		t.Errorf("fetched wrong commit. got=%v want=%v", gotCommit, wantCommit)
 	}
@@ -125,6 +137,110 @@ func TestIngoreSizeMax(t *testing.T) {
 	}
 }
 
+func TestCopySearchable_lfs(t *testing.T) {
+	pointer := func(oid string, size int) string {
+		return "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize " + strconv.Itoa(size) + "\n"
+	}
+	files := map[string]string{
+		"regular":   "hello world\n",
+		"text":      pointer(strings.Repeat("1", 64), 10),
+		"binary":    pointer(strings.Repeat("2", 64), 10),
+		"notsynced": pointer(strings.Repeat("3", 64), 10),
+		"large":     pointer(strings.Repeat("4", 64), maxFileSize+1),
+	}
+	objects := map[string][]byte{
+		git.BlobObjectID([]byte(files["text"])):   []byte("hello lfs\n"),
+		git.BlobObjectID([]byte(files["binary"])): []byte("hello\x00lfs\n"),
+	}
+
+	t.Run("fetched", func(t *testing.T) {
+		var calls int
+		got := copySearchableFiles(t, files, func(blobs []string) (map[string][]byte, error) {
+			calls++
+			if len(blobs) != 3 {
+				t.Errorf("got %d blobs, want 3 (the large object is not fetched)", len(blobs))
+			}
+			return objects, nil
+		})
+		want := map[string]string{
+			"regular":   "hello world\n",
+			"text":      "hello lfs\n",
+			"binary":    "",
+			"notsynced": files["notsynced"],
+			"large":     "",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if calls != 1 {
+			t.Errorf("got %d calls to fetch LFS objects, want 1", calls)
+		}
+	})
+
+	t.Run("fetch fails", func(t *testing.T) {
+		got := copySearchableFiles(t, files, func(blobs []string) (map[string][]byte, error) {
+			return nil, errors.New("gitserver unavailable")
+		})
+		want := map[string]string{
+			"regular":   "hello world\n",
+			"text":      files["text"],
+			"binary":    files["binary"],
+			"notsynced": files["notsynced"],
+			"large":     "",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+// copySearchableFiles returns the files copySearchable copies from a tar
+// archive of files.
+func copySearchableFiles(t *testing.T, files map[string]string, fetchLFSObjects func(blobs []string) (map[string][]byte, error)) map[string]string {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for name, contents := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(contents))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zipBuf := new(bytes.Buffer)
+	zw := zip.NewWriter(zipBuf)
+	if err := copySearchable(tar.NewReader(buf), zw, nil, fetchLFSObjects); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name] = string(b)
+	}
+	return got
+}
+
 func tmpStore(t *testing.T) (*Store, func()) {
 	d, err := ioutil.TempDir("", "store_test")
 	if err != nil {
@@ -0,0 +1,461 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/unix"
+)
+
+// trigramIndexExt is the extension of trigram index files. The trigram index
+// of the archive at path foo.zip is stored at foo.zip.trigrams.
+const trigramIndexExt = ".trigrams"
+
+// trigramIndexMagic is the first 8 bytes of a trigram index file. It should
+// be changed whenever the format changes.
+var trigramIndexMagic = []byte("SGTRI01\n")
+
+// A TrigramIndex records which trigrams (sequences of 3 bytes, lowercased if
+// ASCII) occur in each file of a zip archive. It is used to skip files that
+// cannot contain a match without reading them.
+//
+// The on disk format (all integers are little endian) is:
+//
+//	magic     8 bytes
+//	numFiles  uint32
+//	numTris   uint32
+//	table     numTris entries of (trigram uint32, postings offset uint32), sorted by trigram
+//	postings  for each trigram, the uvarint encoded deltas of the indexes of
+//	          the files (in ZipFile.Files) which contain it
+type TrigramIndex struct {
+	numFiles int
+	table    []byte
+	postings []byte
+
+	// data is the mmapped index file. It is nil for indexes built in memory.
+	data []byte
+	f    *os.File
+}
+
+const trigramIndexHeaderSize = 16
+
+// trigramIndexPath returns the path of the trigram index of the archive at zipPath.
+func trigramIndexPath(zipPath string) string {
+	return zipPath + trigramIndexExt
+}
+
+// buildTrigramIndex returns the encoded trigram index of the files in zf, or
+// false if it would be larger than maxSizeBytes. It gives up as soon as a
+// lower bound of the encoded size exceeds maxSizeBytes, so the postings held
+// in memory are bounded by it as well.
+func buildTrigramIndex(zf *ZipFile, maxSizeBytes int64) ([]byte, bool) {
+	var (
+		postings = map[uint32][]uint32{}
+		// size is a lower bound of the encoded size: the header, a table
+		// entry per trigram and at least a byte per posting.
+		size = int64(trigramIndexHeaderSize)
+	)
+	for i := range zf.Files {
+		data := zf.DataFor(&zf.Files[i])
+		for j := 0; j+3 <= len(data); j++ {
+			tri := trigram(data[j], data[j+1], data[j+2])
+			if files := postings[tri]; len(files) == 0 || files[len(files)-1] != uint32(i) {
+				if len(files) == 0 {
+					size += 8
+				}
+				size++
+				if size > maxSizeBytes {
+					return nil, false
+				}
+				postings[tri] = append(files, uint32(i))
+			}
+		}
+	}
+
+	tris := make([]uint32, 0, len(postings))
+	for tri := range postings {
+		tris = append(tris, tri)
+	}
+	sort.Slice(tris, func(i, j int) bool { return tris[i] < tris[j] })
+
+	var (
+		table = make([]byte, 8*len(tris))
+		buf   bytes.Buffer
+		tmp   [binary.MaxVarintLen32]byte
+	)
+	for i, tri := range tris {
+		binary.LittleEndian.PutUint32(table[8*i:], tri)
+		binary.LittleEndian.PutUint32(table[8*i+4:], uint32(buf.Len()))
+		prev := uint32(0)
+		for _, file := range postings[tri] {
+			n := binary.PutUvarint(tmp[:], uint64(file-prev))
+			buf.Write(tmp[:n])
+			prev = file
+		}
+	}
+
+	data := make([]byte, trigramIndexHeaderSize, trigramIndexHeaderSize+len(table)+buf.Len())
+	copy(data, trigramIndexMagic)
+	binary.LittleEndian.PutUint32(data[8:], uint32(len(zf.Files)))
+	binary.LittleEndian.PutUint32(data[12:], uint32(len(tris)))
+	data = append(data, table...)
+	data = append(data, buf.Bytes()...)
+	return data, int64(len(data)) <= maxSizeBytes
+}
+
+// parseTrigramIndex returns the trigram index encoded in data. It does not
+// copy data.
+func parseTrigramIndex(data []byte) (*TrigramIndex, error) {
+	if len(data) < trigramIndexHeaderSize || !bytes.Equal(data[:8], trigramIndexMagic) {
+		return nil, errors.New("invalid trigram index header")
+	}
+	numFiles := binary.LittleEndian.Uint32(data[8:])
+	numTris := binary.LittleEndian.Uint32(data[12:])
+	tableEnd := trigramIndexHeaderSize + 8*uint64(numTris)
+	if uint64(len(data)) < tableEnd {
+		return nil, errors.New("trigram index is truncated")
+	}
+	return &TrigramIndex{
+		numFiles: int(numFiles),
+		table:    data[trigramIndexHeaderSize:tableEnd],
+		postings: data[tableEnd:],
+	}, nil
+}
+
+// openTrigramIndex opens and mmaps the trigram index at path.
+func openTrigramIndex(path string) (*TrigramIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() == 0 {
+		f.Close()
+		return nil, errors.Errorf("trigram index %s is empty", path)
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	idx, err := parseTrigramIndex(data)
+	if err != nil {
+		unix.Munmap(data)
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to read trigram index %s", path)
+	}
+	idx.data = data
+	idx.f = f
+	return idx, nil
+}
+
+// close releases the resources of an index opened with openTrigramIndex.
+func (idx *TrigramIndex) close() {
+	if idx.f == nil {
+		return
+	}
+	// Like for zip files, only log errors here.
+	if err := unix.Munmap(idx.data); err != nil {
+		log.Printf("failed to munmap %q: %v", idx.f.Name(), err)
+	}
+	if err := idx.f.Close(); err != nil {
+		log.Printf("failed to close %q: %v", idx.f.Name(), err)
+	}
+}
+
+// Candidates returns, for each file in the indexed ZipFile, whether it
+// contains all trigrams of all literals (ignoring ASCII case). Files for
+// which it is false cannot contain all of literals. Literals shorter than
+// 3 bytes are ignored.
+func (idx *TrigramIndex) Candidates(literals []string) []bool {
+	candidates := make([]bool, idx.numFiles)
+	var (
+		files []uint32 // files containing all trigrams seen so far
+		first = true
+		seen  = map[uint32]bool{}
+	)
+	for _, lit := range literals {
+		for j := 0; j+3 <= len(lit); j++ {
+			tri := trigram(lit[j], lit[j+1], lit[j+2])
+			if seen[tri] {
+				continue
+			}
+			seen[tri] = true
+			if first {
+				files = idx.lookup(tri)
+				first = false
+			} else {
+				files = intersect(files, idx.lookup(tri))
+			}
+			if len(files) == 0 {
+				return candidates
+			}
+		}
+	}
+	if first {
+		// No trigrams to look up, so every file is a candidate.
+		for i := range candidates {
+			candidates[i] = true
+		}
+		return candidates
+	}
+	for _, file := range files {
+		if int(file) < len(candidates) {
+			candidates[file] = true
+		}
+	}
+	return candidates
+}
+
+// lookup returns the sorted indexes of the files which contain tri.
+func (idx *TrigramIndex) lookup(tri uint32) []uint32 {
+	n := len(idx.table) / 8
+	i := sort.Search(n, func(i int) bool {
+		return binary.LittleEndian.Uint32(idx.table[8*i:]) >= tri
+	})
+	if i == n || binary.LittleEndian.Uint32(idx.table[8*i:]) != tri {
+		return nil
+	}
+	start := binary.LittleEndian.Uint32(idx.table[8*i+4:])
+	end := uint32(len(idx.postings))
+	if i+1 < n {
+		end = binary.LittleEndian.Uint32(idx.table[8*(i+1)+4:])
+	}
+	if start > end || end > uint32(len(idx.postings)) {
+		return nil // corrupt index
+	}
+
+	var (
+		buf   = idx.postings[start:end]
+		files []uint32
+		file  uint64
+	)
+	for len(buf) > 0 {
+		delta, n := binary.Uvarint(buf)
+		if n <= 0 {
+			break // corrupt index
+		}
+		buf = buf[n:]
+		file += delta
+		files = append(files, uint32(file))
+	}
+	return files
+}
+
+// intersect returns the elements of the sorted lists a and b which are in
+// both. It reuses the storage of a.
+func intersect(a, b []uint32) []uint32 {
+	res := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			res = append(res, a[i])
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+func trigram(a, b, c byte) uint32 {
+	return uint32(toLowerASCII(a))<<16 | uint32(toLowerASCII(b))<<8 | uint32(toLowerASCII(c))
+}
+
+func toLowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + ('a' - 'A')
+	}
+	return b
+}
+
+// MockTrigramIndex builds the trigram index of zf in memory. It is only
+// for testing ZipFiles created with MockZipFile.
+func MockTrigramIndex(zf *ZipFile) error {
+	data, _ := buildTrigramIndex(zf, math.MaxInt64)
+	idx, err := parseTrigramIndex(data)
+	if err != nil {
+		return err
+	}
+	zf.index = idx
+	return nil
+}
+
+// writeTrigramIndex builds the trigram index of the archive at zipPath, unless
+// it already exists or would not fit in what is left of maxSizeBytes, the
+// budget for all trigram indexes.
+func (s *Store) writeTrigramIndex(zipPath string, maxSizeBytes int64) (err error) {
+	indexPath := trigramIndexPath(zipPath)
+	if _, err := os.Stat(indexPath); err == nil {
+		return nil
+	}
+
+	result := "success"
+	defer func(start time.Time) {
+		if err != nil {
+			result = "error"
+		}
+		trigramIndexBuilds.WithLabelValues(result).Inc()
+		trigramIndexBuildDuration.Observe(time.Since(start).Seconds())
+	}(time.Now())
+
+	size, err := s.trigramIndexesSize()
+	if err != nil {
+		return err
+	}
+	zf, err := s.ZipCache.Get(zipPath)
+	if err != nil {
+		return err
+	}
+	data, ok := buildTrigramIndex(zf, maxSizeBytes-size)
+	zf.Close()
+	if !ok {
+		result = "too_large"
+		return nil
+	}
+
+	// We write to a temporary path to prevent ZipFile.TrigramIndex from
+	// finding a partially written file.
+	tmpPath := indexPath + ".part"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, indexPath)
+}
+
+// trigramIndexesSize returns the total size of the trigram indexes in the
+// store.
+func (s *Store) trigramIndexesSize() (int64, error) {
+	list, err := ioutil.ReadDir(s.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrapf(err, "failed to ReadDir %s", s.Path)
+	}
+	var size int64
+	for _, fi := range list {
+		if strings.HasSuffix(fi.Name(), trigramIndexExt) {
+			size += fi.Size()
+		}
+	}
+	return size, nil
+}
+
+// evictTrigramIndexes removes trigram indexes until their total size is at
+// most maxSizeBytes. It removes the indexes of the least recently used
+// archives first, and indexes whose archive has been evicted.
+func (s *Store) evictTrigramIndexes(maxSizeBytes int64) error {
+	list, err := ioutil.ReadDir(s.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to ReadDir %s", s.Path)
+	}
+
+	type index struct {
+		path    string
+		size    int64
+		zipTime time.Time
+	}
+	var (
+		indexes []index
+		size    int64
+	)
+	for _, fi := range list {
+		if !strings.HasSuffix(fi.Name(), trigramIndexExt) {
+			continue
+		}
+		path := filepath.Join(s.Path, fi.Name())
+		zfi, err := os.Stat(strings.TrimSuffix(path, trigramIndexExt))
+		if err != nil {
+			// The archive is gone, so is this index.
+			if err := os.Remove(path); err == nil {
+				trigramIndexEvictions.Inc()
+			}
+			continue
+		}
+		indexes = append(indexes, index{path: path, size: fi.Size(), zipTime: zfi.ModTime()})
+		size += fi.Size()
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].zipTime.Before(indexes[j].zipTime)
+	})
+	for _, idx := range indexes {
+		if size <= maxSizeBytes {
+			break
+		}
+		if err := os.Remove(idx.path); err != nil {
+			log.Printf("failed to remove %s: %s", idx.path, err)
+			continue
+		}
+		trigramIndexEvictions.Inc()
+		size -= idx.size
+	}
+	trigramIndexSizeBytes.Set(float64(size))
+	return nil
+}
+
+// removeTrigramIndex removes the trigram index of the archive at zipPath, if
+// there is one.
+func removeTrigramIndex(zipPath string) {
+	if err := os.Remove(trigramIndexPath(zipPath)); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to remove trigram index of %s: %s", zipPath, err)
+	}
+}
+
+var (
+	trigramIndexBuilds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "searcher",
+		Subsystem: "store",
+		Name:      "trigram_index_builds",
+		Help:      "The total number of trigram index builds, by result (success, too_large, dropped or error).",
+	}, []string{"result"})
+	trigramIndexBuildDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "searcher",
+		Subsystem: "store",
+		Name:      "trigram_index_build_duration_seconds",
+		Help:      "Time spent building trigram indexes.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+	})
+	trigramIndexSizeBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "searcher",
+		Subsystem: "store",
+		Name:      "trigram_index_size_bytes",
+		Help:      "The total size of trigram indexes on disk.",
+	})
+	trigramIndexEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "searcher",
+		Subsystem: "store",
+		Name:      "trigram_index_evictions",
+		Help:      "The total number of trigram indexes evicted from disk.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(trigramIndexBuilds)
+	prometheus.MustRegister(trigramIndexBuildDuration)
+	prometheus.MustRegister(trigramIndexSizeBytes)
+	prometheus.MustRegister(trigramIndexEvictions)
+}
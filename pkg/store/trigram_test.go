@@ -0,0 +1,168 @@
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+func TestTrigramIndex(t *testing.T) {
+	s, cleanup := tmpStore(t)
+	defer cleanup()
+
+	s.FetchTar = func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
+		return tarOf(t, map[string]string{
+			"a.go":   "package main\n\nfunc Foo() {}\n",
+			"b.go":   "package main\n\nvar foobar = 1\n",
+			"c.txt":  "hello world\n",
+			"empty":  "",
+			"binary": "foo\x00bar",
+		}), nil
+	}
+	path, err := s.PrepareZip(context.Background(), gitserver.Repo{Name: "foo"}, "0123456789012345678901234567890123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zf, err := s.ZipCache.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx := zf.TrigramIndex(); idx != nil {
+		t.Fatal("expected no trigram index before it is built")
+	}
+	zf.Close()
+
+	// An index larger than the budget is not written.
+	if err := s.writeTrigramIndex(path, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(trigramIndexPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("expected no trigram index to be written, got %v", err)
+	}
+
+	// Nor is an index larger than what other indexes left of the budget.
+	other := filepath.Join(s.Path, "other.zip"+trigramIndexExt)
+	if err := ioutil.WriteFile(other, make([]byte, 1<<20-100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writeTrigramIndex(path, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(trigramIndexPath(path)); !os.IsNotExist(err) {
+		t.Fatalf("expected no trigram index to be written, got %v", err)
+	}
+	if err := os.Remove(other); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.writeTrigramIndex(path, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	zf, err = s.ZipCache.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := zf.TrigramIndex()
+	if idx == nil {
+		t.Fatal("expected trigram index")
+	}
+	candidates := func(literals ...string) []string {
+		var names []string
+		for i, ok := range idx.Candidates(literals) {
+			if ok {
+				names = append(names, zf.Files[i].Name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+	tests := map[string]struct {
+		literals []string
+		want     []string
+	}{
+		"one":      {[]string{"func"}, []string{"a.go"}},
+		"case":     {[]string{"FOO"}, []string{"a.go", "b.go"}},
+		"all":      {[]string{"package", "foobar"}, []string{"b.go"}},
+		"none":     {[]string{"package", "hello"}, nil},
+		"missing":  {[]string{"nope"}, nil},
+		"short":    {[]string{"fo"}, []string{"a.go", "b.go", "binary", "c.txt", "empty"}},
+		"straddle": {[]string{"o()"}, []string{"a.go"}},
+	}
+	for name, test := range tests {
+		if got := candidates(test.literals...); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got candidates %v, want %v", name, got, test.want)
+		}
+	}
+	zf.Close()
+
+	// Indexes are evicted with their archive.
+	if _, err := s.cache.Evict(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(trigramIndexPath(path)); !os.IsNotExist(err) {
+		t.Errorf("expected trigram index to be evicted, got %v", err)
+	}
+}
+
+func TestEvictTrigramIndexes(t *testing.T) {
+	s, cleanup := tmpStore(t)
+	defer cleanup()
+
+	write := func(name string, size int) {
+		if err := ioutil.WriteFile(s.Path+"/"+name, make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.zip", 1)
+	write("a.zip"+trigramIndexExt, 10)
+	write("b.zip", 1)
+	write("b.zip"+trigramIndexExt, 10)
+	write("orphan.zip"+trigramIndexExt, 10)
+
+	if err := s.evictTrigramIndexes(15); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	files, _ := ioutil.ReadDir(s.Path)
+	for _, fi := range files {
+		got = append(got, fi.Name())
+	}
+	// a.zip and b.zip have the same modification time, so either index
+	// may be evicted.
+	if len(got) != 3 {
+		t.Errorf("expected one of the indexes to be evicted, got %v", got)
+	}
+	for _, name := range got {
+		if name == "orphan.zip"+trigramIndexExt {
+			t.Errorf("expected index without archive to be evicted, got %v", got)
+		}
+	}
+}
+
+func tarOf(t *testing.T, files map[string]string) io.ReadCloser {
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+	for name, contents := range files {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(contents))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
+}
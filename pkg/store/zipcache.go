@@ -90,6 +90,9 @@ func (c *ZipCache) delete(path string) {
 			log.Printf("failed to close %q: %v", zf.f.Name(), err)
 		}
 	}
+	if zf.index != nil {
+		zf.index.close()
+	}
 	delete(shard.m, path)
 }
 
@@ -102,6 +105,36 @@ type ZipFile struct {
 	Data   []byte
 	f      *os.File
 	wg     sync.WaitGroup // ensures underlying file is not munmap'd or closed while in use
+
+	indexMu sync.Mutex    // protects index
+	index   *TrigramIndex // nil until the trigram index is found on disk
+}
+
+// TrigramIndex returns the trigram index of f, or nil if it has not been
+// built (yet). Its file indexes are indexes in f.Files.
+func (f *ZipFile) TrigramIndex() *TrigramIndex {
+	f.indexMu.Lock()
+	defer f.indexMu.Unlock()
+	// Mock zipFiles have nil f, their index can only be set by MockTrigramIndex.
+	if f.index != nil || f.f == nil {
+		return f.index
+	}
+	// The index is built in the background after the archive is fetched, so
+	// we look for it until it exists.
+	idx, err := openTrigramIndex(trigramIndexPath(f.f.Name()))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("failed to open trigram index: %v", err)
+		}
+		return nil
+	}
+	if idx.numFiles != len(f.Files) {
+		log.Printf("ignoring trigram index %q: it has %d files, want %d", idx.f.Name(), idx.numFiles, len(f.Files))
+		idx.close()
+		return nil
+	}
+	f.index = idx
+	return idx
 }
 
 func readZipFile(path string) (*ZipFile, error) {
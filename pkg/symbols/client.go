@@ -101,6 +101,60 @@ func (c *Client) Search(ctx context.Context, args protocol.SearchArgs) (result *
 	return result, err
 }
 
+// Definitions finds the candidate definitions of the identifier at a position
+// in a file.
+func (c *Client) Definitions(ctx context.Context, args protocol.PositionArgs) (result *protocol.DefinitionsResult, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "symbols.Client.Definitions")
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+	span.SetTag("Repo", string(args.Repo))
+	span.SetTag("CommitID", string(args.CommitID))
+
+	err = c.do(ctx, "definitions", key{repo: args.Repo, commitID: args.CommitID}, args, &result)
+	return result, err
+}
+
+// References finds the candidate references to the identifier at a position
+// in a file.
+func (c *Client) References(ctx context.Context, args protocol.PositionArgs) (result *protocol.ReferencesResult, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "symbols.Client.References")
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+	span.SetTag("Repo", string(args.Repo))
+	span.SetTag("CommitID", string(args.CommitID))
+
+	err = c.do(ctx, "references", key{repo: args.Repo, commitID: args.CommitID}, args, &result)
+	return result, err
+}
+
+// do posts payload to the method on the symbols service and decodes the
+// response into result.
+func (c *Client) do(ctx context.Context, method string, key key, payload, result interface{}) error {
+	resp, err := c.httpPost(ctx, method, key, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// best-effort inclusion of body in error message
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 200))
+		return errors.Errorf("symbols %s http status %d: %s", method, resp.StatusCode, string(body))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
 func (c *Client) httpPost(ctx context.Context, method string, key key, payload interface{}) (resp *http.Response, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "symbols.Client.httpPost")
 	defer func() {
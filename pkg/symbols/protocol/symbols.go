@@ -35,6 +35,22 @@ type SearchArgs struct {
 	// need to match to get included in the result
 	ExcludePattern string
 
+	// Kinds is an optional list of symbol kinds (e.g., "function"). If
+	// non-empty, only symbols of one of these kinds are returned. Kinds are
+	// matched case-insensitively.
+	Kinds []string
+
+	// Containers is an optional list of container names (e.g., the name of a
+	// struct or class). If non-empty, only symbols whose parent is one of
+	// these containers are returned. Containers are matched exactly, ignoring
+	// case unless IsCaseSensitive is true.
+	Containers []string
+
+	// Languages is an optional list of languages (e.g., "go"). If non-empty,
+	// only symbols in one of these languages are returned. Languages are
+	// matched case-insensitively.
+	Languages []string
+
 	// First indicates that only the first n symbols should be returned.
 	First int
 }
@@ -58,3 +74,64 @@ type Symbol struct {
 
 	FileLimited bool
 }
+
+// PositionArgs are the arguments to find the definitions of, or references to,
+// the identifier at a position in a file. This is "search-based" code
+// intelligence: it is based on the names of symbols, not on a semantic
+// understanding of the code, so results are only candidates.
+type PositionArgs struct {
+	// Repo is the name of the repository.
+	Repo api.RepoName `json:"repo"`
+
+	// CommitID is the commit.
+	CommitID api.CommitID `json:"commitID"`
+
+	// Path is the path of the file in the repository.
+	Path string
+
+	// Line is the zero-based line of the position.
+	Line int
+
+	// Character is the zero-based character offset of the position in the
+	// line, in Unicode code points.
+	Character int
+
+	// First indicates that only the first n results should be returned.
+	First int
+}
+
+// DefinitionsResult is the result of a definitions request.
+type DefinitionsResult struct {
+	// Identifier is the identifier at the position, or empty if there is no
+	// identifier at the position.
+	Identifier string
+
+	// Symbols are the candidate definitions of Identifier. Symbols in the
+	// same file and in the same language are listed first.
+	Symbols []Symbol
+}
+
+// ReferencesResult is the result of a references request.
+type ReferencesResult struct {
+	// Identifier is the identifier at the position, or empty if there is no
+	// identifier at the position.
+	Identifier string
+
+	// References are the candidate references to Identifier.
+	References []Reference
+
+	// LimitHit is true if there are more references than were returned.
+	LimitHit bool
+}
+
+// Reference is an occurrence of an identifier in a file.
+type Reference struct {
+	Path string
+
+	// Line is the zero-based line of the occurrence.
+	Line int
+
+	// Character is the zero-based character offset of the occurrence in the
+	// line, in Unicode code points.
+	Character int
+}
@@ -0,0 +1,49 @@
+package git
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+// ContainingRefs returns the full names (such as refs/heads/master) of the
+// branches and tags whose history contains commit.
+func ContainingRefs(ctx context.Context, repo gitserver.Repo, commit api.CommitID) ([]string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: ContainingRefs")
+	span.SetTag("Commit", commit)
+	defer span.Finish()
+
+	ensureAbsCommit(commit)
+	resp, err := gitserver.DefaultClient.CommitAncestry(ctx, protocol.CommitAncestryRequest{
+		Repo:           repo.Name,
+		Commit:         commit,
+		ContainingRefs: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.ContainingRefs, nil
+}
+
+// CommitRelation returns how commit relates to other in the commit graph.
+func CommitRelation(ctx context.Context, repo gitserver.Repo, commit, other api.CommitID) (protocol.CommitRelation, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: CommitRelation")
+	span.SetTag("Commit", commit)
+	span.SetTag("Other", other)
+	defer span.Finish()
+
+	ensureAbsCommit(commit)
+	ensureAbsCommit(other)
+	resp, err := gitserver.DefaultClient.CommitAncestry(ctx, protocol.CommitAncestryRequest{
+		Repo:   repo.Name,
+		Commit: commit,
+		Others: []api.CommitID{other},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Relations[other], nil
+}
@@ -0,0 +1,64 @@
+package git_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestContainingRefsAndCommitRelation(t *testing.T) {
+	t.Parallel()
+
+	repo := makeGitRepository(t,
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag v1",
+		"git checkout -b b2",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m qux --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	)
+	resolve := func(spec string) api.CommitID {
+		t.Helper()
+		commit, err := git.ResolveRevision(ctx, repo, nil, spec, nil)
+		if err != nil {
+			t.Fatalf("ResolveRevision(%q): %s", spec, err)
+		}
+		return commit
+	}
+	base, b2, master := resolve("v1"), resolve("b2"), resolve("master")
+
+	for commit, want := range map[api.CommitID][]string{
+		base:   {"refs/heads/b2", "refs/heads/master", "refs/tags/v1"},
+		b2:     {"refs/heads/b2"},
+		master: {"refs/heads/master"},
+	} {
+		refs, err := git.ContainingRefs(ctx, repo, commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(refs, want) {
+			t.Errorf("%s: got containing refs %v, want %v", commit, refs, want)
+		}
+	}
+
+	for _, test := range []struct {
+		commit, other api.CommitID
+		want          protocol.CommitRelation
+	}{
+		{base, base, protocol.CommitRelationEqual},
+		{base, master, protocol.CommitRelationAncestor},
+		{master, base, protocol.CommitRelationDescendant},
+		{b2, master, protocol.CommitRelationDiverged},
+	} {
+		rel, err := git.CommitRelation(ctx, repo, test.commit, test.other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rel != test.want {
+			t.Errorf("%s..%s: got relation %q, want %q", test.commit, test.other, rel, test.want)
+		}
+	}
+}
@@ -28,6 +28,18 @@ func ReadFile(ctx context.Context, repo gitserver.Repo, commit api.CommitID, nam
 	if err != nil {
 		return nil, err
 	}
+
+	// Return the contents of files stored with Git LFS instead of their
+	// pointer, if gitserver fetched them.
+	if IsLFSPointer(b) {
+		content, ok, err := ReadLFSObject(ctx, repo, commit, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return content, nil
+		}
+	}
 	return b, nil
 }
 
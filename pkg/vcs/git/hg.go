@@ -0,0 +1,46 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs"
+)
+
+// HgChangeset returns the Mercurial changeset hash that the commit was
+// converted from, for repositories that gitserver mirrors from Mercurial. The
+// mapping is kept in git notes under refs/notes/hg. It returns "" if the commit
+// has no changeset (e.g. because the repository is not a Mercurial mirror).
+func HgChangeset(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (string, error) {
+	if Mocks.HgChangeset != nil {
+		return Mocks.HgChangeset(commit)
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: HgChangeset")
+	span.SetTag("Commit", commit)
+	defer span.Finish()
+
+	if err := checkSpecArgSafety(string(commit)); err != nil {
+		return "", err
+	}
+
+	cmd := gitserver.DefaultClient.Command("git", "notes", "--ref=hg", "show", string(commit))
+	cmd.Repo = repo
+	stdout, stderr, err := cmd.DividedOutput(ctx)
+	if err != nil {
+		if vcs.IsRepoNotExist(err) {
+			return "", err
+		}
+		// Exit status of 1 and no output means that the commit has no note.
+		if cmd.ExitStatus == 1 && len(stdout) == 0 {
+			return "", nil
+		}
+		return "", errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, stderr))
+	}
+	return string(bytes.TrimSpace(stdout)), nil
+}
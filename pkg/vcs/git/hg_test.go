@@ -0,0 +1,36 @@
+package git_test
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestRepository_HgChangeset(t *testing.T) {
+	t.Parallel()
+
+	const hgHash = "5a4f6a1b1c1d6cb0e5b5b8f4b7b6b5c2e1d3f4a9"
+	repo := makeGitRepository(t,
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git notes --ref=hg add -m "+hgHash+" HEAD",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	)
+
+	for rev, want := range map[string]string{
+		"HEAD~1": hgHash,
+		"HEAD":   "",
+	} {
+		commitID, err := git.ResolveRevision(ctx, repo, nil, rev, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := git.HgChangeset(ctx, repo, commitID)
+		if err != nil {
+			t.Errorf("%s: HgChangeset: %s", rev, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: got changeset %q, want %q", rev, got, want)
+		}
+	}
+}
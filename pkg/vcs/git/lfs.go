@@ -0,0 +1,139 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/util"
+)
+
+// Files stored with Git LFS are committed as small pointer files, which
+// reference the object with the actual contents by its SHA-256 hash. When
+// gitserver fetches LFS objects of a repository, it stores the contents of
+// each object as a git note of the pointer blob under LFSNotesRef.
+const LFSNotesRef = "refs/notes/lfs"
+
+// MaxLFSPointerSize is the maximum size in bytes of an LFS pointer file.
+const MaxLFSPointerSize = 1024
+
+// LFSPointer is a parsed Git LFS pointer file.
+type LFSPointer struct {
+	// OID is the hex-encoded SHA-256 hash of the object.
+	OID string
+	// Size is the size in bytes of the object.
+	Size int64
+}
+
+var lfsOIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ParseLFSPointer parses the contents of an LFS pointer file. It returns
+// false if b is not a pointer file.
+func ParseLFSPointer(b []byte) (*LFSPointer, bool) {
+	if len(b) > MaxLFSPointerSize || !IsLFSPointer(b) {
+		return nil, false
+	}
+	var p LFSPointer
+	var hasSize bool
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		key, value := s.Text(), ""
+		if i := strings.IndexByte(key, ' '); i >= 0 {
+			key, value = key[:i], key[i+1:]
+		}
+		switch key {
+		case "oid":
+			if !strings.HasPrefix(value, "sha256:") || !lfsOIDPattern.MatchString(value[len("sha256:"):]) {
+				return nil, false
+			}
+			p.OID = value[len("sha256:"):]
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return nil, false
+			}
+			p.Size, hasSize = size, true
+		}
+	}
+	if p.OID == "" || !hasSize {
+		return nil, false
+	}
+	return &p, true
+}
+
+// IsLFSPointer reports whether b looks like the contents of an LFS pointer
+// file. Use ParseLFSPointer to also validate it.
+func IsLFSPointer(b []byte) bool {
+	return len(b) <= MaxLFSPointerSize && (bytes.HasPrefix(b, []byte("version https://git-lfs.github.com/spec/v1\n")) ||
+		bytes.HasPrefix(b, []byte("version https://hawser.github.com/spec/v1\n")))
+}
+
+// ReadLFSObject returns the contents of the LFS object that the named pointer
+// file at commit references. It returns false if gitserver did not fetch the
+// object (e.g. because fetching LFS objects is not enabled for the repository,
+// or the object exceeds the size limit).
+func ReadLFSObject(ctx context.Context, repo gitserver.Repo, commit api.CommitID, name string) ([]byte, bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: ReadLFSObject")
+	span.SetTag("Name", name)
+	defer span.Finish()
+
+	if err := checkSpecArgSafety(string(commit)); err != nil {
+		return nil, false, err
+	}
+	ensureAbsCommit(commit)
+
+	cmd := gitserver.DefaultClient.Command("git", "notes", "--ref="+LFSNotesRef, "show", string(commit)+":"+util.Rel(name))
+	cmd.Repo = repo
+	stdout, stderr, err := cmd.DividedOutput(ctx)
+	if err != nil {
+		if vcs.IsRepoNotExist(err) {
+			return nil, false, err
+		}
+		// Exit status of 1 and no output means that the pointer has no note.
+		if cmd.ExitStatus == 1 && len(stdout) == 0 {
+			return nil, false, nil
+		}
+		return nil, false, errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, stderr))
+	}
+	return stdout, true, nil
+}
+
+// ReadLFSObjects returns the contents of the LFS objects that the pointer
+// files with the given blob object IDs (see BlobObjectID) reference, by blob
+// object ID. Like ReadLFSObject, pointers whose objects gitserver did not
+// fetch are omitted. All objects are read with a single gitserver request.
+func ReadLFSObjects(ctx context.Context, repo gitserver.Repo, blobs []string) (map[string][]byte, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: ReadLFSObjects")
+	span.SetTag("Blobs", len(blobs))
+	defer span.Finish()
+
+	if len(blobs) == 0 {
+		return nil, nil
+	}
+	resp, err := gitserver.DefaultClient.LFSObjects(ctx, protocol.LFSObjectsRequest{Repo: repo.Name, Blobs: blobs})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Objects, nil
+}
+
+// BlobObjectID returns the object ID that git assigns to a blob with the
+// given contents.
+func BlobObjectID(contents []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(contents))
+	h.Write(contents)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,74 @@
+package git_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestParseLFSPointer(t *testing.T) {
+	const oid = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+	tests := map[string]*git.LFSPointer{
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345\n": {OID: oid, Size: 12345},
+		"version https://hawser.github.com/spec/v1\noid sha256:" + oid + "\nsize 0\n":      {OID: oid, Size: 0},
+
+		"":           nil,
+		"foo\nbar\n": nil,
+		"version https://git-lfs.github.com/spec/v1\nsize 12345\n":                        nil,
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\n":            nil,
+		"version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 12345\n":        nil,
+		"version https://git-lfs.github.com/spec/v1\noid md5:" + oid + "\nsize 12345\n":   nil,
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize -1\n":   nil,
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 1.5\n":  nil,
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345 ": nil,
+	}
+	for input, want := range tests {
+		got, ok := git.ParseLFSPointer([]byte(input))
+		if ok != (want != nil) {
+			t.Errorf("%q: got ok %v, want %v", input, ok, want != nil)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %+v, want %+v", input, got, want)
+		}
+	}
+}
+
+func TestRepository_ReadFile_lfs(t *testing.T) {
+	t.Parallel()
+
+	const pointer = "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12\n"
+	repo := makeGitRepository(t,
+		"printf '"+pointer+"' > fetched",
+		"printf '"+pointer+"' > missing",
+		"git add fetched missing",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git notes --ref="+git.LFSNotesRef+" add -C $(printf 'hello world\n' | git hash-object -w --stdin) HEAD:fetched",
+	)
+	commitID, err := git.ResolveRevision(ctx, repo, nil, "HEAD", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"fetched": "hello world\n",
+		"missing": pointer,
+	} {
+		got, err := git.ReadFile(ctx, repo, commitID, name)
+		if err != nil {
+			t.Errorf("%s: ReadFile: %s", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBlobObjectID(t *testing.T) {
+	// echo 'hello world' | git hash-object --stdin
+	if got, want := git.BlobObjectID([]byte("hello world\n")), "3b18e512dba79e4c8300dd08aeb37f8e728b8dad"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
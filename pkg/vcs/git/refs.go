@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -54,6 +55,29 @@ type Tag struct {
 	CreatorDate  time.Time
 }
 
+// A Ref is a Git ref, such as a branch or tag.
+type Ref struct {
+	// Name is the full name of the ref, such as "refs/heads/master".
+	Name string
+	// CommitID is the commit the ref points to. For annotated tags, it is the
+	// tagged commit (not the tag object).
+	CommitID api.CommitID
+}
+
+// RefsOptions specifies options for the list of refs returned by ListRefs.
+//
+// The globs are interpreted like the --glob and --exclude flags of git-log:
+// "refs/" is prepended to a glob that doesn't start with it, "/*" is appended
+// to a glob without any of '*', '?' or '[', and '*' matches any sequence of
+// characters (including '/').
+type RefsOptions struct {
+	// Include, if non-empty, restricts the list to refs that match at least
+	// one of these globs.
+	Include []string
+	// Exclude removes refs that match any of these globs from the list.
+	Exclude []string
+}
+
 // BehindAhead is a set of behind/ahead counts.
 type BehindAhead struct {
 	Behind uint32 `json:"Behind,omitempty"`
@@ -247,6 +271,119 @@ func ListTags(ctx context.Context, repo gitserver.Repo) ([]*Tag, error) {
 	return tags, nil
 }
 
+// ListRefs returns a list of the refs in the repository that match opt,
+// sorted by name.
+func ListRefs(ctx context.Context, repo gitserver.Repo, opt RefsOptions) ([]*Ref, error) {
+	if Mocks.ListRefs != nil {
+		return Mocks.ListRefs(opt)
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: ListRefs")
+	span.SetTag("Opt", opt)
+	defer span.Finish()
+
+	include, err := compileRefGlobs(opt.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileRefGlobs(opt.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := gitserver.DefaultClient.Command("git", "for-each-ref", "--format=%(if)%(*objectname)%(then)%(*objectname)%(else)%(objectname)%(end)%00%(refname)")
+	cmd.Repo = repo
+	out, err := cmd.CombinedOutput(ctx)
+	if err != nil {
+		if vcs.IsRepoNotExist(err) {
+			return nil, err
+		}
+		return nil, errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, out))
+	}
+
+	out = bytes.TrimSuffix(out, []byte("\n")) // remove trailing newline
+	if len(out) == 0 {
+		return nil, nil // no refs
+	}
+	var refs []*Ref
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		parts := bytes.SplitN(line, []byte("\x00"), 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid git for-each-ref output line: %q", line)
+		}
+		name := string(parts[1])
+		if (len(include) > 0 && !matchAnyRefGlob(include, name)) || matchAnyRefGlob(exclude, name) {
+			continue
+		}
+		refs = append(refs, &Ref{Name: name, CommitID: api.CommitID(parts[0])})
+	}
+	return refs, nil
+}
+
+func compileRefGlobs(globs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(globs))
+	for i, glob := range globs {
+		re, err := regexp.Compile(refGlobRegexp(glob))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid ref glob %q", glob)
+		}
+		res[i] = re
+	}
+	return res, nil
+}
+
+func matchAnyRefGlob(res []*regexp.Regexp, name string) bool {
+	for _, re := range res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// refGlobRegexp returns a regexp that matches the same ref names as glob
+// (see RefsOptions).
+func refGlobRegexp(glob string) string {
+	if !strings.HasPrefix(glob, "refs/") {
+		glob = "refs/" + glob
+	}
+	if !strings.ContainsAny(glob, "*?[") {
+		glob = strings.TrimSuffix(glob, "/") + "/*"
+	}
+
+	var b strings.Builder
+	b.WriteByte('^')
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteByte('.')
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += 1 + end
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteByte('$')
+	return b.String()
+}
+
 type byteSlices [][]byte
 
 func (p byteSlices) Len() int           { return len(p) }
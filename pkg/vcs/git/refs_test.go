@@ -280,3 +280,45 @@ func TestRepository_ListTags(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_ListRefs(t *testing.T) {
+	t.Parallel()
+
+	dateEnv := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z"
+	repo := makeGitRepository(t,
+		dateEnv+" git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git branch b0",
+		"git branch feature/b1",
+		dateEnv+" git tag --annotate -m foo t0",
+	)
+	const commit = "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"
+
+	tests := map[string]struct {
+		opt  git.RefsOptions
+		want []string
+	}{
+		"all":      {opt: git.RefsOptions{}, want: []string{"refs/heads/b0", "refs/heads/feature/b1", "refs/heads/master", "refs/tags/t0"}},
+		"prefix":   {opt: git.RefsOptions{Include: []string{"refs/heads/"}}, want: []string{"refs/heads/b0", "refs/heads/feature/b1", "refs/heads/master"}},
+		"implicit": {opt: git.RefsOptions{Include: []string{"tags"}}, want: []string{"refs/tags/t0"}},
+		"wildcard": {opt: git.RefsOptions{Include: []string{"heads/*b[0-9]"}}, want: []string{"refs/heads/b0", "refs/heads/feature/b1"}},
+		"exclude":  {opt: git.RefsOptions{Include: []string{"heads/"}, Exclude: []string{"heads/feature/"}}, want: []string{"refs/heads/b0", "refs/heads/master"}},
+		"none":     {opt: git.RefsOptions{Include: []string{"heads/x*"}}, want: nil},
+	}
+	for label, test := range tests {
+		refs, err := git.ListRefs(ctx, repo, test.opt)
+		if err != nil {
+			t.Errorf("%s: ListRefs: %s", label, err)
+			continue
+		}
+		var names []string
+		for _, ref := range refs {
+			if ref.CommitID != commit {
+				t.Errorf("%s: got ref %s commit %s, want %s", label, ref.Name, ref.CommitID, commit)
+			}
+			names = append(names, ref.Name)
+		}
+		if !reflect.DeepEqual(names, test.want) {
+			t.Errorf("%s: got refs %v, want %v", label, names, test.want)
+		}
+	}
+}
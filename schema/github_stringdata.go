@@ -34,6 +34,34 @@ const GitHubSchemaJSON = `{
       "type": "string",
       "minLength": 1
     },
+    "webhookSecret": {
+      "description": "A secret used to validate the signatures of the push webhooks that GitHub sends to https://[your-sourcegraph-hostname]/.api/webhooks/github. Set the same secret on the webhook in the GitHub repository or organization settings. If set, pushes to a repository trigger an immediate update of it on Sourcegraph.",
+      "type": "string",
+      "minLength": 1
+    },
+    "fetchLFS": {
+      "description": "If true, gitserver fetches the Git LFS objects of files at the default branch of repositories of this connection from GitHub, so that search results, file views and raw file downloads show the contents of these files instead of their LFS pointer. Objects larger than ` + "`" + `lfsMaxFileSizeMB` + "`" + ` are not fetched. Only repositories cloned over HTTP(S) are supported.",
+      "type": "boolean",
+      "default": false
+    },
+    "lfsMaxFileSizeMB": {
+      "description": "The maximum size in megabytes of the Git LFS objects gitserver fetches if ` + "`" + `fetchLFS` + "`" + ` is true.",
+      "type": "integer",
+      "minimum": 1,
+      "default": 100
+    },
+    "maxRepoSizeMB": {
+      "description": "The maximum size in megabytes of a repository of this connection on gitserver. Repositories of this connection are cloned partially (without blobs that are not needed) if this is set, and repositories that are still larger are not mirrored.",
+      "type": "integer",
+      "minimum": 1,
+      "examples": [2048]
+    },
+    "partialCloneFilter": {
+      "description": "If set, gitserver clones repositories of this connection partially with the given object filter (such as ` + "`" + `blob:none` + "`" + `, ` + "`" + `blob:limit=1m` + "`" + ` or ` + "`" + `tree:0` + "`" + `), as in ` + "`" + `git clone --filter` + "`" + `. This saves disk space and clone time for very large repositories. Missing objects are fetched from GitHub when they are needed. GitHub must support partial clones.",
+      "type": "string",
+      "pattern": "^(blob:none|blob:limit=[0-9]+[kmg]?|tree:[0-9]+)$",
+      "examples": ["blob:none"]
+    },
     "certificate": {
       "description": "TLS certificate of the GitHub Enterprise instance. This is only necessary if the certificate is self-signed or signed by an internal CA. To get the certificate run ` + "`" + `openssl s_client -connect HOST:443 -showcerts < /dev/null 2> /dev/null | openssl x509 -outform PEM` + "`" + `",
       "type": "string",
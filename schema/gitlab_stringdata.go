@@ -28,6 +28,34 @@ const GitLabSchemaJSON = `{
       "type": "string",
       "minLength": 1
     },
+    "webhookSecret": {
+      "description": "A secret token used to validate the push webhooks that GitLab sends to https://[your-sourcegraph-hostname]/.api/webhooks/gitlab. Set the same secret token on the webhook in the GitLab project or group settings. If set, pushes to a project trigger an immediate update of it on Sourcegraph.",
+      "type": "string",
+      "minLength": 1
+    },
+    "fetchLFS": {
+      "description": "If true, gitserver fetches the Git LFS objects of files at the default branch of repositories of this connection from GitLab, so that search results, file views and raw file downloads show the contents of these files instead of their LFS pointer. Objects larger than ` + "`" + `lfsMaxFileSizeMB` + "`" + ` are not fetched. Only repositories cloned over HTTP(S) are supported.",
+      "type": "boolean",
+      "default": false
+    },
+    "lfsMaxFileSizeMB": {
+      "description": "The maximum size in megabytes of the Git LFS objects gitserver fetches if ` + "`" + `fetchLFS` + "`" + ` is true.",
+      "type": "integer",
+      "minimum": 1,
+      "default": 100
+    },
+    "maxRepoSizeMB": {
+      "description": "The maximum size in megabytes of a repository of this connection on gitserver. Repositories of this connection are cloned partially (without blobs that are not needed) if this is set, and repositories that are still larger are not mirrored.",
+      "type": "integer",
+      "minimum": 1,
+      "examples": [2048]
+    },
+    "partialCloneFilter": {
+      "description": "If set, gitserver clones repositories of this connection partially with the given object filter (such as ` + "`" + `blob:none` + "`" + `, ` + "`" + `blob:limit=1m` + "`" + ` or ` + "`" + `tree:0` + "`" + `), as in ` + "`" + `git clone --filter` + "`" + `. This saves disk space and clone time for very large repositories. Missing objects are fetched from GitLab when they are needed. GitLab must support partial clones.",
+      "type": "string",
+      "pattern": "^(blob:none|blob:limit=[0-9]+[kmg]?|tree:[0-9]+)$",
+      "examples": ["blob:none"]
+    },
     "gitURLType": {
       "description": "The type of Git URLs to use for cloning and fetching Git repositories on this GitLab instance.\n\nIf \"http\", Sourcegraph will access GitLab repositories using Git URLs of the form http(s)://gitlab.example.com/myteam/myproject.git (using https: if the GitLab instance uses HTTPS).\n\nIf \"ssh\", Sourcegraph will access GitLab repositories using Git URLs of the form git@example.gitlab.com:myteam/myproject.git. See the documentation for how to provide SSH private keys and known_hosts: https://docs.sourcegraph.com/admin/repo/auth#repositories-that-need-http-s-or-ssh-authentication.",
       "type": "string",
@@ -80,13 +80,29 @@ func (v *AuthProviders) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("tagged union type must have a %q property whose value is one of %s", "type", []string{"builtin", "saml", "openidconnect", "http-header", "github", "gitlab"})
 }
 
+// BitbucketCloudConnection description: Configuration for a connection to Bitbucket Cloud.
+type BitbucketCloudConnection struct {
+	ApiURL                string                        `json:"apiURL,omitempty"`
+	AppPassword           string                        `json:"appPassword"`
+	Exclude               []*ExcludedBitbucketCloudRepo `json:"exclude,omitempty"`
+	GitURLType            string                        `json:"gitURLType,omitempty"`
+	RepositoryPathPattern string                        `json:"repositoryPathPattern,omitempty"`
+	Teams                 []string                      `json:"teams,omitempty"`
+	Url                   string                        `json:"url"`
+	Username              string                        `json:"username"`
+}
+
 // BitbucketServerConnection description: Configuration for a connection to Bitbucket Server.
 type BitbucketServerConnection struct {
 	Certificate                 string                         `json:"certificate,omitempty"`
 	Exclude                     []*ExcludedBitbucketServerRepo `json:"exclude,omitempty"`
 	ExcludePersonalRepositories bool                           `json:"excludePersonalRepositories,omitempty"`
+	FetchLFS                    bool                           `json:"fetchLFS,omitempty"`
 	GitURLType                  string                         `json:"gitURLType,omitempty"`
 	InitialRepositoryEnablement bool                           `json:"initialRepositoryEnablement,omitempty"`
+	LFSMaxFileSizeMB            int                            `json:"lfsMaxFileSizeMB,omitempty"`
+	MaxRepoSizeMB               int                            `json:"maxRepoSizeMB,omitempty"`
+	PartialCloneFilter          string                         `json:"partialCloneFilter,omitempty"`
 	Password                    string                         `json:"password,omitempty"`
 	Repos                       []string                       `json:"repos,omitempty"`
 	RepositoryPathPattern       string                         `json:"repositoryPathPattern,omitempty"`
@@ -94,6 +110,7 @@ type BitbucketServerConnection struct {
 	Token                       string                         `json:"token,omitempty"`
 	Url                         string                         `json:"url"`
 	Username                    string                         `json:"username"`
+	WebhookSecret               string                         `json:"webhookSecret,omitempty"`
 }
 type BrandAssets struct {
 	Logo   string `json:"logo,omitempty"`
@@ -147,6 +164,11 @@ type Discussions struct {
 	AbuseEmails     []string `json:"abuseEmails,omitempty"`
 	AbuseProtection bool     `json:"abuseProtection,omitempty"`
 }
+type ExcludedBitbucketCloudRepo struct {
+	Name    string `json:"name,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Uuid    string `json:"uuid,omitempty"`
+}
 type ExcludedBitbucketServerRepo struct {
 	Id      int    `json:"id,omitempty"`
 	Name    string `json:"name,omitempty"`
@@ -160,6 +182,11 @@ type ExcludedGitLabProject struct {
 	Id   int    `json:"id,omitempty"`
 	Name string `json:"name,omitempty"`
 }
+type ExcludedGiteaRepo struct {
+	Id      int    `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
 
 // ExperimentalFeatures description: Experimental features to enable or disable. Features that are now enabled by default are marked as deprecated.
 type ExperimentalFeatures struct {
@@ -199,13 +226,18 @@ type GitHubConnection struct {
 	Authorization               *GitHubAuthorization  `json:"authorization,omitempty"`
 	Certificate                 string                `json:"certificate,omitempty"`
 	Exclude                     []*ExcludedGitHubRepo `json:"exclude,omitempty"`
+	FetchLFS                    bool                  `json:"fetchLFS,omitempty"`
 	GitURLType                  string                `json:"gitURLType,omitempty"`
 	InitialRepositoryEnablement bool                  `json:"initialRepositoryEnablement,omitempty"`
+	LFSMaxFileSizeMB            int                   `json:"lfsMaxFileSizeMB,omitempty"`
+	MaxRepoSizeMB               int                   `json:"maxRepoSizeMB,omitempty"`
+	PartialCloneFilter          string                `json:"partialCloneFilter,omitempty"`
 	Repos                       []string              `json:"repos,omitempty"`
 	RepositoryPathPattern       string                `json:"repositoryPathPattern,omitempty"`
 	RepositoryQuery             []string              `json:"repositoryQuery"`
 	Token                       string                `json:"token"`
 	Url                         string                `json:"url"`
+	WebhookSecret               string                `json:"webhookSecret,omitempty"`
 }
 
 // GitLabAuthProvider description: Configures the GitLab OAuth authentication provider for SSO. In addition to specifying this configuration object, you must also create a OAuth App on your GitLab instance: https://docs.gitlab.com/ee/integration/oauth_provider.html. The application should have `api` and `read_user` scopes and the callback URL set to the concatenation of your Sourcegraph instance URL and "/.auth/gitlab/callback".
@@ -228,19 +260,36 @@ type GitLabConnection struct {
 	Authorization               *GitLabAuthorization     `json:"authorization,omitempty"`
 	Certificate                 string                   `json:"certificate,omitempty"`
 	Exclude                     []*ExcludedGitLabProject `json:"exclude,omitempty"`
+	FetchLFS                    bool                     `json:"fetchLFS,omitempty"`
 	GitURLType                  string                   `json:"gitURLType,omitempty"`
 	InitialRepositoryEnablement bool                     `json:"initialRepositoryEnablement,omitempty"`
+	LFSMaxFileSizeMB            int                      `json:"lfsMaxFileSizeMB,omitempty"`
+	MaxRepoSizeMB               int                      `json:"maxRepoSizeMB,omitempty"`
+	PartialCloneFilter          string                   `json:"partialCloneFilter,omitempty"`
 	ProjectQuery                []string                 `json:"projectQuery"`
 	Projects                    []*GitLabProject         `json:"projects,omitempty"`
 	RepositoryPathPattern       string                   `json:"repositoryPathPattern,omitempty"`
 	Token                       string                   `json:"token"`
 	Url                         string                   `json:"url"`
+	WebhookSecret               string                   `json:"webhookSecret,omitempty"`
 }
 type GitLabProject struct {
 	Id   int    `json:"id,omitempty"`
 	Name string `json:"name,omitempty"`
 }
 
+// GiteaConnection description: Configuration for a connection to Gitea (or Gogs).
+type GiteaConnection struct {
+	Certificate           string               `json:"certificate,omitempty"`
+	Exclude               []*ExcludedGiteaRepo `json:"exclude,omitempty"`
+	GitURLType            string               `json:"gitURLType,omitempty"`
+	RepositoryPathPattern string               `json:"repositoryPathPattern,omitempty"`
+	RepositoryQuery       []string             `json:"repositoryQuery"`
+	Repos                 []string             `json:"repos,omitempty"`
+	Token                 string               `json:"token"`
+	Url                   string               `json:"url"`
+}
+
 // GitoliteConnection description: Configuration for a connection to Gitolite.
 type GitoliteConnection struct {
 	Blacklist                  string       `json:"blacklist,omitempty"`
@@ -306,6 +355,13 @@ func (v *IdentityProvider) UnmarshalJSON(data []byte) error {
 type Log struct {
 	Sentry *Sentry `json:"sentry,omitempty"`
 }
+
+// MercurialConnection description: Configuration for a connection to Mercurial repositories. gitserver converts them to Git repositories with hg-fast-export.
+type MercurialConnection struct {
+	Repos                 []string `json:"repos"`
+	RepositoryPathPattern string   `json:"repositoryPathPattern,omitempty"`
+	Url                   string   `json:"url"`
+}
 type Notice struct {
 	Dismissible bool   `json:"dismissible,omitempty"`
 	Location    string `json:"location"`
@@ -380,6 +436,17 @@ type SMTPServerConfig struct {
 	Port           int    `json:"port"`
 	Username       string `json:"username,omitempty"`
 }
+
+// SVNConnection description: Configuration for a connection to Subversion repositories. gitserver mirrors them as Git repositories with git-svn.
+type SVNConnection struct {
+	Authors               map[string]string `json:"authors,omitempty"`
+	Layout                string            `json:"layout,omitempty"`
+	Password              string            `json:"password,omitempty"`
+	Repos                 []string          `json:"repos"`
+	RepositoryPathPattern string            `json:"repositoryPathPattern,omitempty"`
+	Url                   string            `json:"url"`
+	Username              string            `json:"username,omitempty"`
+}
 type SearchSavedQueries struct {
 	Description    string `json:"description"`
 	Key            string `json:"key"`
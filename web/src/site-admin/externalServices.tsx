@@ -9,12 +9,16 @@ import GitLabIcon from 'mdi-react/GitlabIcon'
 import React from 'react'
 import { Link } from 'react-router-dom'
 import awsCodeCommitSchemaJSON from '../../../schema/aws_codecommit.schema.json'
+import bitbucketCloudSchemaJSON from '../../../schema/bitbucket_cloud.schema.json'
 import bitbucketServerSchemaJSON from '../../../schema/bitbucket_server.schema.json'
+import giteaSchemaJSON from '../../../schema/gitea.schema.json'
 import githubSchemaJSON from '../../../schema/github.schema.json'
 import gitlabSchemaJSON from '../../../schema/gitlab.schema.json'
 import gitoliteSchemaJSON from '../../../schema/gitolite.schema.json'
+import mercurialSchemaJSON from '../../../schema/mercurial.schema.json'
 import otherExternalServiceSchemaJSON from '../../../schema/other_external_service.schema.json'
 import phabricatorSchemaJSON from '../../../schema/phabricator.schema.json'
+import svnSchemaJSON from '../../../schema/svn.schema.json'
 import { PhabricatorIcon } from '../../../shared/src/components/icons'
 import * as GQL from '../../../shared/src/graphql/schema'
 import { EditorAction } from './configHelpers.js'
@@ -250,6 +254,64 @@ export const ALL_EXTERNAL_SERVICES: Record<GQL.ExternalServiceKind, ExternalServ
             },
         ],
     },
+    [GQL.ExternalServiceKind.BITBUCKETCLOUD]: {
+        title: 'Bitbucket Cloud repositories',
+        icon: <BitbucketIcon size={ICON_SIZE} />,
+        iconBrandColor: 'bitbucket',
+        shortDescription: 'Add Bitbucket Cloud repositories.',
+        jsonSchema: bitbucketCloudSchemaJSON,
+        defaultDisplayName: 'Bitbucket Cloud',
+        defaultConfig: `{
+  // Use Ctrl+Space for completion, and hover over JSON properties for documentation.
+  // Configuration options are documented here:
+  // https://docs.sourcegraph.com/admin/external_service/bitbucket_cloud#configuration
+
+  "url": "https://bitbucket.org",
+
+  // The username of the user that owns the app password defined below.
+  "username": "",
+
+  // Create an app password with Account: Read, Team membership: Read and
+  // Repositories: Read permissions at
+  // https://bitbucket.org/account/settings/app-passwords/
+  "appPassword": "",
+
+  // The teams whose repositories to mirror on Sourcegraph. If empty, all
+  // repositories that the user is a member of are mirrored.
+  "teams": [
+    // "myteam"
+  ]
+}`,
+        editorActions: [
+            {
+                id: 'setAppPassword',
+                label: 'Set app password',
+                run: config => {
+                    const value = '<Bitbucket Cloud app password>'
+                    const edits = setProperty(config, ['appPassword'], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+            {
+                id: 'addTeam',
+                label: 'Add a team',
+                run: config => {
+                    const value = '<team name>'
+                    const edits = setProperty(config, ['teams', -1], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+            {
+                id: 'excludeRepo',
+                label: 'Exclude a repository',
+                run: config => {
+                    const value = { name: '<team>/<repository>' }
+                    const edits = setProperty(config, ['exclude', -1], value, defaultFormattingOptions)
+                    return { edits, selectText: '{"name": "<team>/<repository>"}' }
+                },
+            },
+        ],
+    },
     [GQL.ExternalServiceKind.BITBUCKETSERVER]: {
         title: 'Bitbucket Server repositories',
         icon: <BitbucketIcon size={ICON_SIZE} />,
@@ -341,6 +403,78 @@ export const ALL_EXTERNAL_SERVICES: Record<GQL.ExternalServiceKind, ExternalServ
             },
         ],
     },
+    [GQL.ExternalServiceKind.GITEA]: {
+        title: 'Gitea repositories',
+        icon: <GitIcon size={ICON_SIZE} />,
+        iconBrandColor: 'git',
+        shortDescription: 'Add Gitea or Gogs repositories.',
+        jsonSchema: giteaSchemaJSON,
+        defaultDisplayName: 'Gitea',
+        defaultConfig: `{
+  // Use Ctrl+Space for completion, and hover over JSON properties for documentation.
+  // Configuration options are documented here:
+  // https://docs.sourcegraph.com/admin/external_service/gitea#configuration
+
+  "url": "https://gitea.example.com",
+
+  // Create an access token at
+  // https://[your-gitea-hostname]/user/settings/applications
+  "token": "",
+
+  // The Gitea API endpoints listing the repositories to mirror, such as
+  // "orgs/myorg/repos" or "?q=foo" (to search all repositories).
+  "repositoryQuery": [
+    "none"
+  ]
+}`,
+        editorActions: [
+            {
+                id: 'setAccessToken',
+                label: 'Set access token',
+                run: config => {
+                    const value = '<Gitea access token>'
+                    const edits = setProperty(config, ['token'], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+            {
+                id: 'addOrgRepos',
+                label: 'Add repositories in an organization',
+                run: config => {
+                    const value = 'orgs/<organization name>/repos'
+                    const edits = setProperty(config, ['repositoryQuery', -1], value, defaultFormattingOptions)
+                    return { edits, selectText: '<organization name>' }
+                },
+            },
+            {
+                id: 'addUserRepos',
+                label: 'Add repositories owned by a user',
+                run: config => {
+                    const value = 'users/<user name>/repos'
+                    const edits = setProperty(config, ['repositoryQuery', -1], value, defaultFormattingOptions)
+                    return { edits, selectText: '<user name>' }
+                },
+            },
+            {
+                id: 'addRepo',
+                label: 'Add a single repository',
+                run: config => {
+                    const value = '<owner>/<repository>'
+                    const edits = setProperty(config, ['repos', -1], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+            {
+                id: 'excludeRepo',
+                label: 'Exclude a repository',
+                run: config => {
+                    const value = { name: '<owner>/<repository>' }
+                    const edits = setProperty(config, ['exclude', -1], value, defaultFormattingOptions)
+                    return { edits, selectText: '{"name": "<owner>/<repository>"}' }
+                },
+            },
+        ],
+    },
     [GQL.ExternalServiceKind.GITLAB]: {
         title: 'GitLab projects',
         icon: <GitLabIcon size={ICON_SIZE} />,
@@ -541,6 +675,45 @@ export const ALL_EXTERNAL_SERVICES: Record<GQL.ExternalServiceKind, ExternalServ
             },
         ],
     },
+    [GQL.ExternalServiceKind.MERCURIAL]: {
+        title: 'Mercurial repositories',
+        icon: <GitIcon size={ICON_SIZE} />,
+        iconBrandColor: 'git',
+        shortDescription: 'Mirror Mercurial repositories as Git repositories with hg-fast-export.',
+        jsonSchema: mercurialSchemaJSON,
+        defaultDisplayName: 'Mercurial',
+        defaultConfig: `{
+  // Use Ctrl+Space for completion, and hover over JSON properties for documentation.
+  // Configuration options are documented here:
+  // https://docs.sourcegraph.com/admin/external_service/mercurial#configuration
+
+  // Supported URL schemes are: http, https, ssh and file
+  "url": "https://hg.example.com/repos",
+
+  // Repository paths relative to the url.
+  "repos": []
+}`,
+        editorActions: [
+            {
+                id: 'setURL',
+                label: 'Set Mercurial server URL',
+                run: config => {
+                    const value = 'https://hg.example.com/repos'
+                    const edits = setProperty(config, ['url'], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+            {
+                id: 'addRepo',
+                label: 'Add a repository',
+                run: config => {
+                    const value = 'path/to/repository'
+                    const edits = setProperty(config, ['repos', -1], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+        ],
+    },
     [GQL.ExternalServiceKind.PHABRICATOR]: {
         title: 'Phabricator connection',
         icon: <PhabricatorIcon size={ICON_SIZE} />,
@@ -591,6 +764,54 @@ export const ALL_EXTERNAL_SERVICES: Record<GQL.ExternalServiceKind, ExternalServ
             },
         ],
     },
+    [GQL.ExternalServiceKind.SVN]: {
+        title: 'Subversion repositories',
+        icon: <GitIcon size={ICON_SIZE} />,
+        iconBrandColor: 'git',
+        shortDescription: 'Mirror Subversion repositories as Git repositories with git-svn.',
+        jsonSchema: svnSchemaJSON,
+        defaultDisplayName: 'Subversion',
+        defaultConfig: `{
+  // Use Ctrl+Space for completion, and hover over JSON properties for documentation.
+  // Configuration options are documented here:
+  // https://docs.sourcegraph.com/admin/external_service/svn#configuration
+
+  // Supported URL schemes are: http, https, svn, svn+ssh and file
+  "url": "https://svn.example.com/repos",
+
+  // Repository paths relative to the url.
+  "repos": []
+}`,
+        editorActions: [
+            {
+                id: 'setURL',
+                label: 'Set Subversion server URL',
+                run: config => {
+                    const value = 'https://svn.example.com/repos'
+                    const edits = setProperty(config, ['url'], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+            {
+                id: 'addRepo',
+                label: 'Add a repository',
+                run: config => {
+                    const value = 'path/to/repository'
+                    const edits = setProperty(config, ['repos', -1], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+            {
+                id: 'mapAuthor',
+                label: 'Map a Subversion user to a Git author',
+                run: config => {
+                    const value = 'Name <email>'
+                    const edits = setProperty(config, ['authors', '<username>'], value, defaultFormattingOptions)
+                    return { edits, selectText: value }
+                },
+            },
+        ],
+    },
     [GQL.ExternalServiceKind.OTHER]: {
         title: 'Single Git repositories',
         icon: <GitIcon size={ICON_SIZE} />,